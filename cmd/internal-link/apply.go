@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"internal-link/pkg/analyzer"
+)
+
+var (
+	applyFrom       string
+	applyFromFormat string
+	applyDryRun     bool
+)
+
+var applyCmd = &cobra.Command{
+	Use:   "apply --from suggestions.json [directory]",
+	Short: "Apply suggestions exported by an earlier run",
+	Long: `apply inserts exactly the links listed in a file written with
+--dry-run --output json, for example after it was reviewed and filtered.
+
+Each suggestion is checked against the current files first. A phrase that
+moved slightly is found again near its recorded position; suggestions whose
+phrase or target is gone are reported and skipped. Use the same parsing flags
+(--min-ngram, --max-ngram, --tokenizer, ...) as the run that exported them.
+Link formatting settings such as url-template and link-template, and
+extensions and excludes, are taken from the config files and the flags of the
+root command.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format := applyFromFormat
+		if format == "" {
+			format = analyzer.SuggestionsFormatJSON
+			if strings.EqualFold(filepath.Ext(applyFrom), ".csv") {
+				format = analyzer.SuggestionsFormatCSV
+			}
+		}
+
+		f, err := os.Open(applyFrom)
+		if err != nil {
+			return fmt.Errorf("failed to open suggestions: %w", err)
+		}
+		defer f.Close()
+
+		suggestions, err := analyzer.ReadSuggestions(f, format)
+		if err != nil {
+			return err
+		}
+
+		if applyDryRun {
+			viper.Set("dry-run", true)
+		}
+		overrides, err := loadDirectoryConfig(cmd, args[0])
+		if err != nil {
+			return err
+		}
+		// The cache directory holds the journal used by undo
+		config, err := newConfig(args[0], overrides, os.Stderr)
+		if err != nil {
+			return err
+		}
+		a, err := analyzer.NewAnalyzer(config)
+		if err != nil {
+			return fmt.Errorf("failed to create analyzer: %w", err)
+		}
+
+		resolved, err := a.ResolveSuggestions(suggestions)
+		if err != nil {
+			return fmt.Errorf("failed to check suggestions: %w", err)
+		}
+		if config.DryRun {
+			fmt.Printf("%d of %d suggested links still match their files\n", len(resolved), len(suggestions))
+			return nil
+		}
+		if err := a.ApplyChanges(resolved); err != nil {
+			return fmt.Errorf("failed to apply changes: %w", err)
+		}
+
+		stats := a.Stats()
+		fmt.Printf("Inserted %d of %d suggested link(s) into %d file(s)\n", stats.Inserted, len(suggestions), stats.Modified)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(applyCmd)
+
+	applyCmd.Flags().StringVar(&applyFrom, "from", "", "file of suggestions to apply")
+	applyCmd.Flags().StringVar(&applyFromFormat, "from-format", "", "format of --from (json, csv; default from its extension)")
+	applyCmd.Flags().BoolVar(&applyDryRun, "dry-run", false, "check the suggestions against the files without changing them")
+	applyCmd.MarkFlagRequired("from")
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"internal-link/pkg/analyzer"
+	"internal-link/pkg/cache"
+)
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Inspect and clean up the analysis cache",
+	Long: `cache manages the analysis results kept in the cache directory
+(--cache-dir, default ~/.cache/internal-link). Each directory analyzed gets
+its own namespace there, named after a hash of its absolute path, with all
+of its entries in a single index file.`,
+}
+
+var cacheClearCmd = &cobra.Command{
+	Use:   "clear [directory]",
+	Short: "Delete cached results, of one directory or of all",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		c, err := openCache(args)
+		if err != nil {
+			return err
+		}
+		if err := c.Clear(); err != nil {
+			return err
+		}
+		fmt.Println("Cache cleared")
+		return nil
+	},
+}
+
+var cachePruneCmd = &cobra.Command{
+	Use:   "prune directory",
+	Short: "Delete cached results of files no longer in a directory",
+	Long: `prune deletes the cached results of files that were deleted from the
+directory or are now left out of it. Every analysis run does the same.
+Extensions, excludes and gitignore handling are taken from the config files.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		overrides, err := loadDirectoryConfig(cmd, args[0])
+		if err != nil {
+			return err
+		}
+		scoring, err := newScoringOptions(args[0], overrides)
+		if err != nil {
+			return err
+		}
+
+		a, err := analyzer.NewAnalyzer(analyzer.Config{ScoringOptions: scoring, Log: os.Stderr})
+		if err != nil {
+			return fmt.Errorf("failed to create analyzer: %w", err)
+		}
+
+		pruned, err := a.PruneCache()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Pruned %d cache entries\n", pruned)
+		return nil
+	},
+}
+
+var cacheStatsCmd = &cobra.Command{
+	Use:   "stats [directory]",
+	Short: "Show the size of the cache, of one directory or of all",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		c, err := openCache(args)
+		if err != nil {
+			return err
+		}
+		stats, err := c.Stats()
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Entries: %d\n", stats.Entries)
+		fmt.Printf("Total size: %d bytes\n", stats.Size)
+		if stats.Entries > 0 {
+			fmt.Printf("Oldest entry: %s\n", stats.Oldest.Format(time.RFC3339))
+		}
+		if len(stats.Corpus) > 0 {
+			fmt.Println("Corpus entries:")
+			for _, blob := range stats.Corpus {
+				fmt.Printf("  %s: %d entry(ies), %d bytes, updated %s\n", blob.Name, blob.Entries, blob.Size, blob.Updated.Format(time.RFC3339))
+			}
+		}
+		return nil
+	},
+}
+
+// openCache opens the cache of the directory in args, or the whole cache
+// directory without one
+func openCache(args []string) (*cache.Cache, error) {
+	cacheDir, err := resolveCacheDir()
+	if err != nil {
+		return nil, err
+	}
+
+	var c *cache.Cache
+	if len(args) == 1 {
+		c, err = cache.NewProjectCache(cacheDir, args[0])
+	} else {
+		c, err = cache.NewCache(cacheDir)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open cache: %w", err)
+	}
+	return c, nil
+}
+
+func init() {
+	rootCmd.AddCommand(cacheCmd)
+	cacheCmd.AddCommand(cacheClearCmd, cachePruneCmd, cacheStatsCmd)
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/viper"
+
+	"internal-link/pkg/analyzer"
+	"internal-link/pkg/markdown"
+	"internal-link/pkg/scorer"
+)
+
+// The analyzer configuration is read from viper rather than from the flags,
+// so every setting can also come from a config file. Flags given on the
+// command line take precedence over config files, which take precedence over
+// the flag defaults.
+
+// newConfig builds the configuration of an analysis run of targetDir
+func newConfig(targetDir string, overrides []analyzer.DirectoryOverrides, log io.Writer) (analyzer.Config, error) {
+	scoring, err := newScoringOptions(targetDir, overrides)
+	if err != nil {
+		return analyzer.Config{}, err
+	}
+	selection, err := newSelectionOptions()
+	if err != nil {
+		return analyzer.Config{}, err
+	}
+	return analyzer.Config{
+		ScoringOptions:   scoring,
+		SelectionOptions: selection,
+		ApplyOptions: analyzer.ApplyOptions{
+			DryRun:         viper.GetBool("dry-run"),
+			Backup:         viper.GetBool("backup"),
+			BestEffort:     viper.GetBool("best-effort"),
+			SectionLinkDir: viper.GetBool("section-link-dir"),
+			HistoryFile:    viper.GetString("history-file"),
+			LinkStyle:      viper.GetString("link-style"),
+			URLTemplate:    viper.GetString("url-template"),
+			AssumeDir:      viper.GetString("assume-dir"),
+			LinkTemplate:   viper.GetString("link-template"),
+		},
+		Log: log,
+	}, nil
+}
+
+// corpusRoots returns the directory an analysis of the given directories is
+// relative to, and the roots to load the corpus from when there are several:
+// their closest common ancestor, and the directories themselves. The
+// directories are made absolute when they have no relative ancestor.
+func corpusRoots(dirs []string) (string, []string) {
+	if len(dirs) == 1 {
+		return dirs[0], nil
+	}
+	roots := make([]string, len(dirs))
+	for i, dir := range dirs {
+		roots[i] = filepath.Clean(dir)
+	}
+	if ancestor, ok := commonDir(roots); ok {
+		return ancestor, roots
+	}
+	for i, root := range roots {
+		if abs, err := filepath.Abs(root); err == nil {
+			roots[i] = abs
+		}
+	}
+	ancestor, _ := commonDir(roots)
+	return ancestor, roots
+}
+
+// commonDir returns the deepest directory containing all of the clean paths
+func commonDir(paths []string) (string, bool) {
+	dir := paths[0]
+	for _, path := range paths[1:] {
+		for !contains(dir, path) {
+			parent := filepath.Dir(dir)
+			if parent == dir {
+				return "", false
+			}
+			dir = parent
+		}
+	}
+	return dir, true
+}
+
+// contains reports whether path is dir or lies beneath it, comparing them
+// as they are spelled
+func contains(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// newScoringOptions builds the options deciding which documents beneath
+// targetDir make up the corpus and how they are scored
+func newScoringOptions(targetDir string, overrides []analyzer.DirectoryOverrides) (analyzer.ScoringOptions, error) {
+	cacheDir, err := resolveCacheDir()
+	if err != nil {
+		return analyzer.ScoringOptions{}, err
+	}
+	// Reporting duplicates needs them detected
+	duplicateSimilarity := viper.GetFloat64("duplicate-similarity")
+	if duplicateSimilarity == 0 && viper.GetBool("report-duplicates") {
+		duplicateSimilarity = analyzer.DefaultDuplicateSimilarity
+	}
+	return analyzer.ScoringOptions{
+		TargetDir:            targetDir,
+		CacheDir:             cacheDir,
+		NoCache:              viper.GetBool("no-cache"),
+		ApproximateIDF:       viper.GetBool("approximate-idf"),
+		SectionPages:         viper.GetString("section-pages"),
+		ParserConfig:         newParserConfig(),
+		ExcludeGlobs:         viper.GetStringSlice("exclude"),
+		Overrides:            overrides,
+		NoGitignore:          viper.GetBool("no-gitignore"),
+		Strict:               viper.GetBool("strict"),
+		FollowSymlinks:       viper.GetBool("follow-symlinks"),
+		Extensions:           viper.GetStringSlice("extensions"),
+		FrontmatterIgnoreKey: viper.GetString("frontmatter-ignore-key"),
+		MaxFileSize:          viper.GetInt64("max-file-size"),
+		Concurrency:          viper.GetInt("concurrency"),
+		DuplicateSimilarity:  duplicateSimilarity,
+		ScorerConfig: &scorer.ScorerConfig{
+			K1:         viper.GetFloat64("bm25-k1"),
+			B:          viper.GetFloat64("bm25-b"),
+			TitleBoost: viper.GetFloat64("title-boost"),
+		},
+	}, nil
+}
+
+// newSelectionOptions builds the options deciding which suggestions are made
+func newSelectionOptions() (analyzer.SelectionOptions, error) {
+	blacklist, err := loadPhrases("phrase-blacklist", viper.GetString("blacklist-file"))
+	if err != nil {
+		return analyzer.SelectionOptions{}, err
+	}
+	whitelist, err := loadPhrases("phrase-whitelist", viper.GetString("whitelist-file"))
+	if err != nil {
+		return analyzer.SelectionOptions{}, err
+	}
+	return analyzer.SelectionOptions{
+		MinScore:     viper.GetFloat64("min-score"),
+		SingleFile:   viper.GetString("file"),
+		RepeatPolicy: viper.GetString("repeat-links"),
+		ChangedSince: viper.GetString("changed-since"),
+
+		SourceInclude: viper.GetStringSlice("sources"),
+		TargetInclude: viper.GetStringSlice("targets"),
+
+		MinScorePercentile: viper.GetFloat64("min-score-percentile"),
+		Top:                viper.GetInt("top"),
+
+		ScoreNormalization: viper.GetString("score-normalization"),
+		MinRawScore:        viper.GetFloat64("min-raw-score"),
+
+		AllowDuplicateTargets: viper.GetBool("allow-duplicate-targets"),
+		AllowRepeatAfter:      viper.GetInt("allow-repeat-after"),
+		ShowSuppressed:        viper.GetBool("show-suppressed"),
+		PreferBacklinks:       viper.GetBool("prefer-backlinks"),
+		TagBoost:              viper.GetFloat64("tag-boost"),
+		SameCategoryOnly:      viper.GetBool("same-category-only"),
+		SectionAnchors:        viper.GetBool("section-anchors"),
+		Explain:               viper.GetBool("explain"),
+		MaxLinksPerFile:       viper.GetInt("max-links-per-file"),
+		CandidatesPerTarget:   viper.GetInt("candidates-per-target"),
+		CandidatePool:         viper.GetInt("candidate-pool"),
+		AnchorWeights: &analyzer.AnchorWeights{
+			Frequency: viper.GetFloat64("anchor-frequency-weight"),
+			IDF:       viper.GetFloat64("anchor-idf-weight"),
+			Length:    viper.GetFloat64("anchor-length-weight"),
+		},
+		IntroLength:          viper.GetInt("intro-length"),
+		MinLinkDistance:      viper.GetInt("min-link-distance"),
+		MaxLinksPerParagraph: viper.GetInt("max-links-per-paragraph"),
+		PhraseBlacklist:      blacklist,
+		PhraseWhitelist:      whitelist,
+	}, nil
+}
+
+// newParserConfig builds the parser configuration from the settings shared
+// by all commands
+func newParserConfig() markdown.ParserConfig {
+	return markdown.ParserConfig{
+		MinNGram:       viper.GetInt("min-ngram"),
+		MaxNGram:       viper.GetInt("max-ngram"),
+		Flavor:         viper.GetString("flavor"),
+		TokenizerName:  viper.GetString("tokenizer"),
+		DebugPositions: viper.GetBool("debug-positions"),
+
+		MaxOccurrencesPerDoc: viper.GetInt("max-occurrences-per-doc"),
+		MaxTermsPerDoc:       viper.GetInt("max-terms-per-doc"),
+		BudgetOverflow:       viper.GetString("budget-overflow"),
+
+		LinkFormat:      viper.GetString("link-format"),
+		LinkInHeadings:  viper.GetBool("link-in-headings"),
+		SkipBlockquotes: viper.GetBool("skip-blockquotes"),
+		LinkedPattern:   viper.GetString("linked-pattern"),
+
+		Language:        viper.GetString("language"),
+		StopWordsFile:   viper.GetString("stop-words-file"),
+		ExtendStopWords: viper.GetBool("extend-stop-words"),
+		Stemming:        viper.GetBool("stemming"),
+
+		MinWordLength:      viper.GetInt("min-word-length"),
+		ContextSize:        viper.GetInt("context-size"),
+		AllowCodeishTokens: viper.GetBool("allow-codeish-tokens"),
+		KeepNumericTokens:  viper.GetBool("keep-numeric-tokens"),
+	}
+}
+
+// loadPhrases returns the phrases listed under key in the config file
+// followed by those of file, if set
+func loadPhrases(key, file string) ([]string, error) {
+	phrases := viper.GetStringSlice(key)
+	if file == "" {
+		return phrases, nil
+	}
+	fromFile, err := analyzer.LoadPhrases(file)
+	if err != nil {
+		return nil, err
+	}
+	return append(phrases, fromFile...), nil
+}
+
+// resolveCacheDir returns the cache directory, defaulting to
+// ~/.cache/internal-link
+func resolveCacheDir() (string, error) {
+	if dir := viper.GetString("cache-dir"); dir != "" {
+		return dir, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(home, ".cache", "internal-link"), nil
+}
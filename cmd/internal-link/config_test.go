@@ -0,0 +1,45 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"internal-link/pkg/markdown"
+)
+
+func TestConfigPrecedence(t *testing.T) {
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "config.yaml")
+	require.NoError(t, os.WriteFile(configFile, []byte("min-ngram: 1\nmax-ngram: 4\nmin-score: 0.2\nlink-format: wikilink\nexclude: [\"drafts/**\"]\n"), 0644))
+
+	targetDir := filepath.Join(dir, "content")
+	require.NoError(t, os.MkdirAll(filepath.Join(targetDir, "posts"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(targetDir, dirConfigFile), []byte("max-ngram: 2\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(targetDir, "posts", dirConfigFile), []byte("min-score: 0.9\nlink-format: reference\n"), 0644))
+
+	require.NoError(t, rootCmd.ParseFlags([]string{"--config", configFile, "--min-score", "0.6", "--cache-dir", t.TempDir()}))
+	initConfig()
+	overrides, err := loadDirectoryConfig(rootCmd, targetDir)
+	require.NoError(t, err)
+	config, err := newConfig(targetDir, overrides, io.Discard)
+	require.NoError(t, err)
+
+	// Set in the config file only
+	assert.Equal(t, 1, config.ParserConfig.MinNGram)
+	assert.Equal(t, markdown.LinkFormatWikilink, config.ParserConfig.LinkFormat)
+	assert.Equal(t, []string{"drafts/**"}, config.ExcludeGlobs)
+	// The analyzed directory's config file wins over the one given with --config
+	assert.Equal(t, 2, config.ParserConfig.MaxNGram)
+	// Flags win over every config file, including those of subdirectories
+	assert.Equal(t, 0.6, config.MinScore)
+	require.Len(t, config.Overrides, 1)
+	assert.Nil(t, config.Overrides[0].MinScore)
+	assert.Equal(t, markdown.LinkFormatReference, config.Overrides[0].LinkFormat)
+	// Neither set: the flag default
+	assert.Equal(t, markdown.DefaultContextSize, config.ParserConfig.ContextSize)
+}
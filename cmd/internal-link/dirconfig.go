@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+
+	"internal-link/pkg/analyzer"
+)
+
+// dirConfigFile is the name of the config files kept in content directories
+const dirConfigFile = ".internal-link.yaml"
+
+// Settings a config file below the analyzed directory may override for the
+// documents beneath it; the rest, such as n-gram lengths, apply to the
+// corpus as a whole
+var subdirectoryKeys = map[string]bool{"min-score": true, "exclude": true, "link-format": true}
+
+// loadDirectoryConfig merges the config file of targetDir over the one in
+// the home or working directory, below command-line flags, and returns the
+// overrides set by the config files of its subdirectories. When the corpus
+// is loaded from roots beneath targetDir, only their config files are read
+// besides that of targetDir itself.
+func loadDirectoryConfig(cmd *cobra.Command, targetDir string, roots ...string) ([]analyzer.DirectoryOverrides, error) {
+	if len(roots) == 0 {
+		roots = []string{targetDir}
+	} else if err := loadRootConfig(cmd, targetDir, targetDir, nil); err != nil {
+		return nil, err
+	}
+	var overrides []analyzer.DirectoryOverrides
+	for _, root := range roots {
+		if err := loadRootConfig(cmd, targetDir, root, &overrides); err != nil {
+			return nil, err
+		}
+	}
+	return overrides, nil
+}
+
+// loadRootConfig reads the config files beneath root for
+// loadDirectoryConfig; without overrides to add to, only that of root itself
+func loadRootConfig(cmd *cobra.Command, targetDir, root string, overrides *[]analyzer.DirectoryOverrides) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != root && (overrides == nil || strings.HasPrefix(d.Name(), ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Name() != dirConfigFile {
+			return nil
+		}
+
+		v := viper.New()
+		v.SetConfigFile(path)
+		if err := v.ReadInConfig(); err != nil {
+			return fmt.Errorf("failed to read config file %s: %w", path, err)
+		}
+		fmt.Fprintln(os.Stderr, "Using directory config file:", path)
+
+		dir := filepath.Dir(path)
+		if dir == filepath.Clean(targetDir) {
+			return viper.MergeConfigMap(v.AllSettings())
+		}
+		rel, err := filepath.Rel(targetDir, dir)
+		if err != nil {
+			return err
+		}
+		*overrides = append(*overrides, directoryOverrides(cmd, v, filepath.ToSlash(rel)))
+		return nil
+	})
+}
+
+// directoryOverrides reads the settings of a subdirectory's config file,
+// leaving out those given as flags
+func directoryOverrides(cmd *cobra.Command, v *viper.Viper, dir string) analyzer.DirectoryOverrides {
+	o := analyzer.DirectoryOverrides{Dir: dir}
+	for _, key := range v.AllKeys() {
+		if !subdirectoryKeys[key] {
+			fmt.Fprintf(os.Stderr, "Warning: ignoring %s in the config file of %s; only min-score, exclude and link-format can be set below the analyzed directory\n", key, dir)
+		}
+	}
+
+	if v.IsSet("min-score") && !cmd.Flags().Changed("min-score") {
+		score := v.GetFloat64("min-score")
+		o.MinScore = &score
+	}
+	if v.IsSet("link-format") && !cmd.Flags().Changed("link-format") {
+		o.LinkFormat = v.GetString("link-format")
+	}
+	// Exclude patterns add up rather than replace each other
+	o.ExcludeGlobs = v.GetStringSlice("exclude")
+	return o
+}
+
+// printEffectiveConfig writes the merged configuration as YAML, with the
+// overrides of subdirectories under "directories"
+func printEffectiveConfig(w io.Writer, cmd *cobra.Command, overrides []analyzer.DirectoryOverrides) error {
+	settings := viper.AllSettings()
+	// viper hands out the values of float flags as they are spelled
+	for key := range settings {
+		if f := cmd.Flags().Lookup(key); f != nil && f.Value.Type() == "float64" {
+			settings[key] = viper.GetFloat64(key)
+		}
+	}
+	if len(overrides) > 0 {
+		dirs := make(map[string]map[string]interface{}, len(overrides))
+		for _, o := range overrides {
+			s := make(map[string]interface{})
+			if o.MinScore != nil {
+				s["min-score"] = *o.MinScore
+			}
+			if len(o.ExcludeGlobs) > 0 {
+				s["exclude"] = o.ExcludeGlobs
+			}
+			if o.LinkFormat != "" {
+				s["link-format"] = o.LinkFormat
+			}
+			dirs[o.Dir] = s
+		}
+		settings["directories"] = dirs
+	}
+
+	// yaml.v3 sorts map keys, so the output is stable
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(settings); err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+	return enc.Close()
+}
@@ -1,69 +1,108 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"runtime"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
 	"internal-link/pkg/analyzer"
 	"internal-link/pkg/markdown"
+	"internal-link/pkg/output"
+	"internal-link/pkg/report"
+	"internal-link/pkg/scorer"
 )
 
-var (
-	cfgFile    string
-	dryRun     bool
-	minScore   float64
-	singleFile string
-	cacheDir   string
-	minNGram   int
-	maxNGram   int
-)
+// cfgFile is the config file given with --config
+var cfgFile string
+
+// checkFailed is set when --check finds links worth inserting
+var checkFailed bool
+
+// Exit codes; 1 is reserved for errors
+const exitCheckFailed = 2
 
 func main() {
-	if err := rootCmd.Execute(); err != nil {
+	err := rootCmd.Execute()
+	if stopErr := stopProfiling(); stopErr != nil && err == nil {
+		err = stopErr
+	}
+	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
+	if checkFailed {
+		os.Exit(exitCheckFailed)
+	}
 }
 
 var rootCmd = &cobra.Command{
-	Use:   "internal-link [directory]",
+	Use:   "internal-link directory...",
 	Short: "A tool for suggesting internal links in markdown files",
 	Long: `internal-link analyzes markdown files in a directory and suggests
 potential internal links based on content similarity using the BM25 algorithm.
-It can analyze all files in a directory or focus on a single file.
+It can analyze all files in a directory or focus on a single file. Several
+directories are analyzed as one corpus, so their files link to each other.
 
 The tool supports n-gram analysis, allowing you to find matches based on phrases
 rather than just single words. Use --min-ngram to set the minimum n-gram length.`,
-	Args: cobra.ExactArgs(1),
+	Args:              cobra.MinimumNArgs(1),
+	PersistentPreRunE: startProfiling,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		targetDir := args[0]
+		targetDir, roots := corpusRoots(args)
 
-		// Set default cache directory if not specified
-		if cacheDir == "" {
-			home, err := os.UserHomeDir()
-			if err != nil {
-				return fmt.Errorf("failed to get home directory: %w", err)
-			}
-			cacheDir = filepath.Join(home, ".cache", "internal-link")
+		overrides, err := loadDirectoryConfig(cmd, targetDir, roots...)
+		if err != nil {
+			return err
+		}
+		// Printing the configuration is an action, not a setting of its own
+		if printConfig, _ := cmd.Flags().GetBool("print-config"); printConfig {
+			return printEffectiveConfig(os.Stdout, cmd, overrides)
 		}
 
-		// Create parser config
-		parserConfig := markdown.ParserConfig{
-			MinNGram: minNGram,
-			MaxNGram: maxNGram,
+		// Check mode only reports, and watch mode never rewrites files while they are being edited
+		check, watch := viper.GetBool("check"), viper.GetBool("watch")
+		if check || watch {
+			viper.Set("dry-run", true)
 		}
+		dryRun := viper.GetBool("dry-run")
 
-		config := analyzer.Config{
-			MinScore:     minScore,
-			DryRun:       dryRun,
-			SingleFile:   singleFile,
-			TargetDir:    targetDir,
-			CacheDir:     cacheDir,
-			ParserConfig: parserConfig,
+		outputFormat, format := viper.GetString("output"), viper.GetString("format")
+		if err := output.CheckFormat(outputFormat); err != nil {
+			return err
+		}
+		if (outputFormat == output.FormatHTML || outputFormat == output.FormatSARIF) && watch {
+			return fmt.Errorf("--watch does not support --output %s", outputFormat)
+		}
+		if viper.GetBool("show-suppressed") && (!dryRun || check) {
+			return fmt.Errorf("--show-suppressed needs --dry-run and does not support --check")
+		}
+		if format != "suggestions" && format != "edits" {
+			return fmt.Errorf("invalid format %q (expected suggestions or edits)", format)
+		}
+		depsOut, depsFormat := viper.GetString("deps-out"), viper.GetString("deps-format")
+		if depsFormat != analyzer.DepsFormatMake && depsFormat != analyzer.DepsFormatJSON {
+			return fmt.Errorf("invalid deps format %q (expected make or json)", depsFormat)
+		}
+
+		// Keep stdout machine-readable when emitting JSON or one line per suggestion
+		var info io.Writer = os.Stdout
+		if outputFormat != output.FormatText || format == "edits" || viper.GetBool("no-context") {
+			info = os.Stderr
+		}
+
+		config, err := newConfig(targetDir, overrides, info)
+		if err != nil {
+			return err
+		}
+		config.Roots = roots
+		if viper.GetBool("progress") {
+			config.OnProgress = (&progressBar{w: os.Stderr}).update
 		}
 
 		a, err := analyzer.NewAnalyzer(config)
@@ -75,47 +114,375 @@ rather than just single words. Use --min-ngram to set the minimum n-gram length.
 		if err != nil {
 			return fmt.Errorf("analysis failed: %w", err)
 		}
+		if viper.GetBool("report-duplicates") {
+			clusters, err := a.Duplicates()
+			if err != nil {
+				return fmt.Errorf("failed to detect duplicates: %w", err)
+			}
+			printDuplicates(info, clusters)
+		}
+
+		// Existing links are audited as they were before any link is inserted
+		var retargets []scorer.RetargetSuggestion
+		audit := viper.GetBool("audit-existing")
+		if audit {
+			if retargets, err = a.AuditExisting(config.SelectionOptions); err != nil {
+				return fmt.Errorf("audit failed: %w", err)
+			}
+		}
 
-		// Print suggestions
-		for _, s := range suggestions {
-			fmt.Printf("File: %s\n", s.SourcePath)
-			fmt.Printf("  Suggested link to: %s\n", s.TargetPath)
-			fmt.Printf("  Score: %.4f\n", s.Score)
-			if dryRun {
-				fmt.Printf("  Context: %s\n", s.Context)
-				fmt.Printf("  Phrase to link: %s\n", s.WordToLink)
+		var out io.Writer = os.Stdout
+		if outputFile := viper.GetString("output-file"); outputFile != "" {
+			f, err := os.Create(outputFile)
+			if err != nil {
+				return fmt.Errorf("failed to create output file: %w", err)
+			}
+			defer f.Close()
+			out = f
+		}
+		if err := printSuggestions(out, a, targetDir, roots, suggestions); err != nil {
+			return err
+		}
+		if audit {
+			if err := printRetargets(out, info, targetDir, roots, retargets); err != nil {
+				return err
 			}
-			fmt.Println()
 		}
 
 		if !dryRun {
 			if err := a.ApplyChanges(suggestions); err != nil {
 				return fmt.Errorf("failed to apply changes: %w", err)
 			}
-			fmt.Println("Successfully applied all suggested links")
+			stats := a.Stats()
+			fmt.Fprintf(info, "Inserted %d link(s) into %d file(s)\n", stats.Inserted, stats.Modified)
+		}
+
+		if audit && viper.GetBool("apply-retargets") && !dryRun {
+			if err := a.ApplyRetargets(retargets); err != nil {
+				return fmt.Errorf("failed to apply retargets: %w", err)
+			}
+			fmt.Fprintln(info, "Successfully retargeted all flagged links")
+		}
+
+		if depsOut != "" {
+			if err := writeDeps(a, suggestions, depsOut, depsFormat); err != nil {
+				return err
+			}
+			fmt.Fprintln(info, "Wrote link dependencies to", depsOut)
+		}
+
+		if check {
+			fmt.Fprintln(info, checkSummary(suggestions, a.Stats().MinScore))
+			checkFailed = len(suggestions) > 0
+		}
+
+		fmt.Fprintln(info, runSummary(a.Stats()))
+
+		if watch {
+			return watchDocuments(out, a, targetDir, roots, config.SelectionOptions, info)
 		}
 
 		return nil
 	},
 }
 
+// checkSummary describes the outcome of --check in one line
+func checkSummary(suggestions []scorer.LinkSuggestion, minScore float64) string {
+	if len(suggestions) == 0 {
+		return fmt.Sprintf("Check passed: no missing links scoring %.2f or more", minScore)
+	}
+	files := make(map[string]bool)
+	for _, s := range suggestions {
+		files[s.SourcePath] = true
+	}
+	return fmt.Sprintf("Check failed: %d missing link(s) scoring %.2f or more in %d file(s)", len(suggestions), minScore, len(files))
+}
+
+// printSuggestions writes suggestions to w in the selected format, or the
+// edits they make with --format edits
+func printSuggestions(w io.Writer, a *analyzer.Analyzer, targetDir string, roots []string, suggestions []scorer.LinkSuggestion) error {
+	if viper.GetString("format") == "edits" {
+		edits, err := a.ComputeEdits(suggestions)
+		if err != nil {
+			return fmt.Errorf("failed to compute edits: %w", err)
+		}
+		if err := printJSON(w, edits); err != nil {
+			return fmt.Errorf("failed to write edits: %w", err)
+		}
+		return nil
+	}
+	// Dry runs show the lines the links change; other output only on request
+	textPreview := viper.GetBool("dry-run") && viper.GetString("output") == output.FormatText && !viper.GetBool("no-context")
+	if textPreview || viper.GetBool("preview-lines") {
+		if err := a.PreviewLines(suggestions); err != nil {
+			return fmt.Errorf("failed to preview links: %w", err)
+		}
+	}
+	writer, err := newOutputWriter(w, targetDir, roots, a.Stats().MinScore)
+	if err != nil {
+		return err
+	}
+	return writer.Write(suggestions)
+}
+
+// newOutputWriter returns the writer of the --output format; HTML reports
+// show paths relative to targetDir, and so does the text output of a corpus
+// made of several roots. The threshold the suggestions met ends the text
+// output's summary.
+func newOutputWriter(w io.Writer, targetDir string, roots []string, threshold float64) (output.Writer, error) {
+	opts := output.Options{
+		Details:          viper.GetBool("dry-run"),
+		Compact:          viper.GetBool("no-context"),
+		HighlightMarkers: viper.GetString("highlight-markers"),
+		Threshold:        threshold,
+		Report:           report.Options{Root: targetDir},
+	}
+	if len(roots) > 0 {
+		opts.Base = targetDir
+	}
+	if outputFile := viper.GetString("output-file"); outputFile != "" {
+		opts.Report.OutputDir = filepath.Dir(outputFile)
+	}
+	return output.New(w, viper.GetString("output"), opts)
+}
+
+// printRetargets writes the links --audit-existing proposes to retarget
+// after the suggestions, in the --output format when it lists them and as
+// text to info otherwise
+func printRetargets(out, info io.Writer, targetDir string, roots []string, retargets []scorer.RetargetSuggestion) error {
+	writer, err := newOutputWriter(out, targetDir, roots, 0)
+	if err != nil {
+		return err
+	}
+	rw, ok := writer.(output.RetargetWriter)
+	if !ok || viper.GetString("format") == "edits" {
+		rw = output.NewTextWriter(info, output.Options{})
+	}
+	return rw.WriteRetargets(retargets)
+}
+
+// printDuplicates writes the clusters of near-duplicate documents, canonical member first
+func printDuplicates(w io.Writer, clusters []analyzer.DuplicateCluster) {
+	fmt.Fprintf(w, "Near-duplicate clusters: %d\n", len(clusters))
+	for _, c := range clusters {
+		fmt.Fprintf(w, "  %s\n", c.Canonical)
+		for _, path := range c.Duplicates {
+			fmt.Fprintf(w, "    duplicate: %s\n", path)
+		}
+	}
+}
+
+// printJSON writes v as indented JSON
+func printJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// writeDeps writes the link dependencies of every document to path
+func writeDeps(a *analyzer.Analyzer, suggestions []scorer.LinkSuggestion, path, format string) error {
+	deps, err := a.LinkDependencies(suggestions)
+	if err != nil {
+		return fmt.Errorf("failed to collect link dependencies: %w", err)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create deps file: %w", err)
+	}
+	defer f.Close()
+
+	if err := analyzer.WriteDeps(f, deps, format); err != nil {
+		return fmt.Errorf("failed to write deps file: %w", err)
+	}
+	return f.Close()
+}
+
 func init() {
 	cobra.OnInitialize(initConfig)
 
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.internal-link.yaml)")
-	rootCmd.Flags().BoolVar(&dryRun, "dry-run", false, "show suggestions without making changes")
-	rootCmd.Flags().Float64Var(&minScore, "min-score", 0.3, "minimum similarity score threshold")
-	rootCmd.Flags().StringVar(&singleFile, "file", "", "analyze a single file against all others")
-	rootCmd.Flags().StringVar(&cacheDir, "cache-dir", "", "directory for caching analysis results")
-	rootCmd.Flags().IntVar(&minNGram, "min-ngram", 2, "minimum number of words in phrases to match (e.g., 2 for bigrams)")
-	rootCmd.Flags().IntVar(&maxNGram, "max-ngram", 3, "maximum number of words in phrases to match (e.g., 3 for trigrams)")
+	rootCmd.PersistentFlags().String("cpuprofile", "", "write a pprof CPU profile of the run to this file")
+	rootCmd.PersistentFlags().String("memprofile", "", "write a pprof heap profile at the end of the run to this file")
+	rootCmd.Flags().Bool("dry-run", false, "show suggestions without making changes")
+	rootCmd.Flags().Bool("check", false, "fail with exit code 2 if any suggestion meets --min-score (implies --dry-run)")
+	rootCmd.Flags().Bool("progress", false, "show a progress bar on stderr while loading and analyzing documents")
+	rootCmd.Flags().Bool("watch", false, "keep running and print fresh suggestions for each file when it is saved (implies --dry-run)")
+	rootCmd.Flags().Float64("min-score", 0.3, "minimum similarity score threshold")
+	rootCmd.Flags().Float64("min-score-percentile", 0, "instead of --min-score, keep the pairs scoring in the top N percent of all scored pairs")
+	rootCmd.Flags().Int("top", 0, "keep only the N best scoring suggestions overall (0 = unlimited)")
+	rootCmd.Flags().String("score-normalization", analyzer.ScoreNormalizationNone, "map each document's pair scores into [0,1] before --min-score applies (minmax, softmax, none)")
+	rootCmd.Flags().Float64("min-raw-score", analyzer.DefaultMinRawScore, "raw score a pair needs whatever its normalized score, with --score-normalization")
+	rootCmd.Flags().String("file", "", "analyze a single file against all others; it may lie outside the directory, e.g. a draft")
+	rootCmd.Flags().String("changed-since", "", "only suggest links from files git reports as added or changed since this ref, e.g. main; all files remain targets")
+	rootCmd.Flags().String("assume-dir", "", "directory, relative to the analyzed one, a --file outside it will live in; its relative links are written from there")
+	rootCmd.PersistentFlags().String("cache-dir", "", "directory for caching analysis results")
+	rootCmd.PersistentFlags().Bool("no-cache", false, "neither read nor write cached analysis results, e.g. for reproducible CI runs")
+	rootCmd.PersistentFlags().Bool("approximate-idf", false, "reuse the cached scores of unchanged document pairs after other documents changed, ignoring the small shift in term statistics")
+	rootCmd.PersistentFlags().Int("min-ngram", 2, "minimum number of words in phrases to match (e.g., 2 for bigrams)")
+	rootCmd.PersistentFlags().Int("max-ngram", 3, "maximum number of words in phrases to match (e.g., 3 for trigrams)")
+	rootCmd.PersistentFlags().String("flavor", markdown.FlavorCommonMark, "markdown flavor of the documents (commonmark, gfm)")
+	rootCmd.PersistentFlags().String("tokenizer", markdown.DefaultTokenizerName, "name of the registered tokenizer used to split text into words")
+	rootCmd.Flags().Bool("debug-positions", false, "show the markdown AST ancestry and raw bytes of each suggestion")
+	rootCmd.Flags().Bool("explain", false, "break each suggestion's score down by matched term (IDF, frequencies and boosts)")
+	rootCmd.Flags().Int("max-occurrences-per-doc", 2000000, "stop analyzing a document after this many word/phrase occurrences (0 = unlimited)")
+	rootCmd.Flags().Int("max-terms-per-doc", 500000, "stop analyzing a document after this many distinct terms (0 = unlimited)")
+	rootCmd.Flags().String("budget-overflow", markdown.BudgetTruncate, "what to do with documents over budget (truncate, unigrams)")
+	rootCmd.Flags().StringSlice("extensions", []string{".md"}, "comma-separated file extensions to analyze (e.g. .md,.markdown,.mdx)")
+	rootCmd.Flags().String("frontmatter-ignore-key", analyzer.DefaultFrontmatterIgnoreKey, "frontmatter key opting a page out of linking with key: false or key_ignore: true")
+	rootCmd.Flags().StringArray("exclude", nil, "leave out files and directories matching this glob relative to the directory (repeatable, e.g. \"**/archive/**\")")
+	rootCmd.Flags().StringArray("sources", nil, "only find links for documents matching this glob relative to the directory (repeatable, e.g. \"blog/**\")")
+	rootCmd.Flags().StringArray("targets", nil, "only link to documents matching this glob relative to the directory (repeatable, e.g. \"docs/**\")")
+	rootCmd.Flags().Bool("no-gitignore", false, "don't leave out files matched by .gitignore (.internal-linkignore still applies)")
+	rootCmd.Flags().Bool("strict", false, "fail on the first file that can't be read or parsed instead of skipping it with a warning")
+	rootCmd.Flags().Bool("follow-symlinks", false, "descend into symlinked directories")
+	rootCmd.Flags().Int64("max-file-size", analyzer.DefaultMaxFileSize, "size in bytes above which files are skipped without being read (0 = unlimited)")
+	rootCmd.Flags().Bool("print-config", false, "print the effective configuration, merged from flags and config files, and exit")
+	rootCmd.Flags().Int("concurrency", runtime.NumCPU(), "number of documents loaded and analyzed in parallel")
+	rootCmd.Flags().Float64("bm25-k1", scorer.DefaultScorerConfig().K1, "BM25 term frequency saturation (k1 >= 0)")
+	rootCmd.Flags().Float64("bm25-b", scorer.DefaultScorerConfig().B, "BM25 document length normalization (0 <= b <= 1)")
+	rootCmd.Flags().Float64("title-boost", scorer.DefaultScorerConfig().TitleBoost, "score multiplier for phrases in a target's title, keywords or tags (1 for none)")
+	rootCmd.Flags().String("section-pages", analyzer.SectionPagesNormal, "how section index pages are treated as targets (boost, normal, exclude)")
+	rootCmd.PersistentFlags().Bool("backup", false, "keep a .bak copy of every modified file")
+	rootCmd.PersistentFlags().Bool("best-effort", false, "apply the suggestions that still match their files and skip the others, instead of changing nothing when any fails")
+	rootCmd.PersistentFlags().Bool("section-link-dir", false, "link to a section's directory instead of its _index.md file")
+	rootCmd.PersistentFlags().String("link-format", markdown.LinkFormatMarkdown, "syntax of inserted links (markdown, wikilink, reference)")
+	rootCmd.PersistentFlags().String("linked-pattern", "", "regular expression matching links in custom markup, which are never linked again; named groups phrase and target give their text and destination")
+	rootCmd.PersistentFlags().Bool("link-in-headings", false, "allow links to be inserted inside headings")
+	rootCmd.PersistentFlags().Bool("skip-blockquotes", false, "never insert links inside blockquotes")
+	rootCmd.PersistentFlags().String("language", markdown.LanguageEnglish, "language of the bundled stop-word list (en, de, fr, es)")
+	rootCmd.PersistentFlags().String("stop-words-file", "", "file of stop words, one per line, replacing the bundled list")
+	rootCmd.PersistentFlags().Bool("extend-stop-words", false, "add the words of --stop-words-file to the bundled list instead of replacing it")
+	rootCmd.PersistentFlags().Bool("stemming", false, "match words by their English stem, so deployment also matches deployments")
+	rootCmd.PersistentFlags().Int("min-word-length", markdown.DefaultMinWordLength, "ignore words shorter than this many characters (e.g., 2 to match Go or AI)")
+	rootCmd.PersistentFlags().Bool("allow-codeish-tokens", false, "count and link words that look like code outside code spans, e.g. myFunctionName, snake_case, src/pkg/foo or v1beta1")
+	rootCmd.PersistentFlags().Bool("keep-numeric-tokens", false, "count and link numbers, dates, versions and sizes, e.g. 2024, 2024-01-15, v2.3.1 or 500ms")
+	rootCmd.PersistentFlags().Int("context-size", markdown.DefaultContextSize, "characters of context shown on each side of a suggested phrase")
+	rootCmd.Flags().Bool("no-context", false, "print text output as one line per suggestion: source -> target score phrase")
+	rootCmd.Flags().String("highlight-markers", "**", "markers around the phrase in the context of text output, the same on both sides or \"open,close\"; empty for none")
+	rootCmd.PersistentFlags().String("link-style", analyzer.LinkStyleRelative, "how link destinations are written (relative, absolute-from-root)")
+	rootCmd.Flags().String("url-template", "", "link to published URLs built from this template, e.g. \"/blog/{slug}/\" ({slug}, {dir})")
+	rootCmd.Flags().String("link-template", "", "Go template writing inserted links instead of the link format ({{.Phrase}}, {{.Target}}, {{.TargetSlug}}, {{.TargetTitle}}, {{.Anchor}})")
+	rootCmd.PersistentFlags().String("history-file", "", "file remembering applied links (default is <directory>/"+analyzer.DefaultHistoryFile+")")
+	rootCmd.Flags().String("repeat-links", analyzer.RepeatOncePerPair, "how often a link may be re-applied across runs (once-per-pair, once-per-phrase, unlimited)")
+	rootCmd.Flags().Float64("duplicate-similarity", 0, "only suggest the canonical page of near duplicates whose terms overlap at least this much (Jaccard, 0 to 1; 0 = off)")
+	rootCmd.Flags().Bool("report-duplicates", false, fmt.Sprintf("list the clusters of near-duplicate pages (--duplicate-similarity defaults to %g)", analyzer.DefaultDuplicateSimilarity))
+	rootCmd.Flags().Bool("allow-duplicate-targets", false, "suggest links to documents the source file already links to")
+	rootCmd.Flags().Int("allow-repeat-after", 0, "suggest linking a document the source file already links to again more than this many bytes after its existing links (0 = never)")
+	rootCmd.Flags().Bool("show-suppressed", false, "also list the suggestions left out because the source file already links their target, marked as suppressed (needs --dry-run)")
+	rootCmd.Flags().Bool("prefer-backlinks", false, "boost links back to documents that already link to the source file")
+	rootCmd.Flags().Float64("tag-boost", 1, "score multiplier for pairs whose frontmatter shares a tag (1 for none)")
+	rootCmd.Flags().Bool("same-category-only", false, "only suggest links between documents sharing a frontmatter category")
+	rootCmd.Flags().Bool("section-anchors", false, "link to the best matching heading of the target, e.g. doc.md#setup")
+	rootCmd.Flags().Int("max-links-per-file", 0, "keep only the best scoring suggestions of each file (0 = unlimited)")
+	rootCmd.Flags().Int("candidate-pool", analyzer.DefaultCandidatePool, "targets of each source with the best upper bound on their score to score in full, for very large corpora (0 = all)")
+	rootCmd.Flags().Int("candidates-per-target", 1, "distinct phrases to suggest for each target, for a reviewer to pick from; only the best one is applied")
+	anchorWeights := analyzer.DefaultAnchorWeights()
+	rootCmd.Flags().Float64("anchor-frequency-weight", anchorWeights.Frequency, "weight of how often the target uses a phrase when picking the phrase to link")
+	rootCmd.Flags().Float64("anchor-idf-weight", anchorWeights.IDF, "weight of how rare a phrase is across the corpus when picking the phrase to link")
+	rootCmd.Flags().Float64("anchor-length-weight", anchorWeights.Length, "weight of the number of words of a phrase when picking the phrase to link")
+	rootCmd.Flags().Int("min-link-distance", analyzer.DefaultMinLinkDistance, "least number of bytes between an inserted link and any other link (0 = no minimum)")
+	rootCmd.Flags().Int("max-links-per-paragraph", analyzer.DefaultMaxLinksPerParagraph, "most links a paragraph may have, counting existing ones (0 = unlimited)")
+	rootCmd.Flags().String("blacklist-file", "", "file of phrases, one per line, never used as link text")
+	rootCmd.Flags().String("whitelist-file", "", "file of phrases, one per line, that are the only ones used as link text")
+	rootCmd.Flags().Int("intro-length", 0, "avoid linking within the first N bytes of each page when the phrase also occurs later")
+	rootCmd.Flags().Bool("audit-existing", false, "report existing links whose anchor text better matches another document")
+	rootCmd.Flags().Bool("apply-retargets", false, "rewrite links reported by --audit-existing to their proposed target")
+	rootCmd.Flags().String("output", "text", "output format for suggestions (text, json, csv, html, sarif)")
+	rootCmd.Flags().Bool("preview-lines", false, "add each suggestion's source line before and after inserting its link to the output, as line_before and line_after in JSON (always shown by text dry runs)")
+	rootCmd.Flags().String("output-file", "", "write suggestions to this file instead of stdout")
+	rootCmd.Flags().String("format", "suggestions", "what to print (suggestions, or edits as JSON edit operations)")
+	rootCmd.Flags().String("deps-out", "", "write each file's link targets to this dependency file")
+	rootCmd.Flags().String("deps-format", analyzer.DepsFormatMake, "format of the dependency file (make, json)")
 
 	viper.BindPFlag("dry-run", rootCmd.Flags().Lookup("dry-run"))
+	viper.BindPFlag("check", rootCmd.Flags().Lookup("check"))
+	viper.BindPFlag("watch", rootCmd.Flags().Lookup("watch"))
+	viper.BindPFlag("progress", rootCmd.Flags().Lookup("progress"))
 	viper.BindPFlag("min-score", rootCmd.Flags().Lookup("min-score"))
+	viper.BindPFlag("min-score-percentile", rootCmd.Flags().Lookup("min-score-percentile"))
+	viper.BindPFlag("top", rootCmd.Flags().Lookup("top"))
+	viper.BindPFlag("score-normalization", rootCmd.Flags().Lookup("score-normalization"))
+	viper.BindPFlag("min-raw-score", rootCmd.Flags().Lookup("min-raw-score"))
 	viper.BindPFlag("file", rootCmd.Flags().Lookup("file"))
-	viper.BindPFlag("cache-dir", rootCmd.Flags().Lookup("cache-dir"))
-	viper.BindPFlag("min-ngram", rootCmd.Flags().Lookup("min-ngram"))
-	viper.BindPFlag("max-ngram", rootCmd.Flags().Lookup("max-ngram"))
+	viper.BindPFlag("assume-dir", rootCmd.Flags().Lookup("assume-dir"))
+	viper.BindPFlag("changed-since", rootCmd.Flags().Lookup("changed-since"))
+	viper.BindPFlag("cache-dir", rootCmd.PersistentFlags().Lookup("cache-dir"))
+	viper.BindPFlag("no-cache", rootCmd.PersistentFlags().Lookup("no-cache"))
+	viper.BindPFlag("approximate-idf", rootCmd.PersistentFlags().Lookup("approximate-idf"))
+	viper.BindPFlag("min-ngram", rootCmd.PersistentFlags().Lookup("min-ngram"))
+	viper.BindPFlag("max-ngram", rootCmd.PersistentFlags().Lookup("max-ngram"))
+	viper.BindPFlag("flavor", rootCmd.PersistentFlags().Lookup("flavor"))
+	viper.BindPFlag("tokenizer", rootCmd.PersistentFlags().Lookup("tokenizer"))
+	viper.BindPFlag("debug-positions", rootCmd.Flags().Lookup("debug-positions"))
+	viper.BindPFlag("explain", rootCmd.Flags().Lookup("explain"))
+	viper.BindPFlag("max-occurrences-per-doc", rootCmd.Flags().Lookup("max-occurrences-per-doc"))
+	viper.BindPFlag("max-terms-per-doc", rootCmd.Flags().Lookup("max-terms-per-doc"))
+	viper.BindPFlag("budget-overflow", rootCmd.Flags().Lookup("budget-overflow"))
+	viper.BindPFlag("extensions", rootCmd.Flags().Lookup("extensions"))
+	viper.BindPFlag("frontmatter-ignore-key", rootCmd.Flags().Lookup("frontmatter-ignore-key"))
+	viper.BindPFlag("exclude", rootCmd.Flags().Lookup("exclude"))
+	viper.BindPFlag("sources", rootCmd.Flags().Lookup("sources"))
+	viper.BindPFlag("targets", rootCmd.Flags().Lookup("targets"))
+	viper.BindPFlag("no-gitignore", rootCmd.Flags().Lookup("no-gitignore"))
+	viper.BindPFlag("strict", rootCmd.Flags().Lookup("strict"))
+	viper.BindPFlag("follow-symlinks", rootCmd.Flags().Lookup("follow-symlinks"))
+	viper.BindPFlag("max-file-size", rootCmd.Flags().Lookup("max-file-size"))
+	viper.BindPFlag("concurrency", rootCmd.Flags().Lookup("concurrency"))
+	viper.BindPFlag("bm25-k1", rootCmd.Flags().Lookup("bm25-k1"))
+	viper.BindPFlag("bm25-b", rootCmd.Flags().Lookup("bm25-b"))
+	viper.BindPFlag("title-boost", rootCmd.Flags().Lookup("title-boost"))
+	viper.BindPFlag("section-pages", rootCmd.Flags().Lookup("section-pages"))
+	viper.BindPFlag("backup", rootCmd.PersistentFlags().Lookup("backup"))
+	viper.BindPFlag("best-effort", rootCmd.PersistentFlags().Lookup("best-effort"))
+	viper.BindPFlag("section-link-dir", rootCmd.PersistentFlags().Lookup("section-link-dir"))
+	viper.BindPFlag("link-format", rootCmd.PersistentFlags().Lookup("link-format"))
+	viper.BindPFlag("linked-pattern", rootCmd.PersistentFlags().Lookup("linked-pattern"))
+	viper.BindPFlag("link-in-headings", rootCmd.PersistentFlags().Lookup("link-in-headings"))
+	viper.BindPFlag("skip-blockquotes", rootCmd.PersistentFlags().Lookup("skip-blockquotes"))
+	viper.BindPFlag("language", rootCmd.PersistentFlags().Lookup("language"))
+	viper.BindPFlag("stop-words-file", rootCmd.PersistentFlags().Lookup("stop-words-file"))
+	viper.BindPFlag("extend-stop-words", rootCmd.PersistentFlags().Lookup("extend-stop-words"))
+	viper.BindPFlag("stemming", rootCmd.PersistentFlags().Lookup("stemming"))
+	viper.BindPFlag("min-word-length", rootCmd.PersistentFlags().Lookup("min-word-length"))
+	viper.BindPFlag("allow-codeish-tokens", rootCmd.PersistentFlags().Lookup("allow-codeish-tokens"))
+	viper.BindPFlag("keep-numeric-tokens", rootCmd.PersistentFlags().Lookup("keep-numeric-tokens"))
+	viper.BindPFlag("context-size", rootCmd.PersistentFlags().Lookup("context-size"))
+	viper.BindPFlag("link-style", rootCmd.PersistentFlags().Lookup("link-style"))
+	viper.BindPFlag("url-template", rootCmd.Flags().Lookup("url-template"))
+	viper.BindPFlag("link-template", rootCmd.Flags().Lookup("link-template"))
+	viper.BindPFlag("history-file", rootCmd.PersistentFlags().Lookup("history-file"))
+	viper.BindPFlag("repeat-links", rootCmd.Flags().Lookup("repeat-links"))
+	viper.BindPFlag("duplicate-similarity", rootCmd.Flags().Lookup("duplicate-similarity"))
+	viper.BindPFlag("report-duplicates", rootCmd.Flags().Lookup("report-duplicates"))
+	viper.BindPFlag("allow-duplicate-targets", rootCmd.Flags().Lookup("allow-duplicate-targets"))
+	viper.BindPFlag("allow-repeat-after", rootCmd.Flags().Lookup("allow-repeat-after"))
+	viper.BindPFlag("show-suppressed", rootCmd.Flags().Lookup("show-suppressed"))
+	viper.BindPFlag("prefer-backlinks", rootCmd.Flags().Lookup("prefer-backlinks"))
+	viper.BindPFlag("tag-boost", rootCmd.Flags().Lookup("tag-boost"))
+	viper.BindPFlag("same-category-only", rootCmd.Flags().Lookup("same-category-only"))
+	viper.BindPFlag("section-anchors", rootCmd.Flags().Lookup("section-anchors"))
+	viper.BindPFlag("max-links-per-file", rootCmd.Flags().Lookup("max-links-per-file"))
+	viper.BindPFlag("candidate-pool", rootCmd.Flags().Lookup("candidate-pool"))
+	viper.BindPFlag("candidates-per-target", rootCmd.Flags().Lookup("candidates-per-target"))
+	viper.BindPFlag("anchor-frequency-weight", rootCmd.Flags().Lookup("anchor-frequency-weight"))
+	viper.BindPFlag("anchor-idf-weight", rootCmd.Flags().Lookup("anchor-idf-weight"))
+	viper.BindPFlag("anchor-length-weight", rootCmd.Flags().Lookup("anchor-length-weight"))
+	viper.BindPFlag("no-context", rootCmd.Flags().Lookup("no-context"))
+	viper.BindPFlag("highlight-markers", rootCmd.Flags().Lookup("highlight-markers"))
+	viper.BindPFlag("intro-length", rootCmd.Flags().Lookup("intro-length"))
+	viper.BindPFlag("min-link-distance", rootCmd.Flags().Lookup("min-link-distance"))
+	viper.BindPFlag("max-links-per-paragraph", rootCmd.Flags().Lookup("max-links-per-paragraph"))
+	viper.BindPFlag("blacklist-file", rootCmd.Flags().Lookup("blacklist-file"))
+	viper.BindPFlag("whitelist-file", rootCmd.Flags().Lookup("whitelist-file"))
+	viper.BindPFlag("audit-existing", rootCmd.Flags().Lookup("audit-existing"))
+	viper.BindPFlag("apply-retargets", rootCmd.Flags().Lookup("apply-retargets"))
+	viper.BindPFlag("output", rootCmd.Flags().Lookup("output"))
+	viper.BindPFlag("preview-lines", rootCmd.Flags().Lookup("preview-lines"))
+	viper.BindPFlag("output-file", rootCmd.Flags().Lookup("output-file"))
+	viper.BindPFlag("format", rootCmd.Flags().Lookup("format"))
+	viper.BindPFlag("deps-out", rootCmd.Flags().Lookup("deps-out"))
+	viper.BindPFlag("deps-format", rootCmd.Flags().Lookup("deps-format"))
 }
 
 func initConfig() {
@@ -128,6 +495,9 @@ func initConfig() {
 			os.Exit(1)
 		}
 
+		// A config file in the working directory, e.g. the repository being
+		// checked, takes precedence over the one in the home directory
+		viper.AddConfigPath(".")
 		viper.AddConfigPath(home)
 		viper.SetConfigName(".internal-link")
 	}
@@ -135,6 +505,6 @@ func initConfig() {
 	viper.AutomaticEnv()
 
 	if err := viper.ReadInConfig(); err == nil {
-		fmt.Println("Using config file:", viper.ConfigFileUsed())
+		fmt.Fprintln(os.Stderr, "Using config file:", viper.ConfigFileUsed())
 	}
 }
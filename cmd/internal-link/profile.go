@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/pprof"
+
+	"github.com/spf13/cobra"
+)
+
+// stopProfiling finishes the profiles started for the running command
+var stopProfiling = func() error { return nil }
+
+// startProfiling starts the CPU profile requested with --cpuprofile and
+// arranges for stopProfiling to write it, and the heap profile requested
+// with --memprofile, once the command is done. The profile files are
+// per-run settings, so they are read from the flags rather than from viper.
+func startProfiling(cmd *cobra.Command, _ []string) error {
+	cpuProfile, _ := cmd.Flags().GetString("cpuprofile")
+	memProfile, _ := cmd.Flags().GetString("memprofile")
+
+	var cpu *os.File
+	if cpuProfile != "" {
+		f, err := os.Create(cpuProfile)
+		if err != nil {
+			return fmt.Errorf("failed to create CPU profile: %w", err)
+		}
+		if err := pprof.StartCPUProfile(f); err != nil {
+			f.Close()
+			return fmt.Errorf("failed to start CPU profile: %w", err)
+		}
+		cpu = f
+	}
+
+	stopProfiling = func() error {
+		if cpu != nil {
+			pprof.StopCPUProfile()
+			if err := cpu.Close(); err != nil {
+				return fmt.Errorf("failed to write CPU profile: %w", err)
+			}
+		}
+		if memProfile != "" {
+			return writeHeapProfile(memProfile)
+		}
+		return nil
+	}
+	return nil
+}
+
+// writeHeapProfile writes a profile of the memory in use at the end of a run
+func writeHeapProfile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create memory profile: %w", err)
+	}
+	// Collect garbage first, so the profile shows what is still reachable
+	runtime.GC()
+	if err := pprof.WriteHeapProfile(f); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write memory profile: %w", err)
+	}
+	return f.Close()
+}
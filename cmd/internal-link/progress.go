@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"internal-link/pkg/analyzer"
+)
+
+// progressWidth is the number of characters of the bar itself
+const progressWidth = 30
+
+// progressLabels names the phases of a run on the progress bar
+var progressLabels = map[string]string{
+	analyzer.PhaseLoad:    "Loading",
+	analyzer.PhaseAnalyze: "Analyzing",
+}
+
+// progressBar draws the progress of each phase on a single line of w,
+// redrawing only when the percentage changes
+type progressBar struct {
+	w       io.Writer
+	phase   string
+	percent int
+}
+
+// update implements analyzer.Config.OnProgress
+func (p *progressBar) update(phase string, done, total int) {
+	if total == 0 {
+		return
+	}
+	percent := done * 100 / total
+	if phase == p.phase && percent == p.percent {
+		return
+	}
+	p.phase, p.percent = phase, percent
+
+	filled := progressWidth * done / total
+	fmt.Fprintf(p.w, "\r%-9s [%s%s] %3d%% (%d/%d)", progressLabels[phase],
+		strings.Repeat("#", filled), strings.Repeat(" ", progressWidth-filled), percent, done, total)
+	if done == total {
+		fmt.Fprintln(p.w)
+	}
+}
+
+// runSummary describes the documents processed, how many of them were
+// sources and targets, and the time each phase took
+func runSummary(stats analyzer.Stats) string {
+	skipped := ""
+	if stats.Skipped > 0 {
+		skipped = fmt.Sprintf(", %d skipped", stats.Skipped)
+	}
+	return fmt.Sprintf("Summary: %d documents (%d parsed, %d from cache%s) loaded in %s; %d suggestions found for %d sources and %d targets in %s",
+		stats.Documents, stats.Parsed, stats.CacheHits, skipped, stats.LoadTime.Round(time.Millisecond),
+		stats.Suggestions, stats.Sources, stats.Targets, stats.AnalyzeTime.Round(time.Millisecond))
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"internal-link/pkg/analyzer"
+)
+
+var (
+	statsOutput          string
+	statsWithSuggestions bool
+)
+
+// graphReport is the JSON output of stats
+type graphReport struct {
+	Current     analyzer.LinkGraph  `json:"current"`
+	Projected   *analyzer.LinkGraph `json:"projected,omitempty"`
+	Suggestions int                 `json:"suggestions,omitempty"`
+
+	// The pair scores that missed the threshold of the suggestions
+	MinScore       float64                  `json:"min_score,omitempty"`
+	BelowThreshold *analyzer.ScoreHistogram `json:"below_threshold,omitempty"`
+}
+
+// histogramBuckets is the number of buckets of the below-threshold histogram
+const histogramBuckets = 10
+
+var statsCmd = &cobra.Command{
+	Use:   "stats directory",
+	Short: "Summarize the links between the documents of a directory",
+	Long: `stats builds the graph of the links the documents of a directory
+already have to each other and prints each document's inbound and outbound
+link counts, the orphans no other document links to, the hubs most linked to
+and the overall density, the share of document pairs that are linked.
+
+With --with-suggestions it also projects the graph after applying the links
+an analysis run would suggest, and shows how the document pairs that missed
+min-score scored, to help choose a threshold. Selection settings such as min-score, and
+extensions, excludes and gitignore handling, are taken from the config files
+and the flags of the root command.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if statsOutput != "text" && statsOutput != "json" {
+			return fmt.Errorf("invalid output format %q (expected text or json)", statsOutput)
+		}
+		overrides, err := loadDirectoryConfig(cmd, args[0])
+		if err != nil {
+			return err
+		}
+		targetDir := args[0]
+		scoring, err := newScoringOptions(targetDir, overrides)
+		if err != nil {
+			return err
+		}
+		selection, err := newSelectionOptions()
+		if err != nil {
+			return err
+		}
+
+		a, err := analyzer.NewAnalyzer(analyzer.Config{
+			ScoringOptions:   scoring,
+			SelectionOptions: selection,
+			Log:              os.Stderr,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create analyzer: %w", err)
+		}
+
+		var report graphReport
+		if report.Current, err = a.LinkGraph(nil); err != nil {
+			return fmt.Errorf("failed to build link graph: %w", err)
+		}
+		if statsWithSuggestions {
+			suggestions, err := a.Analyze()
+			if err != nil {
+				return fmt.Errorf("analysis failed: %w", err)
+			}
+			projected, err := a.LinkGraph(suggestions)
+			if err != nil {
+				return fmt.Errorf("failed to build link graph: %w", err)
+			}
+			report.Projected = &projected
+			report.Suggestions = len(suggestions)
+			report.MinScore = a.Stats().MinScore
+			if histogram := a.BelowThreshold(histogramBuckets); len(histogram.Buckets) > 0 {
+				report.BelowThreshold = &histogram
+			}
+		}
+
+		if statsOutput == "json" {
+			return printJSON(os.Stdout, report)
+		}
+		printGraph(os.Stdout, targetDir, report)
+		return nil
+	},
+}
+
+// printGraph writes the link graph statistics in the human-readable format
+func printGraph(w io.Writer, targetDir string, report graphReport) {
+	rel := func(path string) string {
+		if r, err := filepath.Rel(targetDir, path); err == nil {
+			return filepath.ToSlash(r)
+		}
+		return path
+	}
+	current, projected := report.Current, report.Projected
+
+	fmt.Fprintf(w, "Documents: %d\n", len(current.Documents))
+	fmt.Fprintf(w, "Links: %d (density %.4f)\n", current.Links, current.Density)
+	if projected != nil {
+		fmt.Fprintf(w, "After applying %d suggestions: %d links (density %.4f), %d orphans\n",
+			report.Suggestions, projected.Links, projected.Density, len(projected.Orphans))
+	}
+
+	fmt.Fprintf(w, "\nOrphans (%d):\n", len(current.Orphans))
+	for _, path := range current.Orphans {
+		fmt.Fprintf(w, "  %s\n", rel(path))
+	}
+	if len(current.Hubs) > 0 {
+		fmt.Fprintf(w, "\nHubs (%d):\n", len(current.Hubs))
+		inbound := make(map[string]int, len(current.Documents))
+		for _, doc := range current.Documents {
+			inbound[doc.Path] = doc.Inbound
+		}
+		for _, path := range current.Hubs {
+			fmt.Fprintf(w, "  %s (%d inbound)\n", rel(path), inbound[path])
+		}
+	}
+
+	if h := report.BelowThreshold; h != nil {
+		fmt.Fprintf(w, "\nScores below --min-score %.4f:\n", report.MinScore)
+		for _, b := range h.Buckets {
+			fmt.Fprintf(w, "  %.4f-%.4f  %d\n", b.Min, b.Max, b.Count)
+		}
+		if h.Omitted > 0 {
+			fmt.Fprintf(w, "  %d lower score(s) not counted\n", h.Omitted)
+		}
+	}
+
+	fmt.Fprintln(w)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	if projected == nil {
+		fmt.Fprintln(tw, "Inbound\tOutbound\t  Document")
+		for _, doc := range current.Documents {
+			fmt.Fprintf(tw, "%d\t%d\t  %s\n", doc.Inbound, doc.Outbound, rel(doc.Path))
+		}
+	} else {
+		// Both graphs hold the same documents, in the same order
+		fmt.Fprintln(tw, "Inbound\tOutbound\tAfter (in/out)\t  Document")
+		for i, doc := range current.Documents {
+			after := projected.Documents[i]
+			fmt.Fprintf(tw, "%d\t%d\t%d/%d\t  %s\n", doc.Inbound, doc.Outbound, after.Inbound, after.Outbound, rel(doc.Path))
+		}
+	}
+	tw.Flush()
+}
+
+func init() {
+	rootCmd.AddCommand(statsCmd)
+
+	statsCmd.Flags().StringVar(&statsOutput, "output", "text", "output format (text, json)")
+	statsCmd.Flags().BoolVar(&statsWithSuggestions, "with-suggestions", false, "also project the graph after applying the current suggestions")
+}
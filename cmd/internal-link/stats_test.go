@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"internal-link/pkg/analyzer"
+)
+
+func TestPrintGraphHubs(t *testing.T) {
+	graph := analyzer.LinkGraph{
+		Documents: []analyzer.DocumentLinks{{Path: "/docs/a.md", Outbound: 1}, {Path: "/docs/b.md", Inbound: 1}},
+		Orphans:   []string{"/docs/a.md"},
+		Hubs:      []string{"/docs/b.md"},
+		Links:     1,
+		Density:   0.5,
+	}
+	var buf bytes.Buffer
+	printGraph(&buf, "/docs", graphReport{Current: graph})
+	assert.Contains(t, buf.String(), "\nHubs (1):\n  b.md (1 inbound)\n")
+
+	// A graph without links has no hubs to list
+	graph.Hubs, graph.Links = nil, 0
+	buf.Reset()
+	printGraph(&buf, "/docs", graphReport{Current: graph})
+	assert.NotContains(t, buf.String(), "Hubs")
+	assert.Contains(t, buf.String(), "Orphans (1):")
+}
+
+func TestPrintGraphBelowThreshold(t *testing.T) {
+	graph := analyzer.LinkGraph{Documents: []analyzer.DocumentLinks{{Path: "/docs/a.md"}, {Path: "/docs/b.md"}}}
+	report := graphReport{
+		Current:   graph,
+		Projected: &graph,
+		MinScore:  0.3,
+		BelowThreshold: &analyzer.ScoreHistogram{
+			Buckets: []analyzer.ScoreBucket{{Min: 0, Max: 0.05, Count: 3}, {Min: 0.05, Max: 0.1, Count: 1}},
+			Omitted: 2,
+		},
+	}
+	var buf bytes.Buffer
+	printGraph(&buf, "/docs", report)
+	assert.Contains(t, buf.String(), "\nScores below --min-score 0.3000:\n  0.0000-0.0500  3\n  0.0500-0.1000  1\n  2 lower score(s) not counted\n")
+}
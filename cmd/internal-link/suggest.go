@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"internal-link/pkg/analyzer"
+	"internal-link/pkg/output"
+)
+
+// stdinPath names the document read from standard input
+const stdinPath = "<stdin>"
+
+var (
+	suggestCorpus    string
+	suggestApply     bool
+	suggestOutput    string
+	suggestAssumeDir string
+)
+
+var suggestCmd = &cobra.Command{
+	Use:   "suggest --corpus directory file|-",
+	Short: "Suggest links for a single document against a corpus",
+	Long: `suggest scores one document, typically a draft piped in by an editor
+with - as the file, against the documents of the corpus directory and prints
+the links it should get. The document is never offered as a link target and
+no file is written: with --apply the document is printed with the links
+inserted instead.
+
+A document read from standard input has no location of its own, so its
+relative links are written from --assume-dir, relative to the corpus
+(default the corpus directory itself). Selection settings such as min-score
+are taken from the config files, like extensions and excludes.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if suggestOutput != output.FormatText && suggestOutput != output.FormatJSON && suggestOutput != output.FormatCSV {
+			return fmt.Errorf("invalid output format %q (expected text, json or csv)", suggestOutput)
+		}
+
+		path := args[0]
+		var content []byte
+		var err error
+		if path == "-" {
+			path = stdinPath
+			content, err = io.ReadAll(os.Stdin)
+		} else {
+			content, err = os.ReadFile(path)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+
+		overrides, err := loadDirectoryConfig(cmd, suggestCorpus)
+		if err != nil {
+			return err
+		}
+		// Messages go to stderr, so stdout only holds the result
+		config, err := newConfig(suggestCorpus, overrides, os.Stderr)
+		if err != nil {
+			return err
+		}
+		config.SingleFile = ""
+		if suggestAssumeDir != "" {
+			config.AssumeDir = suggestAssumeDir
+		} else if path == stdinPath {
+			config.AssumeDir = "."
+		}
+
+		a, err := analyzer.NewAnalyzer(config)
+		if err != nil {
+			return fmt.Errorf("failed to create analyzer: %w", err)
+		}
+		suggestions, err := a.AnalyzeDraft(path, content)
+		if err != nil {
+			return fmt.Errorf("analysis failed: %w", err)
+		}
+
+		if suggestApply {
+			changed, err := a.ApplyChangesToContent(suggestions)
+			if err != nil {
+				return fmt.Errorf("failed to apply changes: %w", err)
+			}
+			if updated, ok := changed[path]; ok {
+				content = updated
+			}
+			_, err = os.Stdout.Write(content)
+			return err
+		}
+
+		writer, err := output.New(os.Stdout, suggestOutput, output.Options{Details: true, Threshold: a.Stats().MinScore})
+		if err != nil {
+			return err
+		}
+		return writer.Write(suggestions)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(suggestCmd)
+
+	suggestCmd.Flags().StringVar(&suggestCorpus, "corpus", "", "directory of the documents to link to")
+	suggestCmd.Flags().BoolVar(&suggestApply, "apply", false, "print the document with the suggested links inserted instead of the suggestions")
+	suggestCmd.Flags().StringVar(&suggestOutput, "output", "text", "output format of the suggestions (text, json, csv)")
+	suggestCmd.Flags().StringVar(&suggestAssumeDir, "assume-dir", "", "directory, relative to the corpus, the document will live in; its relative links are written from there")
+	suggestCmd.MarkFlagRequired("corpus")
+}
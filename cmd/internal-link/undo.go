@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"internal-link/pkg/analyzer"
+)
+
+var undoLast bool
+
+var undoCmd = &cobra.Command{
+	Use:   "undo [directory]",
+	Short: "Remove links previously inserted by the tool",
+	Long: `undo reverts the links inserted into a directory by earlier runs, as
+recorded in the journal kept in the cache directory. Each [phrase](target) is
+turned back into the plain phrase. Links that were edited or moved too far
+since are reported and left alone.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cacheDir, err := resolveCacheDir()
+		if err != nil {
+			return err
+		}
+
+		a, err := analyzer.NewAnalyzer(analyzer.Config{
+			ScoringOptions: analyzer.ScoringOptions{
+				TargetDir:    args[0],
+				CacheDir:     cacheDir,
+				ParserConfig: newParserConfig(),
+			},
+			ApplyOptions: analyzer.ApplyOptions{
+				Backup:      viper.GetBool("backup"),
+				HistoryFile: viper.GetString("history-file"),
+			},
+			Log: os.Stdout,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create analyzer: %w", err)
+		}
+
+		removed, err := a.Undo(undoLast)
+		if err != nil {
+			return fmt.Errorf("undo failed: %w", err)
+		}
+
+		fmt.Printf("Removed %d link(s)\n", removed)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(undoCmd)
+
+	undoCmd.Flags().BoolVar(&undoLast, "last", false, "only revert the most recent run")
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"internal-link/pkg/analyzer"
+)
+
+// watchDebounce is how long a file must stay quiet after a change before it
+// is analyzed again, so editors saving in several steps trigger one run
+const watchDebounce = 300 * time.Millisecond
+
+// watchDocuments keeps the corpus in sync with targetDir, or its roots, and
+// prints fresh suggestions for every document that is saved, until the
+// watcher fails
+func watchDocuments(out io.Writer, a *analyzer.Analyzer, targetDir string, roots []string, selection analyzer.SelectionOptions, info io.Writer) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to start watching: %w", err)
+	}
+	defer watcher.Close()
+
+	watched := roots
+	if len(watched) == 0 {
+		watched = []string{targetDir}
+	}
+	for _, root := range watched {
+		if err := watchTree(watcher, root); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintln(info, "Watching for changes, press Ctrl+C to stop")
+
+	pending := make(map[string]bool)
+	var quiet <-chan time.Time
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if event.Has(fsnotify.Chmod) && !event.Has(fsnotify.Write) {
+				continue
+			}
+			if event.Has(fsnotify.Create) {
+				// Watch new directories, and whatever was created in them already
+				if fi, err := os.Stat(event.Name); err == nil && fi.IsDir() {
+					if err := watchTree(watcher, event.Name); err != nil {
+						fmt.Fprintf(info, "Warning: %v\n", err)
+					}
+				}
+			}
+			pending[event.Name] = true
+			quiet = time.After(watchDebounce)
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			return fmt.Errorf("failed to watch for changes: %w", err)
+
+		case <-quiet:
+			paths := make([]string, 0, len(pending))
+			for path := range pending {
+				paths = append(paths, path)
+			}
+			sort.Strings(paths)
+			pending = make(map[string]bool)
+			quiet = nil
+
+			if err := reanalyze(out, a, targetDir, roots, selection, paths); err != nil {
+				fmt.Fprintf(info, "Error: %v\n", err)
+			}
+		}
+	}
+}
+
+// watchTree watches root and every directory beneath it, except hidden ones
+// such as .git
+func watchTree(watcher *fsnotify.Watcher, root string) error {
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if path != root && strings.HasPrefix(info.Name(), ".") {
+			return filepath.SkipDir
+		}
+		if err := watcher.Add(path); err != nil {
+			return fmt.Errorf("failed to watch %s: %w", path, err)
+		}
+		return nil
+	})
+}
+
+// reanalyze refreshes the changed paths and prints the suggestions of every
+// document that was added or changed
+func reanalyze(out io.Writer, a *analyzer.Analyzer, targetDir string, roots []string, selection analyzer.SelectionOptions, paths []string) error {
+	changed, err := a.Refresh(paths)
+	if err != nil {
+		return fmt.Errorf("failed to refresh documents: %w", err)
+	}
+
+	for _, path := range changed {
+		if selection.SingleFile != "" && path != filepath.Clean(selection.SingleFile) {
+			continue
+		}
+		single := selection
+		single.SingleFile = path
+		suggestions, err := a.AnalyzeWith(single)
+		if err != nil {
+			return fmt.Errorf("analysis of %s failed: %w", path, err)
+		}
+		if err := printSuggestions(out, a, targetDir, roots, suggestions); err != nil {
+			return err
+		}
+	}
+	return nil
+}
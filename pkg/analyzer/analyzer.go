@@ -1,228 +1,1468 @@
 package analyzer
 
 import (
+	"crypto/sha256"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
+	"text/template"
+	"time"
 
 	"internal-link/pkg/cache"
+	"internal-link/pkg/history"
+	"internal-link/pkg/journal"
 	"internal-link/pkg/markdown"
 	"internal-link/pkg/scorer"
 )
 
-// Config holds the analyzer configuration
-type Config struct {
-	MinScore     float64
-	DryRun       bool
-	SingleFile   string
-	TargetDir    string
-	CacheDir     string
-	ParserConfig markdown.ParserConfig
-}
-
 // Analyzer coordinates document analysis and link suggestions
 type Analyzer struct {
-	parser *markdown.Parser
-	scorer scorer.Scorer
-	cache  *cache.Cache
-	config Config
-	docs   map[string]*scorer.Document
+	parser  *markdown.Parser
+	scorer  scorer.Scorer
+	cache   *cache.Cache
+	history *history.History
+	journal *journal.Journal
+	run     *journal.Run // Insertions of this run, started on the first one
+	config  Config
+	docs    map[string]*scorer.Document
+
+	// foldCase is set when the filesystem of TargetDir ignores case, so
+	// paths differing only in case name the same document
+	foldCase bool
+
+	// contents holds documents added with AddDocument, which are never read from disk
+	contents map[string][]byte
+
+	// fingerprint identifies the loaded corpus; it changes whenever any
+	// document is added, removed or edited. It is computed on first use.
+	fingerprint string
+	hashes      map[string][sha256.Size]byte
+
+	// index is the scorer index last loaded from or saved to the cache
+	index *scorer.Index
+
+	// links holds the links found in each document; they are resolved into
+	// the documents' Links once the corpus is complete
+	links      map[string][]markdown.ExistingLink
+	linksStale bool
+
+	// urls maps the published URL paths of documents, their aliases
+	// included, to the documents; built on first use after the corpus changed
+	urls map[string]string
+
+	// duplicates maps each near duplicate to the canonical member of its
+	// cluster, with the clusters as reported; resolved like links
+	duplicates      map[string]string
+	clusters        []DuplicateCluster
+	duplicatesStale bool
+
+	// loaded is set once the corpus has been read, so several selection
+	// passes can share it
+	loaded     bool
+	parsed     int
+	cacheHits  int
+	overBudget int
+
+	// skipped holds the files left out of the corpus because they couldn't
+	// be loaded, with the reason
+	skipped map[string]error
+
+	// Timings and results reported by Stats
+	loadTime    time.Duration
+	analyzeTime time.Duration
+	suggested   int
+	threshold   float64
+	inserted    int
+	modified    int
+	sourceCount int
+	targetCount int
+
+	// targets holds the documents TargetInclude offers as link targets, or
+	// is nil when every document is
+	targets map[string]bool
+
+	// linkTemplate renders inserted links when LinkTemplate is set
+	linkTemplate *template.Template
+
+	// pairs holds the pair scores of the analyses, when they are cached
+	pairs *pairScores
+
+	// belowThreshold records the best pair scores that missed MinScore;
+	// documents are analyzed concurrently, so it is guarded by scoresMu
+	belowThreshold scoreRecord
+	scoresMu       sync.Mutex
 }
 
 // NewAnalyzer creates a new analyzer with the given configuration
 func NewAnalyzer(config Config) (*Analyzer, error) {
-	cache, err := cache.NewCache(config.CacheDir)
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+
+	// Caching only saves work, so a read-only checkout is still analyzed;
+	// a journal kept beside the cache is then only held in memory
+	c, err := openCache(config)
+	if err != nil {
+		if config.JournalFile == filepath.Join(config.CacheDir, DefaultJournalFile) {
+			config.JournalFile = ""
+			fmt.Fprintf(config.Log, "Warning: caching disabled and links inserted by this run can't be undone: %v\n", err)
+		} else {
+			fmt.Fprintf(config.Log, "Warning: caching disabled: %v\n", err)
+		}
+	}
+
+	history, err := history.Load(config.HistoryFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load link history: %w", err)
+	}
+
+	journal, err := journal.Load(config.JournalFile)
 	if err != nil {
-		return nil, fmt.Errorf("failed to initialize cache: %w", err)
+		return nil, fmt.Errorf("failed to load link journal: %w", err)
 	}
 
+	// Queries are split like the documents they are scored against
+	parser := markdown.NewParser(config.ParserConfig)
+	bm25 := scorer.NewBM25Scorer(config.ParserConfig.MaxNGram, *config.ScorerConfig)
+	bm25.UseParser(parser)
+
+	// Validated along with the rest of the configuration
+	linkTemplate, _ := parseLinkTemplate(config.LinkTemplate)
+
 	return &Analyzer{
-		parser: markdown.NewParser(config.ParserConfig),
-		scorer: scorer.NewBM25Scorer(config.ParserConfig.MaxNGram),
-		cache:  cache,
-		config: config,
-		docs:   make(map[string]*scorer.Document),
+		parser:   parser,
+		scorer:   bm25,
+		cache:    c,
+		history:  history,
+		journal:  journal,
+		config:   config,
+		docs:     make(map[string]*scorer.Document),
+		foldCase: config.TargetDir != "" && caseInsensitive(config.TargetDir),
+		contents: make(map[string][]byte),
+		hashes:   make(map[string][sha256.Size]byte),
+		links:    make(map[string][]markdown.ExistingLink),
+		skipped:  make(map[string]error),
+
+		linkTemplate: linkTemplate,
 	}, nil
 }
 
-// Analyze processes markdown files and generates link suggestions
+// Analyze processes markdown files and generates link suggestions, then
+// prunes the cache entries of files no longer in the corpus
 func (a *Analyzer) Analyze() ([]scorer.LinkSuggestion, error) {
-	// Load documents
-	if err := a.loadDocuments(); err != nil {
-		return nil, fmt.Errorf("failed to load documents: %w", err)
+	suggestions, err := a.AnalyzeWith(a.config.SelectionOptions)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := a.PruneCache(); err != nil {
+		return nil, err
+	}
+	return suggestions, nil
+}
+
+// AnalyzeWith generates link suggestions using the given selection options
+// instead of the configured ones. The corpus is only loaded on the first call.
+func (a *Analyzer) AnalyzeWith(selection SelectionOptions) ([]scorer.LinkSuggestion, error) {
+	if err := a.ensureLoaded(); err != nil {
+		return nil, err
+	}
+	return a.analyze(selection)
+}
+
+// ensureLoaded loads the documents beneath TargetDir unless the corpus was
+// already loaded or built with AddDocument
+func (a *Analyzer) ensureLoaded() error {
+	if !a.loaded {
+		start := time.Now()
+		if err := a.loadIndex(); err != nil {
+			return err
+		}
+		if err := a.loadDocuments(); err != nil {
+			return fmt.Errorf("failed to load documents: %w", err)
+		}
+		if err := a.saveIndex(); err != nil {
+			return err
+		}
+		if err := a.flushCache(); err != nil {
+			return err
+		}
+		a.loadTime = time.Since(start)
+		a.loaded = true
+		fmt.Fprintln(a.config.Log, "Loaded ", len(a.docs), " documents")
+		if a.overBudget > 0 {
+			fmt.Fprintln(a.config.Log, a.overBudget, " documents exceeded the per-document token budget")
+		}
+		if len(a.skipped) > 0 {
+			a.printSkipped()
+		}
+	}
+	return nil
+}
+
+// analyze generates link suggestions for the loaded corpus
+func (a *Analyzer) analyze(selection SelectionOptions) ([]scorer.LinkSuggestion, error) {
+	if err := selection.validate(a.linkFormats()); err != nil {
+		return nil, err
+	}
+
+	a.belowThreshold = scoreRecord{}
+	if err := a.resolveLinks(); err != nil {
+		return nil, err
+	}
+	if err := a.resolveDuplicates(); err != nil {
+		return nil, err
 	}
-	fmt.Println("Loaded ", len(a.docs), " documents")
+	if err := a.loadPairScores(); err != nil {
+		return nil, err
+	}
+	phrases := a.newPhraseFilter(selection)
+	start := time.Now()
+	defer func() { a.analyzeTime = time.Since(start) }()
+
+	a.selectTargets(selection)
+	sources, err := a.sources(selection)
+	if err != nil {
+		return nil, err
+	}
+	a.countRoles(sources)
+
+	// The percentile is only known once every pair has been scored
+	if selection.MinScorePercentile > 0 {
+		if threshold, pairs := a.percentileThreshold(sources, selection); pairs > 0 {
+			fmt.Fprintf(a.config.Log, "The top %g%% of %d scored pairs reach --min-score %.4f\n", selection.MinScorePercentile, pairs, threshold)
+			selection.MinScore = threshold
+		}
+	}
+	a.threshold = selection.MinScore
 
 	var suggestions []scorer.LinkSuggestion
+	if selection.SingleFile != "" {
+		if suggestions, err = a.analyzeSingleDocument(sources[0], selection, phrases); err != nil {
+			return nil, err
+		}
+		a.progress(PhaseAnalyze, 1, 1)
+	} else if suggestions, err = a.analyzeDocuments(sources, selection, phrases); err != nil {
+		return nil, err
+	}
 
-	// If analyzing a single file
-	if a.config.SingleFile != "" {
-		fmt.Println("Analyzing single file: ", a.config.SingleFile)
-		doc, exists := a.docs[a.config.SingleFile]
-		if !exists {
-			return nil, fmt.Errorf("file %s not found", a.config.SingleFile)
+	if selection.Top > 0 {
+		var lowest float64
+		var cut bool
+		if suggestions, lowest, cut = topSuggestions(suggestions, selection.Top); cut {
+			fmt.Fprintf(a.config.Log, "The top %d suggestions reach --min-score %.4f\n", selection.Top, lowest)
+			a.threshold = lowest
 		}
-		return a.analyzeSingleDocument(doc)
 	}
 
-	// Analyze all documents
-	for _, doc := range a.docs {
-		docSuggestions, err := a.analyzeSingleDocument(doc)
+	if err := a.savePairScores(); err != nil {
+		return nil, err
+	}
+
+	a.printThresholdHint(suggestions, selection)
+	a.suggested = len(suggestions)
+	orderSuggestions(suggestions)
+	return suggestions, nil
+}
+
+// sources returns the documents to find suggestions for: SingleFile, or
+// every document matching SourceInclude, or those of them changed since
+// ChangedSince
+func (a *Analyzer) sources(selection SelectionOptions) ([]*scorer.Document, error) {
+	if selection.SingleFile != "" {
+		fmt.Fprintln(a.config.Log, "Analyzing single file: ", selection.SingleFile)
+		doc, err := a.sourceDocument(selection.SingleFile)
 		if err != nil {
-			return nil, fmt.Errorf("failed to analyze %s: %w", doc.Path, err)
+			return nil, err
+		}
+		return []*scorer.Document{doc}, nil
+	}
+
+	sources := make([]*scorer.Document, 0, len(a.docs))
+	var changed map[string]bool
+	if selection.ChangedSince != "" {
+		var err error
+		if changed, err = a.changedSince(selection.ChangedSince); err != nil {
+			return nil, err
+		}
+	}
+	for path, doc := range a.docs {
+		if (changed == nil || changed[path]) && included(selection.SourceInclude, a.relPath(path)) {
+			sources = append(sources, doc)
+		}
+	}
+	if changed != nil {
+		fmt.Fprintf(a.config.Log, "Analyzing %d document(s) changed since %s\n", len(sources), selection.ChangedSince)
+	}
+	return sources, nil
+}
+
+// selectTargets records the documents TargetInclude offers as link targets
+func (a *Analyzer) selectTargets(selection SelectionOptions) {
+	a.targets = nil
+	if len(selection.TargetInclude) == 0 {
+		return
+	}
+	a.targets = make(map[string]bool)
+	for path := range a.docs {
+		if included(selection.TargetInclude, a.relPath(path)) {
+			a.targets[path] = true
 		}
-		suggestions = append(suggestions, docSuggestions...)
+	}
+}
+
+// isTarget reports whether doc may be offered as a link target
+func (a *Analyzer) isTarget(doc *scorer.Document) bool {
+	return !doc.Ignored && (a.targets == nil || a.targets[doc.Path])
+}
+
+// countRoles records for Stats how many documents are analyzed as sources
+// and how many are offered as targets
+func (a *Analyzer) countRoles(sources []*scorer.Document) {
+	a.sourceCount, a.targetCount = 0, 0
+	for _, doc := range sources {
+		if !doc.Ignored {
+			a.sourceCount++
+		}
+	}
+	for _, doc := range a.docs {
+		if a.isTarget(doc) {
+			a.targetCount++
+		}
+	}
+}
+
+// analyzeDocuments analyzes the sources with a pool of workers
+func (a *Analyzer) analyzeDocuments(sources []*scorer.Document, selection SelectionOptions, phrases phraseFilter) ([]scorer.LinkSuggestion, error) {
+	type analysis struct {
+		suggestions []scorer.LinkSuggestion
+		err         error
 	}
 
+	jobs := make(chan *scorer.Document)
+	results := make(chan analysis)
+	var wg sync.WaitGroup
+	for i := 0; i < a.config.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for doc := range jobs {
+				docSuggestions, err := a.analyzeSingleDocument(doc, selection, phrases)
+				if err != nil {
+					err = fmt.Errorf("failed to analyze %s: %w", doc.Path, err)
+				}
+				results <- analysis{suggestions: docSuggestions, err: err}
+			}
+		}()
+	}
+	go func() {
+		for _, doc := range sources {
+			jobs <- doc
+		}
+		close(jobs)
+		wg.Wait()
+		close(results)
+	}()
+
+	var suggestions []scorer.LinkSuggestion
+	var firstErr error
+	done := 0
+	for result := range results {
+		if result.err != nil && firstErr == nil {
+			firstErr = result.err
+		}
+		suggestions = append(suggestions, result.suggestions...)
+		done++
+		a.progress(PhaseAnalyze, done, len(sources))
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
 	return suggestions, nil
 }
 
+// earlier breaks ties between equally good phrases by position, then text,
+// so results don't depend on map iteration order
+func earlier(a, b *markdown.WordOccurrence) bool {
+	if a.Position != b.Position {
+		return a.Position < b.Position
+	}
+	return a.Word < b.Word
+}
+
+// occurrenceCandidate is the occurrence of a phrase put forward for linking
+// to a target
+type occurrenceCandidate struct {
+	occ   *markdown.WordOccurrence
+	intro bool    // The phrase only occurs within the introduction
+	score float64 // Quality of the phrase as an anchor for the target
+}
+
+// newOccurrenceCandidate picks the first occurrence of a phrase past the
+// introduction, falling back to its first occurrence, and scores the phrase
+// by its frequency in the target and its IDF
+func newOccurrenceCandidate(occs []markdown.WordOccurrence, freq int, idf float64, introEnd int, weights AnchorWeights) occurrenceCandidate {
+	c := occurrenceCandidate{occ: &occs[0], intro: occs[0].Position < introEnd}
+	for i := range occs {
+		if occs[i].Position >= introEnd {
+			c.occ, c.intro = &occs[i], false
+			break
+		}
+	}
+	c.score = weights.score(freq, idf, strings.Count(c.occ.Word, " ")+1)
+	return c
+}
+
+// better reports whether c is preferred over o: occurrences outside the
+// introduction first, then the better anchor, then the earliest occurrence
+func (c occurrenceCandidate) better(o occurrenceCandidate) bool {
+	switch {
+	case c.intro != o.intro:
+		return !c.intro
+	case c.score != o.score:
+		return c.score > o.score
+	}
+	return earlier(c.occ, o.occ)
+}
+
+// recordBelowThreshold remembers a pair score that missed MinScore
+func (a *Analyzer) recordBelowThreshold(score float64) {
+	a.scoresMu.Lock()
+	defer a.scoresMu.Unlock()
+	a.belowThreshold.add(score)
+}
+
+// orderSuggestions puts suggestions in the order they are returned in, by
+// source file and position in it, then by descending score and target
+// path, so unchanged corpora give identical output from run to run
+func orderSuggestions(suggestions []scorer.LinkSuggestion) {
+	sort.Slice(suggestions, func(i, j int) bool {
+		si, sj := suggestions[i], suggestions[j]
+		if si.SourcePath != sj.SourcePath {
+			return si.SourcePath < sj.SourcePath
+		}
+		if si.Position != sj.Position {
+			return si.Position < sj.Position
+		}
+		if si.Score != sj.Score {
+			return si.Score > sj.Score
+		}
+		return si.TargetPath < sj.TargetPath
+	})
+}
+
+// sortSuggestions ranks suggestions by source file, then by descending
+// score, breaking ties by target path and position
+func sortSuggestions(suggestions []scorer.LinkSuggestion) {
+	sort.Slice(suggestions, func(i, j int) bool {
+		si, sj := suggestions[i], suggestions[j]
+		if si.SourcePath != sj.SourcePath {
+			return si.SourcePath < sj.SourcePath
+		}
+		if si.Score != sj.Score {
+			return si.Score > sj.Score
+		}
+		if si.TargetPath != sj.TargetPath {
+			return si.TargetPath < sj.TargetPath
+		}
+		return si.Position < sj.Position
+	})
+}
+
+// printThresholdHint tells the user which threshold would have produced
+// suggestions when MinScore filtered out every scored pair
+func (a *Analyzer) printThresholdHint(suggestions []scorer.LinkSuggestion, selection SelectionOptions) {
+	if len(suggestions) > 0 {
+		return
+	}
+	if hint := a.belowThreshold.thresholdHint(selection.MinScore); hint != "" {
+		fmt.Fprintln(a.config.Log, hint)
+	}
+}
+
 // loadDocuments reads and processes all markdown files
 func (a *Analyzer) loadDocuments() error {
-	return filepath.Walk(a.config.TargetDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
+	return a.walkDocuments()
+}
+
+// walkDocuments walks TargetDir and registers every markdown file with the
+// scorer. Files are read and parsed by a pool of workers; the results are
+// registered one at a time in path order, so the outcome doesn't depend on
+// scheduling.
+func (a *Analyzer) walkDocuments() error {
+	paths, err := a.findDocuments()
+	if err != nil {
+		return err
+	}
+
+	jobs := make(chan string)
+	results := make(chan loadResult)
+	var wg sync.WaitGroup
+	for i := 0; i < a.config.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range jobs {
+				results <- a.loadDocument(path)
+			}
+		}()
+	}
+	go func() {
+		for _, path := range paths {
+			jobs <- path
 		}
+		close(jobs)
+		wg.Wait()
+		close(results)
+	}()
 
-		if info.IsDir() || !strings.HasSuffix(strings.ToLower(path), ".md") {
-			return nil
+	loaded := make([]loadResult, 0, len(paths))
+	for result := range results {
+		loaded = append(loaded, result)
+		a.progress(PhaseLoad, len(loaded), len(paths))
+	}
+	sort.Slice(loaded, func(i, j int) bool {
+		return loaded[i].doc.Path < loaded[j].doc.Path
+	})
+
+	for _, result := range loaded {
+		if result.oversized > 0 {
+			fmt.Fprintf(a.config.Log, "Skipping %s: %d bytes exceeds the maximum file size of %d bytes\n", result.doc.Path, result.oversized, a.config.MaxFileSize)
+			continue
+		}
+		if result.err != nil {
+			if err := a.skip(result.doc.Path, result.err); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := a.registerDocument(result); err != nil {
+			return err
 		}
+	}
+
+	// Skipping every file would make for an empty analysis that looks clean
+	if len(paths) > 0 && len(a.docs) == 0 {
+		return fmt.Errorf("none of the %d document(s) could be loaded", len(paths))
+	}
+
+	return nil
+}
 
-		// Try to get from cache first
-		cached, err := a.cache.Get(path)
+// registerDocument adds a loaded document to the corpus and the scorer
+func (a *Analyzer) registerDocument(result loadResult) error {
+	if result.err != nil {
+		return result.err
+	}
+
+	path := result.doc.Path
+	delete(a.skipped, path)
+	if result.parsed {
+		fmt.Fprintln(a.config.Log, "Parsing file: ", path)
+		a.parsed++
+	} else {
+		a.cacheHits++
+	}
+	if result.budgetErr != nil {
+		fmt.Fprintf(a.config.Log, "Warning: %s: %v\n", path, result.budgetErr)
+		a.overBudget++
+	}
+
+	if err := a.scorer.ProcessDocument(result.doc); err != nil {
+		return fmt.Errorf("failed to process document %s: %w", path, err)
+	}
+	a.hashes[path] = result.hash
+	a.docs[path] = result.doc
+	a.links[path] = result.links
+	a.linksStale = true
+	a.urls = nil
+	a.duplicatesStale = true
+	a.fingerprint = ""
+	return nil
+}
+
+// findDocuments returns the markdown files beneath TargetDir, or its Roots,
+// that aren't excluded or ignored
+func (a *Analyzer) findDocuments() ([]string, error) {
+	roots := a.config.Roots
+	if len(roots) == 0 {
+		roots = []string{a.config.TargetDir}
+	}
+
+	// Roots may overlap, so documents are collected across all of them
+	canonical := a.newCanonicalPaths()
+	var paths []string
+	for _, root := range roots {
+		ignores, err := newIgnoreMatcher(root, !a.config.NoGitignore)
 		if err != nil {
-			return fmt.Errorf("failed to check cache for %s: %w", path, err)
+			return nil, err
 		}
 
-		var wordFreq map[string]int
-
-		if cached != nil {
-			wordFreq = cached.WordFreq
-		} else {
-			fmt.Println("Parsing file: ", path)
-			content, err := os.ReadFile(path)
+		err = walkTree(root, a.config.FollowSymlinks, func(path string, info os.FileInfo, err error) error {
+			// An unreadable directory below the root is skipped like a file
 			if err != nil {
-				return fmt.Errorf("failed to read file %s: %w", path, err)
+				if path == root {
+					return err
+				}
+				return a.skip(path, err)
 			}
 
-			wordFreq, err = a.parser.ParseContent(content)
-			if err != nil {
-				return fmt.Errorf("failed to parse file %s: %w", path, err)
+			if path != root && (excluded(a.config.ExcludeGlobs, a.relPath(path), info.IsDir()) || a.excludedByOverrides(path, info.IsDir()) || ignores.ignored(path, info.IsDir())) {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
 			}
 
-			// Cache the results
-			if err := a.cache.Set(path, wordFreq); err != nil {
-				return fmt.Errorf("failed to cache results for %s: %w", path, err)
+			if info.IsDir() {
+				return ignores.enter(path)
+			}
+			if !a.isDocument(path) {
+				return nil
 			}
+
+			if path, ok := canonical.add(path); ok {
+				paths = append(paths, path)
+			}
+			return nil
+		})
+		if err != nil {
+			sort.Strings(paths)
+			return paths, err
+		}
+	}
+	// Canonical paths no longer follow the order of the walk
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// isDocument reports whether the file has one of the configured extensions
+func (a *Analyzer) isDocument(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, allowed := range a.config.Extensions {
+		if ext == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// prose returns the content the parser should analyze; for MDX documents
+// the ESM statements and JSX tags are blanked out, keeping offsets intact
+func (a *Analyzer) prose(path string, content []byte) []byte {
+	if strings.EqualFold(filepath.Ext(path), ".mdx") {
+		return a.parser.MaskMDX(content)
+	}
+	return content
+}
+
+// loadResult is the outcome of reading and parsing one document
+type loadResult struct {
+	doc       *scorer.Document
+	hash      [sha256.Size]byte
+	links     []markdown.ExistingLink
+	parsed    bool  // The document wasn't cached and had to be parsed
+	oversized int64 // Size of a document skipped for exceeding MaxFileSize
+	budgetErr error // The document exceeded the token budget
+	err       error
+}
+
+// loadDocument reads a document and builds its word frequencies, using the
+// cache when possible. It is safe to call from several goroutines.
+func (a *Analyzer) loadDocument(path string) loadResult {
+	if a.config.MaxFileSize > 0 {
+		info, err := os.Stat(path)
+		if err != nil {
+			return loadResult{doc: &scorer.Document{Path: path}, err: fmt.Errorf("failed to stat file %s: %w", path, err)}
+		}
+		if info.Size() > a.config.MaxFileSize {
+			return loadResult{doc: &scorer.Document{Path: path}, oversized: info.Size()}
+		}
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return loadResult{doc: &scorer.Document{Path: path}, err: fmt.Errorf("failed to read file %s: %w", path, err)}
+	}
+	return a.parseDocument(path, content, a.cache != nil)
+}
+
+// parseDocument builds the word frequencies of a document's content. The
+// cache is only consulted when useCache is set, since it checks the file.
+func (a *Analyzer) parseDocument(path string, content []byte, useCache bool) loadResult {
+	result := loadResult{doc: &scorer.Document{Path: path}}
+	fail := func(err error) loadResult {
+		result.err = err
+		return result
+	}
+	result.hash = sha256.Sum256(content)
+	result.doc.Hash = fmt.Sprintf("%x", result.hash)
+	if err := checkText(content); err != nil {
+		return fail(fmt.Errorf("failed to load %s: %w", path, err))
+	}
+
+	// Try to get from cache first
+	var cached *cache.DocumentCache
+	if useCache {
+		var err error
+		if cached, err = a.cache.Get(path, a.parser.CacheKey(), content); err != nil {
+			return fail(fmt.Errorf("failed to check cache for %s: %w", path, err))
 		}
+	}
 
-		doc := &scorer.Document{
-			Path:     path,
-			WordFreq: wordFreq,
+	var err error
+	var wordFreq map[string]int
+	var sections []markdown.Section
+
+	if cached != nil {
+		wordFreq = cached.WordFreq
+		sections = cached.Sections
+	} else {
+		result.parsed = true
+		wordFreq, sections, err = a.parser.ParseSections(a.prose(path, content))
+		var budgetErr *markdown.BudgetError
+		if errors.As(err, &budgetErr) {
+			result.budgetErr = budgetErr
+		} else if err != nil {
+			return fail(fmt.Errorf("failed to parse file %s: %w", path, err))
 		}
 
-		if err := a.scorer.ProcessDocument(doc); err != nil {
-			return fmt.Errorf("failed to process document %s: %w", path, err)
+		// Cache the results
+		if useCache {
+			if err := a.cache.Set(path, a.parser.CacheKey(), content, wordFreq, sections); err != nil {
+				return fail(fmt.Errorf("failed to cache results for %s: %w", path, err))
+			}
 		}
+	}
+
+	fm, err := a.parser.ParseFrontmatter(content)
+	if err != nil {
+		return fail(fmt.Errorf("failed to read metadata of %s: %w", path, err))
+	}
 
-		a.docs[path] = doc
+	if result.doc.Ignored, err = a.parser.OptedOut(content, a.config.FrontmatterIgnoreKey); err != nil {
+		return fail(fmt.Errorf("failed to read metadata of %s: %w", path, err))
+	}
+
+	if result.links, err = a.parser.FindLinks(content); err != nil {
+		return fail(fmt.Errorf("failed to find links in %s: %w", path, err))
+	}
+
+	// A section's title represents the whole section, so index pages with
+	// little body text can still be matched by it
+	if isSectionPage(path) && fm.Title != "" {
+		titleFreq, err := a.parser.ParseContent([]byte(fm.Title))
+		if err != nil {
+			return fail(fmt.Errorf("failed to parse title of %s: %w", path, err))
+		}
+		merged := make(map[string]int, len(wordFreq)+len(titleFreq))
+		for term, freq := range wordFreq {
+			merged[term] = freq
+		}
+		for term, freq := range titleFreq {
+			merged[term] += freq
+		}
+		wordFreq = merged
+	}
+
+	result.doc.Title = fm.Title
+	result.doc.Keywords = fm.Keywords
+	result.doc.Tags = fm.Tags
+	result.doc.Categories = fm.Categories
+	result.doc.Canonical = fm.Canonical
+	if result.doc.TitleTerms, err = a.titleTerms(fm); err != nil {
+		return fail(fmt.Errorf("failed to parse title of %s: %w", path, err))
+	}
+	result.doc.Slug = fm.Slug
+	result.doc.URL = fm.URL
+	result.doc.Aliases = fm.Aliases
+	result.doc.WordFreq = wordFreq
+	for _, section := range sections {
+		result.doc.Sections = append(result.doc.Sections, scorer.Section{Anchor: section.Anchor, WordFreq: section.WordFreq})
+	}
+	return result
+}
+
+// titleTerms returns the terms of a document's title and keywords as the
+// parser normalizes them, so they match the terms of its WordFreq
+func (a *Analyzer) titleTerms(fm markdown.Frontmatter) (map[string]bool, error) {
+	terms := make(map[string]bool)
+	for _, text := range append([]string{fm.Title}, fm.Keywords...) {
+		freq, err := a.parser.ParseContent([]byte(text))
+		if err != nil {
+			return nil, err
+		}
+		for term := range freq {
+			terms[term] = true
+		}
+	}
+	return terms, nil
+}
+
+// corpusFingerprint hashes the paths and contents of all loaded documents
+// together with the parser settings that shape derived data
+func (a *Analyzer) corpusFingerprint() string {
+	paths := make([]string, 0, len(a.hashes))
+	for path := range a.hashes {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	h := sha256.New()
+	fmt.Fprintln(h, a.parser.CacheKey())
+	for _, path := range paths {
+		hash := a.hashes[path]
+		fmt.Fprintf(h, "%s\x00%x\n", path, hash)
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// corpusArtifact loads the named corpus-level artifact from the cache into v,
+// or computes and stores it when the corpus changed since it was last derived
+func (a *Analyzer) corpusArtifact(name string, v interface{}, compute func() error) error {
+	if a.cache == nil {
+		return compute()
+	}
+	if a.fingerprint == "" {
+		a.fingerprint = a.corpusFingerprint()
+	}
+
+	found, err := a.cache.GetCorpus(name, a.fingerprint, v)
+	if err != nil {
+		return fmt.Errorf("failed to check cache for %s: %w", name, err)
+	}
+	if found {
 		return nil
-	})
+	}
+
+	if err := compute(); err != nil {
+		return fmt.Errorf("failed to compute %s: %w", name, err)
+	}
+
+	if err := a.cache.SetCorpus(name, a.fingerprint, v); err != nil {
+		return fmt.Errorf("failed to cache %s: %w", name, err)
+	}
+	return a.flushCache()
 }
 
-// analyzeSingleDocument generates link suggestions for a single document
-func (a *Analyzer) analyzeSingleDocument(doc *scorer.Document) ([]scorer.LinkSuggestion, error) {
+// analyzeSingleDocument generates link suggestions for a single document,
+// linking only the phrases the filter allows
+func (a *Analyzer) analyzeSingleDocument(doc *scorer.Document, selection SelectionOptions, phrases phraseFilter) ([]scorer.LinkSuggestion, error) {
 	var suggestions []scorer.LinkSuggestion
+	if doc.Ignored {
+		return suggestions, nil
+	}
 
 	// Read the document content
-	content, err := os.ReadFile(doc.Path)
+	content, err := a.readDocument(doc.Path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read file %s: %w", doc.Path, err)
 	}
 
 	// Find word occurrences in the document
-	occurrences, err := a.parser.FindWordOccurrences(content, 3) // Skip words shorter than 3 chars
-	if err != nil {
+	// Documents over budget were already reported while loading
+	prose := a.prose(doc.Path, content)
+	occurrences, err := a.parser.FindOccurrences(prose, a.parser.MinWordLength())
+	var budgetErr *markdown.BudgetError
+	if err != nil && !errors.As(err, &budgetErr) {
 		return nil, fmt.Errorf("failed to find word occurrences in %s: %w", doc.Path, err)
 	}
 
-	// Group occurrences by word
+	// Group the linkable occurrences by word; filtered phrases still count
+	// towards the score through the document's frequencies
 	wordOccurrences := make(map[string][]markdown.WordOccurrence)
 	for _, occ := range occurrences {
+		if !phrases.allows(occ.Word) {
+			continue
+		}
 		wordOccurrences[occ.Word] = append(wordOccurrences[occ.Word], occ)
 	}
+	introEnd := 0
+	if selection.IntroLength > 0 {
+		introEnd = a.parser.FrontmatterEnd(content) + selection.IntroLength
+	}
 
-	// Check each target document for potential links
-	positionSuggestions := make(map[int]scorer.LinkSuggestion)
+	// The source's indexed terms are the query against every target
+	query := scorer.NewQuery(doc.WordFreq)
 
-	for targetPath, targetDoc := range a.docs {
-		if targetPath == doc.Path {
-			continue
-		}
-
-		score := a.scorer.Score(string(content), targetDoc)
-		if score >= a.config.MinScore {
-			// Find the best word to link based on frequency and presence in target
-			var bestOccurrence *markdown.WordOccurrence
-			var maxFreq int
+	pairs := a.scorePairs(doc, query, selection)
+	minScore := a.minScore(doc.Path, selection)
 
+	positionSuggestions := make(map[int]scorer.LinkSuggestion)
+	paragraphs := make(map[int]int)                          // Paragraph of each suggestion, by position
+	alternatives := make(map[string][]scorer.LinkSuggestion) // Further phrases offered, by target
+	var suppressed []scorer.LinkSuggestion                   // Kept with ShowSuppressed only
+	var linked map[string][]markdown.ExistingLink            // Links to each target, found once needed
+	var lines *markdown.LineIndex
+	for _, pair := range pairs {
+		targetDoc, targetPath, score := pair.target, pair.target.Path, pair.score
+		if score >= minScore {
+			// Rank the phrases the target contains by their best occurrence
+			var candidates []occurrenceCandidate
 			for word, occs := range wordOccurrences {
-				if freq, exists := targetDoc.WordFreq[word]; exists && freq > maxFreq {
-					maxFreq = freq
-					// Use the first occurrence of the most frequent matching word
-					bestOccurrence = &occs[0]
+				if selection.RepeatPolicy == RepeatOncePerPhrase && a.history.HasPhrase(a.relPath(doc.Path), word) {
+					continue
+				}
+				freq, exists := targetDoc.WordFreq[word]
+				if !exists {
+					continue
+				}
+				candidates = append(candidates, newOccurrenceCandidate(occs, freq, a.idf(word), introEnd, *selection.AnchorWeights))
+			}
+			sort.Slice(candidates, func(i, j int) bool { return candidates[i].better(candidates[j]) })
+
+			var chosen []*markdown.WordOccurrence
+			var anchorScores []float64
+			for _, candidate := range candidates {
+				if len(chosen) == selection.CandidatesPerTarget {
+					break
+				}
+				if !overlapsAny(candidate.occ, chosen) {
+					chosen = append(chosen, candidate.occ)
+					anchorScores = append(anchorScores, candidate.score)
 				}
 			}
 
-			if bestOccurrence != nil {
+			for rank, occ := range chosen {
+				a.parser.OccurrenceContext(prose, occ)
 				suggestion := scorer.LinkSuggestion{
-					SourcePath: doc.Path,
-					TargetPath: targetPath,
-					Score:      score,
-					WordToLink: bestOccurrence.Word,
-					Position:   bestOccurrence.Position,
-					Context:    bestOccurrence.Context,
+					SourcePath:    doc.Path,
+					TargetPath:    targetPath,
+					Score:         score,
+					WordToLink:    occ.Word,
+					Position:      occ.Position,
+					Line:          occ.Line,
+					Column:        occ.Column,
+					Location:      fmt.Sprintf("%s:%d:%d", doc.Path, occ.Line, occ.Column),
+					Context:       occ.Context,
+					ContextBefore: occ.Context[:occ.Highlight[0]],
+					Phrase:        occ.Context[occ.Highlight[0]:occ.Highlight[1]],
+					ContextAfter:  occ.Context[occ.Highlight[1]:],
+					Backlink:      pair.backlink,
+					SharedTags:    pair.sharedTags,
+					Alternative:   rank,
+					AnchorScore:   anchorScores[rank],
+				}
+				if selection.ScoreNormalization != ScoreNormalizationNone {
+					suggestion.RawScore = pair.raw
+				}
+				if occ.Surface != "" {
+					// Link the text as written, not its normalized form
+					suggestion.WordToLink = occ.Surface
+				}
+				if occ.Heading != nil {
+					suggestion.SourceSection = occ.Heading.String()
+				}
+				if selection.SectionAnchors {
+					suggestion.Anchor = a.bestSection(targetDoc, suggestion.WordToLink)
+				}
+				if a.config.ParserConfig.DebugPositions {
+					suggestion.DebugInfo = debugInfo(content, occ)
+				}
+				if selection.Explain {
+					suggestion.Explanation = a.explain(query, targetDoc, pair.boost)
+				}
+				if pair.linked {
+					if linked == nil {
+						if linked, err = a.linksByTarget(doc.Path, content); err != nil {
+							return nil, err
+						}
+						lines = markdown.NewLineIndex(content)
+					}
+					if link, blocked := repeatBlockedBy(linked[targetPath], occ.Position, selection.AllowRepeatAfter); blocked {
+						if selection.ShowSuppressed && rank == 0 {
+							line, _ := lines.Position(link.Start)
+							suggestion.Suppressed = fmt.Sprintf("already linked at line %d", line)
+							suppressed = append(suppressed, suggestion)
+						}
+						continue
+					}
+				}
+				if rank > 0 {
+					alternatives[targetPath] = append(alternatives[targetPath], suggestion)
+					continue
 				}
 
 				// Only keep the suggestion if it has a higher score than any existing one at this position
-				if existing, exists := positionSuggestions[bestOccurrence.Position]; !exists || suggestion.Score > existing.Score {
-					positionSuggestions[bestOccurrence.Position] = suggestion
+				existing, exists := positionSuggestions[occ.Position]
+				if !exists || suggestion.Score > existing.Score ||
+					suggestion.Score == existing.Score && suggestion.TargetPath < existing.TargetPath {
+					positionSuggestions[occ.Position] = suggestion
+					paragraphs[occ.Position] = occ.Paragraph
 				}
 			}
+		} else {
+			a.recordBelowThreshold(score)
 		}
 	}
 
-	// Convert map to slice
+	// Convert map to slice, best first
 	suggestions = make([]scorer.LinkSuggestion, 0, len(positionSuggestions))
 	for _, suggestion := range positionSuggestions {
 		suggestions = append(suggestions, suggestion)
 	}
+	sortSuggestions(suggestions)
 
-	return suggestions, nil
+	if selection.MinLinkDistance > 0 {
+		if suggestions, err = a.spaceLinks(doc.Path, content, suggestions, selection.MinLinkDistance); err != nil {
+			return nil, err
+		}
+	}
+
+	if selection.MaxLinksPerParagraph > 0 {
+		if suggestions, err = a.limitPerParagraph(doc.Path, content, suggestions, paragraphs, selection.MaxLinksPerParagraph); err != nil {
+			return nil, err
+		}
+	}
+
+	if selection.MaxLinksPerFile > 0 && len(suggestions) > selection.MaxLinksPerFile {
+		suggestions = suggestions[:selection.MaxLinksPerFile]
+	}
+
+	sortSuggestions(suppressed)
+	return append(withAlternatives(suggestions, alternatives), suppressed...), nil
+}
+
+// candidates returns the targets to score doc against: every document
+// sharing a term with its query, or with CandidatePool the most promising of
+// those it may link to, ranked by their bound times their boost
+func (a *Analyzer) candidates(doc *scorer.Document, query scorer.Query, selection SelectionOptions) []*scorer.Document {
+	ranker, ok := a.scorer.(scorer.CandidateRanker)
+	if selection.CandidatePool == 0 || !ok {
+		return a.scorer.Candidates(query)
+	}
+	candidates, _ := ranker.TopCandidates(query, selection.CandidatePool, func(target *scorer.Document) float64 {
+		pair, ok := a.pairFor(doc, target, selection)
+		if !ok {
+			return 0
+		}
+		return pair.boost
+	})
+	return candidates
+}
+
+// pairFor returns the pair of doc and target to score, with its boost but
+// without a score yet, or false when doc may not link to target
+func (a *Analyzer) pairFor(doc, targetDoc *scorer.Document, selection SelectionOptions) (scoredPair, bool) {
+	targetPath := targetDoc.Path
+	if a.samePath(targetPath, doc.Path) || !a.isTarget(targetDoc) {
+		return scoredPair{}, false
+	}
+	// Near duplicates are only linked through their canonical member, and
+	// never to it from within their cluster
+	if _, duplicate := a.duplicates[targetPath]; duplicate || a.duplicates[doc.Path] == targetPath {
+		return scoredPair{}, false
+	}
+	linked := !selection.AllowDuplicateTargets && linksTo(doc, targetPath)
+	if linked && selection.AllowRepeatAfter == 0 && !selection.ShowSuppressed {
+		return scoredPair{}, false
+	}
+	backlink := linksTo(targetDoc, doc.Path)
+
+	// Once a pair has been linked anywhere in the file, later runs leave it alone
+	if selection.RepeatPolicy == RepeatOncePerPair && a.history.HasPair(a.relPath(doc.Path), a.relPath(targetPath)) {
+		return scoredPair{}, false
+	}
+
+	section := isSectionPage(targetPath)
+	if section && a.config.SectionPages == SectionPagesExclude {
+		return scoredPair{}, false
+	}
+	if selection.SameCategoryOnly && len(sharedTaxonomy(doc.Categories, targetDoc.Categories)) == 0 {
+		return scoredPair{}, false
+	}
+	sharedTags := sharedTaxonomy(doc.Tags, targetDoc.Tags)
+
+	boost := 1.0
+	if section && a.config.SectionPages == SectionPagesBoost {
+		boost *= sectionBoost
+	}
+	if backlink && selection.PreferBacklinks {
+		boost *= backlinkBoost
+	}
+	if len(sharedTags) > 0 && selection.TagBoost > 0 {
+		boost *= selection.TagBoost
+	}
+	return scoredPair{
+		target:     targetDoc,
+		boost:      boost,
+		backlink:   backlink,
+		sharedTags: sharedTags,
+		linked:     linked,
+	}, true
+}
+
+// scorePairs scores doc against each of the targets it may link to, with
+// the scores normalized as selected
+func (a *Analyzer) scorePairs(doc *scorer.Document, query scorer.Query, selection SelectionOptions) []scoredPair {
+	// Every target is scored first, so the scores can be normalized
+	// against each other; documents sharing no term with the source would
+	// score 0, so only candidates are considered
+	var pairs []scoredPair
+	for _, targetDoc := range a.candidates(doc, query, selection) {
+		pair, ok := a.pairFor(doc, targetDoc, selection)
+		if !ok {
+			continue
+		}
+		pair.raw = a.scorePair(doc, query, targetDoc) * pair.boost
+		pairs = append(pairs, pair)
+	}
+	return normalizeScores(selection.ScoreNormalization, selection.MinRawScore, pairs)
 }
 
-// ApplyChanges applies the suggested changes to the documents
+// overlapsAny reports whether the phrase of occ overlaps one of others
+func overlapsAny(occ *markdown.WordOccurrence, others []*markdown.WordOccurrence) bool {
+	end := occ.Position + len(occurrenceText(occ))
+	for _, o := range others {
+		if occ.Position < o.Position+len(occurrenceText(o)) && o.Position < end {
+			return true
+		}
+	}
+	return false
+}
+
+// occurrenceText returns the text of an occurrence as written
+func occurrenceText(occ *markdown.WordOccurrence) string {
+	if occ.Surface != "" {
+		return occ.Surface
+	}
+	return occ.Word
+}
+
+// withAlternatives adds the alternative phrases of the pairs whose preferred
+// suggestion survived the link limits, which they don't count towards.
+// Alternatives at a position already taken by a suggestion are left out.
+func withAlternatives(suggestions []scorer.LinkSuggestion, alternatives map[string][]scorer.LinkSuggestion) []scorer.LinkSuggestion {
+	if len(alternatives) == 0 {
+		return suggestions
+	}
+	taken := make(map[int]bool, len(suggestions))
+	for _, s := range suggestions {
+		taken[s.Position] = true
+	}
+	kept := suggestions
+	for _, s := range suggestions {
+		for _, alt := range alternatives[s.TargetPath] {
+			if !taken[alt.Position] {
+				taken[alt.Position] = true
+				kept = append(kept, alt)
+			}
+		}
+	}
+	return kept
+}
+
+// spaceLinks keeps the suggestions of a source, best first, that are at
+// least distance bytes away from its existing links and from the
+// suggestions kept before them
+func (a *Analyzer) spaceLinks(source string, content []byte, suggestions []scorer.LinkSuggestion, distance int) ([]scorer.LinkSuggestion, error) {
+	links, err := a.parser.FindLinks(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find links in %s: %w", source, err)
+	}
+
+	type span struct{ start, end int }
+	taken := make([]span, 0, len(links)+len(suggestions))
+	for _, link := range links {
+		taken = append(taken, span{link.Start, link.End})
+	}
+
+	kept := suggestions[:0]
+	for _, s := range suggestions {
+		start, end := s.Position, s.Position+len(s.WordToLink)
+		crowded := false
+		for _, t := range taken {
+			if start < t.end+distance && t.start < end+distance {
+				crowded = true
+				break
+			}
+		}
+		if !crowded {
+			kept = append(kept, s)
+			taken = append(taken, span{start, end})
+		}
+	}
+	return kept, nil
+}
+
+// limitPerParagraph keeps the suggestions of a source, best first, while
+// their paragraph has fewer than max links, counting its existing links and
+// the suggestions kept before them. Suggestions outside paragraphs are kept.
+func (a *Analyzer) limitPerParagraph(source string, content []byte, suggestions []scorer.LinkSuggestion, paragraphs map[int]int, max int) ([]scorer.LinkSuggestion, error) {
+	links, err := a.parser.FindLinks(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find links in %s: %w", source, err)
+	}
+
+	count := make(map[int]int)
+	for _, link := range links {
+		count[link.Paragraph]++
+	}
+
+	kept := suggestions[:0]
+	for _, s := range suggestions {
+		paragraph := paragraphs[s.Position]
+		if paragraph == 0 || count[paragraph] < max {
+			kept = append(kept, s)
+			count[paragraph]++
+		}
+	}
+	return kept, nil
+}
+
+// bestSection returns the anchor of the target's section that best matches
+// the phrase as written, or "" when the text before its first heading matches best
+func (a *Analyzer) bestSection(doc *scorer.Document, phrase string) string {
+	var best string
+	var bestScore float64
+	for _, section := range doc.Sections {
+		score := a.scorer.Score(phrase, &scorer.Document{Path: doc.Path, WordFreq: section.WordFreq})
+		if score > bestScore {
+			best, bestScore = section.Anchor, score
+		}
+	}
+	return best
+}
+
+// ApplyChanges applies the suggested changes to the documents. If any
+// suggestion no longer matches its file, nothing is written and an
+// *ApplyError lists every such suggestion, unless BestEffort is set.
 func (a *Analyzer) ApplyChanges(suggestions []scorer.LinkSuggestion) error {
 	if a.config.DryRun {
 		return nil
 	}
 
-	for _, suggestion := range suggestions {
-		content, err := os.ReadFile(suggestion.SourcePath)
+	// Every file's new content is planned and checked before any is written,
+	// so a suggestion that no longer fits leaves the whole tree untouched
+	paths, byFile := groupByFile(onePerPair(unsuppressed(suggestions)))
+	var changes []fileChange
+	var failures []SuggestionFailure
+	for _, path := range paths {
+		change, failed, err := a.planChange(path, byFile[path])
 		if err != nil {
-			return fmt.Errorf("failed to read file %s: %w", suggestion.SourcePath, err)
+			return err
 		}
+		failures = append(failures, failed...)
+		if len(change.planned) > 0 {
+			changes = append(changes, change)
+		}
+	}
+	if len(failures) > 0 {
+		if !a.config.BestEffort {
+			return &ApplyError{Failures: failures}
+		}
+		for _, f := range failures {
+			fmt.Fprintf(a.config.Log, "Skipping link to %s in %s: %s\n", f.Suggestion.TargetPath, f.Suggestion.SourcePath, f.Reason)
+		}
+	}
 
-		newContent, err := a.parser.InsertLink(content, suggestion.WordToLink, suggestion.TargetPath, suggestion.Position)
+	var modified []string
+	inserted := make(map[string]int)
+	a.inserted, a.modified = 0, 0
+	for _, change := range changes {
+		n, err := a.writeChange(change)
 		if err != nil {
-			return fmt.Errorf("failed to insert link in %s: %w", suggestion.SourcePath, err)
+			return err
 		}
-
-		if err := os.WriteFile(suggestion.SourcePath, newContent, 0644); err != nil {
-			return fmt.Errorf("failed to write file %s: %w", suggestion.SourcePath, err)
+		if n > 0 {
+			modified = append(modified, change.path)
+			inserted[change.path] = n
+			a.inserted += n
+			a.modified++
 		}
 	}
 
+	if len(suggestions) == 0 {
+		return nil
+	}
+	if err := a.history.Save(); err != nil {
+		return fmt.Errorf("failed to save link history: %w", err)
+	}
+	if err := a.journal.Save(); err != nil {
+		return fmt.Errorf("failed to save link journal: %w", err)
+	}
+
+	fmt.Fprintf(a.config.Log, "Modified %d file(s):\n", len(modified))
+	for _, path := range modified {
+		fmt.Fprintf(a.config.Log, "  %s: %d link(s) inserted\n", path, inserted[path])
+	}
+
 	return nil
 }
+
+// fileChange is the planned rewrite of one file
+type fileChange struct {
+	path    string
+	content []byte
+	planned []plannedEdit
+}
+
+// planChange plans the edits for one file, returning the suggestions that
+// can't be applied to it as failures. A file that can't be read fails all
+// of its suggestions.
+func (a *Analyzer) planChange(path string, suggestions []scorer.LinkSuggestion) (fileChange, []SuggestionFailure, error) {
+	change := fileChange{path: path}
+	content, err := a.readDocument(path)
+	if err != nil {
+		reason := fmt.Sprintf("failed to read file: %v", err)
+		failures := make([]SuggestionFailure, len(suggestions))
+		for i, s := range suggestions {
+			failures[i] = SuggestionFailure{Suggestion: s, Reason: reason}
+		}
+		return change, failures, nil
+	}
+
+	valid, failures := a.validSuggestions(content, suggestions)
+	planned, err := a.planFile(path, content, valid)
+	if err != nil {
+		return change, nil, err
+	}
+	change.content, change.planned = content, planned
+	return change, failures, nil
+}
+
+// writeChange writes a planned file change, records the inserted links in
+// the history and returns how many were inserted
+func (a *Analyzer) writeChange(change fileChange) (int, error) {
+	if err := a.writeDocument(change.path, change.content, applyEdits(change.content, change.planned)); err != nil {
+		return 0, err
+	}
+
+	inserted := 0
+	for _, p := range change.planned {
+		if p.references {
+			continue
+		}
+		a.history.Record(a.relPath(change.path), a.relPath(p.suggestion.TargetPath), a.parser.Normalize(p.suggestion.WordToLink))
+		inserted++
+	}
+	a.journalInsertions(change.path, change.planned)
+
+	return inserted, nil
+}
+
+// linkTarget returns the destination written into a link from source to a target document
+func (a *Analyzer) linkTarget(source, targetPath string) string {
+	// Wikilinks name notes by their path within the vault
+	if a.linkFormat(source) == markdown.LinkFormatWikilink {
+		return a.relPath(targetPath)
+	}
+
+	if a.config.URLTemplate != "" {
+		if doc, ok := a.docs[targetPath]; ok {
+			return a.permalink(doc)
+		}
+	}
+
+	dir := a.config.SectionLinkDir && isSectionPage(targetPath)
+	if dir {
+		targetPath = filepath.Dir(targetPath)
+	}
+
+	var dest string
+	switch a.config.LinkStyle {
+	case LinkStyleAbsoluteFromRoot:
+		dest = "/" + strings.TrimPrefix(a.relPath(targetPath), ".")
+	default:
+		rel, err := filepath.Rel(filepath.Dir(a.linkBase(source)), targetPath)
+		if err != nil {
+			rel = targetPath
+		}
+		dest = filepath.ToSlash(rel)
+	}
+
+	if dir && !strings.HasSuffix(dest, "/") {
+		dest += "/"
+	}
+	return dest
+}
+
+// permalink returns the published URL of a document: its frontmatter url if
+// set, otherwise the URL template filled in with its slug and directory
+func (a *Analyzer) permalink(doc *scorer.Document) string {
+	if doc.URL != "" {
+		return doc.URL
+	}
+
+	dir := a.relPath(filepath.Dir(doc.Path))
+	if dir == "." {
+		dir = ""
+	}
+
+	r := strings.NewReplacer("{slug}", slug(doc), "{dir}", dir)
+	return r.Replace(a.config.URLTemplate)
+}
+
+// slug returns the slug a document is published under: its frontmatter
+// slug if set, otherwise its file name without extension
+func slug(doc *scorer.Document) string {
+	if doc.Slug != "" {
+		return doc.Slug
+	}
+	// Page bundles and section pages are published under their directory name
+	if isSectionPage(doc.Path) {
+		return filepath.Base(filepath.Dir(doc.Path))
+	}
+	return strings.TrimSuffix(filepath.Base(doc.Path), filepath.Ext(doc.Path))
+}
+
+// idf returns how rare term is across the corpus, or 0 if the scorer
+// can't tell
+func (a *Analyzer) idf(term string) float64 {
+	if weigher, ok := a.scorer.(scorer.TermWeigher); ok {
+		return weigher.IDF(term)
+	}
+	return 0
+}
+
+// explain breaks the score of a pair down, if the scorer can, noting the
+// boost applied on top of it
+func (a *Analyzer) explain(query scorer.Query, target *scorer.Document, boost float64) *scorer.Explanation {
+	explainer, ok := a.scorer.(scorer.Explainer)
+	if !ok {
+		return nil
+	}
+	explanation := explainer.Explain(query, target)
+	explanation.Boost = boost
+	return &explanation
+}
+
+// debugInfo describes where an occurrence sits in the AST and quotes the raw bytes around it
+func debugInfo(content []byte, occ *markdown.WordOccurrence) string {
+	const window = 20
+
+	start := occ.Position - window
+	if start < 0 {
+		start = 0
+	}
+	// The span as written, which stemming or skipped stop words make differ
+	// from the normalized word
+	length := occ.Length
+	if length == 0 {
+		length = len(occurrenceText(occ))
+	}
+	end := occ.Position + length + window
+	if end > len(content) {
+		end = len(content)
+	}
+
+	return fmt.Sprintf("%s %q", occ.Ancestry, content[start:end])
+}
+
+// relPath returns path relative to TargetDir, or path itself if it lies outside
+func (a *Analyzer) relPath(path string) string {
+	rel, err := filepath.Rel(a.config.TargetDir, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(path)
+	}
+	return filepath.ToSlash(rel)
+}
+
+// isSectionPage reports whether the path is a section index page (_index.md or index.md)
+func isSectionPage(path string) bool {
+	name := strings.ToLower(filepath.Base(path))
+	name = strings.TrimSuffix(name, filepath.Ext(name))
+	return name == "_index" || name == "index"
+}
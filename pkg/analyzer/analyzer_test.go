@@ -0,0 +1,1065 @@
+package analyzer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"internal-link/pkg/markdown"
+	"internal-link/pkg/scorer"
+)
+
+// writeFixture creates the given files beneath a temporary directory and returns its path
+func writeFixture(t testing.TB, files map[string]string) string {
+	t.Helper()
+
+	root := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+	return root
+}
+
+// newTestAnalyzer creates an analyzer over root with a private cache directory
+func newTestAnalyzer(t testing.TB, root string, config Config) *Analyzer {
+	t.Helper()
+
+	config.TargetDir = root
+	config.CacheDir = t.TempDir()
+	if config.Log == nil {
+		config.Log = io.Discard
+	}
+	if config.ParserConfig == (markdown.ParserConfig{}) {
+		config.ParserConfig = markdown.ParserConfig{MinNGram: 2, MaxNGram: 3}
+	}
+
+	a, err := NewAnalyzer(config)
+	require.NoError(t, err)
+	return a
+}
+
+var hugoFixture = map[string]string{
+	"docs/_index.md": "---\ntitle: Documentation\n---\nWelcome to the documentation.\n",
+	"docs/monitoring/_index.md": "---\ntitle: Monitoring Section\n---\n" +
+		"Prometheus alerting covers grafana dashboards.\n",
+	"posts/setup.md": "We configure prometheus alerting for the cluster.\n",
+}
+
+func TestSectionPages(t *testing.T) {
+	tests := []struct {
+		name         string
+		sectionPages string
+		linkDir      bool
+		expectedLink string
+	}{
+		{
+			name:         "link to index file",
+			sectionPages: SectionPagesNormal,
+			expectedLink: "../docs/monitoring/_index.md",
+		},
+		{
+			name:         "link to section directory",
+			sectionPages: SectionPagesNormal,
+			linkDir:      true,
+			expectedLink: "../docs/monitoring/",
+		},
+		{
+			name:         "boosted section",
+			sectionPages: SectionPagesBoost,
+			linkDir:      true,
+			expectedLink: "../docs/monitoring/",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := writeFixture(t, hugoFixture)
+			source := filepath.Join(root, "posts", "setup.md")
+
+			a := newTestAnalyzer(t, root, Config{
+				ScoringOptions:   ScoringOptions{SectionPages: tt.sectionPages},
+				SelectionOptions: SelectionOptions{MinScore: 0.1, SingleFile: source},
+				ApplyOptions:     ApplyOptions{SectionLinkDir: tt.linkDir},
+			})
+
+			suggestions, err := a.Analyze()
+			require.NoError(t, err)
+			require.Len(t, suggestions, 1)
+			assert.Equal(t, "prometheus alerting", suggestions[0].WordToLink)
+
+			require.NoError(t, a.ApplyChanges(suggestions))
+			content, err := os.ReadFile(source)
+			require.NoError(t, err)
+
+			expected := "[prometheus alerting](" + tt.expectedLink + ")"
+			assert.Contains(t, string(content), expected)
+		})
+	}
+}
+
+func TestLinkTarget(t *testing.T) {
+	tests := []struct {
+		name      string
+		linkStyle string
+		linkDir   bool
+		source    string
+		target    string
+		expected  string
+	}{
+		{
+			name:     "same directory",
+			source:   "posts/a.md",
+			target:   "posts/b.md",
+			expected: "b.md",
+		},
+		{
+			name:     "sibling directories",
+			source:   "posts/a.md",
+			target:   "docs/b.md",
+			expected: "../docs/b.md",
+		},
+		{
+			name:     "nested subdirectory",
+			source:   "posts/a.md",
+			target:   "posts/2024/deep/b.md",
+			expected: "2024/deep/b.md",
+		},
+		{
+			name:     "from nested subdirectory",
+			source:   "posts/2024/deep/a.md",
+			target:   "docs/guides/b.md",
+			expected: "../../../docs/guides/b.md",
+		},
+		{
+			name:     "section directory",
+			linkDir:  true,
+			source:   "posts/a.md",
+			target:   "docs/monitoring/_index.md",
+			expected: "../docs/monitoring/",
+		},
+		{
+			name:      "absolute from root",
+			linkStyle: LinkStyleAbsoluteFromRoot,
+			source:    "posts/2024/a.md",
+			target:    "docs/guides/b.md",
+			expected:  "/docs/guides/b.md",
+		},
+		{
+			name:      "absolute root section directory",
+			linkStyle: LinkStyleAbsoluteFromRoot,
+			linkDir:   true,
+			source:    "posts/a.md",
+			target:    "_index.md",
+			expected:  "/",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			a := newTestAnalyzer(t, root, Config{
+				ApplyOptions: ApplyOptions{LinkStyle: tt.linkStyle, SectionLinkDir: tt.linkDir},
+			})
+
+			source := filepath.Join(root, filepath.FromSlash(tt.source))
+			target := filepath.Join(root, filepath.FromSlash(tt.target))
+			assert.Equal(t, tt.expected, a.linkTarget(source, target))
+		})
+	}
+}
+
+func TestURLTemplate(t *testing.T) {
+	root := writeFixture(t, map[string]string{
+		"posts/with-slug.md":        "---\ntitle: Article\nslug: my-article\n---\nBody text.\n",
+		"posts/plain.md":            "Body text.\n",
+		"about.md":                  "+++\ntitle = \"About\"\nurl = \"/about-us/\"\n+++\nBody text.\n",
+		"docs/monitoring/_index.md": "Body text.\n",
+	})
+
+	tests := []struct {
+		name     string
+		template string
+		target   string
+		expected string
+	}{
+		{name: "frontmatter slug", template: "/blog/{slug}/", target: "posts/with-slug.md", expected: "/blog/my-article/"},
+		{name: "file name fallback", template: "/blog/{slug}/", target: "posts/plain.md", expected: "/blog/plain/"},
+		{name: "explicit url", template: "/blog/{slug}/", target: "about.md", expected: "/about-us/"},
+		{name: "section directory", template: "/{dir}/", target: "docs/monitoring/_index.md", expected: "/docs/monitoring/"},
+		{name: "directory and slug", template: "/{dir}/{slug}/", target: "posts/with-slug.md", expected: "/posts/my-article/"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestAnalyzer(t, root, Config{
+				ApplyOptions: ApplyOptions{URLTemplate: tt.template},
+			})
+			require.NoError(t, a.loadDocuments())
+
+			source := filepath.Join(root, "posts", "plain.md")
+			target := filepath.Join(root, filepath.FromSlash(tt.target))
+			assert.Equal(t, tt.expected, a.linkTarget(source, target))
+		})
+	}
+}
+
+func TestInvalidLinkStyle(t *testing.T) {
+	_, err := NewAnalyzer(Config{
+		ScoringOptions: ScoringOptions{CacheDir: t.TempDir()},
+		ApplyOptions:   ApplyOptions{LinkStyle: "url"},
+	})
+	assert.Error(t, err)
+}
+
+func TestSectionPagesBoostAndExclude(t *testing.T) {
+	root := writeFixture(t, hugoFixture)
+	source := filepath.Join(root, "posts", "setup.md")
+
+	scoreFor := func(mode string) []float64 {
+		a := newTestAnalyzer(t, root, Config{
+			ScoringOptions:   ScoringOptions{SectionPages: mode},
+			SelectionOptions: SelectionOptions{MinScore: 0.1, SingleFile: source},
+			ApplyOptions:     ApplyOptions{DryRun: true},
+		})
+		suggestions, err := a.Analyze()
+		require.NoError(t, err)
+
+		var scores []float64
+		for _, s := range suggestions {
+			scores = append(scores, s.Score)
+		}
+		return scores
+	}
+
+	normal := scoreFor(SectionPagesNormal)
+	boosted := scoreFor(SectionPagesBoost)
+	require.Len(t, normal, 1)
+	require.Len(t, boosted, 1)
+	assert.InDelta(t, normal[0]*sectionBoost, boosted[0], 1e-9)
+
+	assert.Empty(t, scoreFor(SectionPagesExclude))
+}
+
+func TestSectionTitleMetadata(t *testing.T) {
+	root := writeFixture(t, hugoFixture)
+	a := newTestAnalyzer(t, root, Config{
+		SelectionOptions: SelectionOptions{MinScore: 0.1},
+		ApplyOptions:     ApplyOptions{DryRun: true},
+	})
+	require.NoError(t, a.loadDocuments())
+
+	doc := a.docs[filepath.Join(root, "docs", "monitoring", "_index.md")]
+	require.NotNil(t, doc)
+	assert.Equal(t, "Monitoring Section", doc.Title)
+	assert.Equal(t, 1, doc.WordFreq["monitoring section"])
+}
+
+func TestInvalidSectionPagesMode(t *testing.T) {
+	_, err := NewAnalyzer(Config{
+		ScoringOptions: ScoringOptions{CacheDir: t.TempDir(), SectionPages: "sometimes"},
+	})
+	assert.Error(t, err)
+}
+
+func TestCorpusArtifactReuse(t *testing.T) {
+	root := writeFixture(t, hugoFixture)
+	cacheDir := t.TempDir()
+
+	load := func() *Analyzer {
+		a, err := NewAnalyzer(Config{
+			ScoringOptions: ScoringOptions{TargetDir: root, CacheDir: cacheDir, ParserConfig: markdown.ParserConfig{MinNGram: 2, MaxNGram: 3}},
+		})
+		require.NoError(t, err)
+		require.NoError(t, a.loadDocuments())
+		return a
+	}
+
+	computed := 0
+	derive := func(a *Analyzer) int {
+		var count int
+		require.NoError(t, a.corpusArtifact("doc-count", &count, func() error {
+			computed++
+			count = len(a.docs)
+			return nil
+		}))
+		return count
+	}
+
+	assert.Equal(t, 3, derive(load()))
+	assert.Equal(t, 3, derive(load()))
+	assert.Equal(t, 1, computed, "unchanged corpus should reuse the cached artifact")
+
+	path := filepath.Join(root, "posts", "setup.md")
+	require.NoError(t, os.WriteFile(path, []byte("Edited content about grafana dashboards.\n"), 0644))
+
+	assert.Equal(t, 3, derive(load()))
+	assert.Equal(t, 2, computed, "edited corpus should recompute the artifact")
+}
+
+func TestCacheNotReusedAcrossNGramSettings(t *testing.T) {
+	root := writeFixture(t, map[string]string{"post.md": "we use prometheus alerting daily.\n"})
+	cacheDir := t.TempDir()
+	path := filepath.Join(root, "post.md")
+
+	load := func(minNGram, maxNGram int) map[string]int {
+		a, err := NewAnalyzer(Config{
+			ScoringOptions: ScoringOptions{
+				TargetDir:    root,
+				CacheDir:     cacheDir,
+				ParserConfig: markdown.ParserConfig{MinNGram: minNGram, MaxNGram: maxNGram},
+			},
+			Log: io.Discard,
+		})
+		require.NoError(t, err)
+		require.NoError(t, a.loadDocuments())
+		return a.docs[path].WordFreq
+	}
+
+	assert.NotContains(t, load(1, 1), "prometheus alerting")
+	assert.Contains(t, load(2, 3), "prometheus alerting")
+}
+
+func TestConcurrencyDoesNotChangeSuggestions(t *testing.T) {
+	files := map[string]string{}
+	for i := 0; i < 20; i++ {
+		files[fmt.Sprintf("posts/post-%02d.md", i)] = fmt.Sprintf("post %d covers prometheus alerting and grafana dashboards.\n", i)
+	}
+	files["alerts.md"] = "Prometheus alerting guide.\n"
+	files["grafana.md"] = "Grafana dashboards guide.\n"
+	root := writeFixture(t, files)
+
+	var runs [][]scorer.LinkSuggestion
+	for _, concurrency := range []int{1, 8} {
+		a := newTestAnalyzer(t, root, Config{
+			ScoringOptions:   ScoringOptions{Concurrency: concurrency},
+			SelectionOptions: SelectionOptions{MinScore: 0.1},
+		})
+		suggestions, err := a.Analyze()
+		require.NoError(t, err)
+		require.NotEmpty(t, suggestions)
+		runs = append(runs, suggestions)
+	}
+
+	assert.Equal(t, runs[0], runs[1])
+}
+
+func TestMaxLinksPerFile(t *testing.T) {
+	root := writeFixture(t, map[string]string{
+		"post.md":    "we use prometheus alerting with grafana dashboards and loki logs daily.\n",
+		"alerts.md":  "Prometheus alerting guide. prometheus alerting rules.\n",
+		"grafana.md": "Grafana dashboards guide.\n",
+		"loki.md":    "Loki logs guide.\n",
+	})
+	source := filepath.Join(root, "post.md")
+
+	all := newTestAnalyzer(t, root, Config{
+		SelectionOptions: SelectionOptions{MinScore: 0.1, SingleFile: source},
+	})
+	unlimited, err := all.Analyze()
+	require.NoError(t, err)
+	require.Len(t, unlimited, 3)
+
+	limited, err := all.AnalyzeWith(SelectionOptions{MinScore: 0.1, SingleFile: source, MaxLinksPerFile: 2})
+	require.NoError(t, err)
+	assert.Equal(t, bestSuggestions(unlimited, 2), limited)
+}
+
+func TestSuggestionLocation(t *testing.T) {
+	root := writeFixture(t, map[string]string{
+		"post.md":   "---\ntitle: Post\n---\n# Setup\n\nWe tuned prometheus alerting today.\n",
+		"alerts.md": "Prometheus alerting guide. prometheus alerting rules.\n",
+	})
+	source := filepath.Join(root, "post.md")
+	a := newTestAnalyzer(t, root, Config{
+		SelectionOptions: SelectionOptions{MinScore: 0.1, SingleFile: source},
+	})
+
+	suggestions, err := a.Analyze()
+	require.NoError(t, err)
+	require.Len(t, suggestions, 1)
+	assert.Equal(t, 6, suggestions[0].Line)
+	assert.Equal(t, 10, suggestions[0].Column)
+	assert.Equal(t, source+":6:10", suggestions[0].Location)
+}
+
+func TestCandidatesPerTarget(t *testing.T) {
+	root := writeFixture(t, map[string]string{
+		"post.md":   "set up prometheus alerting first. later, alerting rules keep the pager quiet.\n",
+		"alerts.md": "Prometheus alerting guide. prometheus alerting with alerting rules.\n",
+	})
+	source := filepath.Join(root, "post.md")
+	a := newTestAnalyzer(t, root, Config{
+		SelectionOptions: SelectionOptions{MinScore: 0.1, SingleFile: source},
+	})
+
+	single, err := a.Analyze()
+	require.NoError(t, err)
+	require.Len(t, single, 1)
+
+	several, err := a.AnalyzeWith(SelectionOptions{MinScore: 0.1, SingleFile: source, CandidatesPerTarget: 2})
+	require.NoError(t, err)
+	require.Len(t, several, 2)
+	ranks := map[int]scorer.LinkSuggestion{}
+	for _, s := range several {
+		assert.Equal(t, single[0].TargetPath, s.TargetPath)
+		assert.Equal(t, single[0].Score, s.Score)
+		ranks[s.Alternative] = s
+	}
+	require.Contains(t, ranks, 0)
+	require.Contains(t, ranks, 1)
+	assert.Equal(t, single[0], ranks[0])
+	assert.NotEqual(t, ranks[0].WordToLink, ranks[1].WordToLink)
+
+	// Only the best phrase is linked
+	changed, err := a.ApplyChangesToContent(several)
+	require.NoError(t, err)
+	require.Contains(t, changed, source)
+	assert.Equal(t, 1, strings.Count(string(changed[source]), "]("))
+	assert.Contains(t, string(changed[source]), "["+ranks[0].WordToLink+"]")
+
+	require.NoError(t, a.ApplyChanges(several))
+	content, err := os.ReadFile(source)
+	require.NoError(t, err)
+	assert.Equal(t, 1, strings.Count(string(content), "]("))
+	assert.Contains(t, string(content), "["+ranks[0].WordToLink+"]")
+}
+
+func TestInvalidCandidatesPerTarget(t *testing.T) {
+	_, err := NewAnalyzer(Config{
+		ScoringOptions:   ScoringOptions{CacheDir: t.TempDir()},
+		SelectionOptions: SelectionOptions{CandidatesPerTarget: -1},
+	})
+	assert.ErrorContains(t, err, "invalid candidates per target")
+}
+
+func TestAnchorPrefersDistinctivePhrases(t *testing.T) {
+	files := map[string]string{
+		"post.md":     "The cluster configuration of our admission webhook needs care.\n",
+		"webhooks.md": "Admission webhook setup: cluster configuration, more cluster configuration, cluster configuration again.\n",
+	}
+	for _, name := range []string{"basics", "files", "ref", "tuning", "upgrades", "backups"} {
+		files[name+".md"] = "Notes on cluster configuration " + name + ".\n"
+	}
+	root := writeFixture(t, files)
+	source := filepath.Join(root, "post.md")
+	a := newTestAnalyzer(t, root, Config{
+		SelectionOptions: SelectionOptions{MinScore: 0.1, SingleFile: source},
+	})
+
+	linkTo := func(suggestions []scorer.LinkSuggestion, target string) scorer.LinkSuggestion {
+		for _, s := range suggestions {
+			if s.TargetPath == filepath.Join(root, target) {
+				return s
+			}
+		}
+		t.Fatalf("no suggestion links to %s", target)
+		return scorer.LinkSuggestion{}
+	}
+
+	suggestions, err := a.Analyze()
+	require.NoError(t, err)
+	chosen := linkTo(suggestions, "webhooks.md")
+	assert.Equal(t, "admission webhook", chosen.WordToLink, "the rarer, specific phrase wins over the frequent one")
+	assert.Greater(t, chosen.AnchorScore, 0.0)
+
+	// Weighing only the frequency in the target picks the generic term
+	suggestions, err = a.AnalyzeWith(SelectionOptions{MinScore: 0.1, SingleFile: source, AnchorWeights: &AnchorWeights{Frequency: 1}})
+	require.NoError(t, err)
+	chosen = linkTo(suggestions, "webhooks.md")
+	assert.Equal(t, "cluster configuration", chosen.WordToLink)
+	assert.InDelta(t, math.Log1p(3), chosen.AnchorScore, 1e-9)
+}
+
+func TestInvalidAnchorWeights(t *testing.T) {
+	_, err := NewAnalyzer(Config{
+		ScoringOptions:   ScoringOptions{CacheDir: t.TempDir()},
+		SelectionOptions: SelectionOptions{AnchorWeights: &AnchorWeights{IDF: -1}},
+	})
+	assert.ErrorContains(t, err, "invalid anchor weights")
+}
+
+// bestSuggestions returns the n best scoring suggestions, in output order
+func bestSuggestions(suggestions []scorer.LinkSuggestion, n int) []scorer.LinkSuggestion {
+	ranked := append([]scorer.LinkSuggestion(nil), suggestions...)
+	sortSuggestions(ranked)
+	ranked = ranked[:n]
+	orderSuggestions(ranked)
+	return ranked
+}
+
+func TestSortSuggestionsBreaksTiesByTarget(t *testing.T) {
+	suggestions := []scorer.LinkSuggestion{
+		{SourcePath: "b.md", TargetPath: "x.md", Score: 1},
+		{SourcePath: "a.md", TargetPath: "z.md", Score: 2},
+		{SourcePath: "a.md", TargetPath: "y.md", Score: 2},
+		{SourcePath: "a.md", TargetPath: "w.md", Score: 3},
+	}
+	sortSuggestions(suggestions)
+
+	var order []string
+	for _, s := range suggestions {
+		order = append(order, s.SourcePath+">"+s.TargetPath)
+	}
+	assert.Equal(t, []string{"a.md>w.md", "a.md>y.md", "a.md>z.md", "b.md>x.md"}, order)
+}
+
+func TestOrderSuggestions(t *testing.T) {
+	suggestions := []scorer.LinkSuggestion{
+		{SourcePath: "b.md", TargetPath: "x.md", Position: 1, Score: 1},
+		{SourcePath: "a.md", TargetPath: "z.md", Position: 40, Score: 3},
+		{SourcePath: "a.md", TargetPath: "y.md", Position: 10, Score: 1},
+		{SourcePath: "a.md", TargetPath: "w.md", Position: 10, Score: 1},
+		{SourcePath: "a.md", TargetPath: "v.md", Position: 10, Score: 2},
+	}
+	orderSuggestions(suggestions)
+
+	var order []string
+	for _, s := range suggestions {
+		order = append(order, s.SourcePath+">"+s.TargetPath)
+	}
+	assert.Equal(t, []string{"a.md>v.md", "a.md>w.md", "a.md>y.md", "a.md>z.md", "b.md>x.md"}, order)
+}
+
+func TestOutputIsDeterministic(t *testing.T) {
+	root := writeFixture(t, hugoFixture)
+	run := func() string {
+		a := newTestAnalyzer(t, root, Config{SelectionOptions: SelectionOptions{MinScore: 0.1, RepeatPolicy: RepeatUnlimited}})
+		suggestions, err := a.Analyze()
+		require.NoError(t, err)
+		require.NotEmpty(t, suggestions)
+		out, err := json.Marshal(suggestions)
+		require.NoError(t, err)
+		return string(out)
+	}
+
+	first := run()
+	for i := 0; i < 5; i++ {
+		assert.Equal(t, first, run())
+	}
+}
+
+func TestInvalidScorerConfig(t *testing.T) {
+	_, err := NewAnalyzer(Config{
+		ScoringOptions: ScoringOptions{CacheDir: t.TempDir(), ScorerConfig: &scorer.ScorerConfig{K1: 1.2, B: 2}},
+	})
+	assert.ErrorContains(t, err, "0 <= b <= 1")
+}
+
+func TestInvalidConcurrency(t *testing.T) {
+	_, err := NewAnalyzer(Config{
+		ScoringOptions: ScoringOptions{CacheDir: t.TempDir(), Concurrency: -1},
+	})
+	assert.Error(t, err)
+}
+
+func TestRepeatedRunsStabilize(t *testing.T) {
+	for _, policy := range []string{RepeatOncePerPair, RepeatOncePerPhrase} {
+		t.Run(policy, func(t *testing.T) {
+			root := writeFixture(t, map[string]string{
+				"alerts.md": "Prometheus alerting guide for operators.\n",
+				"post.md": "we tuned prometheus alerting today.\n\n" +
+					"later prometheus alerting paged us.\n\n" +
+					"finally prometheus alerting was quiet.\n",
+			})
+			source := filepath.Join(root, "post.md")
+
+			var contents []string
+			for run := 0; run < 3; run++ {
+				a := newTestAnalyzer(t, root, Config{
+					SelectionOptions: SelectionOptions{MinScore: 0.1, SingleFile: source, RepeatPolicy: policy},
+				})
+				suggestions, err := a.Analyze()
+				require.NoError(t, err)
+				if run > 0 {
+					assert.Empty(t, suggestions, "run %d should find nothing new", run+1)
+				}
+				require.NoError(t, a.ApplyChanges(suggestions))
+
+				content, err := os.ReadFile(source)
+				require.NoError(t, err)
+				contents = append(contents, string(content))
+			}
+
+			assert.Contains(t, contents[0], "[prometheus alerting](")
+			assert.Equal(t, contents[0], contents[1])
+			assert.Equal(t, contents[0], contents[2])
+		})
+	}
+}
+
+func TestRepeatedWikilinkRunsDoNotDoubleWrap(t *testing.T) {
+	root := writeFixture(t, map[string]string{
+		"Alerts.md": "Prometheus alerting guide for operators.\n",
+		"post.md":   "we tuned prometheus alerting today.\n",
+	})
+	source := filepath.Join(root, "post.md")
+
+	var contents []string
+	for run := 0; run < 2; run++ {
+		// Without the history, only the existing wikilink prevents a repeat
+		a := newTestAnalyzer(t, root, Config{
+			ScoringOptions: ScoringOptions{
+				ParserConfig: markdown.ParserConfig{MinNGram: 2, MaxNGram: 3, LinkFormat: markdown.LinkFormatWikilink},
+			},
+			SelectionOptions: SelectionOptions{MinScore: 0.1, SingleFile: source, RepeatPolicy: RepeatUnlimited},
+		})
+		suggestions, err := a.Analyze()
+		require.NoError(t, err)
+		require.NoError(t, a.ApplyChanges(suggestions))
+
+		content, err := os.ReadFile(source)
+		require.NoError(t, err)
+		contents = append(contents, string(content))
+	}
+
+	assert.Equal(t, "we tuned [[Alerts|prometheus alerting]] today.\n", contents[0])
+	assert.Equal(t, contents[0], contents[1])
+}
+
+func TestInvalidRepeatPolicy(t *testing.T) {
+	_, err := NewAnalyzer(Config{
+		ScoringOptions:   ScoringOptions{CacheDir: t.TempDir()},
+		SelectionOptions: SelectionOptions{RepeatPolicy: "twice"},
+	})
+	assert.Error(t, err)
+}
+
+func TestSelectionPassesReuseLoadedCorpus(t *testing.T) {
+	root := writeFixture(t, hugoFixture)
+	source := filepath.Join(root, "posts", "setup.md")
+	a := newTestAnalyzer(t, root, Config{
+		SelectionOptions: SelectionOptions{MinScore: 0.1},
+		ApplyOptions:     ApplyOptions{DryRun: true},
+	})
+
+	all, err := a.Analyze()
+	require.NoError(t, err)
+	assert.NotEmpty(t, all)
+	assert.Equal(t, 3, a.parsed)
+
+	strict, err := a.AnalyzeWith(SelectionOptions{MinScore: 100})
+	require.NoError(t, err)
+	assert.Empty(t, strict)
+
+	single, err := a.AnalyzeWith(SelectionOptions{MinScore: 0.1, SingleFile: source})
+	require.NoError(t, err)
+	require.Len(t, single, 1)
+	assert.Equal(t, source, single[0].SourcePath)
+
+	assert.Equal(t, 3, a.parsed, "selection passes should not re-parse the corpus")
+	assert.Len(t, a.docs, 3)
+}
+
+func TestFlatConfig(t *testing.T) {
+	flat := FlatConfig{MinScore: 0.5, DryRun: true, SingleFile: "a.md", TargetDir: "docs", RepeatPolicy: RepeatUnlimited}
+	config := flat.Config()
+
+	assert.Equal(t, 0.5, config.MinScore)
+	assert.True(t, config.DryRun)
+	assert.Equal(t, "a.md", config.SingleFile)
+	assert.Equal(t, "docs", config.TargetDir)
+	assert.Equal(t, RepeatUnlimited, config.RepeatPolicy)
+}
+
+func TestOverBudgetDocumentDoesNotAbortRun(t *testing.T) {
+	var huge strings.Builder
+	for i := 0; i < 5000; i++ {
+		// Spelled in letters, as words with digits look like identifiers
+		fmt.Fprintf(&huge, "term%s ", strings.Map(func(r rune) rune { return r - '0' + 'a' }, fmt.Sprint(i)))
+	}
+	huge.WriteString("prometheus alerting\n")
+
+	root := writeFixture(t, map[string]string{
+		"huge.md":  huge.String(),
+		"small.md": "Prometheus alerting covers grafana dashboards.\n",
+	})
+
+	a := newTestAnalyzer(t, root, Config{
+		ScoringOptions: ScoringOptions{ParserConfig: markdown.ParserConfig{
+			MinNGram: 2, MaxNGram: 3, MaxOccurrencesPerDoc: 1000,
+		}},
+		SelectionOptions: SelectionOptions{MinScore: 0.1},
+		ApplyOptions:     ApplyOptions{DryRun: true},
+	})
+
+	_, err := a.Analyze()
+	require.NoError(t, err)
+	assert.Equal(t, 1, a.overBudget)
+	assert.LessOrEqual(t, len(a.docs[filepath.Join(root, "huge.md")].WordFreq), 1000)
+}
+
+func TestApplyMultipleSuggestionsToOneFile(t *testing.T) {
+	root := writeFixture(t, map[string]string{
+		"post.md": "we use prometheus alerting with grafana dashboards and loki logs daily.\n",
+	})
+	source := filepath.Join(root, "post.md")
+	a := newTestAnalyzer(t, root, Config{})
+
+	// Deliberately out of order, as map iteration would produce them
+	suggestions := []scorer.LinkSuggestion{
+		{SourcePath: source, TargetPath: "grafana.md", WordToLink: "grafana dashboards", Position: 32},
+		{SourcePath: source, TargetPath: "loki.md", WordToLink: "loki logs", Position: 55},
+		{SourcePath: source, TargetPath: "alerts.md", WordToLink: "prometheus alerting", Position: 7},
+	}
+	require.NoError(t, a.ApplyChanges(suggestions))
+
+	content, err := os.ReadFile(source)
+	require.NoError(t, err)
+	assert.Equal(t, "we use [prometheus alerting](alerts.md) with [grafana dashboards](grafana.md) "+
+		"and [loki logs](loki.md) daily.\n", string(content))
+}
+
+func TestApplySkipsOverlappingSuggestions(t *testing.T) {
+	root := writeFixture(t, map[string]string{
+		"post.md": "a test document about testing\n",
+	})
+	source := filepath.Join(root, "post.md")
+	a := newTestAnalyzer(t, root, Config{Log: io.Discard})
+
+	suggestions := []scorer.LinkSuggestion{
+		{SourcePath: source, TargetPath: "a.md", WordToLink: "test document", Position: 2},
+		{SourcePath: source, TargetPath: "b.md", WordToLink: "document about", Position: 7},
+	}
+	require.NoError(t, a.ApplyChanges(suggestions))
+
+	content, err := os.ReadFile(source)
+	require.NoError(t, err)
+	assert.Equal(t, "a test [document about](b.md) testing\n", string(content))
+}
+
+func TestSkipTargetsAlreadyLinked(t *testing.T) {
+	tests := []struct {
+		name            string
+		allowDuplicates bool
+		expectSuggested bool
+	}{
+		{name: "skipped by default", allowDuplicates: false, expectSuggested: false},
+		{name: "allowed duplicates", allowDuplicates: true, expectSuggested: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := writeFixture(t, depsFixture)
+			a := newTestAnalyzer(t, root, Config{
+				SelectionOptions: SelectionOptions{MinScore: 0.1, AllowDuplicateTargets: tt.allowDuplicates},
+			})
+
+			suggestions, err := a.Analyze()
+			require.NoError(t, err)
+
+			suggested := false
+			for _, s := range suggestions {
+				if s.SourcePath == filepath.Join(root, "a.md") && s.TargetPath == filepath.Join(root, "b.md") {
+					suggested = true
+				}
+			}
+			assert.Equal(t, tt.expectSuggested, suggested)
+		})
+	}
+}
+
+func TestAllowRepeatAfter(t *testing.T) {
+	filler := strings.Repeat("Unrelated words fill this paragraph. ", 10)
+	root := writeFixture(t, map[string]string{
+		"post.md": "See the [alerting guide](alerts.md) first.\n\n" + filler + "\n\n" +
+			"Later we tuned prometheus alerting again.\n",
+		"alerts.md": "Prometheus alerting guide. prometheus alerting rules.\n",
+	})
+	source := filepath.Join(root, "post.md")
+	a := newTestAnalyzer(t, root, Config{
+		SelectionOptions: SelectionOptions{MinScore: 0.1, SingleFile: source},
+	})
+
+	// Already linked, so left out by default
+	suggestions, err := a.Analyze()
+	require.NoError(t, err)
+	assert.Empty(t, suggestions)
+
+	// The existing link ends more than 100 bytes before the phrase, but not 1000
+	suggestions, err = a.AnalyzeWith(SelectionOptions{MinScore: 0.1, SingleFile: source, AllowRepeatAfter: 100})
+	require.NoError(t, err)
+	require.Len(t, suggestions, 1)
+	assert.Equal(t, "prometheus alerting", suggestions[0].WordToLink)
+	assert.Empty(t, suggestions[0].Suppressed)
+
+	suggestions, err = a.AnalyzeWith(SelectionOptions{MinScore: 0.1, SingleFile: source, AllowRepeatAfter: 1000})
+	require.NoError(t, err)
+	assert.Empty(t, suggestions)
+
+	_, err = a.AnalyzeWith(SelectionOptions{AllowRepeatAfter: -1})
+	assert.Error(t, err)
+}
+
+func TestShowSuppressed(t *testing.T) {
+	root := writeFixture(t, map[string]string{
+		"post.md":   "See the [alerting guide](alerts.md). We tuned prometheus alerting.\n",
+		"alerts.md": "Prometheus alerting guide. prometheus alerting rules.\n",
+	})
+	source := filepath.Join(root, "post.md")
+	a := newTestAnalyzer(t, root, Config{
+		SelectionOptions: SelectionOptions{MinScore: 0.1, SingleFile: source, ShowSuppressed: true},
+	})
+
+	suggestions, err := a.Analyze()
+	require.NoError(t, err)
+	require.Len(t, suggestions, 1)
+	assert.Equal(t, filepath.Join(root, "alerts.md"), suggestions[0].TargetPath)
+	assert.Equal(t, "already linked at line 1", suggestions[0].Suppressed)
+
+	// Suppressed suggestions are never applied
+	edits, err := a.ComputeEdits(suggestions)
+	require.NoError(t, err)
+	assert.Empty(t, edits)
+}
+
+func TestSectionAnchors(t *testing.T) {
+	root := writeFixture(t, map[string]string{
+		"monitoring.md": "Monitoring overview.\n\n" +
+			"## Getting Started\n\nInstall the agent first.\n\n" +
+			"## Prometheus Alerting\n\nPrometheus alerting rules page the on-call team.\n",
+		"post.md": "we tuned prometheus alerting today.\n",
+	})
+	source := filepath.Join(root, "post.md")
+
+	tests := []struct {
+		name           string
+		sectionAnchors bool
+		expected       string
+	}{
+		{name: "file links by default", expected: "we tuned [prometheus alerting](monitoring.md) today.\n"},
+		{name: "section anchors", sectionAnchors: true, expected: "we tuned [prometheus alerting](monitoring.md#prometheus-alerting) today.\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.NoError(t, os.WriteFile(source, []byte("we tuned prometheus alerting today.\n"), 0644))
+			a := newTestAnalyzer(t, root, Config{
+				SelectionOptions: SelectionOptions{MinScore: 0.1, SingleFile: source, SectionAnchors: tt.sectionAnchors},
+				ApplyOptions:     ApplyOptions{HistoryFile: filepath.Join(t.TempDir(), "history.json")},
+			})
+
+			suggestions, err := a.Analyze()
+			require.NoError(t, err)
+			require.Len(t, suggestions, 1)
+			require.NoError(t, a.ApplyChanges(suggestions))
+
+			content, err := os.ReadFile(source)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, string(content))
+		})
+	}
+}
+
+func TestSuggestionSourceSection(t *testing.T) {
+	a := newMemoryAnalyzer(t, map[string]string{
+		"alerts.md": "prometheus alerting rules and prometheus alerting basics.\n",
+		"post.md":   "we tuned prometheus alerting today.\n\n## Follow Up\n\nprometheus alerting rules again.\n",
+	})
+	suggestions, err := a.AnalyzeWith(SelectionOptions{MinScore: 0.01, CandidatesPerTarget: 2})
+	require.NoError(t, err)
+
+	sections := make(map[int]string)
+	for _, s := range suggestions {
+		if s.SourcePath == "post.md" {
+			sections[s.Line] = s.SourceSection
+		}
+	}
+	assert.Equal(t, map[int]string{1: "", 5: "## Follow Up"}, sections)
+}
+
+func TestSectionAnchorsRequireMarkdownLinks(t *testing.T) {
+	_, err := NewAnalyzer(Config{
+		ScoringOptions: ScoringOptions{
+			CacheDir:     t.TempDir(),
+			ParserConfig: markdown.ParserConfig{LinkFormat: markdown.LinkFormatWikilink},
+		},
+		SelectionOptions: SelectionOptions{SectionAnchors: true},
+	})
+	assert.Error(t, err)
+}
+
+func TestExtensions(t *testing.T) {
+	root := writeFixture(t, map[string]string{
+		"guide.md":        "Prometheus alerting guide.\n",
+		"notes.markdown":  "prometheus alerting notes.\n",
+		"docs/setup.MDX":  "import Tabs from '@theme/Tabs';\n\nprometheus alerting setup.\n",
+		"docs/readme.txt": "prometheus alerting readme.\n",
+	})
+
+	tests := []struct {
+		name       string
+		extensions []string
+		expected   []string
+	}{
+		{name: "markdown by default", expected: []string{"guide.md"}},
+		{name: "several extensions", extensions: []string{".md", "markdown", ".mdx"}, expected: []string{"docs/setup.MDX", "guide.md", "notes.markdown"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestAnalyzer(t, root, Config{ScoringOptions: ScoringOptions{Extensions: tt.extensions}})
+			require.NoError(t, a.loadDocuments())
+
+			var paths []string
+			for path := range a.docs {
+				paths = append(paths, a.relPath(path))
+			}
+			sort.Strings(paths)
+			assert.Equal(t, tt.expected, paths)
+		})
+	}
+
+	// ESM imports of MDX pages don't reach the term index
+	a := newTestAnalyzer(t, root, Config{ScoringOptions: ScoringOptions{Extensions: []string{".mdx"}}})
+	require.NoError(t, a.loadDocuments())
+	mdx := a.docs[filepath.Join(root, "docs/setup.MDX")]
+	require.NotNil(t, mdx)
+	assert.Contains(t, mdx.WordFreq, "alerting setup")
+	assert.NotContains(t, mdx.WordFreq, "import tabs")
+}
+
+func TestInvalidExtension(t *testing.T) {
+	_, err := NewAnalyzer(Config{
+		ScoringOptions: ScoringOptions{CacheDir: t.TempDir(), Extensions: []string{".md", " "}},
+	})
+	assert.Error(t, err)
+}
+
+func TestInvalidStopWordLanguage(t *testing.T) {
+	_, err := NewAnalyzer(Config{
+		ScoringOptions: ScoringOptions{CacheDir: t.TempDir(), ParserConfig: markdown.ParserConfig{Language: "klingon"}},
+	})
+	assert.Error(t, err)
+}
+
+func TestFrontmatterOptOut(t *testing.T) {
+	root := writeFixture(t, map[string]string{
+		"alerts.md":  "a guide to prometheus alerting for operators.\n",
+		"legal.md":   "---\ninternal_link: false\n---\nprometheus alerting terms of service.\n",
+		"landing.md": "---\nlinks_ignore: true\n---\nprometheus alerting for everyone.\n",
+		"post.md":    "we tuned prometheus alerting today.\n",
+	})
+
+	a := newTestAnalyzer(t, root, Config{SelectionOptions: SelectionOptions{MinScore: 0.01}})
+	suggestions, err := a.Analyze()
+	require.NoError(t, err)
+
+	// The custom key isn't consulted, so only legal.md is left out
+	var sources []string
+	for _, s := range suggestions {
+		sources = append(sources, a.relPath(s.SourcePath))
+		assert.NotEqual(t, "legal.md", a.relPath(s.SourcePath))
+		assert.NotEqual(t, "legal.md", a.relPath(s.TargetPath))
+	}
+	assert.Contains(t, sources, "landing.md")
+
+	a = newTestAnalyzer(t, root, Config{
+		ScoringOptions:   ScoringOptions{FrontmatterIgnoreKey: "links"},
+		SelectionOptions: SelectionOptions{MinScore: 0.01},
+	})
+	suggestions, err = a.Analyze()
+	require.NoError(t, err)
+	require.NotEmpty(t, suggestions)
+	for _, s := range suggestions {
+		assert.NotEqual(t, "landing.md", a.relPath(s.SourcePath))
+		assert.NotEqual(t, "landing.md", a.relPath(s.TargetPath))
+	}
+}
+
+// generatedFixture lays out size documents over a few sections, in groups of
+// four sharing phrases of their own besides those all documents share
+func generatedFixture(size int) map[string]string {
+	spell := func(i int) string {
+		return strings.Map(func(r rune) rune { return r - '0' + 'a' }, fmt.Sprint(i))
+	}
+	sections := []string{"docs", "posts", "guides", "notes"}
+	files := make(map[string]string, size)
+	for i := 0; i < size; i++ {
+		group, page := spell(i/4), spell(i)
+		files[fmt.Sprintf("%s/page-%05d.md", sections[i%len(sections)], i)] = fmt.Sprintf(
+			"---\ntitle: Page %s\n---\n# Page %s\n\n"+
+				"The cluster%s deployment covers prometheus alerting and grafana dashboards.\n\n"+
+				"Operators of cluster%s deployment tune the topic%s settings for every release.\n\n"+
+				"Notes on page%s stay local to this document.\n",
+			page, page, group, group, group, page)
+	}
+	return files
+}
+
+// BenchmarkAnalyze loads and analyzes generated corpora end to end, with an
+// empty cache each time
+func BenchmarkAnalyze(b *testing.B) {
+	for _, size := range []int{100, 1000} {
+		root := writeFixture(b, generatedFixture(size))
+		b.Run(fmt.Sprintf("docs-%d", size), func(b *testing.B) {
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				a := newTestAnalyzer(b, root, Config{SelectionOptions: SelectionOptions{MinScore: 0.1}})
+				benchmarkRun(b, a.config, false)
+			}
+		})
+
+		// Warm runs find the documents parsed and the pair scores of the
+		// unchanged corpus in the cache of an earlier run
+		b.Run(fmt.Sprintf("docs-%d-warm", size), func(b *testing.B) {
+			config := newTestAnalyzer(b, root, Config{SelectionOptions: SelectionOptions{MinScore: 0.1}}).config
+			benchmarkRun(b, config, false)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				benchmarkRun(b, config, false)
+			}
+		})
+
+		// The same without the scorer index, which is rebuilt on each run
+		b.Run(fmt.Sprintf("docs-%d-warm-no-index", size), func(b *testing.B) {
+			config := newTestAnalyzer(b, root, Config{SelectionOptions: SelectionOptions{MinScore: 0.1}}).config
+			benchmarkRun(b, config, false)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				benchmarkRun(b, config, true)
+			}
+		})
+	}
+}
+
+// benchmarkRun analyzes the corpus of config with a new analyzer, after
+// dropping the cached scorer index if noIndex is set
+func benchmarkRun(b *testing.B, config Config, noIndex bool) {
+	a, err := NewAnalyzer(config)
+	if err != nil {
+		b.Fatal(err)
+	}
+	if noIndex {
+		if err := a.cache.SetCorpus(scorerIndexArtifact, "", nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+	suggestions, err := a.Analyze()
+	if err != nil {
+		b.Fatal(err)
+	}
+	if len(suggestions) == 0 {
+		b.Fatal("no suggestions")
+	}
+}
+
+func TestDebugInfoQuotesWrittenSpan(t *testing.T) {
+	content := []byte(strings.Repeat("x", 30) + "Configuring the Alerts" + strings.Repeat("y", 30))
+	occ := &markdown.WordOccurrence{Word: "configur alert", Position: 30, Length: len("Configuring the Alerts"), Ancestry: "Document > Paragraph"}
+
+	assert.Equal(t, fmt.Sprintf("Document > Paragraph %q", strings.Repeat("x", 20)+"Configuring the Alerts"+strings.Repeat("y", 20)), debugInfo(content, occ))
+}
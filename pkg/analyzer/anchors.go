@@ -0,0 +1,39 @@
+package analyzer
+
+import (
+	"fmt"
+	"math"
+)
+
+// AnchorWeights weigh what makes a phrase a good anchor for a target: how
+// often the target uses it, how rare it is across the corpus, by its IDF,
+// and how many words it has. A phrase is scored
+//
+//	Frequency*ln(1+freq) + IDF*idf + Length*(words-1)
+//
+// so a distinctive phrase like "mutating admission webhook" can beat a
+// generic term the target repeats, like "configuration".
+type AnchorWeights struct {
+	Frequency float64
+	IDF       float64
+	Length    float64
+}
+
+// DefaultAnchorWeights returns the weights used when none are configured
+func DefaultAnchorWeights() AnchorWeights {
+	return AnchorWeights{Frequency: 1, IDF: 1, Length: 1}
+}
+
+// validate checks that no weight is negative
+func (w AnchorWeights) validate() error {
+	if w.Frequency < 0 || w.IDF < 0 || w.Length < 0 {
+		return fmt.Errorf("invalid anchor weights %+v (expected weights >= 0)", w)
+	}
+	return nil
+}
+
+// score rates a phrase of the given number of words as an anchor for a
+// target using it freq times
+func (w AnchorWeights) score(freq int, idf float64, words int) float64 {
+	return w.Frequency*math.Log1p(float64(freq)) + w.IDF*idf + w.Length*float64(words-1)
+}
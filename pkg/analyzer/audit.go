@@ -0,0 +1,282 @@
+package analyzer
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"internal-link/pkg/markdown"
+	"internal-link/pkg/scorer"
+)
+
+// retargetMargin is how many times better a different target must score
+// against an existing link's anchor text before retargeting is proposed
+const retargetMargin = 2.0
+
+// AuditExisting finds existing internal links whose anchor text matches a
+// different document much better than the one they point to. Proposed
+// targets are those the source may be suggested a link to, so the selection
+// excludes them alike. The corpus is loaded on first use like AnalyzeWith.
+// Retargets are sorted by source and position.
+func (a *Analyzer) AuditExisting(selection SelectionOptions) ([]scorer.RetargetSuggestion, error) {
+	if err := selection.validate(a.linkFormats()); err != nil {
+		return nil, err
+	}
+	if err := a.ensureLoaded(); err != nil {
+		return nil, err
+	}
+
+	a.selectTargets(selection)
+	paths := make([]string, 0, len(a.docs))
+	for path := range a.docs {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var retargets []scorer.RetargetSuggestion
+	for _, path := range paths {
+		doc := a.docs[path]
+		if selection.SingleFile != "" && doc.Path != selection.SingleFile || doc.Ignored {
+			continue
+		}
+		if selection.SingleFile == "" && !included(selection.SourceInclude, a.relPath(doc.Path)) {
+			continue
+		}
+
+		content, err := a.readDocument(doc.Path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file %s: %w", doc.Path, err)
+		}
+
+		links, err := a.parser.FindLinks(content)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find links in %s: %w", doc.Path, err)
+		}
+
+		for _, link := range links {
+			// Retargeting rewrites inline markdown link syntax only
+			if link.Wikilink || link.Reference || link.Custom {
+				continue
+			}
+			current, ok := a.resolveLink(doc.Path, link.Destination)
+			if !ok {
+				continue
+			}
+
+			query := scorer.NewQuery(a.parser.Terms(link.Text))
+			currentScore := a.scorer.ScoreQuery(query, a.docs[current])
+			// Candidates come sorted by path, so ties go to the first
+			var best string
+			var bestScore float64
+			for _, targetDoc := range a.candidates(doc, query, selection) {
+				if targetDoc.Path == current {
+					continue
+				}
+				pair, ok := a.pairFor(doc, targetDoc, selection)
+				if !ok || pair.linked {
+					continue
+				}
+				score := a.scorer.ScoreQuery(query, targetDoc) * pair.boost
+				if score > bestScore {
+					best, bestScore = targetDoc.Path, score
+				}
+			}
+
+			if best == "" || bestScore < a.minScore(doc.Path, selection) || bestScore < currentScore*retargetMargin {
+				continue
+			}
+
+			retargets = append(retargets, scorer.RetargetSuggestion{
+				SourcePath:     doc.Path,
+				AnchorText:     link.Text,
+				Destination:    link.Destination,
+				CurrentTarget:  current,
+				ProposedTarget: best,
+				CurrentScore:   currentScore,
+				ProposedScore:  bestScore,
+				Start:          link.Start,
+				End:            link.End,
+			})
+		}
+	}
+
+	sort.SliceStable(retargets, func(i, j int) bool {
+		if retargets[i].SourcePath != retargets[j].SourcePath {
+			return retargets[i].SourcePath < retargets[j].SourcePath
+		}
+		return retargets[i].Start < retargets[j].Start
+	})
+	return retargets, nil
+}
+
+// ApplyRetargets rewrites the destinations of the given existing links.
+// Each link is looked for near the span it was audited at, so links
+// inserted into its file since then don't get in the way.
+func (a *Analyzer) ApplyRetargets(retargets []scorer.RetargetSuggestion) error {
+	if a.config.DryRun {
+		return nil
+	}
+
+	byFile := make(map[string][]scorer.RetargetSuggestion)
+	for _, r := range retargets {
+		byFile[r.SourcePath] = append(byFile[r.SourcePath], r)
+	}
+
+	for path, fileRetargets := range byFile {
+		original, err := a.readDocument(path)
+		if err != nil {
+			return fmt.Errorf("failed to read file %s: %w", path, err)
+		}
+		links, err := a.parser.FindLinks(original)
+		if err != nil {
+			return fmt.Errorf("failed to find links in %s: %w", path, err)
+		}
+
+		// The retargets are copies, so their spans can follow the links
+		for i, r := range fileRetargets {
+			link, ok := nearestLink(links, r)
+			if !ok {
+				return fmt.Errorf("link at %d in %s no longer points to %s", r.Start, path, r.Destination)
+			}
+			fileRetargets[i].Start, fileRetargets[i].End = link.Start, link.End
+		}
+
+		// Rewrite the file back to front so earlier spans stay valid
+		sort.Slice(fileRetargets, func(i, j int) bool {
+			return fileRetargets[i].Start > fileRetargets[j].Start
+		})
+
+		content := original
+		for _, r := range fileRetargets {
+			markup := string(content[r.Start:r.End])
+			old := "](" + r.Destination
+			idx := strings.LastIndex(markup, old)
+			if idx == -1 {
+				return fmt.Errorf("link at %d in %s no longer points to %s", r.Start, path, r.Destination)
+			}
+
+			updated := markup[:idx] + "](" + a.linkTarget(path, r.ProposedTarget) + markup[idx+len(old):]
+			result := make([]byte, 0, len(content)+len(updated)-len(markup))
+			result = append(result, content[:r.Start]...)
+			result = append(result, updated...)
+			result = append(result, content[r.End:]...)
+			content = result
+		}
+
+		if err := a.writeDocument(path, original, content); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// nearestLink returns the inline link with the anchor text and destination
+// of a retarget closest to the span it was audited at
+func nearestLink(links []markdown.ExistingLink, r scorer.RetargetSuggestion) (markdown.ExistingLink, bool) {
+	var best markdown.ExistingLink
+	bestDistance := -1
+	for _, link := range links {
+		if link.Wikilink || link.Reference || link.Custom || link.Text != r.AnchorText || link.Destination != r.Destination {
+			continue
+		}
+		distance := link.Start - r.Start
+		if distance < 0 {
+			distance = -distance
+		}
+		if bestDistance == -1 || distance < bestDistance {
+			best, bestDistance = link, distance
+		}
+	}
+	return best, bestDistance != -1
+}
+
+// linkedTargets returns the sorted corpus documents the links of source point to
+func (a *Analyzer) linkedTargets(source string, links []markdown.ExistingLink) []string {
+	seen := make(map[string]bool)
+	var targets []string
+	for _, link := range links {
+		resolve := a.resolveLink
+		if link.Wikilink {
+			resolve = a.resolveNote
+		}
+		if target, ok := resolve(source, link.Destination); ok && target != source && !seen[target] {
+			seen[target] = true
+			targets = append(targets, target)
+		}
+	}
+	sort.Strings(targets)
+	return targets
+}
+
+// resolveLink maps a link destination found in source to a loaded document path
+func (a *Analyzer) resolveLink(source, destination string) (string, bool) {
+	u, err := url.Parse(destination)
+	if err != nil || u.Scheme != "" || u.Host != "" || u.Path == "" {
+		return "", false
+	}
+	dest := filepath.FromSlash(u.Path)
+
+	var candidates []string
+	if filepath.IsAbs(dest) {
+		candidates = append(candidates, dest)
+	} else {
+		candidates = append(candidates, filepath.Join(filepath.Dir(source), dest), dest)
+	}
+
+	for _, candidate := range candidates {
+		candidate = filepath.Clean(candidate)
+		if _, ok := a.docs[candidate]; ok {
+			return candidate, true
+		}
+
+		// Directory links point at the section's index page
+		for _, index := range []string{"_index.md", "index.md"} {
+			if _, ok := a.docs[filepath.Join(candidate, index)]; ok {
+				return filepath.Join(candidate, index), true
+			}
+		}
+	}
+
+	// Site-rooted links may name a page by its published URL or an alias
+	if strings.HasPrefix(u.Path, "/") {
+		if target, ok := a.urlIndex()[urlKey(u.Path)]; ok {
+			return target, true
+		}
+	}
+
+	return "", false
+}
+
+// resolveNote maps a wikilink note name to a loaded document path. Like
+// Obsidian, it accepts a path within TargetDir or a bare note name, matched
+// case-insensitively.
+func (a *Analyzer) resolveNote(source, name string) (string, bool) {
+	if !strings.HasSuffix(strings.ToLower(name), ".md") {
+		name += ".md"
+	}
+
+	if path := filepath.Join(a.config.TargetDir, filepath.FromSlash(name)); a.docs[path] != nil {
+		return path, true
+	}
+
+	// Prefer a note in the source's own directory when names are ambiguous
+	var matches []string
+	for path := range a.docs {
+		if strings.EqualFold(filepath.Base(path), filepath.Base(name)) {
+			matches = append(matches, path)
+		}
+	}
+	if len(matches) == 0 {
+		return "", false
+	}
+	sort.Strings(matches)
+	for _, path := range matches {
+		if filepath.Dir(path) == filepath.Dir(source) {
+			return path, true
+		}
+	}
+	return matches[0], true
+}
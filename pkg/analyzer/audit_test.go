@@ -0,0 +1,100 @@
+package analyzer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"internal-link/pkg/scorer"
+)
+
+var staleLinkFixture = map[string]string{
+	"post.md":        "Read about [kubernetes deployment strategies](old-notes.md) before you ship.\n",
+	"old-notes.md":   "Legacy cluster notes from the previous hosting provider.\n",
+	"deployments.md": "Kubernetes deployment strategies explained: rolling updates and kubernetes deployment strategies for canaries.\n",
+}
+
+func TestAuditExisting(t *testing.T) {
+	root := writeFixture(t, staleLinkFixture)
+	a := newTestAnalyzer(t, root, Config{ApplyOptions: ApplyOptions{DryRun: true}})
+
+	retargets, err := a.AuditExisting(SelectionOptions{MinScore: 0.1})
+	require.NoError(t, err)
+	require.Len(t, retargets, 1)
+
+	r := retargets[0]
+	assert.Equal(t, filepath.Join(root, "post.md"), r.SourcePath)
+	assert.Equal(t, "kubernetes deployment strategies", r.AnchorText)
+	assert.Equal(t, filepath.Join(root, "old-notes.md"), r.CurrentTarget)
+	assert.Equal(t, filepath.Join(root, "deployments.md"), r.ProposedTarget)
+	assert.Greater(t, r.ProposedScore, r.CurrentScore)
+
+	// Dry runs never rewrite links
+	require.NoError(t, a.ApplyRetargets(retargets))
+	content, err := os.ReadFile(r.SourcePath)
+	require.NoError(t, err)
+	assert.Equal(t, staleLinkFixture["post.md"], string(content))
+}
+
+func TestApplyRetargets(t *testing.T) {
+	root := writeFixture(t, staleLinkFixture)
+	a := newTestAnalyzer(t, root, Config{})
+
+	retargets, err := a.AuditExisting(SelectionOptions{MinScore: 0.1})
+	require.NoError(t, err)
+	require.NoError(t, a.ApplyRetargets(retargets))
+
+	content, err := os.ReadFile(filepath.Join(root, "post.md"))
+	require.NoError(t, err)
+	assert.Equal(t, "Read about [kubernetes deployment strategies](deployments.md) before you ship.\n", string(content))
+}
+
+func TestApplyRetargetsAfterInsertions(t *testing.T) {
+	fixture := map[string]string{
+		"post.md":   "We tuned prometheus alerting today. " + staleLinkFixture["post.md"],
+		"alerts.md": "Prometheus alerting guide for prometheus alerting rules.\n",
+	}
+	for path, content := range staleLinkFixture {
+		if path != "post.md" {
+			fixture[path] = content
+		}
+	}
+	root := writeFixture(t, fixture)
+	source := filepath.Join(root, "post.md")
+	a := newTestAnalyzer(t, root, Config{})
+
+	// Audited before the link ahead of it is inserted, shifting its span
+	retargets, err := a.AuditExisting(SelectionOptions{MinScore: 0.1, SingleFile: source})
+	require.NoError(t, err)
+	require.Len(t, retargets, 1)
+	require.NoError(t, a.ApplyChanges([]scorer.LinkSuggestion{
+		{SourcePath: source, TargetPath: filepath.Join(root, "alerts.md"), WordToLink: "prometheus alerting", Position: 9},
+	}))
+	require.NoError(t, a.ApplyRetargets(retargets))
+
+	content, err := os.ReadFile(source)
+	require.NoError(t, err)
+	assert.Equal(t, "We tuned [prometheus alerting](alerts.md) today. Read about [kubernetes deployment strategies](deployments.md) before you ship.\n", string(content))
+}
+
+func TestAuditExistingRespectsSelection(t *testing.T) {
+	fixture := map[string]string{
+		"post.md":        "---\ncategories: [ops]\n---\n" + staleLinkFixture["post.md"],
+		"old-notes.md":   "---\ncategories: [ops]\n---\n" + staleLinkFixture["old-notes.md"],
+		"deployments.md": "---\ncategories: [dev]\n---\n" + staleLinkFixture["deployments.md"],
+	}
+	root := writeFixture(t, fixture)
+	a := newTestAnalyzer(t, root, Config{})
+
+	retargets, err := a.AuditExisting(SelectionOptions{MinScore: 0.1})
+	require.NoError(t, err)
+	assert.Len(t, retargets, 1)
+
+	// The better match shares no category with the source
+	retargets, err = a.AuditExisting(SelectionOptions{MinScore: 0.1, SameCategoryOnly: true})
+	require.NoError(t, err)
+	assert.Empty(t, retargets)
+}
@@ -0,0 +1,124 @@
+package analyzer
+
+import (
+	"bytes"
+	"fmt"
+
+	"internal-link/pkg/cache"
+	"internal-link/pkg/scorer"
+)
+
+// scorerIndexArtifact names the corpus blob holding the scorer's index. It
+// is stored under the parser settings rather than a corpus fingerprint,
+// since the documents it was built from are matched one by one.
+const scorerIndexArtifact = "scorer-index"
+
+// resolvedLinksArtifact names the corpus blob mapping each document to the
+// documents its links point to
+const resolvedLinksArtifact = "links"
+
+// openCache returns the cache of the configuration, or nil to parse every
+// document on each run: without a cache directory, with NoCache, or when the
+// directory can't be created or written, which is reported as an error. A
+// corpus beneath TargetDir keeps its entries apart from other projects.
+func openCache(config Config) (*cache.Cache, error) {
+	if config.CacheDir == "" || config.NoCache {
+		return nil, nil
+	}
+	var c *cache.Cache
+	var err error
+	if config.TargetDir != "" {
+		c, err = cache.NewProjectCache(config.CacheDir, config.TargetDir)
+	} else {
+		c, err = cache.NewCache(config.CacheDir)
+	}
+	if err == nil {
+		err = c.CheckWritable()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// PruneCache deletes the cache entries of files that are no longer part of
+// the corpus beneath TargetDir, e.g. because they were deleted or excluded,
+// and returns how many were deleted
+func (a *Analyzer) PruneCache() (int, error) {
+	if a.cache == nil || a.config.TargetDir == "" {
+		return 0, nil
+	}
+
+	var paths []string
+	if a.loaded {
+		for path := range a.docs {
+			paths = append(paths, path)
+		}
+	} else {
+		var err error
+		if paths, err = a.findDocuments(); err != nil {
+			return 0, fmt.Errorf("failed to find documents: %w", err)
+		}
+	}
+
+	pruned, err := a.cache.Prune(paths)
+	if err != nil {
+		return pruned, fmt.Errorf("failed to prune cache: %w", err)
+	}
+	return pruned, nil
+}
+
+// flushCache writes the cache entries set or removed since the last flush
+func (a *Analyzer) flushCache() error {
+	if a.cache == nil {
+		return nil
+	}
+	if err := a.cache.Flush(); err != nil {
+		return fmt.Errorf("failed to write cache: %w", err)
+	}
+	return nil
+}
+
+// loadIndex seeds the scorer with the index saved by the last run with the
+// same parser settings, if any. An unreadable index is rebuilt.
+func (a *Analyzer) loadIndex() error {
+	indexer, ok := a.scorer.(scorer.Indexer)
+	if a.cache == nil || !ok {
+		return nil
+	}
+	var data []byte
+	found, err := a.cache.GetCorpus(scorerIndexArtifact, a.parser.CacheKey(), &data)
+	if err != nil || !found {
+		return err
+	}
+	idx, err := scorer.LoadIndex(bytes.NewReader(data))
+	if err != nil {
+		fmt.Fprintf(a.config.Log, "Warning: rebuilding the scorer index: %v\n", err)
+		return nil
+	}
+	a.index = idx
+	indexer.LoadIndex(idx)
+	return nil
+}
+
+// saveIndex stores the scorer's index of the loaded corpus for the next
+// run, unless it is the one loaded
+func (a *Analyzer) saveIndex() error {
+	indexer, ok := a.scorer.(scorer.Indexer)
+	if a.cache == nil || !ok {
+		return nil
+	}
+	idx := indexer.Index()
+	if idx == a.index {
+		return nil
+	}
+	var buf bytes.Buffer
+	if err := idx.Save(&buf); err != nil {
+		return err
+	}
+	if err := a.cache.SetCorpus(scorerIndexArtifact, a.parser.CacheKey(), buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to cache %s: %w", scorerIndexArtifact, err)
+	}
+	a.index = idx
+	return nil
+}
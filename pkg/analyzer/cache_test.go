@@ -0,0 +1,189 @@
+package analyzer
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"internal-link/pkg/cache"
+	"internal-link/pkg/markdown"
+)
+
+func TestAnalyzePrunesCache(t *testing.T) {
+	root := writeFixture(t, map[string]string{
+		"alerts.md": "prometheus alerting rules and prometheus alerting basics.\n",
+		"post.md":   "we changed prometheus alerting rules.\n",
+	})
+	a := newTestAnalyzer(t, root, Config{})
+	_, err := a.Analyze()
+	require.NoError(t, err)
+
+	project, err := cache.NewProjectCache(a.config.CacheDir, root)
+	require.NoError(t, err)
+	stats, err := project.Stats()
+	require.NoError(t, err)
+	// One entry per document, the scorer index, the resolved links and the pair scores
+	assert.Equal(t, 5, stats.Entries, "entries live in the directory's namespace")
+
+	require.NoError(t, os.Remove(filepath.Join(root, "post.md")))
+	b, err := NewAnalyzer(a.config)
+	require.NoError(t, err)
+	_, err = b.Analyze()
+	require.NoError(t, err)
+
+	stats, err = project.Stats()
+	require.NoError(t, err)
+	assert.Equal(t, 4, stats.Entries, "the deleted file's entry is pruned")
+}
+
+func TestResolvedLinksCached(t *testing.T) {
+	root := writeFixture(t, map[string]string{
+		"alerts.md": "prometheus alerting rules and prometheus alerting basics.\n",
+		"post.md":   "we changed [alerting rules](alerts.md).\n",
+	})
+	cacheDir := t.TempDir()
+	load := func() *Analyzer {
+		a, err := NewAnalyzer(Config{
+			ScoringOptions: ScoringOptions{TargetDir: root, CacheDir: cacheDir, ParserConfig: markdown.ParserConfig{MinNGram: 2, MaxNGram: 3}},
+			Log:            io.Discard,
+		})
+		require.NoError(t, err)
+		require.NoError(t, a.ensureLoaded())
+		return a
+	}
+	post := filepath.Join(root, "post.md")
+
+	a := load()
+	links, err := a.ExistingLinks()
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(root, "alerts.md")}, links[post])
+
+	// Links found again from the cache are the ones stored, not resolved anew
+	var cached map[string][]string
+	found, err := a.cache.GetCorpus(resolvedLinksArtifact, a.corpusFingerprint(), &cached)
+	require.NoError(t, err)
+	require.True(t, found)
+	cached[post] = []string{filepath.Join(root, "elsewhere.md")}
+	require.NoError(t, a.cache.SetCorpus(resolvedLinksArtifact, a.corpusFingerprint(), cached))
+	require.NoError(t, a.flushCache())
+
+	links, err = load().ExistingLinks()
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(root, "elsewhere.md")}, links[post], "unchanged corpus should reuse the cached links")
+
+	// Editing any document resolves them again
+	require.NoError(t, os.WriteFile(filepath.Join(root, "alerts.md"), []byte("prometheus alerting rules.\n"), 0644))
+	links, err = load().ExistingLinks()
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(root, "alerts.md")}, links[post], "edited corpus should recompute the links")
+}
+
+func TestScorerIndexReusedAcrossRuns(t *testing.T) {
+	root := writeFixture(t, map[string]string{
+		"alerts.md": "prometheus alerting rules and prometheus alerting basics.\n",
+		"post.md":   "we changed prometheus alerting rules.\n",
+		"loki.md":   "loki logs next to prometheus alerting rules.\n",
+	})
+	a := newTestAnalyzer(t, root, Config{SelectionOptions: SelectionOptions{MinScore: 0.1}})
+	first, err := a.Analyze()
+	require.NoError(t, err)
+	require.NotEmpty(t, first)
+
+	b, err := NewAnalyzer(a.config)
+	require.NoError(t, err)
+	second, err := b.Analyze()
+	require.NoError(t, err)
+	require.NotNil(t, b.index, "the index saved by the first run is loaded")
+	assert.Equal(t, first, second)
+
+	// After an edit the index is updated, and scores match a run without it
+	require.NoError(t, os.WriteFile(filepath.Join(root, "loki.md"), []byte("loki logs only.\n"), 0644))
+	c, err := NewAnalyzer(a.config)
+	require.NoError(t, err)
+	updated, err := c.Analyze()
+	require.NoError(t, err)
+
+	uncached := a.config
+	uncached.CacheDir = ""
+	d, err := NewAnalyzer(uncached)
+	require.NoError(t, err)
+	expected, err := d.Analyze()
+	require.NoError(t, err)
+	assert.Equal(t, expected, updated)
+}
+
+func TestUnwritableCacheDirDisablesCache(t *testing.T) {
+	root := writeFixture(t, map[string]string{
+		"alerts.md": "prometheus alerting rules and prometheus alerting basics.\n",
+		"post.md":   "we changed prometheus alerting rules.\n",
+	})
+	file := filepath.Join(t.TempDir(), "file")
+	require.NoError(t, os.WriteFile(file, nil, 0644))
+	readOnly := t.TempDir()
+	require.NoError(t, os.Chmod(readOnly, 0555))
+	t.Cleanup(func() { os.Chmod(readOnly, 0755) })
+
+	tests := []struct {
+		name     string
+		cacheDir string
+	}{
+		{name: "can't be created", cacheDir: filepath.Join(file, "cache")},
+		{name: "can't be written", cacheDir: readOnly},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.cacheDir == readOnly && os.Geteuid() == 0 {
+				t.Skip("directory permissions don't apply to root")
+			}
+			var log bytes.Buffer
+			config := Config{
+				ScoringOptions:   ScoringOptions{TargetDir: root, CacheDir: tt.cacheDir},
+				SelectionOptions: SelectionOptions{MinScore: 0.01},
+				Log:              &log,
+			}
+			for run := 0; run < 2; run++ {
+				a, err := NewAnalyzer(config)
+				require.NoError(t, err)
+				_, err = a.Analyze()
+				require.NoError(t, err)
+				assert.Equal(t, 2, a.Stats().Parsed, "nothing is read from the cache")
+			}
+			assert.Equal(t, 2, strings.Count(log.String(), "Warning: caching disabled"), "one warning per analyzer")
+		})
+	}
+}
+
+func TestNoCache(t *testing.T) {
+	root := writeFixture(t, map[string]string{
+		"alerts.md": "prometheus alerting rules and prometheus alerting basics.\n",
+		"post.md":   "we changed prometheus alerting rules.\n",
+	})
+	a := newTestAnalyzer(t, root, Config{})
+	_, err := a.Analyze()
+	require.NoError(t, err)
+
+	// The entries of the first run are neither read nor added to
+	config := a.config
+	config.NoCache = true
+	b, err := NewAnalyzer(config)
+	require.NoError(t, err)
+	_, err = b.Analyze()
+	require.NoError(t, err)
+	assert.Equal(t, 0, b.Stats().CacheHits)
+	assert.Equal(t, 2, b.Stats().Parsed)
+
+	config.CacheDir = t.TempDir()
+	c, err := NewAnalyzer(config)
+	require.NoError(t, err)
+	_, err = c.Analyze()
+	require.NoError(t, err)
+	entries, err := os.ReadDir(config.CacheDir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
@@ -0,0 +1,53 @@
+package analyzer
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// changedSince returns the files beneath TargetDir that git reports as
+// added or modified since ref, committed or not, along with untracked files
+// that aren't ignored. Deleted files are left out; they are gone from the
+// corpus anyway.
+func (a *Analyzer) changedSince(ref string) (map[string]bool, error) {
+	if a.config.TargetDir == "" {
+		return nil, fmt.Errorf("finding changed files requires a target directory")
+	}
+
+	diff, err := a.git("diff", "--name-only", "--relative", "--diff-filter=d", "-z", ref, "--")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files changed since %s: %w", ref, err)
+	}
+	untracked, err := a.git("ls-files", "--others", "--exclude-standard", "-z")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list untracked files: %w", err)
+	}
+
+	changed := make(map[string]bool)
+	for _, name := range strings.Split(diff+untracked, "\x00") {
+		if name != "" {
+			changed[filepath.Join(a.config.TargetDir, filepath.FromSlash(name))] = true
+		}
+	}
+	return changed, nil
+}
+
+// git runs a git command in TargetDir and returns its output; paths in it
+// are relative to TargetDir
+func (a *Analyzer) git(args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = a.config.TargetDir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%w: %s", err, msg)
+		}
+		return "", err
+	}
+	return string(out), nil
+}
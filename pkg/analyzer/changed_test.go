@@ -0,0 +1,73 @@
+package analyzer
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// gitFixture commits files to a new repository and returns its directory
+func gitFixture(t *testing.T, files map[string]string) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	root := writeFixture(t, files)
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "."},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "initial"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = root
+		out, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(out))
+	}
+	return root
+}
+
+func TestChangedSince(t *testing.T) {
+	root := gitFixture(t, map[string]string{
+		"alerts.md":     "# Alerts\n\nprometheus alerting rules and prometheus alerting basics.\n",
+		"post.md":       "# Post\n\nwe changed prometheus alerting rules today.\n",
+		"other.md":      "# Other\n\nmore about prometheus alerting rules.\n",
+		"removed.md":    "# Removed\n\nprometheus alerting rules, gone soon.\n",
+		"sub/nested.md": "# Nested\n\nnested prometheus alerting rules.\n",
+	})
+	require.NoError(t, os.WriteFile(filepath.Join(root, "post.md"), []byte("# Post\n\nwe changed prometheus alerting rules again.\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "sub", "draft.md"), []byte("# Draft\n\nprometheus alerting rules draft.\n"), 0644))
+	require.NoError(t, os.Remove(filepath.Join(root, "removed.md")))
+
+	// Analyzing a subdirectory of the repository works with paths relative to it
+	for _, dir := range []string{root, filepath.Join(root, "sub")} {
+		a := newTestAnalyzer(t, dir, Config{SelectionOptions: SelectionOptions{MinScore: 0.1, ChangedSince: "HEAD"}})
+		suggestions, err := a.Analyze()
+		require.NoError(t, err)
+		require.NotEmpty(t, suggestions)
+
+		for _, s := range suggestions {
+			assert.Contains(t, []string{"post.md", "sub/draft.md", "draft.md"}, a.relPath(s.SourcePath))
+			assert.NotEqual(t, "removed.md", filepath.Base(s.TargetPath))
+		}
+	}
+
+	a := newTestAnalyzer(t, root, Config{SelectionOptions: SelectionOptions{MinScore: 0.1, ChangedSince: "HEAD"}})
+	suggestions, err := a.Analyze()
+	require.NoError(t, err)
+	targets := make(map[string]bool)
+	for _, s := range suggestions {
+		targets[a.relPath(s.TargetPath)] = true
+	}
+	assert.True(t, targets["alerts.md"] || targets["other.md"], "unchanged documents remain targets")
+}
+
+func TestChangedSinceUnknownRef(t *testing.T) {
+	root := gitFixture(t, map[string]string{"post.md": "# Post\n\nprometheus alerting rules.\n"})
+	a := newTestAnalyzer(t, root, Config{SelectionOptions: SelectionOptions{MinScore: 0.1, ChangedSince: "no-such-branch"}})
+	_, err := a.Analyze()
+	assert.ErrorContains(t, err, "failed to list files changed since no-such-branch")
+}
@@ -0,0 +1,605 @@
+package analyzer
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"internal-link/pkg/markdown"
+	"internal-link/pkg/scorer"
+)
+
+// Section page handling modes
+const (
+	SectionPagesBoost   = "boost"   // Prefer section index pages as link targets
+	SectionPagesNormal  = "normal"  // Treat section index pages like any other document
+	SectionPagesExclude = "exclude" // Never suggest section index pages as link targets
+)
+
+// Repeat policies controlling how often a link may be applied across runs
+const (
+	RepeatOncePerPair   = "once-per-pair"   // Never link the same source and target twice
+	RepeatOncePerPhrase = "once-per-phrase" // Never link the same phrase twice in a source
+	RepeatUnlimited     = "unlimited"       // Don't consult the link history
+)
+
+// Score normalization modes, mapping the pair scores of each source into
+// [0,1] before MinScore applies
+const (
+	ScoreNormalizationNone    = "none"    // Raw BM25 scores
+	ScoreNormalizationMinMax  = "minmax"  // The best pair of a source scores 1, the weakest 0
+	ScoreNormalizationSoftmax = "softmax" // Shares of the source's pairs, summing to 1
+)
+
+// Link styles controlling how link destinations are written
+const (
+	LinkStyleRelative         = "relative"           // Relative to the source file's directory
+	LinkStyleAbsoluteFromRoot = "absolute-from-root" // Rooted at TargetDir, e.g. /posts/bar.md
+)
+
+// DefaultHistoryFile is the history file name used inside TargetDir
+const DefaultHistoryFile = ".internal-link-history.json"
+
+// DefaultJournalFile is the journal file name used inside CacheDir
+const DefaultJournalFile = ".internal-link-journal.json"
+
+// DefaultFrontmatterIgnoreKey is the frontmatter key opting a document out of linking
+const DefaultFrontmatterIgnoreKey = "internal_link"
+
+// DefaultMinRawScore is the raw score floor used by the command line when
+// scores are normalized
+const DefaultMinRawScore = 0.1
+
+// DefaultMinLinkDistance is the spacing between links used by the command line
+const DefaultMinLinkDistance = 200
+
+// DefaultMaxLinksPerParagraph is the paragraph link limit used by the command line
+const DefaultMaxLinksPerParagraph = 1
+
+// DefaultMaxFileSize is the file size limit used by the command line
+const DefaultMaxFileSize = 2 << 20
+
+// DefaultCandidatePool is the number of targets scored in full for each
+// source used by the command line
+const DefaultCandidatePool = 50
+
+// DefaultDuplicateSimilarity is the near-duplicate threshold used by the
+// command line when duplicates are reported without one
+const DefaultDuplicateSimilarity = 0.9
+
+// sectionBoost is the score multiplier applied to section pages in boost mode
+const sectionBoost = 1.5
+
+// backlinkBoost is the score multiplier applied with PreferBacklinks to
+// suggestions whose target already links to the source
+const backlinkBoost = 1.5
+
+// ScoringOptions control how the corpus is loaded and scored. Changing them
+// requires building a new Analyzer.
+type ScoringOptions struct {
+	TargetDir    string
+	CacheDir     string
+	NoCache      bool   // Neither read nor write the cache in CacheDir, e.g. for reproducible CI runs
+	SectionPages string // How section index pages (_index.md, index.md) are treated as targets
+	ParserConfig markdown.ParserConfig
+
+	// ScorerConfig tunes BM25 (default scorer.DefaultScorerConfig())
+	ScorerConfig *scorer.ScorerConfig
+
+	// ApproximateIDF reuses the cached score of every document pair whose
+	// contents are unchanged, even though edits elsewhere shifted the term
+	// statistics it was computed with. By default cached pair scores are
+	// only reused while the whole corpus is unchanged.
+	ApproximateIDF bool
+
+	// Roots, when set, are the directories the corpus is loaded from instead
+	// of all of TargetDir, e.g. content/posts and content/docs of content.
+	// They must lie beneath TargetDir, which paths, links and the cache are
+	// still relative to.
+	Roots []string
+
+	// ExcludeGlobs are doublestar-style patterns, relative to TargetDir, of
+	// files and directories left out of the corpus entirely
+	ExcludeGlobs []string
+
+	// NoGitignore stops .gitignore files from leaving files out of the
+	// corpus; .internal-linkignore files still apply
+	NoGitignore bool
+
+	// Overrides replace some settings for the documents beneath
+	// directories of TargetDir
+	Overrides []DirectoryOverrides
+
+	// FollowSymlinks descends into symlinked directories. Either way a file
+	// reached by several paths is only loaded once, under its real path
+	// when that lies beneath TargetDir, and symlink loops are broken.
+	FollowSymlinks bool
+
+	// Strict fails on the first file that can't be walked, read or parsed.
+	// Otherwise such files, binary ones included, are skipped with a
+	// warning, and loading only fails when no document could be loaded.
+	Strict bool
+
+	// MaxFileSize is the size in bytes above which a file is left out of the
+	// corpus without being read, such as a large generated page (0 = unlimited)
+	MaxFileSize int64
+
+	// Concurrency is how many documents are loaded and analyzed in parallel
+	// (default runtime.NumCPU())
+	Concurrency int
+
+	// Extensions are the file extensions loaded as documents, matched
+	// case-insensitively (default [".md"]). ".mdx" files have their ESM
+	// statements and JSX tags left out of the analysis.
+	Extensions []string
+
+	// FrontmatterIgnoreKey names the frontmatter key that opts a document
+	// out of linking with "key: false" or "key_ignore: true" (default
+	// internal_link)
+	FrontmatterIgnoreKey string
+
+	// DuplicateSimilarity collapses near-duplicate documents, such as stubs
+	// or old revisions of a page: documents whose terms overlap at least this
+	// much (Jaccard similarity, 0 to 1) form a cluster of which only the
+	// canonical member is suggested as a target (0 = off). The canonical
+	// member is the one marked "canonical: true" in its frontmatter, or else
+	// the most recently modified.
+	DuplicateSimilarity float64
+}
+
+// SelectionOptions control which scored pairs become suggestions. They can
+// be overridden per call with AnalyzeWith without reloading the corpus.
+type SelectionOptions struct {
+	MinScore float64
+
+	// MinScorePercentile replaces MinScore, including the thresholds of
+	// directory overrides, with the score the best N percent of all scored
+	// pairs reach (0 = off). Every pair is scored before any is selected.
+	// Top keeps only the N best scoring suggestions overall (0 = unlimited).
+	// Stats reports the threshold either translated to.
+	MinScorePercentile float64
+	Top                int
+
+	// ScoreNormalization maps the scores of each source's pairs into [0,1]
+	// before MinScore applies, so one threshold suits corpora of any size
+	// (default none). MinScore then compares a pair to the source's other
+	// pairs; MinRawScore is the boosted BM25 score a pair needs regardless,
+	// so a source with only weak matches gets none.
+	ScoreNormalization string
+	MinRawScore        float64
+
+	// SingleFile limits the analysis to one source. A file that isn't part
+	// of the corpus, such as a draft, is parsed on the fly and only scored
+	// as a source, never offered as a target.
+	SingleFile   string
+	RepeatPolicy string // How links recorded in the history limit new suggestions
+
+	// ChangedSince limits the sources to the documents git reports as added
+	// or modified since this ref, untracked ones included; every document
+	// remains a target. TargetDir must lie in a git work tree.
+	ChangedSince string
+
+	// SourceInclude and TargetInclude are patterns, relative to TargetDir and
+	// matched like ExcludeGlobs, limiting the documents analyzed as sources
+	// and those offered as link targets, e.g. targets "docs/**" to only link
+	// into the docs. A pattern matching a directory includes everything
+	// beneath it. Documents left out still count towards scoring, excluded
+	// ones are never part of the corpus, and SingleFile is analyzed either
+	// way. Unset means every document.
+	SourceInclude []string
+	TargetInclude []string
+
+	// AllowDuplicateTargets keeps suggestions for targets the source already links to
+	AllowDuplicateTargets bool
+
+	// AllowRepeatAfter lets a source link a target it already links to once
+	// more, where every existing link to the target ends more than this many
+	// bytes before the phrase (0 = never)
+	AllowRepeatAfter int
+
+	// ShowSuppressed keeps the suggestions left out because the source
+	// already links their target, marked with LinkSuggestion.Suppressed, to
+	// audit that filter. They are never applied and don't count towards the
+	// link limits.
+	ShowSuppressed bool
+
+	// PreferBacklinks boosts suggestions that would turn a one-way link into
+	// a pair, i.e. whose target already links to the source
+	PreferBacklinks bool
+
+	// TagBoost multiplies the score of pairs whose frontmatter shares at
+	// least one tag, compared case-insensitively (0 or 1 for none)
+	TagBoost float64
+
+	// SameCategoryOnly only suggests links between documents sharing a
+	// frontmatter category, so documents without one get no suggestions
+	SameCategoryOnly bool
+
+	// CandidatesPerTarget is how many distinct phrases are suggested for
+	// each target, as separate suggestions with the same score, for a
+	// reviewer to pick from (default 1). The best is preferred, and only one
+	// suggestion per pair is ever applied. The link limits below only count
+	// the preferred phrases.
+	CandidatesPerTarget int
+
+	// CandidatePool, with a scorer implementing scorer.CandidateRanker, only
+	// scores the targets of each source with the highest upper bound on
+	// their boosted score in full, among those it may link to, as a shortcut
+	// for very large corpora (0 = every target sharing a term with the
+	// source). This is an approximation: the
+	// bound is loose, so a target left out may still have scored better than
+	// one kept, though never more than the bounds of the pool. Scores are
+	// normalized among the pool.
+	CandidatePool int
+
+	// AnchorWeights decide which phrase of the source links to a target
+	// (default DefaultAnchorWeights())
+	AnchorWeights *AnchorWeights
+
+	// MaxLinksPerFile keeps only the best scoring suggestions of each source (0 = unlimited)
+	MaxLinksPerFile int
+
+	// IntroLength is how many bytes at the start of a document body form its
+	// introduction, typically already linked by hand. A phrase is only linked
+	// there when it occurs nowhere later in the document (0 = no preference).
+	IntroLength int
+
+	// MinLinkDistance is the least number of bytes between an inserted link
+	// and any other link in the document. Suggestions closer than that to an
+	// existing link or a better scoring suggestion are dropped (0 = no minimum).
+	MinLinkDistance int
+
+	// MaxLinksPerParagraph keeps only the best scoring suggestions of each
+	// paragraph, counting the links it already has (0 = unlimited)
+	MaxLinksPerParagraph int
+
+	// Explain attaches a breakdown of its score by matched term to each
+	// suggestion. Only pairs that reach MinScore are explained.
+	Explain bool
+
+	// SectionAnchors links to the heading of the target that best matches
+	// the linked phrase, e.g. other-doc.md#configuration-options
+	SectionAnchors bool
+
+	// PhraseBlacklist lists phrases never used as link text. When
+	// PhraseWhitelist is set, only its phrases are. Both match the phrases
+	// after normalization, so case, stop words and stemming don't matter,
+	// and neither changes how document pairs score.
+	PhraseBlacklist []string
+	PhraseWhitelist []string
+}
+
+// ApplyOptions control how suggestions are written back to the documents
+type ApplyOptions struct {
+	DryRun         bool
+	Backup         bool   // Keep a .bak copy of every modified file
+	BestEffort     bool   // Apply the suggestions that still fit and skip the others, instead of writing nothing when any does not
+	SectionLinkDir bool   // Link to a section's directory instead of its index file
+	HistoryFile    string // Where applied links are remembered (default TargetDir/.internal-link-history.json, in memory only without TargetDir)
+	JournalFile    string // Where inserted links are recorded for Undo (default CacheDir/.internal-link-journal.json, in memory only without CacheDir)
+	LinkStyle      string // How link destinations are written (default relative)
+
+	// AssumeDir is the directory a SingleFile outside TargetDir will
+	// eventually live in, relative to TargetDir unless absolute. Relative
+	// links of that file are written, and its existing ones resolved, from
+	// there (default its own directory).
+	AssumeDir string
+
+	// URLTemplate, when set, writes links to published URLs instead of files.
+	// {slug} is replaced by the target's slug (default: file name without
+	// extension) and {dir} by its directory relative to TargetDir.
+	URLTemplate string
+
+	// LinkTemplate, when set, is a text/template rendering the markup of
+	// every inserted link from a LinkTemplateData, replacing the link
+	// format, e.g. <a href="{{.Target}}">{{.Phrase}}</a>. Set
+	// ParserConfig.LinkedPattern to match the markup it writes, so the
+	// links it inserted are recognized on the next run.
+	LinkTemplate string
+}
+
+// Config holds the analyzer configuration
+type Config struct {
+	ScoringOptions
+	SelectionOptions
+	ApplyOptions
+
+	// Log receives progress and informational messages (default os.Stderr)
+	Log io.Writer
+
+	// OnProgress, when set, is called as each document is loaded (PhaseLoad)
+	// and analyzed (PhaseAnalyze), with the number done out of total. It is
+	// never called concurrently.
+	OnProgress func(phase string, done, total int)
+}
+
+// FlatConfig is the original single-level configuration layout, kept so
+// existing callers can migrate at their own pace
+type FlatConfig struct {
+	MinScore       float64
+	DryRun         bool
+	SingleFile     string
+	TargetDir      string
+	CacheDir       string
+	SectionPages   string
+	SectionLinkDir bool
+	HistoryFile    string
+	RepeatPolicy   string
+	ParserConfig   markdown.ParserConfig
+}
+
+// Config converts the flat layout into the grouped Config
+func (c FlatConfig) Config() Config {
+	return Config{
+		ScoringOptions: ScoringOptions{
+			TargetDir:    c.TargetDir,
+			CacheDir:     c.CacheDir,
+			SectionPages: c.SectionPages,
+			ParserConfig: c.ParserConfig,
+		},
+		SelectionOptions: SelectionOptions{
+			MinScore:     c.MinScore,
+			SingleFile:   c.SingleFile,
+			RepeatPolicy: c.RepeatPolicy,
+		},
+		ApplyOptions: ApplyOptions{
+			DryRun:         c.DryRun,
+			SectionLinkDir: c.SectionLinkDir,
+			HistoryFile:    c.HistoryFile,
+		},
+	}
+}
+
+// validate checks the scoring options and fills in defaults
+func (o *ScoringOptions) validate() error {
+	// Document paths are derived from TargetDir, so spelling it cleanly
+	// keeps them comparable to paths given elsewhere
+	if o.TargetDir != "" {
+		o.TargetDir = filepath.Clean(o.TargetDir)
+	}
+	// Cleaned into a slice of their own, leaving the caller's untouched
+	if len(o.Roots) > 0 {
+		roots := make([]string, len(o.Roots))
+		for i, root := range o.Roots {
+			if _, ok := within(o.TargetDir, root); o.TargetDir == "" || !ok {
+				return fmt.Errorf("root %s does not lie beneath the target directory %s", root, o.TargetDir)
+			}
+			roots[i] = filepath.Clean(root)
+		}
+		o.Roots = roots
+	}
+
+	switch o.SectionPages {
+	case "":
+		o.SectionPages = SectionPagesNormal
+	case SectionPagesBoost, SectionPagesNormal, SectionPagesExclude:
+	default:
+		return fmt.Errorf("invalid section pages mode %q (expected boost, normal or exclude)", o.SectionPages)
+	}
+
+	switch {
+	case o.Concurrency == 0:
+		o.Concurrency = runtime.NumCPU()
+	case o.Concurrency < 0:
+		return fmt.Errorf("invalid concurrency %d (expected a positive number)", o.Concurrency)
+	}
+
+	if o.MaxFileSize < 0 {
+		return fmt.Errorf("invalid max file size %d (must be at least 0)", o.MaxFileSize)
+	}
+
+	if o.DuplicateSimilarity < 0 || o.DuplicateSimilarity > 1 {
+		return fmt.Errorf("invalid duplicate similarity %g (expected 0 to 1)", o.DuplicateSimilarity)
+	}
+
+	if o.ScorerConfig == nil {
+		defaults := scorer.DefaultScorerConfig()
+		o.ScorerConfig = &defaults
+	}
+	if err := o.ScorerConfig.Validate(); err != nil {
+		return err
+	}
+
+	if err := validateGlobs("exclude", o.ExcludeGlobs); err != nil {
+		return err
+	}
+	if err := validateOverrides(o.Overrides); err != nil {
+		return err
+	}
+
+	if o.FrontmatterIgnoreKey == "" {
+		o.FrontmatterIgnoreKey = DefaultFrontmatterIgnoreKey
+	}
+
+	if len(o.Extensions) == 0 {
+		o.Extensions = []string{".md"}
+	}
+	extensions := make([]string, len(o.Extensions))
+	for i, ext := range o.Extensions {
+		ext = strings.ToLower(strings.TrimSpace(ext))
+		if ext == "" || ext == "." {
+			return fmt.Errorf("invalid file extension %q", o.Extensions[i])
+		}
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		extensions[i] = ext
+	}
+	o.Extensions = extensions
+
+	switch o.ParserConfig.Flavor {
+	case "":
+		o.ParserConfig.Flavor = markdown.FlavorCommonMark
+	case markdown.FlavorCommonMark, markdown.FlavorGFM:
+	default:
+		return fmt.Errorf("invalid markdown flavor %q (expected commonmark or gfm)", o.ParserConfig.Flavor)
+	}
+
+	switch o.ParserConfig.BudgetOverflow {
+	case "":
+		o.ParserConfig.BudgetOverflow = markdown.BudgetTruncate
+	case markdown.BudgetTruncate, markdown.BudgetUnigrams:
+	default:
+		return fmt.Errorf("invalid budget overflow mode %q (expected truncate or unigrams)", o.ParserConfig.BudgetOverflow)
+	}
+
+	switch o.ParserConfig.LinkFormat {
+	case "":
+		o.ParserConfig.LinkFormat = markdown.LinkFormatMarkdown
+	case markdown.LinkFormatMarkdown, markdown.LinkFormatWikilink, markdown.LinkFormatReference:
+	default:
+		return fmt.Errorf("invalid link format %q (expected markdown, wikilink or reference)", o.ParserConfig.LinkFormat)
+	}
+
+	if o.ParserConfig.MinWordLength < 0 {
+		return fmt.Errorf("invalid minimum word length %d (must be at least 0)", o.ParserConfig.MinWordLength)
+	}
+	if o.ParserConfig.ContextSize < 0 {
+		return fmt.Errorf("invalid context size %d (must be at least 0)", o.ParserConfig.ContextSize)
+	}
+
+	if o.ParserConfig.Tokenizer == nil && o.ParserConfig.TokenizerName != "" {
+		if _, ok := markdown.LookupTokenizer(o.ParserConfig.TokenizerName); !ok {
+			return fmt.Errorf("unknown tokenizer %q", o.ParserConfig.TokenizerName)
+		}
+	}
+
+	if _, err := markdown.LoadStopWords(o.ParserConfig); err != nil {
+		return err
+	}
+
+	if _, err := regexp.Compile(o.ParserConfig.LinkedPattern); err != nil {
+		return fmt.Errorf("invalid linked pattern %q: %w", o.ParserConfig.LinkedPattern, err)
+	}
+
+	return nil
+}
+
+// validate checks the selection options against the link formats in use and
+// fills in defaults
+func (o *SelectionOptions) validate(linkFormats []string) error {
+	switch o.RepeatPolicy {
+	case "":
+		o.RepeatPolicy = RepeatOncePerPair
+	case RepeatOncePerPair, RepeatOncePerPhrase, RepeatUnlimited:
+	default:
+		return fmt.Errorf("invalid repeat policy %q (expected once-per-pair, once-per-phrase or unlimited)", o.RepeatPolicy)
+	}
+
+	switch o.ScoreNormalization {
+	case "":
+		o.ScoreNormalization = ScoreNormalizationNone
+	case ScoreNormalizationNone, ScoreNormalizationMinMax, ScoreNormalizationSoftmax:
+	default:
+		return fmt.Errorf("invalid score normalization %q (expected minmax, softmax or none)", o.ScoreNormalization)
+	}
+	if o.MinRawScore < 0 {
+		return fmt.Errorf("invalid min raw score %g (expected 0 or more)", o.MinRawScore)
+	}
+	if o.MinScorePercentile < 0 || o.MinScorePercentile > 100 {
+		return fmt.Errorf("invalid min score percentile %g (expected a percentage between 0 and 100)", o.MinScorePercentile)
+	}
+	if o.Top < 0 {
+		return fmt.Errorf("invalid top %d (expected 0 or more)", o.Top)
+	}
+
+	switch {
+	case o.CandidatesPerTarget == 0:
+		o.CandidatesPerTarget = 1
+	case o.CandidatesPerTarget < 0:
+		return fmt.Errorf("invalid candidates per target %d (expected 1 or more)", o.CandidatesPerTarget)
+	}
+
+	if o.CandidatePool < 0 {
+		return fmt.Errorf("invalid candidate pool %d (expected 0 or more)", o.CandidatePool)
+	}
+
+	if o.AnchorWeights == nil {
+		defaults := DefaultAnchorWeights()
+		o.AnchorWeights = &defaults
+	}
+	if err := o.AnchorWeights.validate(); err != nil {
+		return err
+	}
+
+	if err := validateGlobs("source include", o.SourceInclude); err != nil {
+		return err
+	}
+	if err := validateGlobs("target include", o.TargetInclude); err != nil {
+		return err
+	}
+
+	if o.TagBoost < 0 {
+		return fmt.Errorf("invalid tag boost %g (expected a boost >= 0)", o.TagBoost)
+	}
+	if o.AllowRepeatAfter < 0 {
+		return fmt.Errorf("invalid allow repeat after %d (expected 0 or more)", o.AllowRepeatAfter)
+	}
+	if o.MaxLinksPerFile < 0 {
+		return fmt.Errorf("invalid max links per file %d (expected 0 or more)", o.MaxLinksPerFile)
+	}
+	if o.IntroLength < 0 {
+		return fmt.Errorf("invalid intro length %d (expected 0 or more)", o.IntroLength)
+	}
+	if o.MinLinkDistance < 0 {
+		return fmt.Errorf("invalid min link distance %d (expected 0 or more)", o.MinLinkDistance)
+	}
+	if o.MaxLinksPerParagraph < 0 {
+		return fmt.Errorf("invalid max links per paragraph %d (expected 0 or more)", o.MaxLinksPerParagraph)
+	}
+
+	for _, format := range linkFormats {
+		if o.SectionAnchors && format == markdown.LinkFormatWikilink {
+			return fmt.Errorf("section anchors are not supported with the wikilink link format")
+		}
+	}
+
+	return nil
+}
+
+// validate checks the apply options and fills in defaults
+func (o *ApplyOptions) validate(targetDir, cacheDir string) error {
+	if o.HistoryFile == "" && targetDir != "" {
+		o.HistoryFile = filepath.Join(targetDir, DefaultHistoryFile)
+	}
+	if o.JournalFile == "" && cacheDir != "" {
+		o.JournalFile = filepath.Join(cacheDir, DefaultJournalFile)
+	}
+
+	switch o.LinkStyle {
+	case "":
+		o.LinkStyle = LinkStyleRelative
+	case LinkStyleRelative, LinkStyleAbsoluteFromRoot:
+	default:
+		return fmt.Errorf("invalid link style %q (expected relative or absolute-from-root)", o.LinkStyle)
+	}
+
+	if _, err := parseLinkTemplate(o.LinkTemplate); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// validate checks the whole configuration and fills in defaults
+func (c *Config) validate() error {
+	if c.Log == nil {
+		c.Log = os.Stderr
+	}
+
+	if err := c.ScoringOptions.validate(); err != nil {
+		return err
+	}
+	formats := []string{c.ParserConfig.LinkFormat}
+	for _, o := range c.Overrides {
+		formats = append(formats, o.LinkFormat)
+	}
+	if err := c.SelectionOptions.validate(formats); err != nil {
+		return err
+	}
+	return c.ApplyOptions.validate(c.TargetDir, c.CacheDir)
+}
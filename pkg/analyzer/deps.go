@@ -0,0 +1,91 @@
+package analyzer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"internal-link/pkg/scorer"
+)
+
+// Dependency file formats
+const (
+	DepsFormatMake = "make" // Make-compatible .d rules
+	DepsFormatJSON = "json" // JSON object mapping sources to targets
+)
+
+// LinkDependencies maps every document to the sorted set of corpus documents
+// it links to, combining links already present in the files with the given
+// suggestions
+func (a *Analyzer) LinkDependencies(suggestions []scorer.LinkSuggestion) (map[string][]string, error) {
+	sets := make(map[string]map[string]bool)
+	add := func(source, target string) {
+		if sets[source] == nil {
+			sets[source] = make(map[string]bool)
+		}
+		sets[source][target] = true
+	}
+
+	existing, err := a.ExistingLinks()
+	if err != nil {
+		return nil, err
+	}
+	for source, targets := range existing {
+		for _, target := range targets {
+			add(source, target)
+		}
+	}
+
+	for _, s := range suggestions {
+		add(s.SourcePath, s.TargetPath)
+	}
+
+	deps := make(map[string][]string, len(sets))
+	for source, targets := range sets {
+		for target := range targets {
+			deps[source] = append(deps[source], target)
+		}
+		sort.Strings(deps[source])
+	}
+
+	return deps, nil
+}
+
+// WriteDeps writes link dependencies in the given format
+func WriteDeps(w io.Writer, deps map[string][]string, format string) error {
+	switch format {
+	case DepsFormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(deps)
+	case DepsFormatMake, "":
+	default:
+		return fmt.Errorf("invalid deps format %q (expected make or json)", format)
+	}
+
+	sources := make([]string, 0, len(deps))
+	for source := range deps {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+
+	for _, source := range sources {
+		targets := make([]string, len(deps[source]))
+		for i, target := range deps[source] {
+			targets[i] = escapeMakePath(target)
+		}
+		if _, err := fmt.Fprintf(w, "%s: %s\n", escapeMakePath(source), strings.Join(targets, " ")); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// escapeMakePath escapes the characters Make treats specially in rule names
+func escapeMakePath(path string) string {
+	r := strings.NewReplacer(" ", `\ `, "#", `\#`, "$", "$$", ":", `\:`)
+	return r.Replace(path)
+}
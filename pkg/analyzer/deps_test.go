@@ -0,0 +1,53 @@
+package analyzer
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var depsFixture = map[string]string{
+	"a.md":        "Read [the b page](b.md) first. we use prometheus alerting daily.\n",
+	"b.md":        "Prometheus alerting guide with grafana dashboards.\n",
+	"my notes.md": "grafana dashboards everywhere.\n",
+}
+
+func TestWriteDepsGolden(t *testing.T) {
+	for _, format := range []string{DepsFormatMake, DepsFormatJSON} {
+		t.Run(format, func(t *testing.T) {
+			root := writeFixture(t, depsFixture)
+			a := newTestAnalyzer(t, root, Config{
+				SelectionOptions: SelectionOptions{MinScore: 0.1},
+				ApplyOptions:     ApplyOptions{DryRun: true},
+			})
+
+			suggestions, err := a.Analyze()
+			require.NoError(t, err)
+
+			deps, err := a.LinkDependencies(suggestions)
+			require.NoError(t, err)
+
+			var buf bytes.Buffer
+			require.NoError(t, WriteDeps(&buf, deps, format))
+
+			golden := map[string]string{DepsFormatMake: "deps.d", DepsFormatJSON: "deps.json"}[format]
+			expected, err := os.ReadFile(filepath.Join("testdata", golden))
+			require.NoError(t, err)
+
+			// The fixture lives in a temporary directory, so compare relative paths
+			actual := strings.ReplaceAll(buf.String(), filepath.ToSlash(root)+"/", "")
+			actual = strings.ReplaceAll(actual, escapeMakePath(filepath.ToSlash(root))+"/", "")
+			assert.Equal(t, string(expected), actual)
+		})
+	}
+}
+
+func TestWriteDepsInvalidFormat(t *testing.T) {
+	var buf bytes.Buffer
+	assert.Error(t, WriteDeps(&buf, nil, "ninja"))
+}
@@ -0,0 +1,134 @@
+package analyzer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"internal-link/pkg/scorer"
+)
+
+// sourceDocument returns the document SingleFile names: the loaded
+// document at that path, however it is spelled, or otherwise the file
+// parsed on the fly as an external document
+func (a *Analyzer) sourceDocument(path string) (*scorer.Document, error) {
+	if doc, ok := a.docs[path]; ok {
+		return doc, nil
+	}
+	corpusPath := a.corpusPath(path)
+	if doc, ok := a.docs[corpusPath]; ok {
+		return doc, nil
+	}
+	if a.foldCase {
+		for docPath, doc := range a.docs {
+			if strings.EqualFold(docPath, corpusPath) {
+				return doc, nil
+			}
+		}
+	}
+	return a.externalDocument(path)
+}
+
+// corpusPath spells path the way documents beneath TargetDir are keyed,
+// which is the path cleaned when it lies outside TargetDir. Like the walk,
+// it names a file that really lies beneath TargetDir by that location, even
+// when path reaches it through a symlink.
+func (a *Analyzer) corpusPath(path string) string {
+	path = filepath.Clean(path)
+	if a.config.TargetDir == "" {
+		return path
+	}
+	if real, err := filepath.EvalSymlinks(path); err == nil {
+		if realRoot, err := filepath.EvalSymlinks(a.config.TargetDir); err == nil {
+			if rel, ok := within(realRoot, real); ok {
+				return filepath.Join(a.config.TargetDir, rel)
+			}
+		}
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	root, err := filepath.Abs(a.config.TargetDir)
+	if err != nil {
+		return path
+	}
+	rel, ok := within(root, abs)
+	if !ok {
+		return path
+	}
+	return filepath.Join(a.config.TargetDir, rel)
+}
+
+// within returns path relative to dir, and false if it lies outside of dir
+func within(dir, path string) (string, bool) {
+	if d, err := filepath.Abs(dir); err == nil {
+		dir = d
+	}
+	if p, err := filepath.Abs(path); err == nil {
+		path = p
+	}
+	rel, err := filepath.Rel(dir, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return rel, true
+}
+
+// externalDocument parses a file that isn't part of the corpus, such as a
+// draft written elsewhere, to be analyzed as a source only. It is never
+// registered with the scorer, so it is never offered as a target, and its
+// links are resolved from where it will live (see linkBase).
+func (a *Analyzer) externalDocument(path string) (*scorer.Document, error) {
+	content, err := a.readDocument(path)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("file %s not found", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %s: %w", path, err)
+	}
+
+	result := a.parseDocument(path, content, false)
+	if result.err != nil {
+		return nil, result.err
+	}
+	if result.budgetErr != nil {
+		fmt.Fprintf(a.config.Log, "Warning: %s: %v\n", path, result.budgetErr)
+	}
+	result.doc.Links = a.linkedTargets(a.linkBase(path), result.links)
+	return result.doc, nil
+}
+
+// AnalyzeDraft generates link suggestions for a document held in memory,
+// such as one read from standard input, against the corpus beneath
+// TargetDir. Like a SingleFile outside TargetDir, the draft is only a
+// source, and its links are written from AssumeDir; path only names it. Its
+// content is kept, so ApplyChangesToContent can insert the links.
+func (a *Analyzer) AnalyzeDraft(path string, content []byte) ([]scorer.LinkSuggestion, error) {
+	if err := a.ensureLoaded(); err != nil {
+		return nil, err
+	}
+	if _, ok := a.docs[a.corpusPath(path)]; ok {
+		return nil, fmt.Errorf("document %s is part of the corpus", path)
+	}
+	a.contents[path] = content
+
+	selection := a.config.SelectionOptions
+	selection.SingleFile = path
+	return a.analyze(selection)
+}
+
+// linkBase returns the path relative links of source are written from. For
+// a document outside the corpus that is its file name within AssumeDir,
+// where it will eventually live, if set; otherwise source itself.
+func (a *Analyzer) linkBase(source string) string {
+	if _, ok := a.docs[source]; ok || a.config.AssumeDir == "" {
+		return source
+	}
+	dir := a.config.AssumeDir
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(a.config.TargetDir, dir)
+	}
+	return filepath.Join(dir, filepath.Base(source))
+}
@@ -0,0 +1,117 @@
+package analyzer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var draftCorpus = map[string]string{
+	"docs/monitoring/alerts.md": "Prometheus alerting covers grafana dashboards.\n",
+	"posts/intro.md":            "Welcome to the blog about kubernetes clusters.\n",
+}
+
+func TestAnalyzeExternalDraft(t *testing.T) {
+	root := writeFixture(t, draftCorpus)
+	draft := filepath.Join(t.TempDir(), "draft.md")
+	require.NoError(t, os.WriteFile(draft, []byte("Our new prometheus alerting setup.\n"), 0644))
+
+	tests := []struct {
+		name         string
+		assumeDir    string
+		expectedLink string
+	}{
+		{name: "assumed directory", assumeDir: "posts", expectedLink: "../docs/monitoring/alerts.md"},
+		{name: "absolute assumed directory", assumeDir: filepath.Join(root, "docs"), expectedLink: "monitoring/alerts.md"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestAnalyzer(t, root, Config{
+				SelectionOptions: SelectionOptions{MinScore: 0.1, SingleFile: draft},
+				ApplyOptions:     ApplyOptions{AssumeDir: tt.assumeDir},
+			})
+
+			suggestions, err := a.Analyze()
+			require.NoError(t, err)
+			require.Len(t, suggestions, 1)
+			assert.Equal(t, draft, suggestions[0].SourcePath)
+			assert.Equal(t, filepath.Join(root, "docs", "monitoring", "alerts.md"), suggestions[0].TargetPath)
+
+			edits, err := a.ComputeEdits(suggestions)
+			require.NoError(t, err)
+			require.Len(t, edits, 1)
+			assert.Equal(t, "[prometheus alerting]("+tt.expectedLink+")", edits[0].InsertText)
+
+			// The draft never joins the corpus, so it is never a target
+			assert.NotContains(t, a.docs, draft)
+			all, err := a.AnalyzeWith(SelectionOptions{MinScore: 0.01})
+			require.NoError(t, err)
+			for _, s := range all {
+				assert.NotEqual(t, draft, s.TargetPath)
+			}
+		})
+	}
+}
+
+func TestExternalDraftLinksResolvedFromAssumedDir(t *testing.T) {
+	root := writeFixture(t, draftCorpus)
+	draft := filepath.Join(t.TempDir(), "draft.md")
+	require.NoError(t, os.WriteFile(draft, []byte("Our new prometheus alerting setup, see [alerts](../docs/monitoring/alerts.md).\n"), 0644))
+
+	a := newTestAnalyzer(t, root, Config{
+		SelectionOptions: SelectionOptions{MinScore: 0.1, SingleFile: draft},
+		ApplyOptions:     ApplyOptions{AssumeDir: "posts"},
+	})
+	suggestions, err := a.Analyze()
+	require.NoError(t, err)
+	assert.Empty(t, suggestions, "the draft already links to the target")
+}
+
+func TestSingleFileInCorpusSpelledDifferently(t *testing.T) {
+	root := writeFixture(t, hugoFixture)
+	a := newTestAnalyzer(t, root, Config{
+		SelectionOptions: SelectionOptions{MinScore: 0.1, SingleFile: filepath.Join(root, "posts", ".", "setup.md")},
+	})
+
+	suggestions, err := a.Analyze()
+	require.NoError(t, err)
+	require.Len(t, suggestions, 1)
+	assert.Equal(t, filepath.Join(root, "posts", "setup.md"), suggestions[0].SourcePath)
+}
+
+func TestSingleFileNotFound(t *testing.T) {
+	a := newTestAnalyzer(t, writeFixture(t, hugoFixture), Config{
+		SelectionOptions: SelectionOptions{MinScore: 0.1, SingleFile: filepath.Join(t.TempDir(), "missing.md")},
+	})
+	_, err := a.Analyze()
+	assert.ErrorContains(t, err, "not found")
+}
+
+func TestAnalyzeDraftContent(t *testing.T) {
+	root := writeFixture(t, draftCorpus)
+	a := newTestAnalyzer(t, root, Config{
+		SelectionOptions: SelectionOptions{MinScore: 0.1},
+		ApplyOptions:     ApplyOptions{AssumeDir: "posts"},
+	})
+
+	// The draft exists only in memory, as when read from standard input
+	draft := []byte("Our new prometheus alerting setup.\n")
+	suggestions, err := a.AnalyzeDraft("<stdin>", draft)
+	require.NoError(t, err)
+	require.Len(t, suggestions, 1)
+	assert.Equal(t, "<stdin>", suggestions[0].SourcePath)
+	assert.Equal(t, filepath.Join(root, "docs", "monitoring", "alerts.md"), suggestions[0].TargetPath)
+	assert.NotContains(t, a.docs, "<stdin>")
+
+	changed, err := a.ApplyChangesToContent(suggestions)
+	require.NoError(t, err)
+	assert.Equal(t, "Our new [prometheus alerting](../docs/monitoring/alerts.md) setup.\n", string(changed["<stdin>"]))
+
+	// Documents of the corpus are analyzed with SingleFile instead
+	_, err = a.AnalyzeDraft(filepath.Join(root, "posts", "intro.md"), draft)
+	assert.ErrorContains(t, err, "is part of the corpus")
+}
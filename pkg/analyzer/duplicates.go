@@ -0,0 +1,160 @@
+package analyzer
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"internal-link/pkg/scorer"
+)
+
+// DuplicateCluster is a group of near-duplicate documents, of which only
+// the canonical one is suggested as a target
+type DuplicateCluster struct {
+	Canonical  string   `json:"canonical"`
+	Duplicates []string `json:"duplicates"` // The other members, sorted
+}
+
+// Duplicates returns the clusters of near-duplicate documents found with
+// ScoringOptions.DuplicateSimilarity, sorted by canonical path
+func (a *Analyzer) Duplicates() ([]DuplicateCluster, error) {
+	if err := a.ensureLoaded(); err != nil {
+		return nil, err
+	}
+	if err := a.resolveDuplicates(); err != nil {
+		return nil, err
+	}
+	return a.clusters, nil
+}
+
+// resolveDuplicates clusters the near-duplicate documents and picks the
+// canonical member of each cluster, once documents changed since the last time
+func (a *Analyzer) resolveDuplicates() error {
+	if !a.duplicatesStale || a.config.DuplicateSimilarity == 0 {
+		return nil
+	}
+
+	// Clusters only depend on the documents' terms, while the newest member
+	// may change without them, so only the clusters are cached
+	var groups [][]string
+	name := fmt.Sprintf("duplicates-%g", a.config.DuplicateSimilarity)
+	if err := a.corpusArtifact(name, &groups, func() error {
+		groups = clusterDuplicates(a.docs, a.config.DuplicateSimilarity)
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	a.duplicates = make(map[string]string)
+	a.clusters = nil
+	for _, members := range groups {
+		canonical := a.canonicalMember(members)
+		cluster := DuplicateCluster{Canonical: canonical}
+		for _, path := range members {
+			if path != canonical {
+				a.duplicates[path] = canonical
+				cluster.Duplicates = append(cluster.Duplicates, path)
+			}
+		}
+		a.clusters = append(a.clusters, cluster)
+	}
+	sort.Slice(a.clusters, func(i, j int) bool { return a.clusters[i].Canonical < a.clusters[j].Canonical })
+	a.duplicatesStale = false
+	return nil
+}
+
+// canonicalMember returns the member of a cluster marked canonical in its
+// frontmatter, or else the most recently modified one. Ties go to the first
+// path, so the choice is stable.
+func (a *Analyzer) canonicalMember(members []string) string {
+	best, bestMarked, bestTime := "", false, time.Time{}
+	for _, path := range members {
+		doc := a.docs[path]
+		marked := doc != nil && doc.Canonical
+		var modified time.Time
+		if _, inMemory := a.contents[path]; !inMemory {
+			if info, err := os.Stat(path); err == nil {
+				modified = info.ModTime()
+			}
+		}
+		if best == "" || marked && !bestMarked || marked == bestMarked && modified.After(bestTime) {
+			best, bestMarked, bestTime = path, marked, modified
+		}
+	}
+	return best
+}
+
+// clusterDuplicates groups the documents whose sets of terms have a Jaccard
+// similarity of at least threshold, directly or through other members. It
+// returns the groups of more than one document, each sorted.
+func clusterDuplicates(docs map[string]*scorer.Document, threshold float64) [][]string {
+	var paths []string
+	for path, doc := range docs {
+		if !doc.Ignored && len(doc.WordFreq) > 0 {
+			paths = append(paths, path)
+		}
+	}
+	// Sets of very different sizes can't be similar, which ends the inner
+	// loop early once the documents are ordered by size
+	sort.Slice(paths, func(i, j int) bool {
+		ni, nj := len(docs[paths[i]].WordFreq), len(docs[paths[j]].WordFreq)
+		if ni != nj {
+			return ni < nj
+		}
+		return paths[i] < paths[j]
+	})
+
+	parent := make(map[string]string, len(paths))
+	var find func(string) string
+	find = func(path string) string {
+		if p, ok := parent[path]; ok && p != path {
+			root := find(p)
+			parent[path] = root
+			return root
+		}
+		return path
+	}
+
+	for i, path := range paths {
+		small := docs[path].WordFreq
+		for _, other := range paths[i+1:] {
+			large := docs[other].WordFreq
+			if float64(len(small)) < threshold*float64(len(large)) {
+				break
+			}
+			if jaccard(small, large) >= threshold {
+				if ri, rj := find(path), find(other); ri != rj {
+					parent[rj] = ri
+				}
+			}
+		}
+	}
+
+	byRoot := make(map[string][]string)
+	for _, path := range paths {
+		root := find(path)
+		byRoot[root] = append(byRoot[root], path)
+	}
+	var groups [][]string
+	for _, members := range byRoot {
+		if len(members) > 1 {
+			sort.Strings(members)
+			groups = append(groups, members)
+		}
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i][0] < groups[j][0] })
+	return groups
+}
+
+// jaccard returns the share of the terms of two documents they have in
+// common, where small has no more terms than large
+func jaccard(small, large map[string]int) float64 {
+	shared := 0
+	for term := range small {
+		if _, ok := large[term]; ok {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(small)+len(large)-shared)
+}
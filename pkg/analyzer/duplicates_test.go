@@ -0,0 +1,84 @@
+package analyzer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const alertingGuide = "Prometheus alerting guide. prometheus alerting rules page the on-call team.\n"
+
+func TestDuplicateTargetsCollapse(t *testing.T) {
+	root := writeFixture(t, map[string]string{
+		"post.md":           "we tuned prometheus alerting today.\n",
+		"alerts.md":         alertingGuide,
+		"old/alerts.md":     alertingGuide,
+		"grafana.md":        "Grafana dashboards guide.\n",
+		"old/grafana.md":    "Grafana dashboards guide, revised.\n",
+		"notes/pager.md":    "The pager on-call rotation.\n",
+		"notes/rotation.md": "Rotation of the team.\n",
+	})
+	// The copy kept around is older, so the page itself is canonical
+	past := time.Now().Add(-time.Hour)
+	require.NoError(t, os.Chtimes(filepath.Join(root, "old", "alerts.md"), past, past))
+	source := filepath.Join(root, "post.md")
+
+	a := newTestAnalyzer(t, root, Config{
+		ScoringOptions:   ScoringOptions{DuplicateSimilarity: 0.9},
+		SelectionOptions: SelectionOptions{MinScore: 0.01, SingleFile: source},
+	})
+
+	clusters, err := a.Duplicates()
+	require.NoError(t, err)
+	assert.Equal(t, []DuplicateCluster{{
+		Canonical:  filepath.Join(root, "alerts.md"),
+		Duplicates: []string{filepath.Join(root, "old", "alerts.md")},
+	}}, clusters)
+
+	suggestions, err := a.Analyze()
+	require.NoError(t, err)
+	require.Len(t, suggestions, 1)
+	assert.Equal(t, filepath.Join(root, "alerts.md"), suggestions[0].TargetPath)
+
+	// A duplicate isn't linked to its canonical member either
+	suggestions, err = a.AnalyzeWith(SelectionOptions{MinScore: 0.01, SingleFile: filepath.Join(root, "old", "alerts.md")})
+	require.NoError(t, err)
+	for _, s := range suggestions {
+		assert.NotEqual(t, filepath.Join(root, "alerts.md"), s.TargetPath)
+	}
+}
+
+func TestCanonicalFrontmatter(t *testing.T) {
+	root := writeFixture(t, map[string]string{
+		"post.md":       "we tuned prometheus alerting today.\n",
+		"alerts.md":     alertingGuide,
+		"old/alerts.md": "---\ncanonical: true\n---\n" + alertingGuide,
+	})
+	a := newTestAnalyzer(t, root, Config{
+		ScoringOptions: ScoringOptions{DuplicateSimilarity: 0.9},
+	})
+
+	clusters, err := a.Duplicates()
+	require.NoError(t, err)
+	require.Len(t, clusters, 1)
+	assert.Equal(t, filepath.Join(root, "old", "alerts.md"), clusters[0].Canonical)
+}
+
+func TestDuplicatesOff(t *testing.T) {
+	root := writeFixture(t, map[string]string{
+		"alerts.md":     alertingGuide,
+		"old/alerts.md": alertingGuide,
+	})
+	a := newTestAnalyzer(t, root, Config{})
+
+	clusters, err := a.Duplicates()
+	require.NoError(t, err)
+	assert.Empty(t, clusters)
+
+	_, err = NewAnalyzer(Config{ScoringOptions: ScoringOptions{TargetDir: root, DuplicateSimilarity: 1.5}})
+	assert.Error(t, err)
+}
@@ -0,0 +1,331 @@
+package analyzer
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"strings"
+
+	"internal-link/pkg/markdown"
+	"internal-link/pkg/scorer"
+)
+
+// Edit is a single byte-level change to a document. Edits for a file are
+// ordered from the end of the file backwards, so applying them one after
+// another leaves the offsets of the remaining edits valid.
+type Edit struct {
+	Path         string `json:"path"`
+	ByteOffset   int    `json:"byte_offset"`
+	Line         int    `json:"line"` // 1-based line of ByteOffset
+	Col          int    `json:"col"`  // 1-based byte column of ByteOffset
+	DeleteLen    int    `json:"delete_len"`
+	InsertText   string `json:"insert_text"`
+	SuggestionID string `json:"suggestion_id"` // Empty for edits adding reference definitions
+}
+
+// plannedEdit ties an edit to the suggestion it implements. In the reference
+// link format, the definitions the links need are edits of their own.
+type plannedEdit struct {
+	edit       Edit
+	suggestion scorer.LinkSuggestion
+	definition string // Reference definition added for this link, if any
+	references bool   // Adds reference definitions rather than a link
+}
+
+// ComputeEdits returns the edits ApplyChanges would perform for the given
+// suggestions, without writing anything
+func (a *Analyzer) ComputeEdits(suggestions []scorer.LinkSuggestion) ([]Edit, error) {
+	paths, byFile := groupByFile(onePerPair(unsuppressed(suggestions)))
+
+	edits := []Edit{}
+	for _, path := range paths {
+		content, err := a.readDocument(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file %s: %w", path, err)
+		}
+
+		planned, err := a.planFile(path, content, byFile[path])
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range planned {
+			edits = append(edits, p.edit)
+		}
+	}
+
+	return edits, nil
+}
+
+// PreviewLines sets the LineBefore and LineAfter of each suggestion to the
+// source line holding its phrase, as it is and with the suggestion's link
+// inserted on its own. Suppressed suggestions are never applied, so they
+// get no preview.
+func (a *Analyzer) PreviewLines(suggestions []scorer.LinkSuggestion) error {
+	type source struct {
+		content []byte
+		lines   *markdown.LineIndex
+	}
+	sources := make(map[string]source)
+	for i := range suggestions {
+		s := &suggestions[i]
+		if s.Suppressed != "" {
+			continue
+		}
+		src, ok := sources[s.SourcePath]
+		if !ok {
+			content, err := a.readDocument(s.SourcePath)
+			if err != nil {
+				return fmt.Errorf("failed to read file %s: %w", s.SourcePath, err)
+			}
+			src = source{content: content, lines: markdown.NewLineIndex(content)}
+			sources[s.SourcePath] = src
+		}
+
+		planned, err := a.planFile(s.SourcePath, src.content, []scorer.LinkSuggestion{*s})
+		if err != nil {
+			return err
+		}
+		for _, p := range planned {
+			if p.references {
+				continue
+			}
+			// A phrase may run over a line break, onto the next line
+			e := p.edit
+			start, _ := src.lines.Line(e.ByteOffset)
+			_, end := src.lines.Line(e.ByteOffset + e.DeleteLen)
+			s.LineBefore = string(src.content[start:end])
+			s.LineAfter = string(src.content[start:e.ByteOffset]) + e.InsertText + string(src.content[e.ByteOffset+e.DeleteLen:end])
+		}
+	}
+	return nil
+}
+
+// onePerPair keeps a single suggestion, the one with the lowest
+// Alternative, for each source and target offered with alternative phrases,
+// so they are never all linked. Other pairs are kept as they are.
+func onePerPair(suggestions []scorer.LinkSuggestion) []scorer.LinkSuggestion {
+	type pair struct{ source, target string }
+	best := make(map[pair]int) // Index of the suggestion kept for each pair with alternatives
+	for _, s := range suggestions {
+		if s.Alternative > 0 {
+			best[pair{s.SourcePath, s.TargetPath}] = -1
+		}
+	}
+	if len(best) == 0 {
+		return suggestions
+	}
+	for i, s := range suggestions {
+		p := pair{s.SourcePath, s.TargetPath}
+		if j, ok := best[p]; ok && (j < 0 || s.Alternative < suggestions[j].Alternative) {
+			best[p] = i
+		}
+	}
+
+	kept := make([]scorer.LinkSuggestion, 0, len(suggestions))
+	for i, s := range suggestions {
+		if j, ok := best[pair{s.SourcePath, s.TargetPath}]; !ok || j == i {
+			kept = append(kept, s)
+		}
+	}
+	return kept
+}
+
+// groupByFile groups suggestions by source file, keeping the order in which
+// files first appear
+func groupByFile(suggestions []scorer.LinkSuggestion) ([]string, map[string][]scorer.LinkSuggestion) {
+	byFile := make(map[string][]scorer.LinkSuggestion)
+	var paths []string
+	for _, suggestion := range suggestions {
+		if _, seen := byFile[suggestion.SourcePath]; !seen {
+			paths = append(paths, suggestion.SourcePath)
+		}
+		byFile[suggestion.SourcePath] = append(byFile[suggestion.SourcePath], suggestion)
+	}
+	return paths, byFile
+}
+
+// planFile turns the suggestions for one file into edits, working from the
+// end of the file backwards and skipping suggestions that overlap a link
+// planned after them
+func (a *Analyzer) planFile(path string, content []byte, suggestions []scorer.LinkSuggestion) ([]plannedEdit, error) {
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		return suggestions[i].Position > suggestions[j].Position
+	})
+
+	// Links must never land in the frontmatter, whatever the suggestion says
+	bodyStart := a.parser.FrontmatterEnd(content)
+
+	format := a.linkFormat(path)
+	var refs *markdown.References
+	if format == markdown.LinkFormatReference && a.linkTemplate == nil {
+		refs = a.parser.NewReferences(content)
+	}
+
+	// Start of the most recently planned link; spans reaching past it overlap
+	limit := len(content)
+	var planned []plannedEdit
+	for _, suggestion := range suggestions {
+		end := suggestion.Position + len(suggestion.WordToLink)
+		if end > limit {
+			fmt.Fprintf(a.config.Log, "Skipping link to %s in %s: overlaps another link\n", suggestion.TargetPath, path)
+			continue
+		}
+		if err := checkSuggestion(content, bodyStart, suggestion); err != nil {
+			return nil, fmt.Errorf("failed to insert link in %s: %w", path, err)
+		}
+		if err := markdown.CheckLinkSpan(content, suggestion.Position, end); err != nil {
+			fmt.Fprintf(a.config.Log, "Skipping link to %s in %s: %v\n", suggestion.TargetPath, path, err)
+			continue
+		}
+
+		markup := markdown.FormatLinkAs(format, suggestion.WordToLink, a.linkDestination(path, suggestion))
+		var definition string
+		if refs != nil {
+			markup, definition = refs.Link(suggestion.WordToLink, a.linkDestination(path, suggestion))
+		}
+		if a.linkTemplate != nil {
+			var err error
+			if markup, err = a.templateLink(path, suggestion); err != nil {
+				return nil, fmt.Errorf("failed to insert link in %s: %w", path, err)
+			}
+		}
+
+		line, col := lineCol(content, suggestion.Position)
+		planned = append(planned, plannedEdit{
+			edit: Edit{
+				Path:         path,
+				ByteOffset:   suggestion.Position,
+				Line:         line,
+				Col:          col,
+				DeleteLen:    len(suggestion.WordToLink),
+				InsertText:   markup,
+				SuggestionID: a.suggestionID(suggestion),
+			},
+			suggestion: suggestion,
+			definition: definition,
+		})
+		limit = suggestion.Position
+	}
+
+	if refs == nil {
+		return planned, nil
+	}
+
+	// Definitions go at the end of the document, after every link
+	var definitions []plannedEdit
+	for _, insertion := range refs.Insertions(content) {
+		line, col := lineCol(content, insertion.Offset)
+		definitions = append(definitions, plannedEdit{
+			edit: Edit{
+				Path:       path,
+				ByteOffset: insertion.Offset,
+				Line:       line,
+				Col:        col,
+				InsertText: insertion.Text,
+			},
+			references: true,
+		})
+	}
+	return append(definitions, planned...), nil
+}
+
+// checkSuggestion reports why the link of a suggestion can't be inserted
+// into content, whose body starts at bodyStart, if it can't
+func checkSuggestion(content []byte, bodyStart int, s scorer.LinkSuggestion) error {
+	end := s.Position + len(s.WordToLink)
+	if s.Position < 0 || end > len(content) {
+		return fmt.Errorf("position %d is outside the file", s.Position)
+	}
+	if s.Position < bodyStart {
+		return fmt.Errorf("position %d is inside the frontmatter", s.Position)
+	}
+	if string(content[s.Position:end]) != s.WordToLink {
+		return fmt.Errorf("text at position %d is not '%s'", s.Position, s.WordToLink)
+	}
+	return nil
+}
+
+// SuggestionFailure is a suggestion whose link could not be inserted
+type SuggestionFailure struct {
+	Suggestion scorer.LinkSuggestion
+	Reason     string
+}
+
+// ApplyError reports the suggestions ApplyChanges found it could not apply.
+// No file is written when it is returned; with BestEffort the failures are
+// logged and the other suggestions applied instead.
+type ApplyError struct {
+	Failures []SuggestionFailure
+}
+
+func (e *ApplyError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d suggestion(s) can't be applied, no files were changed:", len(e.Failures))
+	for _, f := range e.Failures {
+		where := f.Suggestion.Location
+		if where == "" {
+			where = f.Suggestion.SourcePath
+		}
+		fmt.Fprintf(&b, "\n  %s: link to %s: %s", where, f.Suggestion.TargetPath, f.Reason)
+	}
+	return b.String()
+}
+
+// validSuggestions splits the suggestions for one file into those whose
+// links can be inserted into its content and those that can't
+func (a *Analyzer) validSuggestions(content []byte, suggestions []scorer.LinkSuggestion) ([]scorer.LinkSuggestion, []SuggestionFailure) {
+	bodyStart := a.parser.FrontmatterEnd(content)
+	var valid []scorer.LinkSuggestion
+	var failures []SuggestionFailure
+	for _, s := range suggestions {
+		if err := checkSuggestion(content, bodyStart, s); err != nil {
+			failures = append(failures, SuggestionFailure{Suggestion: s, Reason: err.Error()})
+			continue
+		}
+		valid = append(valid, s)
+	}
+	return valid, failures
+}
+
+// linkDestination is where the link inserted for a suggestion points,
+// including the target section if one was chosen
+func (a *Analyzer) linkDestination(source string, s scorer.LinkSuggestion) string {
+	dest := a.linkTarget(source, s.TargetPath)
+	if s.Anchor != "" {
+		dest += "#" + s.Anchor
+	}
+	return dest
+}
+
+// applyEdits applies edits in order; they must be sorted by descending offset
+func applyEdits(content []byte, planned []plannedEdit) []byte {
+	for _, p := range planned {
+		e := p.edit
+		result := make([]byte, 0, len(content)+len(e.InsertText)-e.DeleteLen)
+		result = append(result, content[:e.ByteOffset]...)
+		result = append(result, e.InsertText...)
+		result = append(result, content[e.ByteOffset+e.DeleteLen:]...)
+		content = result
+	}
+	return content
+}
+
+// lineCol converts a byte offset into a 1-based line and byte column
+func lineCol(content []byte, offset int) (int, int) {
+	before := content[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	col := offset - (bytes.LastIndexByte(before, '\n') + 1) + 1
+	return line, col
+}
+
+// suggestionID derives an identifier from what the suggestion changes, stable
+// across machines since paths are taken relative to TargetDir
+func (a *Analyzer) suggestionID(s scorer.LinkSuggestion) string {
+	key := fmt.Sprintf("%s\x00%s\x00%d\x00%s", a.relPath(s.SourcePath), a.relPath(s.TargetPath), s.Position, s.WordToLink)
+	if s.Anchor != "" {
+		key += "\x00#" + s.Anchor
+	}
+	sum := sha256.Sum256([]byte(key))
+	return fmt.Sprintf("%x", sum[:8])
+}
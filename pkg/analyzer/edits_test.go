@@ -0,0 +1,183 @@
+package analyzer
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"internal-link/pkg/markdown"
+	"internal-link/pkg/scorer"
+)
+
+const editsPost = "# Monitoring\n\nwe use prometheus alerting with grafana dashboards.\nloki logs too.\n"
+
+// editsSuggestions returns suggestions for editsPost, including one that overlaps
+func editsSuggestions(source string) []scorer.LinkSuggestion {
+	return []scorer.LinkSuggestion{
+		{SourcePath: source, TargetPath: "alerts.md", WordToLink: "prometheus alerting", Position: 21},
+		{SourcePath: source, TargetPath: "loki.md", WordToLink: "loki logs", Position: 66},
+		{SourcePath: source, TargetPath: "grafana.md", WordToLink: "grafana dashboards", Position: 46},
+		{SourcePath: source, TargetPath: "alerting.md", WordToLink: "alerting with", Position: 32},
+	}
+}
+
+func TestComputeEditsGolden(t *testing.T) {
+	root := writeFixture(t, map[string]string{"post.md": editsPost})
+	source := filepath.Join(root, "post.md")
+	a := newTestAnalyzer(t, root, Config{})
+
+	edits, err := a.ComputeEdits(editsSuggestions(source))
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetIndent("", "  ")
+	require.NoError(t, enc.Encode(edits))
+
+	expected, err := os.ReadFile(filepath.Join("testdata", "edits.json"))
+	require.NoError(t, err)
+
+	// The fixture lives in a temporary directory, so compare relative paths
+	actual := strings.ReplaceAll(buf.String(), filepath.ToSlash(root)+"/", "")
+	assert.Equal(t, string(expected), actual)
+
+	// Nothing is written while computing edits
+	content, err := os.ReadFile(source)
+	require.NoError(t, err)
+	assert.Equal(t, editsPost, string(content))
+}
+
+func TestComputeEditsMatchApplyChanges(t *testing.T) {
+	root := writeFixture(t, map[string]string{"post.md": editsPost})
+	source := filepath.Join(root, "post.md")
+	a := newTestAnalyzer(t, root, Config{})
+
+	edits, err := a.ComputeEdits(editsSuggestions(source))
+	require.NoError(t, err)
+
+	// Applying the edits sequentially must reproduce ApplyChanges
+	expected := editsPost
+	for _, e := range edits {
+		expected = expected[:e.ByteOffset] + e.InsertText + expected[e.ByteOffset+e.DeleteLen:]
+	}
+
+	require.NoError(t, a.ApplyChanges(editsSuggestions(source)))
+	content, err := os.ReadFile(source)
+	require.NoError(t, err)
+	assert.Equal(t, expected, string(content))
+}
+
+func TestNoLinksInFrontmatter(t *testing.T) {
+	post := "---\ntitle: prometheus alerting\n---\nwe use prometheus alerting daily.\n"
+	root := writeFixture(t, map[string]string{"post.md": post})
+	source := filepath.Join(root, "post.md")
+	a := newTestAnalyzer(t, root, Config{})
+
+	// The text matches, but the position is inside the metadata block
+	suggestions := []scorer.LinkSuggestion{
+		{SourcePath: source, TargetPath: "alerts.md", WordToLink: "prometheus alerting", Position: 11},
+	}
+	err := a.ApplyChanges(suggestions)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "inside the frontmatter")
+
+	content, err := os.ReadFile(source)
+	require.NoError(t, err)
+	assert.Equal(t, post, string(content))
+}
+
+func TestReferenceLinkFormat(t *testing.T) {
+	post := "we use prometheus alerting with grafana dashboards.\nprometheus alerting again, see [the docs][docs].\n\n[docs]: https://example.com/docs\n[zz]: zz.md\n"
+	root := writeFixture(t, map[string]string{"post.md": post})
+	source := filepath.Join(root, "post.md")
+	a := newTestAnalyzer(t, root, Config{
+		ScoringOptions: ScoringOptions{ParserConfig: markdown.ParserConfig{LinkFormat: markdown.LinkFormatReference}},
+	})
+
+	suggestions := []scorer.LinkSuggestion{
+		{SourcePath: source, TargetPath: filepath.Join(root, "alerts.md"), WordToLink: "prometheus alerting", Position: 7},
+		{SourcePath: source, TargetPath: filepath.Join(root, "grafana.md"), WordToLink: "grafana dashboards", Position: 32},
+		{SourcePath: source, TargetPath: filepath.Join(root, "alerts.md"), WordToLink: "prometheus alerting", Position: 52},
+	}
+	edits, err := a.ComputeEdits(suggestions)
+	require.NoError(t, err)
+	expected := post
+	for _, e := range edits {
+		expected = expected[:e.ByteOffset] + e.InsertText + expected[e.ByteOffset+e.DeleteLen:]
+	}
+
+	// The same target shares one label; definitions are merged in sorted
+	require.NoError(t, a.ApplyChanges(suggestions))
+	content, err := os.ReadFile(source)
+	require.NoError(t, err)
+	assert.Equal(t, "we use [prometheus alerting][auto-alerts] with [grafana dashboards][auto-grafana].\n"+
+		"[prometheus alerting][auto-alerts] again, see [the docs][docs].\n\n"+
+		"[auto-alerts]: alerts.md\n[auto-grafana]: grafana.md\n[docs]: https://example.com/docs\n[zz]: zz.md\n", string(content))
+	assert.Equal(t, expected, string(content))
+
+	// The links are seen on the next run, and undoing them drops the definitions
+	links, err := a.parser.FindLinks(content)
+	require.NoError(t, err)
+	require.Len(t, links, 4)
+	assert.Equal(t, "alerts.md", links[0].Destination)
+	assert.True(t, links[0].Reference)
+
+	removed, err := a.Undo(true)
+	require.NoError(t, err)
+	assert.Equal(t, 3, removed)
+	content, err = os.ReadFile(source)
+	require.NoError(t, err)
+	assert.Equal(t, post, string(content))
+}
+
+func TestPreviewLines(t *testing.T) {
+	post := "# Monitoring\n\nwe use prometheus alerting daily.\r\nloki logs too."
+	root := writeFixture(t, map[string]string{"post.md": post})
+	source := filepath.Join(root, "post.md")
+	a := newTestAnalyzer(t, root, Config{})
+
+	suggestions := []scorer.LinkSuggestion{
+		{SourcePath: source, TargetPath: filepath.Join(root, "alerts.md"), WordToLink: "prometheus alerting", Position: 21},
+		{SourcePath: source, TargetPath: filepath.Join(root, "loki.md"), WordToLink: "loki logs", Position: 49},
+		{SourcePath: source, TargetPath: filepath.Join(root, "loki.md"), WordToLink: "loki logs", Position: 49, Suppressed: "duplicate target"},
+	}
+	require.NoError(t, a.PreviewLines(suggestions))
+
+	// Lines are shown without their line break
+	assert.Equal(t, "we use prometheus alerting daily.", suggestions[0].LineBefore)
+	assert.Equal(t, "we use [prometheus alerting](alerts.md) daily.", suggestions[0].LineAfter)
+	assert.Equal(t, "loki logs too.", suggestions[1].LineBefore)
+	assert.Equal(t, "[loki logs](loki.md) too.", suggestions[1].LineAfter)
+	assert.Empty(t, suggestions[2].LineBefore)
+
+	// Previewing writes nothing
+	content, err := os.ReadFile(source)
+	require.NoError(t, err)
+	assert.Equal(t, post, string(content))
+}
+
+func TestApplyChangesAroundMarkup(t *testing.T) {
+	post := "we use **prometheus alerting** with `grafana dashboards`.\n"
+	root := writeFixture(t, map[string]string{"post.md": post})
+	source := filepath.Join(root, "post.md")
+	var log bytes.Buffer
+	a := newTestAnalyzer(t, root, Config{Log: &log})
+
+	suggestions := []scorer.LinkSuggestion{
+		{SourcePath: source, TargetPath: filepath.Join(root, "alerts.md"), WordToLink: "prometheus alerting", Position: 9},
+		{SourcePath: source, TargetPath: filepath.Join(root, "grafana.md"), WordToLink: "grafana dashboards", Position: 37},
+	}
+	require.NoError(t, a.ApplyChanges(suggestions))
+
+	// The bold phrase is linked inside its markup; the code span is left alone
+	content, err := os.ReadFile(source)
+	require.NoError(t, err)
+	assert.Equal(t, "we use **[prometheus alerting](alerts.md)** with `grafana dashboards`.\n", string(content))
+	assert.Contains(t, log.String(), "Skipping link to "+filepath.Join(root, "grafana.md")+" in "+source+": phrase is inside a code span")
+}
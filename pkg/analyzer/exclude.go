@@ -0,0 +1,77 @@
+package analyzer
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// validateGlobs checks that every pattern of the kind, such as exclude, is
+// well formed
+func validateGlobs(kind string, patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid %s pattern %q: %w", kind, pattern, err)
+		}
+	}
+	return nil
+}
+
+// excluded reports whether the slash-separated path relative to TargetDir
+// matches one of the exclude patterns. Directories also match patterns such
+// as "archive/**" that would match everything beneath them, so the walk can
+// skip them entirely.
+func excluded(patterns []string, rel string, dir bool) bool {
+	for _, pattern := range patterns {
+		// A pattern without a slash matches at any depth, like .gitignore
+		if !strings.Contains(pattern, "/") {
+			pattern = "**/" + pattern
+		}
+		if matchGlob(strings.Split(pattern, "/"), strings.Split(rel, "/")) {
+			return true
+		}
+		if dir && strings.HasSuffix(pattern, "/**") &&
+			matchGlob(strings.Split(strings.TrimSuffix(pattern, "/**"), "/"), strings.Split(rel, "/")) {
+			return true
+		}
+	}
+	return false
+}
+
+// included reports whether the slash-separated path relative to TargetDir,
+// or a directory it lies in, matches one of the include patterns, matched
+// like exclude patterns. Without patterns every path is included.
+func included(patterns []string, rel string) bool {
+	if len(patterns) == 0 || excluded(patterns, rel, false) {
+		return true
+	}
+	for dir := path.Dir(rel); dir != "." && dir != "/"; dir = path.Dir(dir) {
+		if excluded(patterns, dir, true) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchGlob matches path segments against pattern segments, where "**"
+// matches zero or more whole segments
+func matchGlob(pattern, segments []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(segments); i++ {
+				if matchGlob(pattern[1:], segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segments) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], segments[0]); !ok {
+			return false
+		}
+		pattern, segments = pattern[1:], segments[1:]
+	}
+	return len(segments) == 0
+}
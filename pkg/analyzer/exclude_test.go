@@ -0,0 +1,120 @@
+package analyzer
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExcluded(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		rel      string
+		dir      bool
+		expected bool
+	}{
+		{name: "bare name at root", patterns: []string{"CHANGELOG.md"}, rel: "CHANGELOG.md", expected: true},
+		{name: "bare name nested", patterns: []string{"CHANGELOG.md"}, rel: "pkg/tool/CHANGELOG.md", expected: true},
+		{name: "bare directory name", patterns: []string{"node_modules"}, rel: "web/node_modules", dir: true, expected: true},
+		{name: "double star directory", patterns: []string{"**/archive/**"}, rel: "docs/archive", dir: true, expected: true},
+		{name: "double star file", patterns: []string{"**/archive/**"}, rel: "docs/archive/2019/old.md", expected: true},
+		{name: "double star at root", patterns: []string{"**/archive/**"}, rel: "archive", dir: true, expected: true},
+		{name: "rooted pattern", patterns: []string{"drafts/*.md"}, rel: "drafts/idea.md", expected: true},
+		{name: "rooted pattern elsewhere", patterns: []string{"drafts/*.md"}, rel: "posts/drafts/idea.md", expected: false},
+		{name: "single star stays in segment", patterns: []string{"docs/*.md"}, rel: "docs/sub/page.md", expected: false},
+		{name: "no match", patterns: []string{"CHANGELOG.md", "**/archive/**"}, rel: "docs/guide.md", expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, excluded(tt.patterns, tt.rel, tt.dir))
+		})
+	}
+}
+
+func TestExcludeGlobsLeaveFilesOutOfCorpus(t *testing.T) {
+	root := writeFixture(t, map[string]string{
+		"guide.md":                       "Prometheus alerting guide.\n",
+		"CHANGELOG.md":                   "prometheus alerting changes.\n",
+		"docs/archive/old.md":            "prometheus alerting, the old way.\n",
+		"web/node_modules/pkg/README.md": "prometheus alerting package.\n",
+	})
+
+	a := newTestAnalyzer(t, root, Config{
+		ScoringOptions: ScoringOptions{ExcludeGlobs: []string{"CHANGELOG.md", "**/archive/**", "node_modules"}},
+	})
+	require.NoError(t, a.loadDocuments())
+
+	var paths []string
+	for path := range a.docs {
+		paths = append(paths, path)
+	}
+	assert.Equal(t, []string{filepath.Join(root, "guide.md")}, paths)
+}
+
+func TestInvalidExcludeGlob(t *testing.T) {
+	_, err := NewAnalyzer(Config{
+		ScoringOptions: ScoringOptions{CacheDir: t.TempDir(), ExcludeGlobs: []string{"[unclosed"}},
+	})
+	assert.Error(t, err)
+}
+
+func TestIncluded(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		rel      string
+		expected bool
+	}{
+		{name: "no patterns", rel: "blog/post.md", expected: true},
+		{name: "double star", patterns: []string{"docs/**"}, rel: "docs/sub/page.md", expected: true},
+		{name: "directory", patterns: []string{"docs"}, rel: "docs/sub/page.md", expected: true},
+		{name: "nested directory name", patterns: []string{"docs"}, rel: "site/docs/page.md", expected: true},
+		{name: "file", patterns: []string{"about.md"}, rel: "about.md", expected: true},
+		{name: "no match", patterns: []string{"docs/**", "about.md"}, rel: "blog/post.md", expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, included(tt.patterns, tt.rel))
+		})
+	}
+}
+
+func TestSourceAndTargetInclude(t *testing.T) {
+	root := writeFixture(t, map[string]string{
+		"docs/alerts.md":   "# Alerts\n\nprometheus alerting rules and prometheus alerting basics.\n",
+		"docs/setup.md":    "# Setup\n\nprometheus alerting rules for the setup.\n",
+		"blog/post.md":     "# Post\n\nwe tuned prometheus alerting rules today.\n",
+		"blog/other.md":    "# Other\n\nmore about prometheus alerting rules.\n",
+		"archive/draft.md": "# Draft\n\nprometheus alerting rules, archived.\n",
+	})
+
+	a := newTestAnalyzer(t, root, Config{
+		ScoringOptions:   ScoringOptions{ExcludeGlobs: []string{"archive"}},
+		SelectionOptions: SelectionOptions{MinScore: 0.01, SourceInclude: []string{"blog/**"}, TargetInclude: []string{"docs"}},
+	})
+	suggestions, err := a.Analyze()
+	require.NoError(t, err)
+	require.NotEmpty(t, suggestions)
+	for _, s := range suggestions {
+		assert.Contains(t, []string{"blog/post.md", "blog/other.md"}, a.relPath(s.SourcePath))
+		assert.Contains(t, []string{"docs/alerts.md", "docs/setup.md"}, a.relPath(s.TargetPath))
+	}
+
+	stats := a.Stats()
+	assert.Equal(t, 4, stats.Documents)
+	assert.Equal(t, 2, stats.Sources)
+	assert.Equal(t, 2, stats.Targets)
+}
+
+func TestInvalidIncludeGlob(t *testing.T) {
+	_, err := NewAnalyzer(Config{
+		ScoringOptions:   ScoringOptions{CacheDir: t.TempDir()},
+		SelectionOptions: SelectionOptions{TargetInclude: []string{"[unclosed"}},
+	})
+	assert.ErrorContains(t, err, "invalid target include pattern")
+}
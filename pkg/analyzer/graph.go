@@ -0,0 +1,84 @@
+package analyzer
+
+import (
+	"sort"
+
+	"internal-link/pkg/scorer"
+)
+
+// maxHubs is how many of the most linked-to documents LinkGraph lists as hubs
+const maxHubs = 10
+
+// LinkGraph summarizes the internal links between the documents of the corpus
+type LinkGraph struct {
+	Documents []DocumentLinks `json:"documents"` // Sorted by path
+	Orphans   []string        `json:"orphans"`   // Documents no other document links to, sorted
+	Hubs      []string        `json:"hubs"`      // The most linked-to documents, most inbound links first
+	Links     int             `json:"links"`     // Linked pairs of documents
+	Density   float64         `json:"density"`   // Links out of the n*(n-1) pairs possible
+}
+
+// DocumentLinks counts the links of one document to and from other documents
+type DocumentLinks struct {
+	Path     string `json:"path"`
+	Inbound  int    `json:"inbound"`
+	Outbound int    `json:"outbound"`
+}
+
+// LinkGraph builds the graph of the links between corpus documents, loading
+// the corpus if needed. The given suggestions count as links already made,
+// projecting the graph after they are applied; pass none for the current one.
+func (a *Analyzer) LinkGraph(suggestions []scorer.LinkSuggestion) (LinkGraph, error) {
+	if err := a.ensureLoaded(); err != nil {
+		return LinkGraph{}, err
+	}
+	deps, err := a.LinkDependencies(suggestions)
+	if err != nil {
+		return LinkGraph{}, err
+	}
+
+	counts := make(map[string]*DocumentLinks, len(a.docs))
+	for path := range a.docs {
+		counts[path] = &DocumentLinks{Path: path}
+	}
+	graph := LinkGraph{Documents: []DocumentLinks{}, Orphans: []string{}, Hubs: []string{}}
+	for source, targets := range deps {
+		for _, target := range targets {
+			from, to := counts[source], counts[target]
+			if from == nil || to == nil || source == target {
+				continue
+			}
+			from.Outbound++
+			to.Inbound++
+			graph.Links++
+		}
+	}
+
+	for _, doc := range counts {
+		graph.Documents = append(graph.Documents, *doc)
+	}
+	sort.Slice(graph.Documents, func(i, j int) bool {
+		return graph.Documents[i].Path < graph.Documents[j].Path
+	})
+	for _, doc := range graph.Documents {
+		if doc.Inbound == 0 {
+			graph.Orphans = append(graph.Orphans, doc.Path)
+		}
+	}
+
+	hubs := make([]DocumentLinks, 0, len(graph.Documents))
+	for _, doc := range graph.Documents {
+		if doc.Inbound > 0 {
+			hubs = append(hubs, doc)
+		}
+	}
+	sort.SliceStable(hubs, func(i, j int) bool { return hubs[i].Inbound > hubs[j].Inbound })
+	for i := 0; i < len(hubs) && i < maxHubs; i++ {
+		graph.Hubs = append(graph.Hubs, hubs[i].Path)
+	}
+
+	if n := len(graph.Documents); n > 1 {
+		graph.Density = float64(graph.Links) / float64(n*(n-1))
+	}
+	return graph, nil
+}
@@ -0,0 +1,55 @@
+package analyzer
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"internal-link/pkg/scorer"
+)
+
+func TestLinkGraph(t *testing.T) {
+	root := writeFixture(t, map[string]string{
+		"a.md": "# A\n\nSee [b](b.md) and [c](c.md), or [elsewhere](https://example.com).\n",
+		"b.md": "# B\n\nBack to [a](a.md), twice: [a again](a.md).\n",
+		"c.md": "# C\n\nAlso [b](b.md).\n",
+		"d.md": "# D\n\nNo links here.\n",
+	})
+	a := newTestAnalyzer(t, root, Config{SelectionOptions: SelectionOptions{MinScore: 0.1}})
+	path := func(name string) string { return filepath.Join(root, name) }
+
+	graph, err := a.LinkGraph(nil)
+	require.NoError(t, err)
+	assert.Equal(t, []DocumentLinks{
+		{Path: path("a.md"), Inbound: 1, Outbound: 2},
+		{Path: path("b.md"), Inbound: 2, Outbound: 1},
+		{Path: path("c.md"), Inbound: 1, Outbound: 1},
+		{Path: path("d.md")},
+	}, graph.Documents)
+	assert.Equal(t, []string{path("d.md")}, graph.Orphans)
+	assert.Equal(t, []string{path("b.md"), path("a.md"), path("c.md")}, graph.Hubs)
+	assert.Equal(t, 4, graph.Links)
+	assert.InDelta(t, 4.0/12, graph.Density, 1e-9)
+
+	// Suggestions count as links, but only once per pair
+	projected, err := a.LinkGraph([]scorer.LinkSuggestion{
+		{SourcePath: path("b.md"), TargetPath: path("d.md")},
+		{SourcePath: path("c.md"), TargetPath: path("d.md")},
+		{SourcePath: path("a.md"), TargetPath: path("b.md")},
+	})
+	require.NoError(t, err)
+	assert.Empty(t, projected.Orphans)
+	assert.Equal(t, 6, projected.Links)
+	assert.Equal(t, DocumentLinks{Path: path("d.md"), Inbound: 2}, projected.Documents[3])
+}
+
+func TestLinkGraphEmpty(t *testing.T) {
+	a := newTestAnalyzer(t, t.TempDir(), Config{SelectionOptions: SelectionOptions{MinScore: 0.1}})
+	graph, err := a.LinkGraph(nil)
+	require.NoError(t, err)
+	assert.Empty(t, graph.Documents)
+	assert.NotNil(t, graph.Orphans)
+	assert.Zero(t, graph.Density)
+}
@@ -0,0 +1,206 @@
+package analyzer
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Ignore files read while walking TargetDir
+const (
+	GitignoreFile          = ".gitignore"
+	InternalLinkIgnoreFile = ".internal-linkignore" // Same syntax, honored even with NoGitignore
+)
+
+// ignoreRule is one pattern of a gitignore-style file
+type ignoreRule struct {
+	segments []string // Pattern split on "/", relative to the ignore file's directory
+	negate   bool     // "!pattern" re-includes what earlier rules excluded
+	dirOnly  bool     // "pattern/" only matches directories
+}
+
+// ignoreMatcher applies the ignore files found in the directories of a walk.
+// Rules of deeper directories come later and so take precedence, and within
+// a file the last matching pattern wins, as in git.
+type ignoreMatcher struct {
+	files []string                // Ignore file names read in each directory
+	rules map[string][]ignoreRule // By absolute directory
+}
+
+// newIgnoreMatcher prepares a matcher for a walk of root. With gitignore set
+// it also reads the .gitignore files of the directories above root, up to
+// the top of the git repository root lies in.
+func newIgnoreMatcher(root string, gitignore bool) (*ignoreMatcher, error) {
+	m := &ignoreMatcher{files: []string{InternalLinkIgnoreFile}, rules: make(map[string][]ignoreRule)}
+	if !gitignore {
+		return m, nil
+	}
+	m.files = []string{GitignoreFile, InternalLinkIgnoreFile}
+
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %w", root, err)
+	}
+	var parents []string
+	if !exists(filepath.Join(abs, ".git")) {
+		for dir := filepath.Dir(abs); ; dir = filepath.Dir(dir) {
+			parents = append(parents, dir)
+			if exists(filepath.Join(dir, ".git")) {
+				break
+			}
+			if dir == filepath.Dir(dir) {
+				// Not inside a repository, so no .gitignore above root applies
+				parents = nil
+				break
+			}
+		}
+	}
+	for _, dir := range parents {
+		rules, err := readIgnoreFile(filepath.Join(dir, GitignoreFile))
+		if err != nil {
+			return nil, err
+		}
+		m.rules[dir] = rules
+	}
+	return m, nil
+}
+
+// exists reports whether path exists
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// enter reads the ignore files of a directory the walk is about to descend into
+func (m *ignoreMatcher) enter(dir string) error {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", dir, err)
+	}
+	for _, name := range m.files {
+		rules, err := readIgnoreFile(filepath.Join(abs, name))
+		if err != nil {
+			return err
+		}
+		m.rules[abs] = append(m.rules[abs], rules...)
+	}
+	return nil
+}
+
+// ignored reports whether the rules of path's ancestors leave it out
+func (m *ignoreMatcher) ignored(path string, dir bool) bool {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+
+	// Ancestors from the outermost down, so deeper rules are applied last
+	var ancestors []string
+	for d := filepath.Dir(abs); ; d = filepath.Dir(d) {
+		ancestors = append(ancestors, d)
+		if d == filepath.Dir(d) {
+			break
+		}
+	}
+
+	ignored := false
+	for i := len(ancestors) - 1; i >= 0; i-- {
+		base := ancestors[i]
+		rules := m.rules[base]
+		if len(rules) == 0 {
+			continue
+		}
+		rel, err := filepath.Rel(base, abs)
+		if err != nil {
+			continue
+		}
+		segments := strings.Split(filepath.ToSlash(rel), "/")
+		for _, rule := range rules {
+			if rule.dirOnly && !dir {
+				continue
+			}
+			if matchIgnorePattern(rule.segments, segments) {
+				ignored = !rule.negate
+			}
+		}
+	}
+	return ignored
+}
+
+// matchIgnorePattern matches path segments against a pattern. A trailing
+// "**" matches everything inside a directory but not the directory itself.
+func matchIgnorePattern(pattern, segments []string) bool {
+	n := len(pattern)
+	if n > 1 && pattern[n-1] == "**" {
+		for i := 1; i < len(segments); i++ {
+			if matchGlob(pattern[:n-1], segments[:i]) {
+				return true
+			}
+		}
+		return false
+	}
+	return matchGlob(pattern, segments)
+}
+
+// readIgnoreFile parses the gitignore-style file at path, if there is one
+func readIgnoreFile(path string) ([]ignoreRule, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ignore file %s: %w", path, err)
+	}
+
+	var rules []ignoreRule
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		if rule, ok := parseIgnoreLine(scanner.Text()); ok {
+			rules = append(rules, rule)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read ignore file %s: %w", path, err)
+	}
+	return rules, nil
+}
+
+// parseIgnoreLine turns one line of an ignore file into a rule, following
+// gitignore: # starts a comment, ! negates, a trailing / matches directories
+// only and a slash anywhere else anchors the pattern to the file's directory
+func parseIgnoreLine(line string) (ignoreRule, bool) {
+	line = strings.TrimSuffix(line, "\r")
+	// Trailing spaces are ignored unless escaped
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, `\ `) {
+		line = line[:len(line)-1]
+	}
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+
+	var rule ignoreRule
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return ignoreRule{}, false
+	}
+
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+	if !anchored {
+		line = "**/" + line
+	}
+	rule.segments = strings.Split(line, "/")
+	return rule, true
+}
@@ -0,0 +1,134 @@
+package analyzer
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseIgnoreLine(t *testing.T) {
+	tests := []struct {
+		line     string
+		expected ignoreRule
+		ok       bool
+	}{
+		{line: "", ok: false},
+		{line: "# comment", ok: false},
+		{line: "   ", ok: false},
+		{line: "build", expected: ignoreRule{segments: []string{"**", "build"}}, ok: true},
+		{line: "build/", expected: ignoreRule{segments: []string{"**", "build"}, dirOnly: true}, ok: true},
+		{line: "/build", expected: ignoreRule{segments: []string{"build"}}, ok: true},
+		{line: "docs/*.md", expected: ignoreRule{segments: []string{"docs", "*.md"}}, ok: true},
+		{line: "!keep.md", expected: ignoreRule{segments: []string{"**", "keep.md"}, negate: true}, ok: true},
+		{line: `\!literal.md`, expected: ignoreRule{segments: []string{"**", "!literal.md"}}, ok: true},
+		{line: `\#hash.md`, expected: ignoreRule{segments: []string{"**", "#hash.md"}}, ok: true},
+		{line: "trailing.md   ", expected: ignoreRule{segments: []string{"**", "trailing.md"}}, ok: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			rule, ok := parseIgnoreLine(tt.line)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.expected, rule)
+		})
+	}
+}
+
+func TestMatchIgnorePattern(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		path     string
+		expected bool
+	}{
+		{pattern: "**/build", path: "build", expected: true},
+		{pattern: "**/build", path: "site/build", expected: true},
+		{pattern: "docs/*.md", path: "docs/a.md", expected: true},
+		{pattern: "docs/*.md", path: "site/docs/a.md", expected: false},
+		{pattern: "docs/**", path: "docs/a/b.md", expected: true},
+		{pattern: "docs/**", path: "docs", expected: false},
+		{pattern: "a/**/b.md", path: "a/b.md", expected: true},
+		{pattern: "a/**/b.md", path: "a/x/y/b.md", expected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.path, func(t *testing.T) {
+			rule, ok := parseIgnoreLine(tt.pattern)
+			require.True(t, ok)
+			assert.Equal(t, tt.expected, matchIgnorePattern(rule.segments, strings.Split(tt.path, "/")))
+		})
+	}
+}
+
+func TestGitignoreLeavesFilesOutOfCorpus(t *testing.T) {
+	files := map[string]string{
+		".gitignore":           "public/\n/drafts\n*.tmp.md\nvendor/**\n!vendor/keep.md\n",
+		"guide.md":             "prometheus alerting guide.\n",
+		"scratch.tmp.md":       "prometheus alerting scratch.\n",
+		"public/index.md":      "prometheus alerting build output.\n",
+		"drafts/idea.md":       "prometheus alerting idea.\n",
+		"posts/drafts/post.md": "prometheus alerting post.\n",
+		"vendor/lib.md":        "prometheus alerting vendored.\n",
+		"vendor/keep.md":       "prometheus alerting kept.\n",
+		"notes/.gitignore":     "private.md\n",
+		"notes/private.md":     "prometheus alerting private.\n",
+		"notes/public.md":      "prometheus alerting public note.\n",
+		".internal-linkignore": "legal.md\n",
+		"legal.md":             "prometheus alerting terms.\n",
+	}
+	root := writeFixture(t, files)
+
+	tests := []struct {
+		name        string
+		noGitignore bool
+		expected    []string
+	}{
+		{
+			name:     "gitignore and internal-linkignore",
+			expected: []string{"guide.md", "notes/public.md", "posts/drafts/post.md", "vendor/keep.md"},
+		},
+		{
+			name:        "internal-linkignore only",
+			noGitignore: true,
+			expected: []string{
+				"drafts/idea.md", "guide.md", "notes/private.md", "notes/public.md", "posts/drafts/post.md",
+				"public/index.md", "scratch.tmp.md", "vendor/keep.md", "vendor/lib.md",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestAnalyzer(t, root, Config{ScoringOptions: ScoringOptions{NoGitignore: tt.noGitignore}})
+			require.NoError(t, a.loadDocuments())
+			assert.Equal(t, tt.expected, relDocPaths(a))
+		})
+	}
+}
+
+func TestGitignoreAboveTargetDir(t *testing.T) {
+	repo := writeFixture(t, map[string]string{
+		".gitignore":             "content/generated/\n",
+		"content/guide.md":       "prometheus alerting guide.\n",
+		"content/generated/a.md": "prometheus alerting generated.\n",
+	})
+	require.NoError(t, os.Mkdir(filepath.Join(repo, ".git"), 0755))
+
+	a := newTestAnalyzer(t, filepath.Join(repo, "content"), Config{})
+	require.NoError(t, a.loadDocuments())
+	assert.Equal(t, []string{"guide.md"}, relDocPaths(a))
+}
+
+// relDocPaths returns the sorted paths of the loaded documents relative to TargetDir
+func relDocPaths(a *Analyzer) []string {
+	var paths []string
+	for path := range a.docs {
+		paths = append(paths, filepath.ToSlash(a.relPath(path)))
+	}
+	sort.Strings(paths)
+	return paths
+}
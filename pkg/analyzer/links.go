@@ -0,0 +1,166 @@
+package analyzer
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/url"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"internal-link/pkg/markdown"
+	"internal-link/pkg/scorer"
+)
+
+// resolveLinks maps the links found in each document to the corpus
+// documents they point to, once documents were added since the last time.
+// They only change with the documents and the URLs the documents are
+// published under, so the map is cached for the corpus.
+func (a *Analyzer) resolveLinks() error {
+	if !a.linksStale {
+		return nil
+	}
+	// Built up front, as documents are analyzed concurrently
+	a.urlIndex()
+
+	var resolved map[string][]string
+	if err := a.corpusArtifact(a.linksArtifact(), &resolved, func() error {
+		resolved = make(map[string][]string)
+		for path := range a.docs {
+			if targets := a.linkedTargets(path, a.links[path]); len(targets) > 0 {
+				resolved[path] = targets
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	for path, doc := range a.docs {
+		doc.Links = resolved[path]
+	}
+	a.linksStale = false
+	return nil
+}
+
+// linksArtifact names the cached links of the corpus after the URL template
+// resolving site-rooted links
+func (a *Analyzer) linksArtifact() string {
+	if a.config.URLTemplate == "" {
+		return resolvedLinksArtifact
+	}
+	sum := sha256.Sum256([]byte(a.config.URLTemplate))
+	return fmt.Sprintf("%s-%x", resolvedLinksArtifact, sum[:8])
+}
+
+// ExistingLinks returns the graph of links already present in the corpus:
+// every document that links to other documents mapped to their sorted paths
+func (a *Analyzer) ExistingLinks() (map[string][]string, error) {
+	if err := a.resolveLinks(); err != nil {
+		return nil, err
+	}
+	graph := make(map[string][]string)
+	for path, doc := range a.docs {
+		if len(doc.Links) > 0 {
+			graph[path] = append([]string(nil), doc.Links...)
+		}
+	}
+	return graph, nil
+}
+
+// linksTo reports whether doc already links to target
+func linksTo(doc *scorer.Document, target string) bool {
+	i := sort.SearchStrings(doc.Links, target)
+	return i < len(doc.Links) && doc.Links[i] == target
+}
+
+// linksByTarget returns the links of source to corpus documents, grouped by
+// the document they point to
+func (a *Analyzer) linksByTarget(source string, content []byte) (map[string][]markdown.ExistingLink, error) {
+	links, err := a.parser.FindLinks(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find links in %s: %w", source, err)
+	}
+	base := a.linkBase(source)
+	byTarget := make(map[string][]markdown.ExistingLink)
+	for _, link := range links {
+		resolve := a.resolveLink
+		if link.Wikilink {
+			resolve = a.resolveNote
+		}
+		if target, ok := resolve(base, link.Destination); ok {
+			byTarget[target] = append(byTarget[target], link)
+		}
+	}
+	return byTarget, nil
+}
+
+// repeatBlockedBy returns the first of the existing links to a target that
+// rules out linking it again at position: any link after it, or ending
+// within after bytes before it
+func repeatBlockedBy(links []markdown.ExistingLink, position, after int) (markdown.ExistingLink, bool) {
+	for _, link := range links {
+		if after == 0 || position-link.End <= after {
+			return link, true
+		}
+	}
+	return markdown.ExistingLink{}, false
+}
+
+// unsuppressed leaves out the suggestions kept only to audit the filters
+func unsuppressed(suggestions []scorer.LinkSuggestion) []scorer.LinkSuggestion {
+	kept := suggestions[:0:0]
+	for _, s := range suggestions {
+		if s.Suppressed == "" {
+			kept = append(kept, s)
+		}
+	}
+	return kept
+}
+
+// urlIndex returns the documents by the paths of the URLs they are published
+// under: their permalink, when they have an explicit url or URLTemplate is
+// set, and their aliases. Aliases not starting with a slash are taken
+// relative to the document's directory.
+func (a *Analyzer) urlIndex() map[string]string {
+	if a.urls != nil {
+		return a.urls
+	}
+	a.urls = make(map[string]string)
+	paths := make([]string, 0, len(a.docs))
+	for docPath := range a.docs {
+		paths = append(paths, docPath)
+	}
+	// The first document by path wins a URL claimed by several
+	sort.Strings(paths)
+	add := func(u, docPath string) {
+		if key := urlKey(u); key != "" {
+			if _, taken := a.urls[key]; !taken {
+				a.urls[key] = docPath
+			}
+		}
+	}
+	for _, docPath := range paths {
+		doc := a.docs[docPath]
+		if doc.URL != "" || a.config.URLTemplate != "" {
+			add(a.permalink(doc), docPath)
+		}
+		for _, alias := range doc.Aliases {
+			if !strings.HasPrefix(alias, "/") && !strings.Contains(alias, "://") {
+				alias = "/" + a.relPath(filepath.Dir(docPath)) + "/" + alias
+			}
+			add(alias, docPath)
+		}
+	}
+	return a.urls
+}
+
+// urlKey is the form URLs are compared in: the path alone, cleaned, so
+// "/old-url/", "/old-url" and "https://example.com/old-url/" are the same
+func urlKey(u string) string {
+	parsed, err := url.Parse(u)
+	if err != nil || parsed.Path == "" {
+		return ""
+	}
+	return path.Clean("/" + parsed.Path)
+}
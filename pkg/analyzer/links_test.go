@@ -0,0 +1,114 @@
+package analyzer
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"internal-link/pkg/scorer"
+)
+
+func backlinkFixture() map[string]string {
+	return map[string]string{
+		"site/alerts.md": "prometheus alerting for operators, see [grafana dashboards](dashboards.md).\n",
+		"site/dashboards.md": "grafana dashboards show prometheus alerting state and " +
+			"[grafana dashboards](https://grafana.com) upstream.\n",
+		"site/post.md": "notes on prometheus alerting, linking [alerts](./alerts.md) and [[dashboards]] " +
+			"and [missing](missing.md).\n",
+	}
+}
+
+func TestExistingLinks(t *testing.T) {
+	a := newMemoryAnalyzer(t, backlinkFixture())
+
+	links, err := a.ExistingLinks()
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]string{
+		"site/alerts.md": {"site/dashboards.md"},
+		"site/post.md":   {"site/alerts.md", "site/dashboards.md"},
+	}, links)
+
+	// Documents added later are resolved too
+	require.NoError(t, a.AddDocument("site/more.md", []byte("see [post](post.md).\n")))
+	links, err = a.ExistingLinks()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"site/post.md"}, links["site/more.md"])
+}
+
+func TestBacklinks(t *testing.T) {
+	pairs := func(suggestions []scorer.LinkSuggestion) map[string]scorer.LinkSuggestion {
+		byPair := make(map[string]scorer.LinkSuggestion)
+		for _, s := range suggestions {
+			byPair[s.SourcePath+" -> "+s.TargetPath] = s
+		}
+		return byPair
+	}
+
+	a := newMemoryAnalyzer(t, backlinkFixture())
+	plain, err := a.AnalyzeWith(SelectionOptions{MinScore: 0.1})
+	require.NoError(t, err)
+	boosted, err := a.AnalyzeWith(SelectionOptions{MinScore: 0.1, PreferBacklinks: true})
+	require.NoError(t, err)
+
+	// Links already present are never suggested again
+	for pair := range pairs(plain) {
+		assert.NotContains(t, []string{
+			"site/alerts.md -> site/dashboards.md",
+			"site/post.md -> site/alerts.md",
+			"site/post.md -> site/dashboards.md",
+		}, pair)
+	}
+
+	// Linking back from dashboards to alerts completes a pair
+	back, ok := pairs(plain)["site/dashboards.md -> site/alerts.md"]
+	require.True(t, ok)
+	assert.True(t, back.Backlink)
+	assert.InDelta(t, back.Score*backlinkBoost, pairs(boosted)["site/dashboards.md -> site/alerts.md"].Score, 1e-9)
+
+	// Suggestions that don't complete a pair keep their score
+	for pair, s := range pairs(plain) {
+		if !s.Backlink {
+			assert.Equal(t, s.Score, pairs(boosted)[pair].Score, pair)
+		}
+	}
+}
+
+func TestLinksThroughAliases(t *testing.T) {
+	root := writeFixture(t, map[string]string{
+		"posts/alerts.md":     "---\naliases: [/old-alerts/]\n---\nprometheus alerting for operators.\n",
+		"posts/dashboards.md": "---\naliases: legacy-boards\n---\ngrafana dashboards show prometheus alerting state.\n",
+		"posts/setup.md":      "---\nurl: /getting-started/\n---\nsetting up grafana dashboards and prometheus alerting.\n",
+		"posts/post.md": "notes on prometheus alerting and grafana dashboards, see [alerts](/old-alerts), " +
+			"[boards](/posts/legacy-boards/) and [setup](https://example.com/getting-started/).\n",
+	})
+	a := newTestAnalyzer(t, root, Config{
+		SelectionOptions: SelectionOptions{MinScore: 0.01},
+		ApplyOptions:     ApplyOptions{URLTemplate: "/{dir}/{slug}/"},
+	})
+	suggestions, err := a.Analyze()
+	require.NoError(t, err)
+
+	// Aliases, relative ones from the page's directory, lead to the page;
+	// links to other sites never do, even under the same path
+	post := filepath.Join(root, "posts", "post.md")
+	links, err := a.ExistingLinks()
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(root, "posts", "alerts.md"), filepath.Join(root, "posts", "dashboards.md")}, links[post])
+	var targets []string
+	for _, s := range suggestions {
+		if s.SourcePath == post {
+			targets = append(targets, s.TargetPath)
+		}
+	}
+	assert.Equal(t, []string{filepath.Join(root, "posts", "setup.md")}, targets)
+
+	// The permalink itself is the page too
+	target, ok := a.resolveLink(post, "/posts/alerts/")
+	assert.True(t, ok)
+	assert.Equal(t, filepath.Join(root, "posts", "alerts.md"), target)
+	target, ok = a.resolveLink(post, "/getting-started")
+	assert.True(t, ok)
+	assert.Equal(t, filepath.Join(root, "posts", "setup.md"), target)
+}
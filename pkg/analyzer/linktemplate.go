@@ -0,0 +1,59 @@
+package analyzer
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"internal-link/pkg/scorer"
+)
+
+// LinkTemplateData is what ApplyOptions.LinkTemplate is executed with for
+// each inserted link
+type LinkTemplateData struct {
+	Phrase      string // Text being linked, as written
+	Target      string // Destination of the target document, written like in a markdown link but without the anchor
+	TargetSlug  string // Slug of the target, its frontmatter slug or file name
+	TargetTitle string // Title of the target
+	Anchor      string // Section the link points to, without "#", if any
+}
+
+// parseLinkTemplate compiles a link template, returning nil for none.
+// Fields that don't exist only fail when the template runs, so it is run
+// once on empty data.
+func parseLinkTemplate(text string) (*template.Template, error) {
+	if text == "" {
+		return nil, nil
+	}
+	t, err := template.New("link").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid link template: %w", err)
+	}
+	if err := t.Execute(io.Discard, LinkTemplateData{}); err != nil {
+		return nil, fmt.Errorf("invalid link template: %w", err)
+	}
+	return t, nil
+}
+
+// templateLink renders the link template for a suggestion in source
+func (a *Analyzer) templateLink(source string, s scorer.LinkSuggestion) (string, error) {
+	data := LinkTemplateData{
+		Phrase: s.WordToLink,
+		Target: a.linkTarget(source, s.TargetPath),
+		Anchor: s.Anchor,
+	}
+	if doc, ok := a.docs[s.TargetPath]; ok {
+		data.TargetSlug = slug(doc)
+		data.TargetTitle = doc.Title
+	} else {
+		data.TargetSlug = strings.TrimSuffix(filepath.Base(s.TargetPath), filepath.Ext(s.TargetPath))
+	}
+
+	var b strings.Builder
+	if err := a.linkTemplate.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("failed to render link template: %w", err)
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,127 @@
+package analyzer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"internal-link/pkg/markdown"
+	"internal-link/pkg/scorer"
+)
+
+func TestLinkTemplate(t *testing.T) {
+	post := "we use prometheus alerting with grafana dashboards.\n"
+	root := writeFixture(t, map[string]string{
+		"post.md":         post,
+		"alerts.md":       "---\ntitle: Alerting Rules\nslug: alerting\n---\nBody text.\n",
+		"docs/grafana.md": "---\ntitle: Grafana\n---\nBody text.\n",
+	})
+	source := filepath.Join(root, "post.md")
+
+	tests := []struct {
+		name     string
+		template string
+		expected string
+	}{
+		{
+			name:     "hugo relref",
+			template: `[{{.Phrase}}]({{"{{<"}} relref "{{.Target}}{{if .Anchor}}#{{.Anchor}}{{end}}" {{">}}"}})`,
+			expected: `we use [prometheus alerting]({{< relref "alerts.md#rules" >}}) with [grafana dashboards]({{< relref "docs/grafana.md" >}}).` + "\n",
+		},
+		{
+			name:     "html",
+			template: `<a href="/{{.TargetSlug}}/" title="{{.TargetTitle}}">{{.Phrase}}</a>`,
+			expected: `we use <a href="/alerting/" title="Alerting Rules">prometheus alerting</a> with <a href="/grafana/" title="Grafana">grafana dashboards</a>.` + "\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestAnalyzer(t, root, Config{ApplyOptions: ApplyOptions{DryRun: true, LinkTemplate: tt.template}})
+			require.NoError(t, a.loadDocuments())
+
+			changed, err := a.ApplyChangesToContent([]scorer.LinkSuggestion{
+				{SourcePath: source, TargetPath: filepath.Join(root, "alerts.md"), WordToLink: "prometheus alerting", Position: 7, Anchor: "rules"},
+				{SourcePath: source, TargetPath: filepath.Join(root, "docs", "grafana.md"), WordToLink: "grafana dashboards", Position: 32},
+			})
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, string(changed[source]))
+		})
+	}
+}
+
+func TestLinkedPattern(t *testing.T) {
+	post := `we use <a href="alerts.md">prometheus alerting</a> with grafana dashboards.` + "\n"
+	root := writeFixture(t, map[string]string{
+		"post.md":   post,
+		"alerts.md": "Body text.\n",
+	})
+	a := newTestAnalyzer(t, root, Config{ScoringOptions: ScoringOptions{ParserConfig: markdown.ParserConfig{
+		MinNGram:      2,
+		MaxNGram:      2,
+		LinkedPattern: `<a href="(?P<target>[^"]*)">(?P<phrase>[^<]*)</a>`,
+	}}})
+	require.NoError(t, a.loadDocuments())
+
+	// The custom link counts as an existing link to its target
+	require.NoError(t, a.resolveLinks())
+	assert.Equal(t, []string{filepath.Join(root, "alerts.md")}, a.docs[filepath.Join(root, "post.md")].Links)
+
+	// Its text is never linked again
+	occurrences, err := a.parser.FindOccurrences([]byte(post), 1)
+	require.NoError(t, err)
+	var words []string
+	for _, occ := range occurrences {
+		words = append(words, occ.Word)
+	}
+	assert.NotContains(t, words, "prometheus alerting")
+	assert.NotContains(t, words, "alerting with")
+	assert.Contains(t, words, "grafana dashboards")
+}
+
+func TestInvalidLinkTemplateAndPattern(t *testing.T) {
+	for _, config := range []Config{
+		{ApplyOptions: ApplyOptions{LinkTemplate: "[{{.Phrase}}]({{.Target"}},
+		{ApplyOptions: ApplyOptions{LinkTemplate: "[{{.Phrase}}]({{.Destination}})"}},
+		{ScoringOptions: ScoringOptions{ParserConfig: markdown.ParserConfig{LinkedPattern: "<a href=(["}}},
+	} {
+		config.CacheDir = t.TempDir()
+		_, err := NewAnalyzer(config)
+		assert.Error(t, err)
+	}
+}
+
+func TestLinkTemplateRoundTrip(t *testing.T) {
+	post := "we use prometheus alerting here.\n"
+	root := writeFixture(t, map[string]string{"post.md": post, "alerts.md": "Body text.\n"})
+	source := filepath.Join(root, "post.md")
+	a := newTestAnalyzer(t, root, Config{
+		ScoringOptions: ScoringOptions{ParserConfig: markdown.ParserConfig{LinkedPattern: `<a href="(?P<target>[^"]*)">(?P<phrase>[^<]*)</a>`}},
+		ApplyOptions:   ApplyOptions{LinkTemplate: `<a href="{{.Target}}">{{.Phrase}}</a>`},
+	})
+	require.NoError(t, a.loadDocuments())
+
+	require.NoError(t, a.ApplyChanges([]scorer.LinkSuggestion{
+		{SourcePath: source, TargetPath: filepath.Join(root, "alerts.md"), WordToLink: "prometheus alerting", Position: 7},
+	}))
+	content, err := os.ReadFile(source)
+	require.NoError(t, err)
+	assert.Equal(t, `we use <a href="alerts.md">prometheus alerting</a> here.`+"\n", string(content))
+
+	// The inserted link is recognized, and can be undone
+	links, err := a.parser.FindLinks(content)
+	require.NoError(t, err)
+	require.Len(t, links, 1)
+	assert.True(t, links[0].Custom)
+	assert.Equal(t, "prometheus alerting", links[0].Text)
+	assert.Equal(t, "alerts.md", links[0].Destination)
+
+	removed, err := a.Undo(true)
+	require.NoError(t, err)
+	assert.Equal(t, 1, removed)
+	content, err = os.ReadFile(source)
+	require.NoError(t, err)
+	assert.Equal(t, post, string(content))
+}
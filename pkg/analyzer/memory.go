@@ -0,0 +1,63 @@
+package analyzer
+
+import (
+	"fmt"
+	"os"
+
+	"internal-link/pkg/scorer"
+)
+
+// AddDocument registers a document whose content is held in memory, such as
+// a page rendered by a static site generator. Its content is used instead
+// of the file at path from then on, and the cache is bypassed. Once a
+// document is added, Analyze no longer loads TargetDir.
+func (a *Analyzer) AddDocument(path string, content []byte) error {
+	if _, exists := a.docs[path]; exists {
+		return fmt.Errorf("document %s was already added", path)
+	}
+
+	if err := a.registerDocument(a.parseDocument(path, content, false)); err != nil {
+		return err
+	}
+	a.contents[path] = content
+	a.loaded = true
+	return nil
+}
+
+// AnalyzeDocuments generates link suggestions for the documents registered
+// with AddDocument, without touching TargetDir
+func (a *Analyzer) AnalyzeDocuments() ([]scorer.LinkSuggestion, error) {
+	return a.analyze(a.config.SelectionOptions)
+}
+
+// ApplyChangesToContent applies the suggested changes like ApplyChanges, but
+// returns the modified content of each changed document instead of writing
+// it. Neither the documents nor the link history are written.
+func (a *Analyzer) ApplyChangesToContent(suggestions []scorer.LinkSuggestion) (map[string][]byte, error) {
+	paths, byFile := groupByFile(onePerPair(unsuppressed(suggestions)))
+
+	changed := make(map[string][]byte, len(paths))
+	for _, path := range paths {
+		content, err := a.readDocument(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file %s: %w", path, err)
+		}
+
+		planned, err := a.planFile(path, content, byFile[path])
+		if err != nil {
+			return nil, err
+		}
+		changed[path] = applyEdits(content, planned)
+	}
+
+	return changed, nil
+}
+
+// readDocument returns the content of a document, preferring content added
+// with AddDocument over the file on disk
+func (a *Analyzer) readDocument(path string) ([]byte, error) {
+	if content, ok := a.contents[path]; ok {
+		return content, nil
+	}
+	return os.ReadFile(path)
+}
@@ -0,0 +1,270 @@
+package analyzer
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"internal-link/pkg/markdown"
+	"internal-link/pkg/scorer"
+)
+
+// newMemoryAnalyzer creates an analyzer without TargetDir, cache or history
+// file, so nothing is read from or written to disk
+func newMemoryAnalyzer(t *testing.T, files map[string]string) *Analyzer {
+	t.Helper()
+
+	a, err := NewAnalyzer(Config{
+		ScoringOptions:   ScoringOptions{ParserConfig: markdown.ParserConfig{MinNGram: 2, MaxNGram: 3}},
+		SelectionOptions: SelectionOptions{MinScore: 0.1},
+		Log:              io.Discard,
+	})
+	require.NoError(t, err)
+
+	for path, content := range files {
+		require.NoError(t, a.AddDocument(path, []byte(content)))
+	}
+	return a
+}
+
+func TestAnalyzeDocumentsInMemory(t *testing.T) {
+	a := newMemoryAnalyzer(t, map[string]string{
+		"site/alerts.md": "a guide to prometheus alerting for operators.\n",
+		"site/post.md":   "we tuned prometheus alerting today.\n",
+	})
+
+	suggestions, err := a.AnalyzeDocuments()
+	require.NoError(t, err)
+	require.Len(t, suggestions, 2)
+
+	changed, err := a.ApplyChangesToContent(suggestions)
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]byte{
+		"site/alerts.md": []byte("a guide to [prometheus alerting](post.md) for operators.\n"),
+		"site/post.md":   []byte("we tuned [prometheus alerting](alerts.md) today.\n"),
+	}, changed)
+
+	// The registered content itself is left alone
+	again, err := a.AnalyzeDocuments()
+	require.NoError(t, err)
+	assert.Equal(t, suggestions, again)
+}
+
+func TestAnalyzeUsesAddedDocuments(t *testing.T) {
+	a := newMemoryAnalyzer(t, map[string]string{
+		"alerts.md": "a guide to prometheus alerting for operators.\n",
+		"post.md":   "we tuned prometheus alerting today.\n",
+	})
+
+	// Analyze must not go looking for TargetDir once documents were added
+	suggestions, err := a.Analyze()
+	require.NoError(t, err)
+	require.Len(t, suggestions, 2)
+
+	edits, err := a.ComputeEdits(suggestions)
+	require.NoError(t, err)
+	require.Len(t, edits, 2)
+	assert.Equal(t, "post.md", edits[1].Path)
+	assert.Equal(t, "[prometheus alerting](alerts.md)", edits[1].InsertText)
+}
+
+func TestAddDocumentTwice(t *testing.T) {
+	a := newMemoryAnalyzer(t, map[string]string{"post.md": "we tuned prometheus alerting today.\n"})
+	assert.Error(t, a.AddDocument("post.md", []byte("again")))
+}
+
+func TestStemmingLinksWordForms(t *testing.T) {
+	a, err := NewAnalyzer(Config{
+		ScoringOptions:   ScoringOptions{ParserConfig: markdown.ParserConfig{MinNGram: 2, MaxNGram: 3, Stemming: true}},
+		SelectionOptions: SelectionOptions{MinScore: 0.1},
+		Log:              io.Discard,
+	})
+	require.NoError(t, err)
+	require.NoError(t, a.AddDocument("guide.md", []byte("rolling out kubernetes deployments safely.\n")))
+	require.NoError(t, a.AddDocument("post.md", []byte("our Kubernetes deployment failed.\n")))
+
+	suggestions, err := a.AnalyzeDocuments()
+	require.NoError(t, err)
+	require.Len(t, suggestions, 2)
+
+	changed, err := a.ApplyChangesToContent(suggestions)
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]byte{
+		"guide.md": []byte("rolling out [kubernetes deployments](post.md) safely.\n"),
+		"post.md":  []byte("our [Kubernetes deployment](guide.md) failed.\n"),
+	}, changed)
+}
+
+func TestTitleBoostRanksTitledTarget(t *testing.T) {
+	body := "kubernetes deployment strategies, rollbacks and canaries.\n"
+	a := newMemoryAnalyzer(t, map[string]string{
+		"a-notes.md":    "---\ntitle: Operations Notes\n---\n" + body,
+		"b-guide.md":    "---\ntitle: Kubernetes Deployment\n---\n" + body,
+		"post.md":       "our kubernetes deployment broke today.\n",
+		"unrelated.md":  "a recipe for sourdough bread.\n",
+		"unrelated2.md": "notes on tomato gardening.\n",
+	})
+
+	suggestions, err := a.AnalyzeDocuments()
+	require.NoError(t, err)
+
+	var targets []string
+	for _, s := range suggestions {
+		if s.SourcePath == "post.md" {
+			targets = append(targets, s.TargetPath)
+		}
+	}
+	assert.Equal(t, []string{"b-guide.md"}, targets)
+}
+
+func TestOccurrenceSelection(t *testing.T) {
+	target := "prometheus alerting rules. prometheus alerting basics. prometheus alerting for operators.\n"
+	filler := strings.Repeat("unrelated filler words here. ", 4)
+
+	tests := []struct {
+		name        string
+		source      string
+		introLength int
+		phrase      string
+		position    func(source string) int
+	}{
+		{
+			name:     "first occurrence",
+			source:   "prometheus alerting helps. " + filler + "prometheus alerting again.\n",
+			phrase:   "prometheus alerting",
+			position: func(string) int { return 0 },
+		},
+		{
+			name:        "past the introduction",
+			source:      "prometheus alerting helps. " + filler + "prometheus alerting again.\n",
+			introLength: 40,
+			phrase:      "prometheus alerting",
+			position: func(source string) int {
+				return strings.LastIndex(source, "prometheus alerting")
+			},
+		},
+		{
+			name:        "introduction when nothing later",
+			source:      "prometheus alerting helps. " + filler + "\n",
+			introLength: 40,
+			phrase:      "prometheus alerting",
+			position:    func(string) int { return 0 },
+		},
+		{
+			name:     "longer phrase",
+			source:   "prometheus alerting helps. " + filler + "we changed prometheus alerting rules.\n",
+			phrase:   "prometheus alerting rules",
+			position: func(source string) int { return strings.Index(source, "prometheus alerting rules") },
+		},
+		{
+			name:     "not in headings or links",
+			source:   "# prometheus alerting\n\nsee [prometheus alerting](https://example.com). " + filler + "prometheus alerting again.\n",
+			phrase:   "prometheus alerting",
+			position: func(source string) int { return strings.LastIndex(source, "prometheus alerting") },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newMemoryAnalyzer(t, map[string]string{
+				"alerts.md": target,
+				"post.md":   tt.source,
+			})
+
+			suggestions, err := a.AnalyzeWith(SelectionOptions{MinScore: 0.1, IntroLength: tt.introLength})
+			require.NoError(t, err)
+
+			var fromPost []scorer.LinkSuggestion
+			for _, s := range suggestions {
+				if s.SourcePath == "post.md" {
+					fromPost = append(fromPost, s)
+				}
+			}
+			require.Len(t, fromPost, 1, "one suggestion per source and target")
+			assert.Equal(t, tt.phrase, fromPost[0].WordToLink)
+			assert.Equal(t, tt.position(tt.source), fromPost[0].Position)
+		})
+	}
+}
+
+func TestMinLinkDistance(t *testing.T) {
+	files := map[string]string{
+		"alerts.md":     "prometheus alerting rules. prometheus alerting for operators.\n",
+		"dashboards.md": "grafana dashboards and panels.\n",
+		"post.md":       "we set up prometheus alerting and grafana dashboards today.\n",
+		"linked.md":     "see [the runbook](https://example.com/runbook) on prometheus alerting.\n",
+	}
+
+	analyze := func(source string, distance int) []scorer.LinkSuggestion {
+		a := newMemoryAnalyzer(t, files)
+		suggestions, err := a.AnalyzeWith(SelectionOptions{MinScore: 0.1, AllowDuplicateTargets: true, MinLinkDistance: distance})
+		require.NoError(t, err)
+
+		var fromSource []scorer.LinkSuggestion
+		for _, s := range suggestions {
+			if s.SourcePath == source {
+				fromSource = append(fromSource, s)
+			}
+		}
+		return fromSource
+	}
+
+	all := analyze("post.md", 0)
+	require.Len(t, all, 2)
+
+	// The phrases are a few words apart, so only the better one is kept
+	spaced := analyze("post.md", 200)
+	require.Len(t, spaced, 1)
+	assert.Equal(t, bestSuggestions(all, 1), spaced)
+
+	// Distance is the gap between the end of one link and the start of the next
+	assert.Len(t, analyze("post.md", len(" and ")), 2)
+	assert.Len(t, analyze("post.md", len(" and ")+1), 1)
+
+	// Existing links count too
+	assert.Empty(t, analyze("linked.md", 200))
+	assert.Len(t, analyze("linked.md", 1), 1)
+}
+
+func TestMaxLinksPerParagraph(t *testing.T) {
+	analyze := func(source string, max int) []scorer.LinkSuggestion {
+		a := newMemoryAnalyzer(t, map[string]string{
+			"alerts.md":     "prometheus alerting rules. prometheus alerting for operators.\n",
+			"dashboards.md": "grafana dashboards and panels.\n",
+			"post.md":       source,
+		})
+		suggestions, err := a.AnalyzeWith(SelectionOptions{MinScore: 0.1, MaxLinksPerParagraph: max})
+		require.NoError(t, err)
+
+		var fromPost []scorer.LinkSuggestion
+		for _, s := range suggestions {
+			if s.SourcePath == "post.md" {
+				fromPost = append(fromPost, s)
+			}
+		}
+		return fromPost
+	}
+
+	// Only the better suggestion of a shared paragraph is kept
+	together := "we set up prometheus alerting and grafana dashboards today.\n"
+	all := analyze(together, 0)
+	require.Len(t, all, 2)
+	limited := analyze(together, 1)
+	require.Len(t, limited, 1)
+	assert.Equal(t, bestSuggestions(all, 1), limited)
+	assert.Len(t, analyze(together, 2), 2)
+
+	// Separate paragraphs and list items each get their own link
+	assert.Len(t, analyze("we set up prometheus alerting today.\n\nand then grafana dashboards.\n", 1), 2)
+	assert.Len(t, analyze("- prometheus alerting\n- grafana dashboards\n", 1), 2)
+
+	// Existing links count towards their paragraph's limit
+	linked := "see [the runbook](https://example.com/runbook) on prometheus alerting.\n\ngrafana dashboards too.\n"
+	fromLinked := analyze(linked, 1)
+	require.Len(t, fromLinked, 1)
+	assert.Equal(t, "grafana dashboards", fromLinked[0].WordToLink)
+	assert.Len(t, analyze(linked, 2), 2)
+}
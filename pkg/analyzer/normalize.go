@@ -0,0 +1,71 @@
+package analyzer
+
+import (
+	"math"
+
+	"internal-link/pkg/scorer"
+)
+
+// scoredPair is a target scored against the source being analyzed
+type scoredPair struct {
+	target     *scorer.Document
+	raw        float64 // Boosted BM25 score
+	score      float64 // raw, or normalized among the pairs of the source
+	boost      float64
+	backlink   bool
+	sharedTags []string
+
+	// linked is set when the source already links to the target, which
+	// only suggestions far enough from those links may repeat
+	linked bool
+}
+
+// normalizeScores maps the scores of one source's pairs into [0,1] in the
+// given mode. When normalizing, pairs below minRaw are dropped first, so a
+// source with only weak matches can't see them inflated.
+func normalizeScores(mode string, minRaw float64, pairs []scoredPair) []scoredPair {
+	normalized := mode == ScoreNormalizationMinMax || mode == ScoreNormalizationSoftmax
+	kept := pairs[:0]
+	for _, p := range pairs {
+		p.score = p.raw
+		if normalized && p.raw < minRaw {
+			continue
+		}
+		kept = append(kept, p)
+	}
+	if len(kept) == 0 {
+		return kept
+	}
+
+	switch mode {
+	case ScoreNormalizationMinMax:
+		lo, hi := kept[0].raw, kept[0].raw
+		for _, p := range kept {
+			lo, hi = math.Min(lo, p.raw), math.Max(hi, p.raw)
+		}
+		for i := range kept {
+			if hi == lo {
+				// A lone or tied best match is as good as it gets
+				kept[i].score = 1
+			} else {
+				kept[i].score = (kept[i].raw - lo) / (hi - lo)
+			}
+		}
+
+	case ScoreNormalizationSoftmax:
+		// Shifting by the maximum keeps math.Exp from overflowing
+		hi := kept[0].raw
+		for _, p := range kept {
+			hi = math.Max(hi, p.raw)
+		}
+		var sum float64
+		for i := range kept {
+			kept[i].score = math.Exp(kept[i].raw - hi)
+			sum += kept[i].score
+		}
+		for i := range kept {
+			kept[i].score /= sum
+		}
+	}
+	return kept
+}
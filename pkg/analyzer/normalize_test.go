@@ -0,0 +1,89 @@
+package analyzer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"internal-link/pkg/scorer"
+)
+
+func TestNormalizeScores(t *testing.T) {
+	pairs := func(raw ...float64) []scoredPair {
+		var p []scoredPair
+		for _, r := range raw {
+			p = append(p, scoredPair{raw: r})
+		}
+		return p
+	}
+	scores := func(p []scoredPair) []float64 {
+		var s []float64
+		for _, pair := range p {
+			s = append(s, pair.score)
+		}
+		return s
+	}
+
+	assert.Equal(t, []float64{4, 2, 0.05}, scores(normalizeScores(ScoreNormalizationNone, 1, pairs(4, 2, 0.05))))
+	assert.Equal(t, []float64{1, 0}, scores(normalizeScores(ScoreNormalizationMinMax, 1, pairs(4, 2, 0.05))))
+	assert.Equal(t, []float64{1}, scores(normalizeScores(ScoreNormalizationMinMax, 0, pairs(0.5))))
+	assert.Empty(t, normalizeScores(ScoreNormalizationMinMax, 1, pairs(0.5, 0.2)))
+
+	softmax := scores(normalizeScores(ScoreNormalizationSoftmax, 0, pairs(1000, 999)))
+	require.Len(t, softmax, 2)
+	assert.InDelta(t, 0.731, softmax[0], 0.001)
+	assert.InDelta(t, 1, softmax[0]+softmax[1], 1e-9)
+}
+
+func TestScoreNormalization(t *testing.T) {
+	files := map[string]string{
+		"alerts.md":     "prometheus alerting rules and prometheus alerting basics.\n",
+		"dashboards.md": "grafana dashboards for prometheus alerting users.\n",
+		"post.md":       "we changed prometheus alerting rules and the grafana dashboards.\n",
+		"weak.md":       "some prometheus alerting in passing.\n",
+	}
+	byPair := func(suggestions []scorer.LinkSuggestion) map[[2]string]scorer.LinkSuggestion {
+		m := make(map[[2]string]scorer.LinkSuggestion)
+		for _, s := range suggestions {
+			m[[2]string{s.SourcePath, s.TargetPath}] = s
+		}
+		return m
+	}
+
+	raw, err := newMemoryAnalyzer(t, files).AnalyzeWith(SelectionOptions{MinScore: 0})
+	require.NoError(t, err)
+	rawPairs := byPair(raw)
+	require.Contains(t, rawPairs, [2]string{"post.md", "alerts.md"})
+
+	suggestions, err := newMemoryAnalyzer(t, files).AnalyzeWith(SelectionOptions{MinScore: 0.99, ScoreNormalization: ScoreNormalizationMinMax})
+	require.NoError(t, err)
+	require.NotEmpty(t, suggestions)
+	sources := make(map[string]bool)
+	for _, s := range suggestions {
+		// Only each source's best target is left, whatever its raw score
+		assert.Equal(t, 1.0, s.Score)
+		assert.Equal(t, rawPairs[[2]string{s.SourcePath, s.TargetPath}].Score, s.RawScore)
+		assert.False(t, sources[s.SourcePath], "one suggestion per source")
+		sources[s.SourcePath] = true
+	}
+
+	// A floor above every raw score leaves nothing to inflate
+	var best float64
+	for _, s := range raw {
+		best = max(best, s.Score)
+	}
+	suggestions, err = newMemoryAnalyzer(t, files).AnalyzeWith(SelectionOptions{MinScore: 0, ScoreNormalization: ScoreNormalizationSoftmax, MinRawScore: best + 1})
+	require.NoError(t, err)
+	assert.Empty(t, suggestions)
+
+	// Without normalization the floor doesn't apply
+	suggestions, err = newMemoryAnalyzer(t, files).AnalyzeWith(SelectionOptions{MinScore: 0, MinRawScore: best + 1})
+	require.NoError(t, err)
+	assert.Len(t, suggestions, len(raw))
+}
+
+func TestInvalidScoreNormalization(t *testing.T) {
+	_, err := newMemoryAnalyzer(t, map[string]string{"a.md": "prometheus alerting\n"}).AnalyzeWith(SelectionOptions{ScoreNormalization: "zscore"})
+	assert.ErrorContains(t, err, `invalid score normalization "zscore"`)
+}
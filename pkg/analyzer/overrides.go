@@ -0,0 +1,113 @@
+package analyzer
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"internal-link/pkg/markdown"
+)
+
+// DirectoryOverrides replace some settings for the documents beneath one
+// directory of TargetDir, e.g. a collection with a config file of its own.
+// Where several apply, those of the deepest directory win.
+type DirectoryOverrides struct {
+	Dir string // Relative to TargetDir, slash-separated
+
+	// MinScore replaces SelectionOptions.MinScore for sources beneath Dir
+	MinScore *float64
+
+	// ExcludeGlobs are further exclude patterns, relative to Dir
+	ExcludeGlobs []string
+
+	// LinkFormat replaces ParserConfig.LinkFormat for links inserted into
+	// documents beneath Dir
+	LinkFormat string
+}
+
+// contains reports whether the slash-separated path relative to TargetDir
+// lies beneath the directory of the overrides, returning it relative to that
+func (o DirectoryOverrides) contains(rel string) (string, bool) {
+	if o.Dir == "" || o.Dir == "." {
+		return rel, true
+	}
+	inner, ok := strings.CutPrefix(rel, o.Dir+"/")
+	return inner, ok
+}
+
+// validateOverrides checks the overrides and cleans their directories
+func validateOverrides(overrides []DirectoryOverrides) error {
+	for i := range overrides {
+		o := &overrides[i]
+		o.Dir = path.Clean(strings.ReplaceAll(o.Dir, "\\", "/"))
+		if o.Dir == ".." || strings.HasPrefix(o.Dir, "../") || path.IsAbs(o.Dir) {
+			return fmt.Errorf("invalid overrides directory %q (expected a directory within the target directory)", o.Dir)
+		}
+		if err := validateGlobs("exclude", o.ExcludeGlobs); err != nil {
+			return fmt.Errorf("invalid overrides for %s: %w", o.Dir, err)
+		}
+		switch o.LinkFormat {
+		case "", markdown.LinkFormatMarkdown, markdown.LinkFormatWikilink, markdown.LinkFormatReference:
+		default:
+			return fmt.Errorf("invalid link format %q for %s (expected markdown, wikilink or reference)", o.LinkFormat, o.Dir)
+		}
+	}
+	return nil
+}
+
+// overridden returns the deepest overrides applying to path for which set
+// reports a setting, and false if none does
+func (a *Analyzer) overridden(path string, set func(DirectoryOverrides) bool) (DirectoryOverrides, bool) {
+	rel := a.relPath(path)
+	var best DirectoryOverrides
+	found := false
+	for _, o := range a.config.Overrides {
+		if _, ok := o.contains(rel); ok && set(o) && (!found || len(o.Dir) > len(best.Dir)) {
+			best, found = o, true
+		}
+	}
+	return best, found
+}
+
+// minScore returns the threshold pairs with the given source must reach
+func (a *Analyzer) minScore(source string, selection SelectionOptions) float64 {
+	// A percentile is translated into one threshold for the whole corpus
+	if selection.MinScorePercentile > 0 {
+		return selection.MinScore
+	}
+	if o, ok := a.overridden(source, func(o DirectoryOverrides) bool { return o.MinScore != nil }); ok {
+		return *o.MinScore
+	}
+	return selection.MinScore
+}
+
+// linkFormat returns the syntax of the links inserted into a document
+func (a *Analyzer) linkFormat(path string) string {
+	if o, ok := a.overridden(path, func(o DirectoryOverrides) bool { return o.LinkFormat != "" }); ok {
+		return o.LinkFormat
+	}
+	return a.config.ParserConfig.LinkFormat
+}
+
+// linkFormats returns every link format in use, the configured one first
+func (a *Analyzer) linkFormats() []string {
+	formats := []string{a.config.ParserConfig.LinkFormat}
+	for _, o := range a.config.Overrides {
+		if o.LinkFormat != "" {
+			formats = append(formats, o.LinkFormat)
+		}
+	}
+	return formats
+}
+
+// excludedByOverrides reports whether the exclude patterns of any overrides
+// containing it match path
+func (a *Analyzer) excludedByOverrides(path string, dir bool) bool {
+	rel := a.relPath(path)
+	for _, o := range a.config.Overrides {
+		if inner, ok := o.contains(rel); ok && excluded(o.ExcludeGlobs, inner, dir) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,82 @@
+package analyzer
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"internal-link/pkg/markdown"
+)
+
+var overridesFixture = map[string]string{
+	"docs/alerts.md":     "# Alerts\n\nprometheus alerting rules and prometheus alerting basics.\n",
+	"docs/post.md":       "# Post\n\nwe changed prometheus alerting rules today.\n",
+	"blog/post.md":       "# Blog\n\nhow we do prometheus alerting rules.\n",
+	"blog/drafts/new.md": "# New\n\nprometheus alerting rules soon.\n",
+	"blog/old/legacy.md": "# Legacy\n\nprometheus alerting rules of old.\n",
+}
+
+func TestDirectoryOverrides(t *testing.T) {
+	root := writeFixture(t, overridesFixture)
+	strict, lax := 1000.0, 0.0
+	a := newTestAnalyzer(t, root, Config{
+		ScoringOptions: ScoringOptions{Overrides: []DirectoryOverrides{
+			{Dir: "blog", MinScore: &strict, ExcludeGlobs: []string{"old/**"}, LinkFormat: markdown.LinkFormatWikilink},
+			{Dir: "blog/drafts", MinScore: &lax},
+		}},
+		SelectionOptions: SelectionOptions{MinScore: 0.1},
+	})
+
+	suggestions, err := a.Analyze()
+	require.NoError(t, err)
+	sources := make(map[string]bool)
+	for _, s := range suggestions {
+		sources[a.relPath(s.SourcePath)] = true
+		assert.NotEqual(t, "blog/old/legacy.md", a.relPath(s.TargetPath), "excluded beneath blog")
+	}
+	assert.True(t, sources["docs/post.md"])
+	assert.False(t, sources["blog/post.md"], "blog requires a higher score")
+	assert.True(t, sources["blog/drafts/new.md"], "the deepest overrides win")
+	assert.Equal(t, 4, a.Stats().Documents)
+
+	// Links inserted beneath blog are wikilinks, elsewhere markdown links
+	edits, err := a.ComputeEdits(suggestions)
+	require.NoError(t, err)
+	formats := make(map[string]string)
+	for _, e := range edits {
+		formats[a.relPath(e.Path)] = e.InsertText
+	}
+	assert.Regexp(t, `^\[\[.*\|.*\]\]$`, formats["blog/drafts/new.md"])
+	assert.Regexp(t, `^\[.*\]\(.*\)$`, formats["docs/post.md"])
+}
+
+func TestInvalidDirectoryOverrides(t *testing.T) {
+	tests := []struct {
+		name      string
+		overrides DirectoryOverrides
+		selection SelectionOptions
+		err       string
+	}{
+		{name: "outside", overrides: DirectoryOverrides{Dir: "../elsewhere"}, err: "invalid overrides directory"},
+		{name: "pattern", overrides: DirectoryOverrides{Dir: "blog", ExcludeGlobs: []string{"["}}, err: "invalid exclude pattern"},
+		{name: "link format", overrides: DirectoryOverrides{Dir: "blog", LinkFormat: "html"}, err: `invalid link format "html" for blog`},
+		{
+			name:      "anchors and wikilinks",
+			overrides: DirectoryOverrides{Dir: "blog", LinkFormat: markdown.LinkFormatWikilink},
+			selection: SelectionOptions{SectionAnchors: true},
+			err:       "section anchors are not supported",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewAnalyzer(Config{
+				ScoringOptions:   ScoringOptions{TargetDir: t.TempDir(), CacheDir: filepath.Join(t.TempDir(), "cache"), Overrides: []DirectoryOverrides{tt.overrides}},
+				SelectionOptions: tt.selection,
+			})
+			assert.ErrorContains(t, err, tt.err)
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package analyzer
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"internal-link/pkg/scorer"
+)
+
+// pairScoresArtifact names the corpus blob holding the scores of document
+// pairs
+const pairScoresArtifact = "pair-scores"
+
+// pairScores remembers the BM25 score of each source and target pair by the
+// hashes of their contents, so a later run only scores the pairs a changed
+// document is part of. Documents are analyzed concurrently, so it is safe
+// for concurrent use.
+type pairScores struct {
+	mu      sync.Mutex
+	key     string             // Settings and, unless approximate, corpus the scores were computed for
+	cached  map[string]float64 // Scores of an earlier run, by pairKey
+	current map[string]float64 // Scores of live pairs, cached or computed by this run, saved for the next
+	scored  int                // Pairs scored by the last analysis
+	reused  int                // Pairs of the last analysis whose score was cached
+}
+
+// pairKey identifies a pair by the contents of its documents
+func pairKey(source, target *scorer.Document) string {
+	return source.Hash + " " + target.Hash
+}
+
+// pairScoresKey describes what pair scores depend on: the parser and scorer
+// settings and, unless ApproximateIDF accepts scores computed against an
+// earlier corpus, the corpus itself
+func (a *Analyzer) pairScoresKey() string {
+	key := fmt.Sprintf("%s scorer=%+v", a.parser.CacheKey(), *a.config.ScorerConfig)
+	if a.config.ApproximateIDF {
+		return key
+	}
+	if a.fingerprint == "" {
+		a.fingerprint = a.corpusFingerprint()
+	}
+	return key + " corpus=" + a.fingerprint
+}
+
+// loadPairScores prepares the pair scores of an analysis, reading those of
+// the last run from the cache when it was made with the same key. Scores
+// kept from an earlier analysis are dropped once the corpus changed, and
+// either way only those of pairs whose documents are still in the corpus as
+// they were are kept. Without a cache, pairs are only counted.
+func (a *Analyzer) loadPairScores() error {
+	if a.cache == nil {
+		a.pairs = &pairScores{}
+		return nil
+	}
+	key := a.pairScoresKey()
+	if a.pairs != nil && a.pairs.key == key {
+		a.pairs.scored, a.pairs.reused = 0, 0
+		a.pairs.current = a.livePairs(a.pairs.current)
+		return nil
+	}
+
+	cached := make(map[string]float64)
+	if _, err := a.cache.GetCorpus(pairScoresArtifact, key, &cached); err != nil {
+		return fmt.Errorf("failed to check cache for %s: %w", pairScoresArtifact, err)
+	}
+	a.pairs = &pairScores{key: key, cached: cached, current: a.livePairs(cached)}
+	return nil
+}
+
+// livePairs returns the scores of the pairs whose documents are both in the
+// corpus with the content they were scored with
+func (a *Analyzer) livePairs(scores map[string]float64) map[string]float64 {
+	hashes := make(map[string]bool, len(a.docs))
+	for _, doc := range a.docs {
+		hashes[doc.Hash] = true
+	}
+	live := make(map[string]float64, len(scores))
+	for key, score := range scores {
+		source, target, _ := strings.Cut(key, " ")
+		if hashes[source] && hashes[target] {
+			live[key] = score
+		}
+	}
+	return live
+}
+
+// savePairScores stores the pair scores for the next run: those of the
+// analyses so far and those of earlier runs still in the corpus, so a run
+// over a single file doesn't drop the rest. Pairs of removed or changed
+// documents are left out, so the entry doesn't grow with every edit.
+func (a *Analyzer) savePairScores() error {
+	if a.cache == nil || a.pairs.scored == 0 {
+		return nil
+	}
+	if err := a.cache.SetCorpus(pairScoresArtifact, a.pairs.key, a.pairs.current); err != nil {
+		return fmt.Errorf("failed to cache %s: %w", pairScoresArtifact, err)
+	}
+	return a.flushCache()
+}
+
+// scorePair returns the BM25 score of the query of source against target,
+// reusing the cached score of the pair if there is one
+func (a *Analyzer) scorePair(source *scorer.Document, query scorer.Query, target *scorer.Document) float64 {
+	p := a.pairs
+	if p.current == nil || source.Hash == "" || target.Hash == "" {
+		p.mu.Lock()
+		p.scored++
+		p.mu.Unlock()
+		return a.scorer.ScoreQuery(query, target)
+	}
+	key := pairKey(source, target)
+
+	p.mu.Lock()
+	score, ok := p.current[key]
+	if !ok {
+		score, ok = p.cached[key]
+	}
+	p.mu.Unlock()
+	if !ok {
+		score = a.scorer.ScoreQuery(query, target)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.current[key] = score
+	if ok {
+		p.reused++
+	} else {
+		p.scored++
+	}
+	return score
+}
@@ -0,0 +1,103 @@
+package analyzer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPairScoresReusedAcrossRuns(t *testing.T) {
+	root := writeFixture(t, generatedFixture(20))
+	a := newTestAnalyzer(t, root, Config{SelectionOptions: SelectionOptions{MinScore: 0.1}})
+	first, err := a.Analyze()
+	require.NoError(t, err)
+	require.NotEmpty(t, first)
+	total := a.Stats().ScoredPairs
+	assert.Equal(t, 20*19, total, "every page shares phrases with every other")
+	assert.Zero(t, a.Stats().CachedPairs)
+
+	// Another pass of the same analyzer reuses its scores too
+	_, err = a.Analyze()
+	require.NoError(t, err)
+	assert.Zero(t, a.Stats().ScoredPairs)
+
+	b, err := NewAnalyzer(a.config)
+	require.NoError(t, err)
+	second, err := b.Analyze()
+	require.NoError(t, err)
+	assert.Equal(t, first, second)
+	assert.Zero(t, b.Stats().ScoredPairs)
+	assert.Equal(t, total, b.Stats().CachedPairs)
+
+	// Any edit changes the term statistics, so every pair is scored again
+	edited := filepath.Join(root, "docs", "page-00000.md")
+	require.NoError(t, os.WriteFile(edited, []byte("An edited page on prometheus alerting.\n"), 0o644))
+	c, err := NewAnalyzer(a.config)
+	require.NoError(t, err)
+	_, err = c.Analyze()
+	require.NoError(t, err)
+	assert.Zero(t, c.Stats().CachedPairs)
+}
+
+func TestApproximateIDF(t *testing.T) {
+	root := writeFixture(t, generatedFixture(20))
+	a := newTestAnalyzer(t, root, Config{
+		ScoringOptions:   ScoringOptions{ApproximateIDF: true},
+		SelectionOptions: SelectionOptions{MinScore: 0.1},
+	})
+	_, err := a.Analyze()
+	require.NoError(t, err)
+	require.Equal(t, 20*19, a.Stats().ScoredPairs)
+
+	// Only the pairs the edited page is part of are scored again
+	edited := filepath.Join(root, "docs", "page-00000.md")
+	require.NoError(t, os.WriteFile(edited, []byte("An edited page on prometheus alerting and grafana dashboards.\n"), 0o644))
+	b, err := NewAnalyzer(a.config)
+	require.NoError(t, err)
+	_, err = b.Analyze()
+	require.NoError(t, err)
+	assert.Equal(t, 2*19, b.Stats().ScoredPairs)
+	assert.Equal(t, 20*19-2*19, b.Stats().CachedPairs)
+
+	// Scores of untouched pairs are those of the earlier corpus
+	edit := b.docs[edited]
+	source, target := b.docs[filepath.Join(root, "posts", "page-00001.md")], b.docs[filepath.Join(root, "guides", "page-00002.md")]
+	_, cached := b.pairs.cached[pairKey(source, target)]
+	assert.True(t, cached)
+	_, cached = b.pairs.cached[pairKey(source, edit)]
+	assert.False(t, cached)
+}
+
+func TestPairScoresKeptBySingleFileRuns(t *testing.T) {
+	root := writeFixture(t, generatedFixture(20))
+	config := Config{
+		ScoringOptions:   ScoringOptions{ApproximateIDF: true},
+		SelectionOptions: SelectionOptions{MinScore: 0.1},
+	}
+	a := newTestAnalyzer(t, root, config)
+	_, err := a.Analyze()
+	require.NoError(t, err)
+	require.Equal(t, 20*19, a.Stats().ScoredPairs)
+
+	// A run over the edited page scores its pairs without forgetting the others
+	edited := filepath.Join(root, "docs", "page-00000.md")
+	require.NoError(t, os.WriteFile(edited, []byte("An edited page on prometheus alerting and grafana dashboards.\n"), 0o644))
+	single := a.config
+	single.SingleFile = edited
+	b, err := NewAnalyzer(single)
+	require.NoError(t, err)
+	_, err = b.Analyze()
+	require.NoError(t, err)
+	assert.Equal(t, 19, b.Stats().ScoredPairs)
+
+	// The next full run only scores the pairs linking to the edited page
+	c, err := NewAnalyzer(a.config)
+	require.NoError(t, err)
+	_, err = c.Analyze()
+	require.NoError(t, err)
+	assert.Equal(t, 19, c.Stats().ScoredPairs)
+	assert.Equal(t, 20*19-19, c.Stats().CachedPairs)
+}
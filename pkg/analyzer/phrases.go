@@ -0,0 +1,68 @@
+package analyzer
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// phraseFilter decides which phrases of a source may become link anchors,
+// by their normalized form
+type phraseFilter struct {
+	blacklist map[string]bool
+	whitelist map[string]bool // nil means every phrase not blacklisted
+}
+
+// newPhraseFilter normalizes the phrase lists of selection the way the
+// parser normalizes occurrences, so they match case-insensitively and
+// regardless of stop words or stemming
+func (a *Analyzer) newPhraseFilter(selection SelectionOptions) phraseFilter {
+	normalize := func(phrases []string) map[string]bool {
+		set := make(map[string]bool, len(phrases))
+		for _, phrase := range phrases {
+			if normalized := a.parser.Normalize(phrase); normalized != "" {
+				set[normalized] = true
+			}
+		}
+		return set
+	}
+
+	f := phraseFilter{blacklist: normalize(selection.PhraseBlacklist)}
+	if len(selection.PhraseWhitelist) > 0 {
+		f.whitelist = normalize(selection.PhraseWhitelist)
+	}
+	return f
+}
+
+// allows reports whether the normalized phrase may be linked
+func (f phraseFilter) allows(phrase string) bool {
+	if f.blacklist[phrase] {
+		return false
+	}
+	return f.whitelist == nil || f.whitelist[phrase]
+}
+
+// LoadPhrases reads a phrase list file: one phrase per line, ignoring blank
+// lines and # comments
+func LoadPhrases(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open phrase file: %w", err)
+	}
+	defer f.Close()
+
+	var phrases []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		phrase := strings.TrimSpace(scanner.Text())
+		if phrase == "" || strings.HasPrefix(phrase, "#") {
+			continue
+		}
+		phrases = append(phrases, phrase)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read phrase file %s: %w", path, err)
+	}
+	return phrases, nil
+}
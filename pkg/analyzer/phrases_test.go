@@ -0,0 +1,83 @@
+package analyzer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"internal-link/pkg/scorer"
+)
+
+func TestPhraseLists(t *testing.T) {
+	files := map[string]string{
+		"alerts.md": "prometheus alerting rules and prometheus alerting basics. read the docs.\n",
+		"post.md":   "we changed prometheus alerting rules. read the docs before upgrading.\n",
+	}
+
+	tests := []struct {
+		name      string
+		blacklist []string
+		whitelist []string
+		phrase    string // Empty when no suggestion is expected
+	}{
+		{name: "no lists", phrase: "prometheus alerting rules"},
+		{name: "blacklisted", blacklist: []string{"Prometheus Alerting Rules"}, phrase: "prometheus alerting"},
+		{name: "blacklist after normalization", blacklist: []string{"prometheus alerting rules", "the prometheus alerting", "ALERTING RULES"}, phrase: "read the docs"},
+		{name: "whitelisted", whitelist: []string{"Read the docs"}, phrase: "read the docs"},
+		{name: "whitelisted but blacklisted", blacklist: []string{"read docs"}, whitelist: []string{"read the docs"}},
+		{name: "nothing whitelisted occurs", whitelist: []string{"grafana dashboards"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newMemoryAnalyzer(t, files)
+			suggestions, err := a.AnalyzeWith(SelectionOptions{MinScore: 0.1, PhraseBlacklist: tt.blacklist, PhraseWhitelist: tt.whitelist})
+			require.NoError(t, err)
+
+			var fromPost []scorer.LinkSuggestion
+			for _, s := range suggestions {
+				if s.SourcePath == "post.md" {
+					fromPost = append(fromPost, s)
+				}
+			}
+			if tt.phrase == "" {
+				assert.Empty(t, fromPost)
+				return
+			}
+			require.Len(t, fromPost, 1)
+			assert.Equal(t, tt.phrase, fromPost[0].WordToLink)
+		})
+	}
+}
+
+func TestPhraseListsKeepScores(t *testing.T) {
+	files := map[string]string{
+		"alerts.md": "prometheus alerting rules and prometheus alerting basics. read the docs.\n",
+		"post.md":   "we changed prometheus alerting rules. read the docs before upgrading.\n",
+	}
+
+	unfiltered, err := newMemoryAnalyzer(t, files).AnalyzeWith(SelectionOptions{MinScore: 0.1})
+	require.NoError(t, err)
+	filtered, err := newMemoryAnalyzer(t, files).AnalyzeWith(SelectionOptions{MinScore: 0.1, PhraseWhitelist: []string{"read the docs"}})
+	require.NoError(t, err)
+
+	require.Len(t, filtered, len(unfiltered))
+	for i := range filtered {
+		assert.Equal(t, unfiltered[i].Score, filtered[i].Score)
+	}
+}
+
+func TestLoadPhrases(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "phrases.txt")
+	require.NoError(t, os.WriteFile(path, []byte("# boilerplate\nclick here\n\n  this post  \n"), 0644))
+
+	phrases, err := LoadPhrases(path)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"click here", "this post"}, phrases)
+
+	_, err = LoadPhrases(filepath.Join(t.TempDir(), "missing.txt"))
+	assert.Error(t, err)
+}
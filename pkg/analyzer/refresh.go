@@ -0,0 +1,115 @@
+package analyzer
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Refresh brings the corpus up to date with the files at or beneath the
+// given paths, for example after they were saved, created or deleted: new
+// documents are added, changed ones parsed again and documents that were
+// deleted or are now excluded dropped. It returns the documents that were
+// added or changed, sorted.
+func (a *Analyzer) Refresh(paths []string) ([]string, error) {
+	if a.config.TargetDir == "" {
+		return nil, fmt.Errorf("refreshing documents requires a target directory")
+	}
+	if err := a.ensureLoaded(); err != nil {
+		return nil, err
+	}
+
+	affected := func(path string) bool {
+		for _, p := range paths {
+			p = filepath.Clean(p)
+			if path == p || strings.HasPrefix(path, p+string(filepath.Separator)) {
+				return true
+			}
+		}
+		return false
+	}
+
+	current, err := a.findDocuments()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find documents: %w", err)
+	}
+	inCorpus := make(map[string]bool, len(current))
+	for _, path := range current {
+		inCorpus[path] = true
+	}
+
+	for path := range a.docs {
+		if affected(path) && !inCorpus[path] {
+			fmt.Fprintln(a.config.Log, "Removing file: ", path)
+			if err := a.removeDocument(path); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var changed []string
+	for _, path := range current {
+		if !affected(path) {
+			continue
+		}
+		content, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			// Deleted since the walk; the next refresh drops it
+			continue
+		}
+		if err != nil {
+			if err := a.skip(path, fmt.Errorf("failed to read file %s: %w", path, err)); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if hash, ok := a.hashes[path]; ok && hash == sha256.Sum256(content) {
+			continue
+		}
+
+		if _, ok := a.docs[path]; ok {
+			if err := a.removeDocument(path); err != nil {
+				return nil, err
+			}
+		}
+		result := a.parseDocument(path, content, a.cache != nil)
+		if result.err != nil {
+			if err := a.skip(path, result.err); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err := a.registerDocument(result); err != nil {
+			return nil, err
+		}
+		changed = append(changed, path)
+	}
+
+	if err := a.flushCache(); err != nil {
+		return nil, err
+	}
+	sort.Strings(changed)
+	return changed, nil
+}
+
+// removeDocument drops a document from the corpus, the scorer and the cache
+func (a *Analyzer) removeDocument(path string) error {
+	a.scorer.RemoveDocument(path)
+	delete(a.docs, path)
+	delete(a.hashes, path)
+	delete(a.links, path)
+	a.linksStale = true
+	a.urls = nil
+	a.duplicatesStale = true
+	a.fingerprint = ""
+
+	if a.cache != nil {
+		if err := a.cache.Remove(path, a.parser.CacheKey()); err != nil {
+			return fmt.Errorf("failed to invalidate cache for %s: %w", path, err)
+		}
+	}
+	return nil
+}
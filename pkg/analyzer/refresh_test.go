@@ -0,0 +1,76 @@
+package analyzer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRefresh(t *testing.T) {
+	root := writeFixture(t, map[string]string{
+		"alerts.md": "prometheus alerting for operators.\n",
+		"post.md":   "nothing to link here.\n",
+	})
+	a := newTestAnalyzer(t, root, Config{SelectionOptions: SelectionOptions{MinScore: 0.01}})
+	_, err := a.Analyze()
+	require.NoError(t, err)
+
+	post := filepath.Join(root, "post.md")
+	suggestionsFor := func(path string) []string {
+		suggestions, err := a.AnalyzeWith(SelectionOptions{MinScore: 0.01, SingleFile: path})
+		require.NoError(t, err)
+		var targets []string
+		for _, s := range suggestions {
+			targets = append(targets, a.relPath(s.TargetPath))
+		}
+		return targets
+	}
+	assert.Empty(t, suggestionsFor(post))
+
+	// A saved document is parsed again
+	require.NoError(t, os.WriteFile(post, []byte("we tuned prometheus alerting today.\n"), 0644))
+	changed, err := a.Refresh([]string{post})
+	require.NoError(t, err)
+	assert.Equal(t, []string{post}, changed)
+	assert.Equal(t, []string{"alerts.md"}, suggestionsFor(post))
+
+	// Unchanged documents are left alone
+	changed, err = a.Refresh([]string{post, filepath.Join(root, "alerts.md")})
+	require.NoError(t, err)
+	assert.Empty(t, changed)
+
+	// Documents in a new directory are added
+	sub := filepath.Join(root, "sub")
+	require.NoError(t, os.MkdirAll(sub, 0755))
+	guide := filepath.Join(sub, "guide.md")
+	require.NoError(t, os.WriteFile(guide, []byte("a prometheus alerting guide.\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(sub, "notes.txt"), []byte("prometheus alerting\n"), 0644))
+	changed, err = a.Refresh([]string{sub})
+	require.NoError(t, err)
+	assert.Equal(t, []string{guide}, changed)
+	assert.Equal(t, []string{"alerts.md"}, suggestionsFor(guide))
+	assert.Equal(t, []string{"alerts.md"}, suggestionsFor(post))
+
+	// Deleted documents are dropped as sources and targets
+	require.NoError(t, os.Remove(filepath.Join(root, "alerts.md")))
+	changed, err = a.Refresh([]string{filepath.Join(root, "alerts.md")})
+	require.NoError(t, err)
+	assert.Empty(t, changed)
+	assert.NotContains(t, a.docs, filepath.Join(root, "alerts.md"))
+	assert.Equal(t, []string{"sub/guide.md"}, suggestionsFor(post))
+
+	// So are documents beneath a deleted directory
+	require.NoError(t, os.RemoveAll(sub))
+	_, err = a.Refresh([]string{sub})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"post.md"}, relDocPaths(a))
+}
+
+func TestRefreshRequiresTargetDir(t *testing.T) {
+	a := newMemoryAnalyzer(t, map[string]string{"post.md": "text\n"})
+	_, err := a.Refresh([]string{"post.md"})
+	assert.Error(t, err)
+}
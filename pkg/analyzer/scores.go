@@ -0,0 +1,210 @@
+package analyzer
+
+import (
+	"container/heap"
+	"fmt"
+	"math"
+	"sort"
+
+	"internal-link/pkg/scorer"
+)
+
+// maxRecordedScores bounds how many below-threshold scores are kept
+const maxRecordedScores = 1000
+
+// hintPairs is the number of document pairs the threshold hint aims to let
+// through
+const hintPairs = 10
+
+// scoreHeap is a min-heap of scores so the lowest retained score can be evicted
+type scoreHeap []float64
+
+func (h scoreHeap) Len() int            { return len(h) }
+func (h scoreHeap) Less(i, j int) bool  { return h[i] < h[j] }
+func (h scoreHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *scoreHeap) Push(x interface{}) { *h = append(*h, x.(float64)) }
+func (h *scoreHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[:n-1]
+	return x
+}
+
+// scoreRecord keeps the highest non-zero pair scores that fell below the threshold
+type scoreRecord struct {
+	top   scoreHeap
+	count int
+}
+
+// add records a below-threshold score, keeping only the top maxRecordedScores
+func (r *scoreRecord) add(score float64) {
+	if score <= 0 {
+		return
+	}
+	r.count++
+	if len(r.top) < maxRecordedScores {
+		heap.Push(&r.top, score)
+		return
+	}
+	if score > r.top[0] {
+		r.top[0] = score
+		heap.Fix(&r.top, 0)
+	}
+}
+
+// descending returns the retained scores from highest to lowest
+func (r *scoreRecord) descending() []float64 {
+	scores := append([]float64(nil), r.top...)
+	sort.Sort(sort.Reverse(sort.Float64Slice(scores)))
+	return scores
+}
+
+// percentile returns the score at percentile p (0-100) of all recorded scores.
+// When the rank falls outside the retained top scores the lowest retained
+// score is returned, which is an upper bound of the true percentile.
+func (r *scoreRecord) percentile(p float64) float64 {
+	scores := r.descending()
+	if len(scores) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(float64(r.count)*(100-p)/100)) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(scores) {
+		rank = len(scores) - 1
+	}
+	return scores[rank]
+}
+
+// thresholdHint explains why nothing passed the threshold and suggests a lower one.
+// The scores are those of pairs, which may still give no suggestion, for
+// lack of a phrase to link or once the link limits are applied, so the hint
+// counts pairs. It returns an empty string when no pair scored above zero.
+func (r *scoreRecord) thresholdHint(minScore float64) string {
+	scores := r.descending()
+	if len(scores) == 0 {
+		return ""
+	}
+
+	n := hintPairs
+	if n > len(scores) {
+		n = len(scores)
+	}
+	suggested := math.Floor(scores[n-1]*10000) / 10000
+
+	return fmt.Sprintf("No suggestions reached --min-score %.4f (max observed score %.4f, 95th percentile %.4f).\n"+
+		"Try --min-score %.4f to let up to %d document pairs through, each of which may give a suggestion.",
+		minScore, scores[0], r.percentile(95), suggested, n)
+}
+
+// ScoreHistogram counts the pair scores of the last analysis that fell below
+// its threshold, in equal-width buckets from zero to the best of them. Only
+// the best of those scores are kept, and Omitted counts the lower ones left
+// out of the buckets.
+type ScoreHistogram struct {
+	Buckets []ScoreBucket `json:"buckets"`
+	Omitted int           `json:"omitted,omitempty"`
+}
+
+// ScoreBucket counts the scores from Min up to Max, including Max in the
+// last bucket only
+type ScoreBucket struct {
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+	Count int     `json:"count"`
+}
+
+// BelowThreshold returns the histogram of the pair scores the last analysis
+// recorded below its threshold in n buckets, without any when none scored
+// above zero
+func (a *Analyzer) BelowThreshold(n int) ScoreHistogram {
+	a.scoresMu.Lock()
+	defer a.scoresMu.Unlock()
+	return a.belowThreshold.histogram(n)
+}
+
+// histogram sorts the retained scores into n buckets
+func (r *scoreRecord) histogram(n int) ScoreHistogram {
+	if len(r.top) == 0 || n <= 0 {
+		return ScoreHistogram{}
+	}
+	highest := r.descending()[0]
+	width := highest / float64(n)
+	h := ScoreHistogram{Buckets: make([]ScoreBucket, n), Omitted: r.count - len(r.top)}
+	for i := range h.Buckets {
+		h.Buckets[i] = ScoreBucket{Min: float64(i) * width, Max: float64(i+1) * width}
+	}
+	h.Buckets[n-1].Max = highest
+	for _, score := range r.top {
+		i := min(int(score/width), n-1)
+		h.Buckets[i].Count++
+	}
+	return h
+}
+
+// percentileThreshold scores every pair of the sources and returns the
+// score the best MinScorePercentile percent of them reach, with the number
+// of pairs that scored above zero
+func (a *Analyzer) percentileThreshold(sources []*scorer.Document, selection SelectionOptions) (float64, int) {
+	var scores []float64
+	for _, doc := range sources {
+		if doc.Ignored {
+			continue
+		}
+		for _, pair := range a.scorePairs(doc, scorer.NewQuery(doc.WordFreq), selection) {
+			// Pairs already linked only count where they may be linked again
+			if pair.score > 0 && (!pair.linked || selection.AllowRepeatAfter > 0) {
+				scores = append(scores, pair.score)
+			}
+		}
+	}
+	if len(scores) == 0 {
+		return 0, 0
+	}
+	sort.Sort(sort.Reverse(sort.Float64Slice(scores)))
+	rank := int(math.Ceil(float64(len(scores))*selection.MinScorePercentile/100)) - 1
+	return scores[max(rank, 0)], len(scores)
+}
+
+// topSuggestions keeps the n best scoring suggestions, with the alternative
+// phrases offered for their pairs and the suppressed suggestions. It
+// returns the lowest score kept and whether any suggestion was dropped.
+func topSuggestions(suggestions []scorer.LinkSuggestion, n int) ([]scorer.LinkSuggestion, float64, bool) {
+	var preferred []scorer.LinkSuggestion
+	for _, s := range suggestions {
+		if s.Alternative == 0 && s.Suppressed == "" {
+			preferred = append(preferred, s)
+		}
+	}
+	if len(preferred) <= n {
+		return suggestions, 0, false
+	}
+	sort.Slice(preferred, func(i, j int) bool {
+		si, sj := preferred[i], preferred[j]
+		if si.Score != sj.Score {
+			return si.Score > sj.Score
+		}
+		if si.SourcePath != sj.SourcePath {
+			return si.SourcePath < sj.SourcePath
+		}
+		if si.Position != sj.Position {
+			return si.Position < sj.Position
+		}
+		return si.TargetPath < sj.TargetPath
+	})
+	preferred = preferred[:n]
+
+	type pair struct{ source, target string }
+	kept := make(map[pair]bool, n)
+	for _, s := range preferred {
+		kept[pair{s.SourcePath, s.TargetPath}] = true
+	}
+	for _, s := range suggestions {
+		if s.Suppressed != "" || s.Alternative > 0 && kept[pair{s.SourcePath, s.TargetPath}] {
+			preferred = append(preferred, s)
+		}
+	}
+	return preferred, preferred[n-1].Score, true
+}
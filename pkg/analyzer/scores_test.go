@@ -0,0 +1,232 @@
+package analyzer
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScoreRecordHint(t *testing.T) {
+	var r scoreRecord
+	for _, score := range []float64{0.1, 0.08, 0.05, 0.02, 0, 0.01} {
+		r.add(score)
+	}
+
+	assert.Equal(t, 5, r.count, "zero scores should not be recorded")
+	assert.Equal(t, 0.1, r.percentile(100))
+	assert.Equal(t, 0.1, r.percentile(95))
+
+	hint := r.thresholdHint(0.3)
+	assert.Contains(t, hint, "max observed score 0.1000")
+	assert.Contains(t, hint, "95th percentile 0.1000")
+	assert.Contains(t, hint, "Try --min-score 0.0100 to let up to 5 document pairs through")
+}
+
+func TestScoreRecordBounded(t *testing.T) {
+	var r scoreRecord
+	for i := 1; i <= maxRecordedScores*2; i++ {
+		r.add(float64(i))
+	}
+
+	assert.Len(t, r.top, maxRecordedScores)
+	assert.Equal(t, maxRecordedScores*2, r.count)
+	assert.Equal(t, float64(maxRecordedScores*2), r.descending()[0])
+	assert.Equal(t, float64(maxRecordedScores+1), r.descending()[maxRecordedScores-1])
+}
+
+func TestScoreRecordEmpty(t *testing.T) {
+	var r scoreRecord
+	assert.Empty(t, r.thresholdHint(0.3))
+	assert.Empty(t, r.histogram(10).Buckets)
+}
+
+func TestScoreRecordHistogram(t *testing.T) {
+	var r scoreRecord
+	for _, score := range []float64{0.1, 0.08, 0.05, 0.02, 0.01} {
+		r.add(score)
+	}
+
+	h := r.histogram(4)
+	require.Len(t, h.Buckets, 4)
+	assert.InDelta(t, 0.025, h.Buckets[0].Max, 1e-9)
+	assert.Equal(t, 0.1, h.Buckets[3].Max)
+	counts := make([]int, len(h.Buckets))
+	for i, b := range h.Buckets {
+		counts[i] = b.Count
+	}
+	assert.Equal(t, []int{2, 0, 1, 2}, counts)
+	assert.Zero(t, h.Omitted)
+
+	// Scores below the retained ones are counted as left out
+	for i := 0; i < maxRecordedScores; i++ {
+		r.add(0.5)
+	}
+	assert.Equal(t, 5, r.histogram(4).Omitted)
+}
+
+func TestAnalyzeRecordsBelowThresholdScores(t *testing.T) {
+	root := writeFixture(t, hugoFixture)
+	a := newTestAnalyzer(t, root, Config{
+		SelectionOptions: SelectionOptions{MinScore: 100, SingleFile: filepath.Join(root, "posts", "setup.md")},
+		ApplyOptions:     ApplyOptions{DryRun: true},
+	})
+
+	suggestions, err := a.Analyze()
+	require.NoError(t, err)
+	assert.Empty(t, suggestions)
+	assert.NotEmpty(t, a.belowThreshold.thresholdHint(a.config.MinScore))
+	assert.NotEmpty(t, a.BelowThreshold(10).Buckets)
+}
+
+func TestExplainSuggestions(t *testing.T) {
+	root := writeFixture(t, hugoFixture)
+	source := filepath.Join(root, "posts", "setup.md")
+	a := newTestAnalyzer(t, root, Config{
+		ScoringOptions:   ScoringOptions{SectionPages: SectionPagesBoost},
+		SelectionOptions: SelectionOptions{MinScore: 0.1, SingleFile: source},
+	})
+
+	plain, err := a.Analyze()
+	require.NoError(t, err)
+	require.Len(t, plain, 1)
+	assert.Nil(t, plain[0].Explanation, "scores are only explained on request")
+
+	explained, err := a.AnalyzeWith(SelectionOptions{MinScore: 0.1, SingleFile: source, Explain: true})
+	require.NoError(t, err)
+	require.Len(t, explained, 1)
+	e := explained[0].Explanation
+	require.NotNil(t, e)
+	assert.Equal(t, sectionBoost, e.Boost, "the target is a boosted section page")
+	assert.InDelta(t, explained[0].Score, e.BM25*e.Boost, 1e-9)
+	assert.Equal(t, plain[0].Score, explained[0].Score)
+	require.NotEmpty(t, e.Terms)
+	assert.Equal(t, "prometheus alerting", e.Terms[0].Term)
+}
+
+var percentileFixture = map[string]string{
+	"a.md": "Prometheus alerting and grafana dashboards keep kubernetes clusters healthy.\n",
+	"b.md": "Prometheus alerting guide for prometheus alerting rules.\n",
+	"c.md": "Grafana dashboards overview: building grafana dashboards.\n",
+	"d.md": "Kubernetes clusters at scale, with prometheus alerting.\n",
+}
+
+func TestMinScorePercentile(t *testing.T) {
+	root := writeFixture(t, percentileFixture)
+	var log bytes.Buffer
+	a := newTestAnalyzer(t, root, Config{
+		SelectionOptions: SelectionOptions{MinScore: 100, MinScorePercentile: 50},
+		ApplyOptions:     ApplyOptions{DryRun: true},
+		Log:              &log,
+	})
+
+	suggestions, err := a.Analyze()
+	require.NoError(t, err)
+	require.NotEmpty(t, suggestions, "the percentile replaces --min-score")
+	threshold := a.Stats().MinScore
+	assert.Less(t, threshold, 100.0)
+	assert.Contains(t, log.String(), fmt.Sprintf("reach --min-score %.4f", threshold))
+	for _, s := range suggestions {
+		assert.GreaterOrEqual(t, s.Score, threshold)
+	}
+
+	// The printed threshold reproduces the run as a fixed --min-score
+	all, err := a.AnalyzeWith(SelectionOptions{})
+	require.NoError(t, err)
+	assert.Greater(t, len(all), len(suggestions))
+	fixed, err := a.AnalyzeWith(SelectionOptions{MinScore: threshold})
+	require.NoError(t, err)
+	assert.Equal(t, suggestions, fixed)
+}
+
+func TestTopSuggestions(t *testing.T) {
+	root := writeFixture(t, percentileFixture)
+	a := newTestAnalyzer(t, root, Config{ApplyOptions: ApplyOptions{DryRun: true}})
+
+	all, err := a.AnalyzeWith(SelectionOptions{})
+	require.NoError(t, err)
+	require.Greater(t, len(all), 2)
+
+	scores := make([]float64, len(all))
+	for i, s := range all {
+		scores[i] = s.Score
+	}
+	sort.Sort(sort.Reverse(sort.Float64Slice(scores)))
+
+	top, err := a.AnalyzeWith(SelectionOptions{Top: 2})
+	require.NoError(t, err)
+	require.Len(t, top, 2)
+	assert.ElementsMatch(t, scores[:2], []float64{top[0].Score, top[1].Score})
+	assert.Equal(t, scores[1], a.Stats().MinScore)
+}
+
+func TestInvalidMinScorePercentile(t *testing.T) {
+	_, err := NewAnalyzer(Config{
+		ScoringOptions:   ScoringOptions{CacheDir: t.TempDir()},
+		SelectionOptions: SelectionOptions{MinScorePercentile: 101},
+	})
+	assert.ErrorContains(t, err, "invalid min score percentile")
+}
+
+func TestCandidatePool(t *testing.T) {
+	root := writeFixture(t, generatedFixture(40))
+	a := newTestAnalyzer(t, root, Config{})
+	all, err := a.AnalyzeWith(SelectionOptions{MinScore: 0.1})
+	require.NoError(t, err)
+	require.NotEmpty(t, all)
+
+	large, err := a.AnalyzeWith(SelectionOptions{MinScore: 0.1, CandidatePool: 1000})
+	require.NoError(t, err)
+	assert.Equal(t, all, large, "a pool holding every candidate changes nothing")
+
+	// A pool of one leaves each source a single target, scored as before
+	pooled, err := a.AnalyzeWith(SelectionOptions{MinScore: 0.1, CandidatePool: 1})
+	require.NoError(t, err)
+	require.NotEmpty(t, pooled)
+	full := suggestionsByPair(all)
+	targets := make(map[string]string)
+	for _, s := range pooled {
+		if target, ok := targets[s.SourcePath]; ok {
+			assert.Equal(t, target, s.TargetPath)
+		}
+		targets[s.SourcePath] = s.TargetPath
+		assert.Equal(t, full[[2]string{s.SourcePath, s.TargetPath}].Score, s.Score)
+	}
+}
+
+func TestCandidatePoolValidation(t *testing.T) {
+	_, err := newMemoryAnalyzer(t, taxonomyCorpus).AnalyzeWith(SelectionOptions{MinScore: 0.1, CandidatePool: -1})
+	assert.ErrorContains(t, err, "invalid candidate pool")
+}
+
+func TestCandidatePoolSkipsExcludedTargets(t *testing.T) {
+	// p2 and p3 share the most with p1 but are linked already, so a pool of
+	// two must still reach p4
+	root := writeFixture(t, map[string]string{
+		"p1.md": "# One\n\nprometheus alerting rules, grafana dashboards panels and loki log queries.\n" +
+			"See [alerts](p2.md) and [dashboards](p3.md).\n",
+		"p2.md": "# Two\n\nprometheus alerting rules and grafana dashboards panels with prometheus alerting rules.\n",
+		"p3.md": "# Three\n\ngrafana dashboards panels and prometheus alerting rules with grafana dashboards panels.\n",
+		"p4.md": "# Four\n\nloki log queries.\n",
+	})
+	a := newTestAnalyzer(t, root, Config{})
+	bySource := func(pool int) []string {
+		suggestions, err := a.AnalyzeWith(SelectionOptions{MinScore: 0.01, CandidatePool: pool})
+		require.NoError(t, err)
+		var targets []string
+		for _, s := range suggestions {
+			if filepath.Base(s.SourcePath) == "p1.md" {
+				targets = append(targets, filepath.Base(s.TargetPath))
+			}
+		}
+		return targets
+	}
+
+	all := bySource(0)
+	require.Equal(t, []string{"p4.md"}, all)
+	assert.Equal(t, all, bySource(1))
+}
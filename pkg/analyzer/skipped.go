@@ -0,0 +1,48 @@
+package analyzer
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"unicode/utf8"
+)
+
+// skip leaves a file that couldn't be walked, read or parsed out of the
+// corpus with a warning, once per file, or returns err in strict mode
+func (a *Analyzer) skip(path string, err error) error {
+	if a.config.Strict {
+		return err
+	}
+	if _, ok := a.skipped[path]; !ok {
+		fmt.Fprintf(a.config.Log, "Warning: skipping %s: %v\n", path, err)
+	}
+	a.skipped[path] = err
+	return nil
+}
+
+// printSkipped lists the files left out of the corpus, sorted, with the
+// reason for each
+func (a *Analyzer) printSkipped() {
+	paths := make([]string, 0, len(a.skipped))
+	for path := range a.skipped {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	fmt.Fprintf(a.config.Log, "Skipped %d file(s) that could not be loaded:\n", len(paths))
+	for _, path := range paths {
+		fmt.Fprintf(a.config.Log, "  %s: %v\n", path, a.skipped[path])
+	}
+}
+
+// checkText rejects content that isn't text, such as a binary file
+// committed with a document extension
+func checkText(content []byte) error {
+	if bytes.IndexByte(content, 0) >= 0 {
+		return fmt.Errorf("binary content (contains null bytes)")
+	}
+	if !utf8.Valid(content) {
+		return fmt.Errorf("binary content (invalid UTF-8)")
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package analyzer
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeBrokenFiles adds a binary file, one that isn't UTF-8 and a dangling
+// symlink, all with a document extension, beneath root
+func writeBrokenFiles(t *testing.T, root string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(filepath.Join(root, "binary.md"), []byte("PK\x03\x04\x00\x00data"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "latin1.md"), []byte("caf\xe9 prometheus alerting\n"), 0644))
+	require.NoError(t, os.Symlink(filepath.Join(root, "missing.md"), filepath.Join(root, "dangling.md")))
+}
+
+func TestSkipUnloadableFiles(t *testing.T) {
+	root := writeFixture(t, hugoFixture)
+	writeBrokenFiles(t, root)
+
+	var log bytes.Buffer
+	a := newTestAnalyzer(t, root, Config{
+		SelectionOptions: SelectionOptions{MinScore: 0.1},
+		Log:              &log,
+	})
+	suggestions, err := a.Analyze()
+	require.NoError(t, err)
+	assert.NotEmpty(t, suggestions)
+
+	stats := a.Stats()
+	assert.Equal(t, 3, stats.Documents)
+	assert.Equal(t, 3, stats.Skipped)
+	assert.Contains(t, log.String(), "Warning: skipping "+filepath.Join(root, "binary.md"))
+	assert.Contains(t, log.String(), "null bytes")
+	assert.Contains(t, log.String(), "invalid UTF-8")
+	assert.Contains(t, log.String(), "Skipped 3 file(s) that could not be loaded:\n  "+filepath.Join(root, "binary.md"))
+}
+
+func TestStrictFailsOnUnloadableFile(t *testing.T) {
+	root := writeFixture(t, hugoFixture)
+	writeBrokenFiles(t, root)
+
+	a := newTestAnalyzer(t, root, Config{
+		ScoringOptions:   ScoringOptions{Strict: true},
+		SelectionOptions: SelectionOptions{MinScore: 0.1},
+	})
+	_, err := a.Analyze()
+	assert.ErrorContains(t, err, "binary content")
+}
+
+func TestFailWhenNoDocumentLoads(t *testing.T) {
+	root := t.TempDir()
+	writeBrokenFiles(t, root)
+
+	a := newTestAnalyzer(t, root, Config{SelectionOptions: SelectionOptions{MinScore: 0.1}})
+	_, err := a.Analyze()
+	assert.ErrorContains(t, err, "none of the 3 document(s) could be loaded")
+
+	// An empty directory is no failure
+	a = newTestAnalyzer(t, t.TempDir(), Config{SelectionOptions: SelectionOptions{MinScore: 0.1}})
+	_, err = a.Analyze()
+	assert.NoError(t, err)
+}
+
+func TestCheckText(t *testing.T) {
+	assert.NoError(t, checkText([]byte("plain text, naïve café\n")))
+	assert.ErrorContains(t, checkText([]byte("text\x00more")), "null bytes")
+	assert.ErrorContains(t, checkText([]byte("caf\xe9")), "invalid UTF-8")
+}
+
+func TestSkipOversizedFiles(t *testing.T) {
+	root := writeFixture(t, hugoFixture)
+	large := filepath.Join(root, "generated.md")
+	require.NoError(t, os.WriteFile(large, bytes.Repeat([]byte("prometheus alerting rules\n"), 100), 0644))
+
+	var log bytes.Buffer
+	a := newTestAnalyzer(t, root, Config{
+		ScoringOptions:   ScoringOptions{MaxFileSize: 1000},
+		SelectionOptions: SelectionOptions{MinScore: 0.1},
+		Log:              &log,
+	})
+	_, err := a.Analyze()
+	require.NoError(t, err)
+
+	// A deliberate limit is no load failure, so it isn't counted as one
+	stats := a.Stats()
+	assert.Equal(t, 3, stats.Documents)
+	assert.Equal(t, 0, stats.Skipped)
+	assert.Contains(t, log.String(), "Skipping "+large+": 2600 bytes exceeds the maximum file size of 1000 bytes")
+}
@@ -0,0 +1,60 @@
+package analyzer
+
+import "time"
+
+// Phases reported to Config.OnProgress
+const (
+	PhaseLoad    = "load"    // Reading and parsing the documents beneath TargetDir
+	PhaseAnalyze = "analyze" // Finding link suggestions for each document
+)
+
+// Stats summarizes the work an Analyzer has done
+type Stats struct {
+	Documents   int           // Documents in the corpus
+	Parsed      int           // Documents that had to be parsed
+	CacheHits   int           // Documents whose analysis was read from the cache
+	Skipped     int           // Files left out because they couldn't be loaded
+	Suggestions int           // Suggestions generated by the last analysis
+	Sources     int           // Documents the last analysis found suggestions for
+	Targets     int           // Documents the last analysis offered as link targets
+	ScoredPairs int           // Document pairs the last analysis had to score
+	CachedPairs int           // Document pairs of the last analysis whose score was cached
+	MinScore    float64       // Threshold of the last analysis, as translated from MinScorePercentile or Top
+	LoadTime    time.Duration // Time spent loading the corpus
+	AnalyzeTime time.Duration // Time spent on the last analysis
+	Inserted    int           // Links inserted by the last ApplyChanges
+	Modified    int           // Files the last ApplyChanges inserted links into
+}
+
+// Stats returns the document counts and timings of the work done so far
+func (a *Analyzer) Stats() Stats {
+	var scored, cached int
+	if a.pairs != nil {
+		a.pairs.mu.Lock()
+		scored, cached = a.pairs.scored, a.pairs.reused
+		a.pairs.mu.Unlock()
+	}
+	return Stats{
+		Documents:   len(a.docs),
+		Parsed:      a.parsed,
+		CacheHits:   a.cacheHits,
+		Skipped:     len(a.skipped),
+		Suggestions: a.suggested,
+		Sources:     a.sourceCount,
+		Targets:     a.targetCount,
+		ScoredPairs: scored,
+		CachedPairs: cached,
+		MinScore:    a.threshold,
+		LoadTime:    a.loadTime,
+		AnalyzeTime: a.analyzeTime,
+		Inserted:    a.inserted,
+		Modified:    a.modified,
+	}
+}
+
+// progress reports progress through a phase to Config.OnProgress, if set
+func (a *Analyzer) progress(phase string, done, total int) {
+	if a.config.OnProgress != nil {
+		a.config.OnProgress(phase, done, total)
+	}
+}
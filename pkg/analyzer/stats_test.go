@@ -0,0 +1,61 @@
+package analyzer
+
+import (
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"internal-link/pkg/markdown"
+)
+
+func TestProgressAndStats(t *testing.T) {
+	root := writeFixture(t, hugoFixture)
+	cacheDir := t.TempDir()
+
+	type report struct {
+		phase       string
+		done, total int
+	}
+	run := func() ([]report, Stats) {
+		var reports []report
+		a, err := NewAnalyzer(Config{
+			ScoringOptions: ScoringOptions{
+				TargetDir:    root,
+				CacheDir:     cacheDir,
+				ParserConfig: markdown.ParserConfig{MinNGram: 2, MaxNGram: 3},
+			},
+			SelectionOptions: SelectionOptions{MinScore: 0.1},
+			ApplyOptions:     ApplyOptions{DryRun: true},
+			Log:              io.Discard,
+			OnProgress: func(phase string, done, total int) {
+				reports = append(reports, report{phase, done, total})
+			},
+		})
+		require.NoError(t, err)
+
+		suggestions, err := a.Analyze()
+		require.NoError(t, err)
+		stats := a.Stats()
+		assert.Equal(t, len(suggestions), stats.Suggestions)
+		return reports, stats
+	}
+
+	reports, stats := run()
+	assert.Equal(t, []report{
+		{PhaseLoad, 1, 3}, {PhaseLoad, 2, 3}, {PhaseLoad, 3, 3},
+		{PhaseAnalyze, 1, 3}, {PhaseAnalyze, 2, 3}, {PhaseAnalyze, 3, 3},
+	}, reports)
+	assert.Equal(t, 3, stats.Documents)
+	assert.Equal(t, 3, stats.Parsed)
+	assert.Equal(t, 0, stats.CacheHits)
+	assert.Positive(t, stats.Suggestions)
+	assert.Positive(t, stats.LoadTime)
+	assert.Positive(t, stats.AnalyzeTime)
+
+	// A second run reads every document from the cache
+	_, stats = run()
+	assert.Equal(t, 0, stats.Parsed)
+	assert.Equal(t, 3, stats.CacheHits)
+}
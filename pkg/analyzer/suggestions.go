@@ -0,0 +1,175 @@
+package analyzer
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"strconv"
+
+	"internal-link/pkg/markdown"
+	"internal-link/pkg/scorer"
+)
+
+// Formats of exported suggestion files
+const (
+	SuggestionsFormatJSON = "json" // The array written by --output json
+	SuggestionsFormatCSV  = "csv"  // One suggestion per row, with a header naming the JSON fields
+)
+
+// resolveWindow is how many bytes from its recorded position the phrase of
+// a suggestion is looked for when the file changed since it was exported
+const resolveWindow = 512
+
+// ReadSuggestions reads suggestions exported by an earlier run
+func ReadSuggestions(r io.Reader, format string) ([]scorer.LinkSuggestion, error) {
+	switch format {
+	case SuggestionsFormatJSON, "":
+		var suggestions []scorer.LinkSuggestion
+		if err := json.NewDecoder(r).Decode(&suggestions); err != nil {
+			return nil, fmt.Errorf("failed to decode suggestions: %w", err)
+		}
+		return suggestions, nil
+	case SuggestionsFormatCSV:
+		return readSuggestionsCSV(r)
+	default:
+		return nil, fmt.Errorf("invalid suggestions format %q (expected json or csv)", format)
+	}
+}
+
+// readSuggestionsCSV reads suggestions from CSV whose header names the
+// columns after the JSON fields of scorer.LinkSuggestion
+func readSuggestionsCSV(r io.Reader) ([]scorer.LinkSuggestion, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read suggestions: %w", err)
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	columns := make(map[string]int)
+	for i, name := range records[0] {
+		columns[name] = i
+	}
+	for _, name := range []string{"source_path", "target_path", "word_to_link", "position"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("failed to read suggestions: missing column %q", name)
+		}
+	}
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return record[i]
+		}
+		return ""
+	}
+
+	suggestions := make([]scorer.LinkSuggestion, 0, len(records)-1)
+	for n, record := range records[1:] {
+		s := scorer.LinkSuggestion{
+			SourcePath:    field(record, "source_path"),
+			TargetPath:    field(record, "target_path"),
+			Context:       field(record, "context"),
+			ContextBefore: field(record, "context_before"),
+			Phrase:        field(record, "phrase"),
+			ContextAfter:  field(record, "context_after"),
+			WordToLink:    field(record, "word_to_link"),
+			Anchor:        field(record, "anchor"),
+		}
+		if s.Position, err = strconv.Atoi(field(record, "position")); err != nil {
+			return nil, fmt.Errorf("failed to read suggestions: row %d: invalid position: %w", n+2, err)
+		}
+		if alternative := field(record, "alternative"); alternative != "" {
+			if s.Alternative, err = strconv.Atoi(alternative); err != nil {
+				return nil, fmt.Errorf("failed to read suggestions: row %d: invalid alternative: %w", n+2, err)
+			}
+		}
+		if score := field(record, "score"); score != "" {
+			if s.Score, err = strconv.ParseFloat(score, 64); err != nil {
+				return nil, fmt.Errorf("failed to read suggestions: row %d: invalid score: %w", n+2, err)
+			}
+		}
+		suggestions = append(suggestions, s)
+	}
+	return suggestions, nil
+}
+
+// ResolveSuggestions checks exported suggestions against the current
+// documents. A phrase that moved is looked for near its recorded position
+// and the suggestion re-anchored to it; suggestions whose phrase is gone or
+// no longer linkable are reported to the log and left out. The corpus is
+// loaded first, so the links written for them follow its documents' URLs
+// and titles.
+func (a *Analyzer) ResolveSuggestions(suggestions []scorer.LinkSuggestion) ([]scorer.LinkSuggestion, error) {
+	if err := a.ensureLoaded(); err != nil {
+		return nil, err
+	}
+	paths, byFile := groupByFile(suggestions)
+
+	var resolved []scorer.LinkSuggestion
+	for _, path := range paths {
+		content, err := a.readDocument(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			fmt.Fprintf(a.config.Log, "Skipping %d stale suggestion(s) for %s: file no longer exists\n", len(byFile[path]), path)
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file %s: %w", path, err)
+		}
+
+		occurrences, err := a.parser.FindOccurrences(a.prose(path, content), a.parser.MinWordLength())
+		var budgetErr *markdown.BudgetError
+		if err != nil && !errors.As(err, &budgetErr) {
+			return nil, fmt.Errorf("failed to find word occurrences in %s: %w", path, err)
+		}
+
+		for _, s := range byFile[path] {
+			if !a.documentExists(s.TargetPath) {
+				fmt.Fprintf(a.config.Log, "Skipping stale suggestion for %s in %s: target no longer exists\n", s.TargetPath, path)
+				continue
+			}
+			position, ok := nearestOccurrence(occurrences, s.WordToLink, s.Position)
+			if !ok {
+				fmt.Fprintf(a.config.Log, "Skipping stale suggestion for %s in %s: '%s' no longer found near position %d\n",
+					s.TargetPath, path, s.WordToLink, s.Position)
+				continue
+			}
+			s.Position = position
+			resolved = append(resolved, s)
+		}
+	}
+
+	return resolved, nil
+}
+
+// documentExists reports whether a document is part of the corpus
+func (a *Analyzer) documentExists(path string) bool {
+	_, ok := a.docs[path]
+	return ok
+}
+
+// nearestOccurrence returns the position of the linkable occurrence of
+// phrase, as written, closest to position and within resolveWindow of it
+func nearestOccurrence(occurrences []markdown.WordOccurrence, phrase string, position int) (int, bool) {
+	best, bestDistance := 0, resolveWindow+1
+	for _, occ := range occurrences {
+		text := occ.Surface
+		if text == "" {
+			text = occ.Word
+		}
+		if text != phrase {
+			continue
+		}
+
+		distance := occ.Position - position
+		if distance < 0 {
+			distance = -distance
+		}
+		if distance < bestDistance || distance == bestDistance && occ.Position < best {
+			best, bestDistance = occ.Position, distance
+		}
+	}
+	return best, bestDistance <= resolveWindow
+}
@@ -0,0 +1,105 @@
+package analyzer
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"internal-link/pkg/scorer"
+)
+
+func TestReadSuggestions(t *testing.T) {
+	expected := []scorer.LinkSuggestion{
+		{SourcePath: "post.md", TargetPath: "alerts.md", Score: 0.5, Context: "we tuned it, today", WordToLink: "prometheus alerting", Position: 9},
+		{SourcePath: "post.md", TargetPath: "setup.md", WordToLink: "grafana", Position: 40, Anchor: "install"},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, json.NewEncoder(&buf).Encode(expected))
+	suggestions, err := ReadSuggestions(&buf, SuggestionsFormatJSON)
+	require.NoError(t, err)
+	assert.Equal(t, expected, suggestions)
+
+	// Columns are found by name, so they may come in any order
+	csv := "position,source_path,target_path,word_to_link,score,context,anchor\n" +
+		"9,post.md,alerts.md,prometheus alerting,0.5,\"we tuned it, today\",\n" +
+		"40,post.md,setup.md,grafana,,,install\n"
+	suggestions, err = ReadSuggestions(strings.NewReader(csv), SuggestionsFormatCSV)
+	require.NoError(t, err)
+	assert.Equal(t, expected, suggestions)
+
+	_, err = ReadSuggestions(strings.NewReader("source_path,target_path,word_to_link\npost.md,alerts.md,grafana\n"), SuggestionsFormatCSV)
+	assert.ErrorContains(t, err, `missing column "position"`)
+
+	_, err = ReadSuggestions(strings.NewReader("source_path,target_path,word_to_link,position\npost.md,alerts.md,grafana,x\n"), SuggestionsFormatCSV)
+	assert.ErrorContains(t, err, "row 2: invalid position")
+
+	_, err = ReadSuggestions(strings.NewReader("[]"), "yaml")
+	assert.ErrorContains(t, err, "invalid suggestions format")
+}
+
+func TestResolveSuggestions(t *testing.T) {
+	root := writeFixture(t, map[string]string{
+		"alerts.md": "a guide to prometheus alerting for operators.\n",
+		"post.md":   "some new intro text.\n\nwe tuned prometheus alerting today.\n",
+		"logs.md":   "loki logs everywhere.\n",
+	})
+	source := filepath.Join(root, "post.md")
+	var log bytes.Buffer
+	a := newTestAnalyzer(t, root, Config{Log: &log})
+
+	// Exported before the intro was added
+	suggestions := []scorer.LinkSuggestion{
+		{SourcePath: source, TargetPath: filepath.Join(root, "alerts.md"), WordToLink: "prometheus alerting", Position: 9},
+		{SourcePath: source, TargetPath: filepath.Join(root, "logs.md"), WordToLink: "grafana dashboards", Position: 20},
+		{SourcePath: source, TargetPath: filepath.Join(root, "gone.md"), WordToLink: "prometheus alerting", Position: 9},
+		{SourcePath: filepath.Join(root, "deleted.md"), TargetPath: filepath.Join(root, "alerts.md"), WordToLink: "prometheus alerting", Position: 0},
+	}
+
+	resolved, err := a.ResolveSuggestions(suggestions)
+	require.NoError(t, err)
+	require.Len(t, resolved, 1)
+	assert.Equal(t, 31, resolved[0].Position)
+	assert.Contains(t, log.String(), "'grafana dashboards' no longer found")
+	assert.Contains(t, log.String(), "target no longer exists")
+	assert.Contains(t, log.String(), "file no longer exists")
+
+	require.NoError(t, a.ApplyChanges(resolved))
+	content, err := os.ReadFile(source)
+	require.NoError(t, err)
+	assert.Equal(t, "some new intro text.\n\nwe tuned [prometheus alerting](alerts.md) today.\n", string(content))
+
+	// Once linked, the phrase is no longer linkable and the suggestion is stale
+	resolved, err = a.ResolveSuggestions(suggestions[:1])
+	require.NoError(t, err)
+	assert.Empty(t, resolved)
+}
+
+func TestResolveSuggestionsLoadsCorpus(t *testing.T) {
+	root := writeFixture(t, map[string]string{
+		"alerts.md": "a guide to prometheus alerting for operators.\n",
+		"post.md":   "we tuned prometheus alerting today.\n",
+		"draft.txt": "not part of the corpus.\n",
+	})
+	source := filepath.Join(root, "post.md")
+	a := newTestAnalyzer(t, root, Config{ApplyOptions: ApplyOptions{URLTemplate: "/blog/{slug}/"}})
+
+	resolved, err := a.ResolveSuggestions([]scorer.LinkSuggestion{
+		{SourcePath: source, TargetPath: filepath.Join(root, "alerts.md"), WordToLink: "prometheus alerting", Position: 9},
+		{SourcePath: source, TargetPath: filepath.Join(root, "draft.txt"), WordToLink: "prometheus alerting", Position: 9},
+	})
+	require.NoError(t, err)
+	require.Len(t, resolved, 1)
+
+	// The link is written to the target's published URL
+	require.NoError(t, a.ApplyChanges(resolved))
+	content, err := os.ReadFile(source)
+	require.NoError(t, err)
+	assert.Equal(t, "we tuned [prometheus alerting](/blog/alerts/) today.\n", string(content))
+}
@@ -0,0 +1,32 @@
+package analyzer
+
+import "strings"
+
+// sharedTaxonomy returns the terms of source, such as its tags, that target
+// has too. Terms are compared case-insensitively and returned as source
+// writes them, once each.
+func sharedTaxonomy(source, target []string) []string {
+	if len(source) == 0 || len(target) == 0 {
+		return nil
+	}
+	has := make(map[string]bool, len(target))
+	for _, term := range target {
+		has[taxonomyKey(term)] = true
+	}
+
+	var shared []string
+	seen := make(map[string]bool)
+	for _, term := range source {
+		key := taxonomyKey(term)
+		if has[key] && !seen[key] {
+			seen[key] = true
+			shared = append(shared, term)
+		}
+	}
+	return shared
+}
+
+// taxonomyKey is the form taxonomy terms are compared in
+func taxonomyKey(term string) string {
+	return strings.ToLower(strings.TrimSpace(term))
+}
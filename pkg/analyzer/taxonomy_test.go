@@ -0,0 +1,69 @@
+package analyzer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"internal-link/pkg/scorer"
+)
+
+func TestSharedTaxonomy(t *testing.T) {
+	assert.Equal(t, []string{"Kubernetes", "CI"}, sharedTaxonomy([]string{"Kubernetes", "Go", "CI", "kubernetes"}, []string{"ci", " KUBERNETES "}))
+	assert.Empty(t, sharedTaxonomy([]string{"go"}, []string{"rust"}))
+	assert.Empty(t, sharedTaxonomy(nil, []string{"go"}))
+}
+
+var taxonomyCorpus = map[string]string{
+	"post.md":   "---\ntags: [Kubernetes, CI]\ncategories: [ops]\n---\nwe tune prometheus alerting and grafana dashboards.\n",
+	"alerts.md": "---\ntags: [kubernetes]\ncategories: Ops\n---\nprometheus alerting rules for the cluster.\n",
+	"dash.md":   "---\ncategories: [dev]\n---\ngrafana dashboards for the team.\n",
+}
+
+// suggestionsByPair indexes suggestions by source and target
+func suggestionsByPair(suggestions []scorer.LinkSuggestion) map[[2]string]scorer.LinkSuggestion {
+	pairs := make(map[[2]string]scorer.LinkSuggestion)
+	for _, s := range suggestions {
+		pairs[[2]string{s.SourcePath, s.TargetPath}] = s
+	}
+	return pairs
+}
+
+func TestTagBoost(t *testing.T) {
+	a := newMemoryAnalyzer(t, taxonomyCorpus)
+	plain, err := a.AnalyzeWith(SelectionOptions{MinScore: 0.1})
+	require.NoError(t, err)
+	boosted, err := a.AnalyzeWith(SelectionOptions{MinScore: 0.1, TagBoost: 2})
+	require.NoError(t, err)
+
+	before, after := suggestionsByPair(plain), suggestionsByPair(boosted)
+	tagged := [2]string{"post.md", "alerts.md"}
+	require.Contains(t, before, tagged)
+	require.Contains(t, after, tagged)
+	assert.InDelta(t, 2*before[tagged].Score, after[tagged].Score, 1e-9)
+	assert.Equal(t, []string{"Kubernetes"}, after[tagged].SharedTags)
+
+	untagged := [2]string{"post.md", "dash.md"}
+	require.Contains(t, after, untagged)
+	assert.Equal(t, before[untagged].Score, after[untagged].Score)
+	assert.Empty(t, after[untagged].SharedTags)
+}
+
+func TestSameCategoryOnly(t *testing.T) {
+	a := newMemoryAnalyzer(t, taxonomyCorpus)
+	suggestions, err := a.AnalyzeWith(SelectionOptions{MinScore: 0.1, SameCategoryOnly: true})
+	require.NoError(t, err)
+
+	pairs := suggestionsByPair(suggestions)
+	assert.Contains(t, pairs, [2]string{"post.md", "alerts.md"}, "categories match case-insensitively")
+	assert.NotContains(t, pairs, [2]string{"post.md", "dash.md"})
+	for _, s := range suggestions {
+		assert.NotEqual(t, "dash.md", s.SourcePath)
+	}
+}
+
+func TestTagBoostValidation(t *testing.T) {
+	_, err := newMemoryAnalyzer(t, taxonomyCorpus).AnalyzeWith(SelectionOptions{MinScore: 0.1, TagBoost: -1})
+	assert.ErrorContains(t, err, "invalid tag boost")
+}
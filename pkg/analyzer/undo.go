@@ -0,0 +1,208 @@
+package analyzer
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"internal-link/pkg/journal"
+)
+
+// journalRoot identifies the corpus in the journal, which may be shared by
+// several directories through the cache
+func (a *Analyzer) journalRoot() string {
+	root, err := filepath.Abs(a.config.TargetDir)
+	if err != nil {
+		return a.config.TargetDir
+	}
+	return root
+}
+
+// journalInsertions records the links just written to path. Edits are
+// planned back to front, so each link ends up shifted by the length the
+// links before it added.
+func (a *Analyzer) journalInsertions(path string, planned []plannedEdit) {
+	if a.run == nil {
+		a.run = a.journal.Begin(a.journalRoot())
+	}
+	file, err := filepath.Abs(path)
+	if err != nil {
+		file = path
+	}
+
+	source := a.relPath(path)
+	shift := 0
+	for i := len(planned) - 1; i >= 0; i-- {
+		e := planned[i].edit
+		if !planned[i].references {
+			a.run.Insertions = append(a.run.Insertions, journal.Insertion{
+				File:       file,
+				Target:     a.relPath(planned[i].suggestion.TargetPath),
+				Position:   e.ByteOffset + shift,
+				Phrase:     planned[i].suggestion.WordToLink,
+				Markup:     e.InsertText,
+				Definition: planned[i].definition,
+				Source:     source,
+			})
+		}
+		shift += len(e.InsertText) - e.DeleteLen
+	}
+}
+
+// Undo removes the links inserted into TargetDir by earlier runs, most
+// recent run first, or only those of the most recent run when last is set.
+// Links that are no longer found at or near where they were inserted are
+// reported to the log. It returns how many links were removed.
+func (a *Analyzer) Undo(last bool) (int, error) {
+	runs := a.journal.RunsFor(a.journalRoot())
+	if len(runs) == 0 {
+		fmt.Fprintln(a.config.Log, "Nothing to undo")
+		return 0, nil
+	}
+	if last {
+		runs = runs[:1]
+	}
+
+	removed := 0
+	for _, run := range runs {
+		n, err := a.undoRun(run)
+		if err != nil {
+			return removed, err
+		}
+		removed += n
+		a.journal.Remove(run)
+	}
+
+	if a.config.DryRun {
+		return removed, nil
+	}
+	if err := a.journal.Save(); err != nil {
+		return removed, fmt.Errorf("failed to save link journal: %w", err)
+	}
+	if err := a.history.Save(); err != nil {
+		return removed, fmt.Errorf("failed to save link history: %w", err)
+	}
+	return removed, nil
+}
+
+// undoRun reverts the insertions of one run, each file back to front so the
+// recorded positions of earlier links stay valid
+func (a *Analyzer) undoRun(run *journal.Run) (int, error) {
+	byFile := make(map[string][]journal.Insertion)
+	var files []string
+	for _, ins := range run.Insertions {
+		if _, seen := byFile[ins.File]; !seen {
+			files = append(files, ins.File)
+		}
+		byFile[ins.File] = append(byFile[ins.File], ins)
+	}
+
+	removed := 0
+	for _, file := range files {
+		insertions := byFile[file]
+		sort.SliceStable(insertions, func(i, j int) bool {
+			return insertions[i].Position > insertions[j].Position
+		})
+
+		original, err := a.readDocument(file)
+		if err != nil {
+			fmt.Fprintf(a.config.Log, "Cannot undo %d link(s) in %s: %v\n", len(insertions), file, err)
+			continue
+		}
+
+		content := original
+		for _, ins := range insertions {
+			pos, ok := findMarkup(content, ins.Markup, ins.Position)
+			if !ok {
+				fmt.Fprintf(a.config.Log, "Cannot undo link '%s' in %s: %s no longer found near position %d\n",
+					ins.Phrase, file, ins.Markup, ins.Position)
+				continue
+			}
+
+			result := make([]byte, 0, len(content)-len(ins.Markup)+len(ins.Phrase))
+			result = append(result, content[:pos]...)
+			result = append(result, ins.Phrase...)
+			result = append(result, content[pos+len(ins.Markup):]...)
+			content = result
+
+			// File is absolute, so it only names the history entry when
+			// TargetDir is absolute too
+			source := ins.Source
+			if source == "" {
+				source = a.relPath(file)
+			}
+			a.history.Forget(source, ins.Target, a.parser.Normalize(ins.Phrase))
+			removed++
+		}
+		for _, ins := range insertions {
+			if ins.Definition != "" {
+				content = removeDefinition(content, ins.Definition)
+			}
+		}
+
+		if a.config.DryRun || bytes.Equal(content, original) {
+			continue
+		}
+		if err := a.writeDocument(file, original, content); err != nil {
+			return removed, err
+		}
+	}
+
+	return removed, nil
+}
+
+// removeDefinition drops a reference definition line the tool added, unless
+// a link still uses its label, along with the blank line that separated the
+// definitions from the rest of the document if it is the last one left
+func removeDefinition(content []byte, definition string) []byte {
+	label, _, ok := strings.Cut(strings.TrimPrefix(definition, "["), "]:")
+	if !ok || bytes.Contains(bytes.ToLower(content), []byte(strings.ToLower("]["+label+"]"))) {
+		return content
+	}
+
+	line := []byte(definition + "\n")
+	i := bytes.Index(content, line)
+	if i == -1 || i > 0 && content[i-1] != '\n' {
+		return content
+	}
+	result := append(append([]byte(nil), content[:i]...), content[i+len(line):]...)
+	if i == len(result) && bytes.HasSuffix(result, []byte("\n\n")) {
+		result = result[:len(result)-1]
+	}
+	return result
+}
+
+// findMarkup returns the offset of the occurrence of markup closest to
+// position, looking no further than resolveWindow bytes away
+func findMarkup(content []byte, markup string, position int) (int, bool) {
+	if position >= 0 && position+len(markup) <= len(content) && string(content[position:position+len(markup)]) == markup {
+		return position, true
+	}
+
+	start := max(position-resolveWindow, 0)
+	end := min(position+resolveWindow+len(markup), len(content))
+	if start >= end {
+		return 0, false
+	}
+
+	best, bestDistance := 0, resolveWindow+1
+	window := content[start:end]
+	for offset := 0; ; {
+		i := bytes.Index(window[offset:], []byte(markup))
+		if i == -1 {
+			break
+		}
+		pos := start + offset + i
+		distance := pos - position
+		if distance < 0 {
+			distance = -distance
+		}
+		if distance < bestDistance {
+			best, bestDistance = pos, distance
+		}
+		offset += i + 1
+	}
+	return best, bestDistance <= resolveWindow
+}
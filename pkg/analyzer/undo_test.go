@@ -0,0 +1,105 @@
+package analyzer
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"internal-link/pkg/scorer"
+)
+
+func TestUndo(t *testing.T) {
+	root := writeFixture(t, map[string]string{"post.md": editsPost})
+	source := filepath.Join(root, "post.md")
+	cacheDir := t.TempDir()
+	newAnalyzer := func(log *bytes.Buffer) *Analyzer {
+		a, err := NewAnalyzer(Config{
+			ScoringOptions: ScoringOptions{TargetDir: root, CacheDir: cacheDir},
+			Log:            log,
+		})
+		require.NoError(t, err)
+		return a
+	}
+
+	// Two runs, each inserting links in the same file
+	var log bytes.Buffer
+	require.NoError(t, newAnalyzer(&log).ApplyChanges(editsSuggestions(source)[:2]))
+	firstRun, err := os.ReadFile(source)
+	require.NoError(t, err)
+	second := editsSuggestions(source)[2]
+	second.Position += len("[prometheus alerting](alerts.md)") - len("prometheus alerting")
+	require.NoError(t, newAnalyzer(&log).ApplyChanges([]scorer.LinkSuggestion{second}))
+
+	removed, err := newAnalyzer(&log).Undo(true)
+	require.NoError(t, err)
+	assert.Equal(t, 1, removed)
+	content, err := os.ReadFile(source)
+	require.NoError(t, err)
+	assert.Equal(t, string(firstRun), string(content))
+
+	// A link moved by a later edit is still found near where it was inserted
+	edited := "Intro.\n" + string(content)
+	require.NoError(t, os.WriteFile(source, []byte(edited), 0644))
+
+	a := newAnalyzer(&log)
+	removed, err = a.Undo(false)
+	require.NoError(t, err)
+	assert.Equal(t, 2, removed)
+	content, err = os.ReadFile(source)
+	require.NoError(t, err)
+	assert.Equal(t, "Intro.\n"+editsPost, string(content))
+	assert.False(t, a.history.HasPair("post.md", "alerts.md"))
+
+	removed, err = newAnalyzer(&log).Undo(false)
+	require.NoError(t, err)
+	assert.Zero(t, removed)
+}
+
+func TestUndoReportsMissingLinks(t *testing.T) {
+	root := writeFixture(t, map[string]string{"post.md": editsPost})
+	source := filepath.Join(root, "post.md")
+	var log bytes.Buffer
+	a, err := NewAnalyzer(Config{
+		ScoringOptions: ScoringOptions{TargetDir: root, CacheDir: t.TempDir()},
+		Log:            &log,
+	})
+	require.NoError(t, err)
+	require.NoError(t, a.ApplyChanges(editsSuggestions(source)[:2]))
+
+	// Someone rewrote one of the links by hand
+	content, err := os.ReadFile(source)
+	require.NoError(t, err)
+	content = bytes.Replace(content, []byte("[loki logs](loki.md)"), []byte("[loki logs](logging.md)"), 1)
+	require.NoError(t, os.WriteFile(source, content, 0644))
+
+	removed, err := a.Undo(true)
+	require.NoError(t, err)
+	assert.Equal(t, 1, removed)
+	assert.Contains(t, log.String(), "Cannot undo link 'loki logs'")
+
+	content, err = os.ReadFile(source)
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "we use prometheus alerting with")
+	assert.Contains(t, string(content), "[loki logs](logging.md)")
+}
+
+func TestUndoRelativeTargetDir(t *testing.T) {
+	root := writeFixture(t, map[string]string{"post.md": editsPost})
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(root))
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	a := newTestAnalyzer(t, ".", Config{})
+	require.NoError(t, a.ApplyChanges(editsSuggestions("post.md")[:1]))
+	require.True(t, a.history.HasPair("post.md", "alerts.md"))
+
+	removed, err := a.Undo(false)
+	require.NoError(t, err)
+	assert.Equal(t, 1, removed)
+	assert.False(t, a.history.HasPair("post.md", "alerts.md"))
+}
@@ -0,0 +1,178 @@
+package analyzer
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// treeWalker walks a directory tree like filepath.Walk, but can descend into
+// symlinked directories too. Directories are remembered by their real path,
+// so one reached again, e.g. through a link back up the tree, is not walked
+// a second time.
+type treeWalker struct {
+	follow  bool
+	visited map[string]bool
+	fn      filepath.WalkFunc
+}
+
+// walkTree calls fn for root and everything beneath it in lexical order.
+// root itself is followed if it is a symlink; links beneath it only when
+// follow is set, otherwise fn sees them as they are.
+func walkTree(root string, follow bool, fn filepath.WalkFunc) error {
+	info, err := os.Stat(root)
+	if err != nil {
+		return fn(root, nil, err)
+	}
+	w := &treeWalker{follow: follow, visited: make(map[string]bool), fn: fn}
+	err = w.walk(root, info)
+	if err == filepath.SkipDir || err == filepath.SkipAll {
+		return nil
+	}
+	return err
+}
+
+// walk visits path and, if it is a directory not visited yet, its entries
+func (w *treeWalker) walk(path string, info fs.FileInfo) error {
+	if !info.IsDir() {
+		return w.fn(path, info, nil)
+	}
+
+	real, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return w.fn(path, info, err)
+	}
+	if w.visited[real] {
+		return nil
+	}
+	if err := w.fn(path, info, nil); err != nil {
+		return err
+	}
+	w.visited[real] = true
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return w.fn(path, info, err)
+	}
+	for _, entry := range entries {
+		child := filepath.Join(path, entry.Name())
+		info, err := w.stat(child)
+		if err != nil {
+			if err := w.fn(child, info, err); err != nil && err != filepath.SkipDir {
+				return err
+			}
+			continue
+		}
+		if err := w.walk(child, info); err != nil {
+			if err == filepath.SkipDir && info.IsDir() {
+				continue
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// stat describes an entry, following it when it is a symlink and links are
+// followed. A link that can't be resolved is described as the link itself,
+// leaving it to reading the file to fail.
+func (w *treeWalker) stat(path string) (fs.FileInfo, error) {
+	info, err := os.Lstat(path)
+	if err != nil || !w.follow || info.Mode()&fs.ModeSymlink == 0 {
+		return info, err
+	}
+	if target, err := os.Stat(path); err == nil {
+		return target, nil
+	}
+	return info, nil
+}
+
+// canonicalPaths spells the paths a walk of TargetDir reached documents by
+// the same way for the same file
+type canonicalPaths struct {
+	root     string          // TargetDir as given
+	realRoot string          // TargetDir with symlinks resolved
+	fold     bool            // Whether the filesystem ignores case
+	seen     map[string]bool // Real paths of the documents found so far
+}
+
+// newCanonicalPaths resolves the symlinks of TargetDir
+func (a *Analyzer) newCanonicalPaths() *canonicalPaths {
+	c := &canonicalPaths{root: a.config.TargetDir, fold: a.foldCase, seen: make(map[string]bool)}
+	if real, err := filepath.EvalSymlinks(a.config.TargetDir); err == nil {
+		if abs, err := filepath.Abs(real); err == nil {
+			c.realRoot = abs
+		}
+	}
+	return c
+}
+
+// add returns the canonical path of a document, and false if the file it
+// resolves to was added before under another name. A file that really lies
+// beneath TargetDir is named by that real location; one outside of it keeps
+// the first path it was reached by, which is where it appears in the site.
+func (c *canonicalPaths) add(path string) (string, bool) {
+	real, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		// Dangling; left to reading the file to fail
+		return path, true
+	}
+	if real, err = filepath.Abs(real); err != nil {
+		return path, true
+	}
+	key := real
+	if c.fold {
+		key = strings.ToLower(real)
+	}
+	if c.seen[key] {
+		return "", false
+	}
+	c.seen[key] = true
+
+	if c.realRoot != "" {
+		if rel, ok := within(c.realRoot, real); ok {
+			return filepath.Join(c.root, rel), true
+		}
+	}
+	return path, true
+}
+
+// caseInsensitive reports whether the filesystem holding dir ignores the
+// case of names, as those of macOS and Windows do by default, by looking dir
+// up again with the case of its path swapped
+func caseInsensitive(dir string) bool {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return false
+	}
+	swapped := strings.Map(func(r rune) rune {
+		if unicode.IsUpper(r) {
+			return unicode.ToLower(r)
+		}
+		return unicode.ToUpper(r)
+	}, abs)
+	if swapped == abs {
+		return false
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return false
+	}
+	other, err := os.Stat(swapped)
+	return err == nil && os.SameFile(info, other)
+}
+
+// samePath reports whether two document paths name the same document,
+// ignoring case where the filesystem of TargetDir does
+func (a *Analyzer) samePath(p, q string) bool {
+	if p == q {
+		return true
+	}
+	p, q = filepath.Clean(p), filepath.Clean(q)
+	if a.foldCase {
+		return strings.EqualFold(p, q)
+	}
+	return p == q
+}
@@ -0,0 +1,172 @@
+package analyzer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"internal-link/pkg/scorer"
+)
+
+// symlinkFixture links a shared section from outside root into it, adds a
+// second name for one of its documents and a link looping back to root
+func symlinkFixture(t *testing.T) string {
+	t.Helper()
+	root := writeFixture(t, map[string]string{
+		"posts/alerts.md": "# Alerts\n\nprometheus alerting rules and prometheus alerting basics.\n",
+		"posts/post.md":   "# Post\n\nwe changed prometheus alerting rules and the grafana dashboards.\n",
+	})
+	shared := writeFixture(t, map[string]string{
+		"dashboards.md": "# Dashboards\n\ngrafana dashboards for grafana dashboards users.\n",
+	})
+	require.NoError(t, os.Symlink(shared, filepath.Join(root, "shared")))
+	require.NoError(t, os.Symlink(filepath.Join(root, "posts", "alerts.md"), filepath.Join(root, "alias.md")))
+	require.NoError(t, os.Symlink(root, filepath.Join(root, "posts", "loop")))
+	return root
+}
+
+func TestFindDocumentsSymlinks(t *testing.T) {
+	root := symlinkFixture(t)
+
+	tests := []struct {
+		name     string
+		follow   bool
+		expected []string
+	}{
+		{name: "not followed", expected: []string{"posts/alerts.md", "posts/post.md"}},
+		{name: "followed", follow: true, expected: []string{"posts/alerts.md", "posts/post.md", "shared/dashboards.md"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestAnalyzer(t, root, Config{ScoringOptions: ScoringOptions{FollowSymlinks: tt.follow}})
+			paths, err := a.findDocuments()
+			require.NoError(t, err)
+
+			var rel []string
+			for _, path := range paths {
+				rel = append(rel, a.relPath(path))
+			}
+			assert.Equal(t, tt.expected, rel)
+		})
+	}
+}
+
+func TestSymlinkedTargetDir(t *testing.T) {
+	root := symlinkFixture(t)
+	link := filepath.Join(t.TempDir(), "site")
+	require.NoError(t, os.Symlink(root, link))
+
+	a := newTestAnalyzer(t, link, Config{})
+	paths, err := a.findDocuments()
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(link, "posts", "alerts.md"), filepath.Join(link, "posts", "post.md")}, paths)
+}
+
+func TestSuggestionsUseCanonicalPaths(t *testing.T) {
+	root := symlinkFixture(t)
+	a := newTestAnalyzer(t, root, Config{
+		ScoringOptions:   ScoringOptions{FollowSymlinks: true},
+		SelectionOptions: SelectionOptions{MinScore: 0.1},
+	})
+	suggestions, err := a.Analyze()
+	require.NoError(t, err)
+	require.NotEmpty(t, suggestions)
+
+	targets := make(map[string]bool)
+	for _, s := range suggestions {
+		assert.NotEqual(t, "alias.md", a.relPath(s.SourcePath))
+		assert.NotEqual(t, "alias.md", a.relPath(s.TargetPath))
+		targets[a.relPath(s.TargetPath)] = true
+	}
+	assert.True(t, targets["shared/dashboards.md"])
+}
+
+func TestAliasedPathsDoNotLinkToThemselves(t *testing.T) {
+	root := writeFixture(t, map[string]string{
+		"content/alerts.md": "# Alerts\n\nprometheus alerting rules and prometheus alerting basics.\n",
+		"content/post.md":   "# Post\n\nwe changed prometheus alerting rules and prometheus alerting basics.\n",
+	})
+	require.NoError(t, os.Symlink(filepath.Join(root, "content"), filepath.Join(root, "alias")))
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(root))
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	for _, source := range []string{"./content/../content/post.md", "content/./post.md", "alias/post.md"} {
+		t.Run(source, func(t *testing.T) {
+			a := newTestAnalyzer(t, "./content/../content", Config{
+				SelectionOptions: SelectionOptions{MinScore: 0.1, SingleFile: source},
+			})
+			suggestions, err := a.Analyze()
+			require.NoError(t, err)
+			require.NotEmpty(t, suggestions)
+			for _, s := range suggestions {
+				assert.Equal(t, filepath.Join("content", "post.md"), s.SourcePath)
+				assert.Equal(t, filepath.Join("content", "alerts.md"), s.TargetPath)
+			}
+		})
+	}
+}
+
+func TestSamePathFoldsCase(t *testing.T) {
+	a := &Analyzer{}
+	assert.True(t, a.samePath("content/post.md", "content/./post.md"))
+	assert.False(t, a.samePath("content/Post.md", "content/post.md"))
+
+	a.foldCase = true
+	assert.True(t, a.samePath("content/Post.md", "content/post.md"))
+	assert.False(t, a.samePath("content/post.md", "content/other.md"))
+}
+
+func TestRootsFormOneCorpus(t *testing.T) {
+	root := writeFixture(t, map[string]string{
+		"posts/ops.md":       "We rely on prometheus alerting for incidents.\n",
+		"docs/alerting.md":   "---\ntitle: Prometheus alerting\n---\nPrometheus alerting guide with prometheus alerting rules.\n",
+		"other/unrelated.md": "Prometheus alerting elsewhere, prometheus alerting again.\n",
+	})
+	a := newTestAnalyzer(t, root, Config{
+		ScoringOptions:   ScoringOptions{Roots: []string{filepath.Join(root, "posts"), filepath.Join(root, "docs")}},
+		SelectionOptions: SelectionOptions{MinScore: 0.1},
+	})
+
+	suggestions, err := a.Analyze()
+	require.NoError(t, err)
+	assert.Len(t, a.docs, 2, "documents outside the roots are left out")
+	require.NotEmpty(t, suggestions)
+	for _, s := range suggestions {
+		assert.NotContains(t, s.TargetPath, "other")
+	}
+
+	source := filepath.Join(root, "posts", "ops.md")
+	var fromPost []scorer.LinkSuggestion
+	for _, s := range suggestions {
+		if s.SourcePath == source {
+			fromPost = append(fromPost, s)
+		}
+	}
+	require.Len(t, fromPost, 1)
+	require.NoError(t, a.ApplyChanges(fromPost))
+	content, err := os.ReadFile(source)
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "[prometheus alerting](../docs/alerting.md)")
+}
+
+func TestRootOutsideTargetDir(t *testing.T) {
+	_, err := NewAnalyzer(Config{
+		ScoringOptions: ScoringOptions{TargetDir: t.TempDir(), Roots: []string{t.TempDir()}},
+	})
+	assert.ErrorContains(t, err, "does not lie beneath the target directory")
+}
+
+func TestRootsLeftAsGiven(t *testing.T) {
+	root := t.TempDir()
+	roots := []string{root + "/posts/", root + "/docs/./"}
+	a := newTestAnalyzer(t, root, Config{ScoringOptions: ScoringOptions{Roots: roots}})
+
+	assert.Equal(t, []string{filepath.Join(root, "posts"), filepath.Join(root, "docs")}, a.config.Roots)
+	assert.Equal(t, []string{root + "/posts/", root + "/docs/./"}, roots, "the caller's roots are not rewritten")
+}
@@ -0,0 +1,62 @@
+package analyzer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// backupSuffix is appended to the name of the copy kept of each modified file
+const backupSuffix = ".bak"
+
+// writeDocument replaces the content of a document without ever leaving it
+// half written: the new content goes to a temporary file in the same
+// directory, which is then renamed over the original, keeping its mode.
+// With Backup set the original content is first kept next to it.
+func (a *Analyzer) writeDocument(path string, original, updated []byte) error {
+	// Replace the file a symlink points to, not the link itself
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("failed to stat file %s: %w", path, err)
+	}
+
+	if a.config.Backup {
+		if err := writeAtomic(path+backupSuffix, original, info.Mode().Perm()); err != nil {
+			return fmt.Errorf("failed to back up file %s: %w", path, err)
+		}
+	}
+	if err := writeAtomic(path, updated, info.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to write file %s: %w", path, err)
+	}
+	return nil
+}
+
+// writeAtomic writes content to a temporary file next to path and renames it
+// into place, so readers see either the old or the new content
+func writeAtomic(path string, content []byte, mode os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // No-op once renamed
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
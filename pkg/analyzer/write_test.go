@@ -0,0 +1,118 @@
+package analyzer
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"internal-link/pkg/scorer"
+)
+
+func TestApplyChangesWritesAtomically(t *testing.T) {
+	root := writeFixture(t, map[string]string{"post.md": editsPost, "alerts.md": "alerts\n"})
+	source := filepath.Join(root, "post.md")
+	require.NoError(t, os.Chmod(source, 0600))
+
+	var log bytes.Buffer
+	a := newTestAnalyzer(t, root, Config{ApplyOptions: ApplyOptions{Backup: true}, Log: &log})
+	require.NoError(t, a.ApplyChanges(editsSuggestions(source)))
+
+	// The original mode is kept rather than reset to 0644
+	info, err := os.Stat(source)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	backup, err := os.ReadFile(source + backupSuffix)
+	require.NoError(t, err)
+	assert.Equal(t, editsPost, string(backup))
+
+	// No temporary files are left behind
+	entries, err := os.ReadDir(root)
+	require.NoError(t, err)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	assert.ElementsMatch(t, []string{"alerts.md", "post.md", "post.md.bak", ".internal-link-history.json"}, names)
+
+	// The overlapping suggestion is skipped, so three links went in
+	assert.Contains(t, log.String(), "Modified 1 file(s):\n  "+source+": 3 link(s) inserted\n")
+}
+
+func TestApplyChangesWithoutBackup(t *testing.T) {
+	root := writeFixture(t, map[string]string{"post.md": editsPost})
+	source := filepath.Join(root, "post.md")
+	a := newTestAnalyzer(t, root, Config{})
+
+	require.NoError(t, a.ApplyChanges([]scorer.LinkSuggestion{
+		{SourcePath: source, TargetPath: filepath.Join(root, "alerts.md"), WordToLink: "prometheus alerting", Position: 21},
+	}))
+	assert.NoFileExists(t, source+backupSuffix)
+}
+
+func TestApplyChangesThroughSymlink(t *testing.T) {
+	root := writeFixture(t, map[string]string{"real/post.md": editsPost})
+	real := filepath.Join(root, "real", "post.md")
+	link := filepath.Join(root, "post.md")
+	require.NoError(t, os.Symlink(real, link))
+	a := newTestAnalyzer(t, root, Config{})
+
+	require.NoError(t, a.ApplyChanges([]scorer.LinkSuggestion{
+		{SourcePath: link, TargetPath: filepath.Join(root, "alerts.md"), WordToLink: "prometheus alerting", Position: 21},
+	}))
+
+	// The link still points at the file, which received the edit
+	target, err := os.Readlink(link)
+	require.NoError(t, err)
+	assert.Equal(t, real, target)
+	content, err := os.ReadFile(real)
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "[prometheus alerting](alerts.md)")
+}
+
+func TestApplyChangesIsTransactional(t *testing.T) {
+	other := "loki logs are kept for a week.\n"
+	root := writeFixture(t, map[string]string{"post.md": editsPost, "other.md": other})
+	source := filepath.Join(root, "post.md")
+	otherPath := filepath.Join(root, "other.md")
+	suggestions := []scorer.LinkSuggestion{
+		{SourcePath: source, TargetPath: filepath.Join(root, "alerts.md"), WordToLink: "prometheus alerting", Position: 21},
+		{SourcePath: otherPath, TargetPath: filepath.Join(root, "loki.md"), WordToLink: "loki logs", Position: 0},
+		{SourcePath: otherPath, TargetPath: filepath.Join(root, "week.md"), WordToLink: "a month", Position: 22},
+		{SourcePath: source, TargetPath: filepath.Join(root, "grafana.md"), WordToLink: "grafana dashboards", Position: 400},
+	}
+
+	// A single mismatch writes nothing, and every failure is reported
+	err := newTestAnalyzer(t, root, Config{}).ApplyChanges(suggestions)
+	var applyErr *ApplyError
+	require.ErrorAs(t, err, &applyErr)
+	require.Len(t, applyErr.Failures, 2)
+	assert.Equal(t, "grafana dashboards", applyErr.Failures[0].Suggestion.WordToLink)
+	assert.Contains(t, applyErr.Failures[0].Reason, "outside the file")
+	assert.Equal(t, "a month", applyErr.Failures[1].Suggestion.WordToLink)
+	assert.Contains(t, applyErr.Failures[1].Reason, "is not 'a month'")
+	assert.Contains(t, err.Error(), "2 suggestion(s) can't be applied")
+	for path, content := range map[string]string{source: editsPost, otherPath: other} {
+		actual, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, content, string(actual))
+	}
+	assert.NoFileExists(t, filepath.Join(root, ".internal-link-history.json"))
+
+	// Best effort applies the rest and logs what was skipped
+	var log bytes.Buffer
+	a := newTestAnalyzer(t, root, Config{ApplyOptions: ApplyOptions{BestEffort: true}, Log: &log})
+	require.NoError(t, a.ApplyChanges(suggestions))
+	content, err := os.ReadFile(otherPath)
+	require.NoError(t, err)
+	assert.Equal(t, "[loki logs](loki.md) are kept for a week.\n", string(content))
+	content, err = os.ReadFile(source)
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "[prometheus alerting](alerts.md)")
+	assert.Contains(t, log.String(), "Skipping link to "+filepath.Join(root, "week.md")+" in "+otherPath)
+	assert.Contains(t, log.String(), "Modified 2 file(s)")
+}
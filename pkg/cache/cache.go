@@ -1,22 +1,79 @@
 package cache
 
 import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/gob"
 	"encoding/json"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
 	"time"
+
+	"internal-link/pkg/markdown"
+)
+
+// SchemaVersion is bumped whenever the layout or meaning of cached entries
+// changes, so entries written by older versions are ignored
+const SchemaVersion = 8
+
+// indexFile holds all entries of a cache directory. Earlier versions kept
+// one file per entry, ending in legacyExt, with those of corpus blobs
+// starting with legacyCorpusPrefix.
+const (
+	indexFile          = "index.gob"
+	legacyExt          = ".cache"
+	legacyCorpusPrefix = "corpus-"
 )
 
 // DocumentCache represents cached document analysis results
 type DocumentCache struct {
-	WordFreq    map[string]int `json:"word_freq"`
-	LastUpdated time.Time      `json:"last_updated"`
+	Version     int                `json:"version"`
+	Path        string             `json:"path"`         // Source the entry was built from, as given to Set
+	Settings    string             `json:"settings"`     // Settings key the entry was built with
+	ContentHash string             `json:"content_hash"` // SHA-256 of the source content, hex encoded
+	ModTime     time.Time          `json:"mod_time"`     // Source modification time when the entry was written
+	Size        int64              `json:"size"`         // Source size when the entry was written
+	WordFreq    map[string]int     `json:"word_freq"`
+	Sections    []markdown.Section `json:"sections,omitempty"` // Frequencies under each heading
+	LastUpdated time.Time          `json:"last_updated"`
 }
 
-// Cache manages document analysis caching
+// CorpusCache represents a named blob derived from the whole corpus
+type CorpusCache struct {
+	Fingerprint string          `json:"fingerprint"`
+	Data        json.RawMessage `json:"data"`
+	LastUpdated time.Time       `json:"last_updated"`
+}
+
+// Cache manages document analysis caching. The entries of a directory are
+// kept in memory and stored together in a single gob-encoded index file;
+// Set, Remove and SetCorpus only take effect on disk once Flush is called.
 type Cache struct {
 	cacheDir string
+
+	mu    sync.Mutex
+	index *index // Loaded on first use
+	dirty bool   // The index changed since it was loaded or flushed
+}
+
+// index is the content of an index file
+type index struct {
+	Version   int
+	Documents map[string]*DocumentCache // By entryKey
+	Corpus    map[string]*CorpusCache   // By name
+}
+
+func newIndex() *index {
+	return &index{
+		Version:   SchemaVersion,
+		Documents: make(map[string]*DocumentCache),
+		Corpus:    make(map[string]*CorpusCache),
+	}
 }
 
 // NewCache creates a new cache instance
@@ -27,73 +84,437 @@ func NewCache(cacheDir string) (*Cache, error) {
 	return &Cache{cacheDir: cacheDir}, nil
 }
 
-// Get retrieves cached document analysis if available and fresh
-func (c *Cache) Get(docPath string) (*DocumentCache, error) {
-	cachePath := c.getCachePath(docPath)
+// CheckWritable returns an error when entries can't be written to the
+// cache directory, such as on a read-only checkout
+func (c *Cache) CheckWritable() error {
+	f, err := os.CreateTemp(c.cacheDir, indexFile+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write to cache directory: %w", err)
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
 
-	// Check if cache file exists
-	info, err := os.Stat(cachePath)
-	if os.IsNotExist(err) {
-		return nil, nil
+// NewProjectCache creates a cache for the documents beneath root, kept in
+// a directory of cacheDir named after a hash of root's absolute path so the
+// entries of different projects stay apart
+func NewProjectCache(cacheDir, root string) (*Cache, error) {
+	return NewCache(filepath.Join(cacheDir, Namespace(root)))
+}
+
+// Namespace returns the name of the directory holding root's cache entries
+func Namespace(root string) string {
+	if abs, err := filepath.Abs(root); err == nil {
+		root = abs
 	}
-	if err != nil {
-		return nil, fmt.Errorf("failed to stat cache file: %w", err)
+	sum := sha256.Sum256([]byte(root))
+	return fmt.Sprintf("%x", sum[:8])
+}
+
+// Get retrieves cached document analysis if it was built from the same
+// content. The key identifies the analysis settings (such as the tokenizer and
+// n-gram range) the entry was built with; an entry built with other settings
+// is a miss.
+//
+// An unchanged modification time and size is trusted as a fast path;
+// otherwise the content is hashed, so touched or re-checked-out files with
+// identical content still hit.
+func (c *Cache) Get(docPath, key string, content []byte) (*DocumentCache, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+
+	cache, ok := c.index.Documents[entryKey(docPath, key)]
+	if !ok {
+		return nil, nil
 	}
 
-	// Check if source file is newer than cache
 	sourceInfo, err := os.Stat(docPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to stat source file: %w", err)
 	}
+	if sourceInfo.ModTime().Equal(cache.ModTime) && sourceInfo.Size() == cache.Size {
+		return cache, nil
+	}
 
-	if sourceInfo.ModTime().After(info.ModTime()) {
+	if contentHash(content) != cache.ContentHash {
 		return nil, nil
 	}
 
-	// Read and parse cache file
-	data, err := os.ReadFile(cachePath)
+	return cache, nil
+}
+
+// Set stores document analysis of the given content in cache under the given settings key
+func (c *Cache) Set(docPath, key string, content []byte, wordFreq map[string]int, sections []markdown.Section) error {
+	sourceInfo, err := os.Stat(docPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read cache file: %w", err)
+		return fmt.Errorf("failed to stat source file: %w", err)
 	}
 
-	var cache DocumentCache
-	if err := json.Unmarshal(data, &cache); err != nil {
-		return nil, fmt.Errorf("failed to parse cache file: %w", err)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.load(); err != nil {
+		return err
 	}
 
-	return &cache, nil
+	c.index.Documents[entryKey(docPath, key)] = &DocumentCache{
+		Version:     SchemaVersion,
+		Path:        docPath,
+		Settings:    key,
+		ContentHash: contentHash(content),
+		ModTime:     sourceInfo.ModTime(),
+		Size:        sourceInfo.Size(),
+		WordFreq:    wordFreq,
+		Sections:    sections,
+		LastUpdated: time.Now(),
+	}
+	c.dirty = true
+	return nil
 }
 
-// Set stores document analysis in cache
-func (c *Cache) Set(docPath string, wordFreq map[string]int) error {
-	cache := DocumentCache{
-		WordFreq:    wordFreq,
+// Remove deletes the cached analysis of a document under the given settings key, if any
+func (c *Cache) Remove(docPath, key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.load(); err != nil {
+		return err
+	}
+
+	k := entryKey(docPath, key)
+	if _, ok := c.index.Documents[k]; ok {
+		delete(c.index.Documents, k)
+		c.dirty = true
+	}
+	return nil
+}
+
+// GetCorpus loads the named corpus blob into v if it was stored for the same fingerprint.
+// It reports whether a fresh entry was found.
+func (c *Cache) GetCorpus(name, fingerprint string, v interface{}) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.load(); err != nil {
+		return false, err
+	}
+
+	// The corpus changed since the blob was derived
+	entry, ok := c.index.Corpus[name]
+	if !ok || entry.Fingerprint != fingerprint {
+		return false, nil
+	}
+
+	if err := json.Unmarshal(entry.Data, v); err != nil {
+		return false, fmt.Errorf("failed to decode corpus cache %s: %w", name, err)
+	}
+
+	return true, nil
+}
+
+// SetCorpus stores a named corpus blob for the given corpus fingerprint
+func (c *Cache) SetCorpus(name, fingerprint string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal corpus cache %s: %w", name, err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.load(); err != nil {
+		return err
+	}
+
+	c.index.Corpus[name] = &CorpusCache{
+		Fingerprint: fingerprint,
+		Data:        data,
 		LastUpdated: time.Now(),
 	}
+	c.dirty = true
+	return nil
+}
 
-	data, err := json.Marshal(cache)
+// Flush writes the index file if entries were set or removed since it was
+// loaded. The file is replaced atomically, so a concurrent run reads either
+// the old index or the new one.
+func (c *Cache) Flush() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.flush()
+}
+
+func (c *Cache) flush() error {
+	if !c.dirty {
+		return nil
+	}
+
+	tmp, err := os.CreateTemp(c.cacheDir, indexFile+".*.tmp")
 	if err != nil {
-		return fmt.Errorf("failed to marshal cache data: %w", err)
+		return fmt.Errorf("failed to create cache index: %w", err)
 	}
+	defer os.Remove(tmp.Name())
 
-	cachePath := c.getCachePath(docPath)
-	if err := os.WriteFile(cachePath, data, 0644); err != nil {
-		return fmt.Errorf("failed to write cache file: %w", err)
+	w := bufio.NewWriter(tmp)
+	if err := gob.NewEncoder(w).Encode(c.index); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to encode cache index: %w", err)
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write cache index: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write cache index: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), c.indexPath()); err != nil {
+		return fmt.Errorf("failed to write cache index: %w", err)
 	}
 
+	c.dirty = false
 	return nil
 }
 
-// Clear removes all cached data
+// load reads the index file on first use. An index that can't be decoded
+// or was written by another schema version is started afresh. Without an
+// index, the entry files of earlier versions are migrated into a new one
+// and deleted. The caller holds c.mu.
+func (c *Cache) load() error {
+	if c.index != nil {
+		return nil
+	}
+
+	idx, err := readIndex(c.indexPath())
+	if err == nil {
+		c.index = idx
+		return nil
+	}
+	if !os.IsNotExist(err) {
+		return err
+	}
+
+	c.index = newIndex()
+	return c.migrate()
+}
+
+// readIndex decodes an index file, returning an empty index for one that
+// is corrupt or outdated
+func readIndex(path string) (*index, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to read cache index: %w", err)
+	}
+	defer f.Close()
+
+	var idx index
+	if err := gob.NewDecoder(bufio.NewReader(f)).Decode(&idx); err != nil || idx.Version != SchemaVersion {
+		return newIndex(), nil
+	}
+	if idx.Documents == nil {
+		idx.Documents = make(map[string]*DocumentCache)
+	}
+	if idx.Corpus == nil {
+		idx.Corpus = make(map[string]*CorpusCache)
+	}
+	return &idx, nil
+}
+
+// migrate moves the per-document entry files that versions before the
+// index left in the cache directory into the index, then deletes them.
+// Entries of other schema versions are dropped. The caller holds c.mu.
+func (c *Cache) migrate() error {
+	files, err := filepath.Glob(filepath.Join(c.cacheDir, "*"+legacyExt))
+	if err != nil || len(files) == 0 {
+		return err
+	}
+
+	for _, path := range files {
+		name := filepath.Base(path)
+		if strings.HasPrefix(name, legacyCorpusPrefix) {
+			var entry CorpusCache
+			if readLegacy(path, &entry) == nil {
+				c.index.Corpus[strings.TrimSuffix(strings.TrimPrefix(name, legacyCorpusPrefix), legacyExt)] = &entry
+			}
+			continue
+		}
+		var entry DocumentCache
+		if readLegacy(path, &entry) == nil && entry.Version == SchemaVersion && entry.Path != "" {
+			c.index.Documents[entryKey(entry.Path, entry.Settings)] = &entry
+		}
+	}
+
+	c.dirty = true
+	if err := c.flush(); err != nil {
+		return err
+	}
+	for _, path := range files {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove migrated cache file: %w", err)
+		}
+	}
+	return nil
+}
+
+// readLegacy decodes a JSON entry file of the layout before the index
+func readLegacy(path string, v interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read cache file: %w", err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to parse cache file: %w", err)
+	}
+	return nil
+}
+
+// Clear removes every cache entry, including those of the project caches
+// beneath the directory. Other files, such as the link journal, are kept.
 func (c *Cache) Clear() error {
-	if err := os.RemoveAll(c.cacheDir); err != nil {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	err := c.walkIndexes(func(path string, _ fs.FileInfo) error {
+		return os.Remove(path)
+	})
+	if err != nil {
 		return fmt.Errorf("failed to clear cache: %w", err)
 	}
-	return os.MkdirAll(c.cacheDir, 0755)
+	c.index = newIndex()
+	c.dirty = false
+	return nil
+}
+
+// Prune deletes the document entries whose path is not among validPaths,
+// such as those of deleted files, and writes the index. It returns how many
+// entries were deleted.
+func (c *Cache) Prune(validPaths []string) (int, error) {
+	valid := make(map[string]bool, len(validPaths))
+	for _, path := range validPaths {
+		valid[path] = true
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.load(); err != nil {
+		return 0, err
+	}
+
+	pruned := 0
+	for key, entry := range c.index.Documents {
+		if !valid[entry.Path] {
+			delete(c.index.Documents, key)
+			pruned++
+		}
+	}
+	if pruned > 0 {
+		c.dirty = true
+	}
+	return pruned, c.flush()
+}
+
+// Stats summarizes the entries of a cache
+type Stats struct {
+	Entries int
+	Size    int64     // Total size of the index files in bytes
+	Oldest  time.Time // When the oldest entry was written, zero without entries
+
+	// Corpus describes the corpus blobs among the entries, by name
+	Corpus []CorpusStats
+}
+
+// CorpusStats summarizes the corpus blobs of one name, one per project
+// cache holding it
+type CorpusStats struct {
+	Name    string
+	Entries int
+	Size    int64     // Size of the encoded data in bytes
+	Updated time.Time // When the newest of them was written
+}
+
+// Stats counts the entries of the cache, including those of the project
+// caches beneath its directory, as last flushed
+func (c *Cache) Stats() (Stats, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.load(); err != nil {
+		return Stats{}, err
+	}
+
+	var stats Stats
+	corpus := make(map[string]*CorpusStats)
+	err := c.walkIndexes(func(path string, info fs.FileInfo) error {
+		if filepath.Base(path) != indexFile {
+			return nil
+		}
+		idx, err := readIndex(path)
+		if err != nil {
+			return err
+		}
+
+		stats.Size += info.Size()
+		oldest := func(t time.Time) {
+			if stats.Oldest.IsZero() || t.Before(stats.Oldest) {
+				stats.Oldest = t
+			}
+		}
+		for _, entry := range idx.Documents {
+			stats.Entries++
+			oldest(entry.LastUpdated)
+		}
+		for name, entry := range idx.Corpus {
+			stats.Entries++
+			oldest(entry.LastUpdated)
+			if corpus[name] == nil {
+				corpus[name] = &CorpusStats{Name: name}
+			}
+			blob := corpus[name]
+			blob.Entries++
+			blob.Size += int64(len(entry.Data))
+			if entry.LastUpdated.After(blob.Updated) {
+				blob.Updated = entry.LastUpdated
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return Stats{}, fmt.Errorf("failed to read cache directory: %w", err)
+	}
+	for _, blob := range corpus {
+		stats.Corpus = append(stats.Corpus, *blob)
+	}
+	sort.Slice(stats.Corpus, func(i, j int) bool { return stats.Corpus[i].Name < stats.Corpus[j].Name })
+	return stats, nil
+}
+
+// walkIndexes calls fn for every index file beneath the cache directory,
+// as well as for entry files of earlier versions not migrated yet
+func (c *Cache) walkIndexes(fn func(path string, info fs.FileInfo) error) error {
+	return filepath.WalkDir(c.cacheDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || d.Name() != indexFile && filepath.Ext(path) != legacyExt {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		return fn(path, info)
+	})
+}
+
+// entryKey identifies a document entry by the document path and settings key
+func entryKey(docPath, key string) string {
+	return docPath + "\x00" + key
+}
+
+func contentHash(content []byte) string {
+	return fmt.Sprintf("%x", sha256.Sum256(content))
 }
 
-func (c *Cache) getCachePath(docPath string) string {
-	// Create a cache file name based on the document path
-	hashedName := fmt.Sprintf("%x", docPath)
-	return filepath.Join(c.cacheDir, hashedName+".cache")
+func (c *Cache) indexPath() string {
+	return filepath.Join(c.cacheDir, indexFile)
 }
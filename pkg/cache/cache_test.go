@@ -0,0 +1,408 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"internal-link/pkg/markdown"
+)
+
+func TestCorpusCache(t *testing.T) {
+	c, err := NewCache(t.TempDir())
+	require.NoError(t, err)
+
+	var vocab []string
+	found, err := c.GetCorpus("vocabulary", "abc", &vocab)
+	require.NoError(t, err)
+	assert.False(t, found, "empty cache should miss")
+
+	require.NoError(t, c.SetCorpus("vocabulary", "abc", []string{"alpha", "beta"}))
+
+	found, err = c.GetCorpus("vocabulary", "abc", &vocab)
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, []string{"alpha", "beta"}, vocab)
+
+	var stale []string
+	found, err = c.GetCorpus("vocabulary", "def", &stale)
+	require.NoError(t, err)
+	assert.False(t, found, "different fingerprint should miss")
+	assert.Nil(t, stale)
+}
+
+func TestCacheKeyedBySettings(t *testing.T) {
+	c, err := NewCache(t.TempDir())
+	require.NoError(t, err)
+
+	doc := filepath.Join(t.TempDir(), "doc.md")
+	content := []byte("content")
+	require.NoError(t, os.WriteFile(doc, content, 0644))
+
+	sections := []markdown.Section{{Anchor: "intro", WordFreq: map[string]int{"content": 1}}}
+	require.NoError(t, c.Set(doc, "default", content, map[string]int{"content": 1}, sections))
+
+	cached, err := c.Get(doc, "default", content)
+	require.NoError(t, err)
+	require.NotNil(t, cached)
+	assert.Equal(t, map[string]int{"content": 1}, cached.WordFreq)
+	assert.Equal(t, sections, cached.Sections)
+
+	cached, err = c.Get(doc, "tickets", content)
+	require.NoError(t, err)
+	assert.Nil(t, cached, "a different tokenizer should not reuse the entry")
+}
+
+func TestCacheMissOnParserConfigChange(t *testing.T) {
+	c, err := NewCache(t.TempDir())
+	require.NoError(t, err)
+
+	doc := filepath.Join(t.TempDir(), "doc.md")
+	content := []byte("simple test document")
+	require.NoError(t, os.WriteFile(doc, content, 0644))
+
+	unigrams := "tokenizer=default ngram=1-1 flavor=commonmark max-occurrences=0 max-terms=0 overflow=truncate stop-words=en"
+	bigrams := "tokenizer=default ngram=2-3 flavor=commonmark max-occurrences=0 max-terms=0 overflow=truncate stop-words=en"
+	require.NoError(t, c.Set(doc, unigrams, content, map[string]int{"simple": 1}, nil))
+
+	cached, err := c.Get(doc, bigrams, content)
+	require.NoError(t, err)
+	assert.Nil(t, cached, "a different n-gram range should not reuse the entry")
+
+	cached, err = c.Get(doc, unigrams, content)
+	require.NoError(t, err)
+	require.NotNil(t, cached)
+	assert.Equal(t, unigrams, cached.Settings)
+}
+
+func TestCacheMissOnSchemaVersionChange(t *testing.T) {
+	c, err := NewCache(t.TempDir())
+	require.NoError(t, err)
+
+	doc := filepath.Join(t.TempDir(), "doc.md")
+	content := []byte("content")
+	require.NoError(t, os.WriteFile(doc, content, 0644))
+	require.NoError(t, c.Set(doc, "default", content, map[string]int{"content": 1}, nil))
+
+	// Rewrite the index as an older version of the tool would have left it
+	c.index.Version = 1
+	c.dirty = true
+	require.NoError(t, c.Flush())
+
+	reopened, err := NewCache(c.cacheDir)
+	require.NoError(t, err)
+	cached, err := reopened.Get(doc, "default", content)
+	require.NoError(t, err)
+	assert.Nil(t, cached)
+
+	// A corrupt index is started afresh too
+	require.NoError(t, os.WriteFile(c.indexPath(), []byte("garbage"), 0644))
+	reopened, err = NewCache(c.cacheDir)
+	require.NoError(t, err)
+	cached, err = reopened.Get(doc, "default", content)
+	require.NoError(t, err)
+	assert.Nil(t, cached)
+}
+
+func TestCachePersistsOnFlush(t *testing.T) {
+	dir := t.TempDir()
+	c, err := NewCache(dir)
+	require.NoError(t, err)
+
+	doc := filepath.Join(t.TempDir(), "doc.md")
+	content := []byte("content")
+	require.NoError(t, os.WriteFile(doc, content, 0644))
+	require.NoError(t, c.Set(doc, "default", content, map[string]int{"content": 1}, nil))
+	require.NoError(t, c.SetCorpus("vocabulary", "abc", []string{"content"}))
+	assert.NoFileExists(t, c.indexPath(), "entries are only written on flush")
+
+	require.NoError(t, c.Flush())
+	reopened, err := NewCache(dir)
+	require.NoError(t, err)
+	cached, err := reopened.Get(doc, "default", content)
+	require.NoError(t, err)
+	require.NotNil(t, cached)
+	assert.Equal(t, map[string]int{"content": 1}, cached.WordFreq)
+
+	var vocab []string
+	found, err := reopened.GetCorpus("vocabulary", "abc", &vocab)
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, []string{"content"}, vocab)
+}
+
+func TestCacheMigratesLegacyFiles(t *testing.T) {
+	dir := t.TempDir()
+	doc := filepath.Join(t.TempDir(), "doc.md")
+	content := []byte("content")
+	require.NoError(t, os.WriteFile(doc, content, 0644))
+	info, err := os.Stat(doc)
+	require.NoError(t, err)
+
+	// Entry files as versions before the index wrote them
+	writeLegacy(t, filepath.Join(dir, "a.cache"), DocumentCache{
+		Version: SchemaVersion, Path: doc, Settings: "default", ContentHash: contentHash(content),
+		ModTime: info.ModTime(), Size: info.Size(), WordFreq: map[string]int{"content": 1},
+	})
+	writeLegacy(t, filepath.Join(dir, "b.cache"), DocumentCache{Version: 1, Path: doc, Settings: "tickets"})
+	writeLegacy(t, filepath.Join(dir, "corpus-vocabulary.cache"), CorpusCache{Fingerprint: "abc", Data: []byte(`["content"]`)})
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "broken.cache"), []byte("{"), 0644))
+
+	c, err := NewCache(dir)
+	require.NoError(t, err)
+	cached, err := c.Get(doc, "default", content)
+	require.NoError(t, err)
+	require.NotNil(t, cached)
+	assert.Equal(t, map[string]int{"content": 1}, cached.WordFreq)
+
+	cached, err = c.Get(doc, "tickets", content)
+	require.NoError(t, err)
+	assert.Nil(t, cached, "entries of older schema versions are dropped")
+
+	var vocab []string
+	found, err := c.GetCorpus("vocabulary", "abc", &vocab)
+	require.NoError(t, err)
+	assert.True(t, found)
+
+	legacy, err := filepath.Glob(filepath.Join(dir, "*.cache"))
+	require.NoError(t, err)
+	assert.Empty(t, legacy, "migrated files are deleted")
+	assert.FileExists(t, c.indexPath())
+
+	reopened, err := NewCache(dir)
+	require.NoError(t, err)
+	cached, err = reopened.Get(doc, "default", content)
+	require.NoError(t, err)
+	assert.NotNil(t, cached)
+}
+
+// writeLegacy writes an entry file of the layout before the index
+func writeLegacy(t testing.TB, path string, entry interface{}) {
+	t.Helper()
+	data, err := json.Marshal(entry)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, data, 0644))
+}
+
+func TestCacheFreshnessByContentHash(t *testing.T) {
+	c, err := NewCache(t.TempDir())
+	require.NoError(t, err)
+
+	doc := filepath.Join(t.TempDir(), "doc.md")
+	content := []byte("simple test document")
+	require.NoError(t, os.WriteFile(doc, content, 0644))
+	require.NoError(t, c.Set(doc, "default", content, map[string]int{"simple": 1}, nil))
+
+	// A checkout or touch changes the mtime but not the content
+	later := time.Now().Add(time.Hour)
+	require.NoError(t, os.Chtimes(doc, later, later))
+	cached, err := c.Get(doc, "default", content)
+	require.NoError(t, err)
+	assert.NotNil(t, cached, "identical content should hit despite a newer mtime")
+
+	// Changed content misses
+	changed := []byte("simple test documents")
+	require.NoError(t, os.WriteFile(doc, changed, 0644))
+	cached, err = c.Get(doc, "default", changed)
+	require.NoError(t, err)
+	assert.Nil(t, cached, "changed content should miss")
+}
+
+func TestCacheTrustsUnchangedModTime(t *testing.T) {
+	c, err := NewCache(t.TempDir())
+	require.NoError(t, err)
+
+	doc := filepath.Join(t.TempDir(), "doc.md")
+	content := []byte("content")
+	require.NoError(t, os.WriteFile(doc, content, 0644))
+	require.NoError(t, c.Set(doc, "default", content, map[string]int{"content": 1}, nil))
+
+	// With mtime and size unchanged the content isn't hashed at all
+	cached, err := c.Get(doc, "default", nil)
+	require.NoError(t, err)
+	assert.NotNil(t, cached)
+}
+
+func TestCacheRemove(t *testing.T) {
+	c, err := NewCache(t.TempDir())
+	require.NoError(t, err)
+
+	doc := filepath.Join(t.TempDir(), "doc.md")
+	content := []byte("content")
+	require.NoError(t, os.WriteFile(doc, content, 0644))
+	require.NoError(t, c.Set(doc, "default", content, map[string]int{"content": 1}, nil))
+	require.NoError(t, c.Set(doc, "tickets", content, map[string]int{"content": 1}, nil))
+
+	require.NoError(t, c.Remove(doc, "default"))
+	cached, err := c.Get(doc, "default", content)
+	require.NoError(t, err)
+	assert.Nil(t, cached)
+
+	cached, err = c.Get(doc, "tickets", content)
+	require.NoError(t, err)
+	assert.NotNil(t, cached, "entries under other settings are kept")
+
+	assert.NoError(t, c.Remove(doc, "default"), "removing a missing entry is not an error")
+}
+
+func TestProjectCacheNamespaces(t *testing.T) {
+	dir := t.TempDir()
+	docs, other := t.TempDir(), t.TempDir()
+	assert.NotEqual(t, Namespace(docs), Namespace(other))
+
+	doc := filepath.Join(docs, "doc.md")
+	content := []byte("content")
+	require.NoError(t, os.WriteFile(doc, content, 0644))
+
+	c, err := NewProjectCache(dir, docs)
+	require.NoError(t, err)
+	require.NoError(t, c.Set(doc, "default", content, map[string]int{"content": 1}, nil))
+	require.NoError(t, c.Flush())
+	assert.FileExists(t, filepath.Join(dir, Namespace(docs), indexFile))
+
+	o, err := NewProjectCache(dir, other)
+	require.NoError(t, err)
+	cached, err := o.Get(doc, "default", content)
+	require.NoError(t, err)
+	assert.Nil(t, cached, "another project doesn't see the entry")
+}
+
+func TestCachePrune(t *testing.T) {
+	c, err := NewCache(t.TempDir())
+	require.NoError(t, err)
+
+	root := t.TempDir()
+	kept, deleted := filepath.Join(root, "kept.md"), filepath.Join(root, "deleted.md")
+	content := []byte("content")
+	for _, doc := range []string{kept, deleted} {
+		require.NoError(t, os.WriteFile(doc, content, 0644))
+		require.NoError(t, c.Set(doc, "default", content, map[string]int{"content": 1}, nil))
+	}
+	require.NoError(t, c.Set(kept, "tickets", content, map[string]int{"content": 1}, nil))
+	require.NoError(t, c.SetCorpus("vocabulary", "abc", []string{"content"}))
+	require.NoError(t, os.Remove(deleted))
+
+	pruned, err := c.Prune([]string{kept})
+	require.NoError(t, err)
+	assert.Equal(t, 1, pruned)
+	assert.NotContains(t, c.index.Documents, entryKey(deleted, "default"))
+	assert.Contains(t, c.index.Documents, entryKey(kept, "default"))
+	assert.Contains(t, c.index.Documents, entryKey(kept, "tickets"))
+	assert.Contains(t, c.index.Corpus, "vocabulary")
+
+	// Pruning writes the index
+	reopened, err := NewCache(c.cacheDir)
+	require.NoError(t, err)
+	cached, err := reopened.Get(kept, "default", content)
+	require.NoError(t, err)
+	assert.NotNil(t, cached)
+	assert.Len(t, reopened.index.Documents, 2)
+}
+
+func TestCacheStatsAndClear(t *testing.T) {
+	dir := t.TempDir()
+	root := t.TempDir()
+	doc := filepath.Join(root, "doc.md")
+	content := []byte("content")
+	require.NoError(t, os.WriteFile(doc, content, 0644))
+
+	project, err := NewProjectCache(dir, root)
+	require.NoError(t, err)
+	require.NoError(t, project.Set(doc, "default", content, map[string]int{"content": 1}, nil))
+	top, err := NewCache(dir)
+	require.NoError(t, err)
+	require.NoError(t, top.Set(doc, "legacy", content, map[string]int{"content": 1}, nil))
+	require.NoError(t, project.SetCorpus("vocabulary", "abc", []string{"content"}))
+	require.NoError(t, project.Flush())
+	require.NoError(t, top.Flush())
+	journal := filepath.Join(dir, "journal.json")
+	require.NoError(t, os.WriteFile(journal, []byte("{}"), 0644))
+
+	// The top-level cache covers every project beneath it
+	stats, err := top.Stats()
+	require.NoError(t, err)
+	assert.Equal(t, 3, stats.Entries)
+	assert.Positive(t, stats.Size)
+	assert.False(t, stats.Oldest.IsZero())
+
+	// Corpus blobs are listed by name
+	require.Len(t, stats.Corpus, 1)
+	assert.Equal(t, "vocabulary", stats.Corpus[0].Name)
+	assert.Equal(t, 1, stats.Corpus[0].Entries)
+	assert.Equal(t, int64(len(`["content"]`)), stats.Corpus[0].Size)
+	assert.False(t, stats.Corpus[0].Updated.IsZero())
+
+	stats, err = project.Stats()
+	require.NoError(t, err)
+	assert.Equal(t, 2, stats.Entries)
+
+	require.NoError(t, top.Clear())
+	stats, err = top.Stats()
+	require.NoError(t, err)
+	assert.Equal(t, Stats{}, stats)
+	assert.FileExists(t, journal, "clearing keeps files that aren't cache entries")
+}
+
+// BenchmarkColdStart compares loading the entries of 1,000 documents from
+// the files of the layout before the index with loading them from the index
+func BenchmarkColdStart(b *testing.B) {
+	const documents = 1000
+	root := b.TempDir()
+	wordFreq := make(map[string]int, 500)
+	for i := 0; i < 500; i++ {
+		wordFreq[fmt.Sprintf("term %d", i)] = i%7 + 1
+	}
+
+	legacyDir, indexDir := b.TempDir(), b.TempDir()
+	c, err := NewCache(indexDir)
+	require.NoError(b, err)
+	var legacy []string
+	for i := 0; i < documents; i++ {
+		doc := filepath.Join(root, fmt.Sprintf("doc-%d.md", i))
+		content := []byte(fmt.Sprintf("document %d", i))
+		require.NoError(b, os.WriteFile(doc, content, 0644))
+		require.NoError(b, c.Set(doc, "default", content, wordFreq, nil))
+
+		path := filepath.Join(legacyDir, fmt.Sprintf("%d.cache", i))
+		writeLegacy(b, path, c.index.Documents[entryKey(doc, "default")])
+		legacy = append(legacy, path)
+	}
+	require.NoError(b, c.Flush())
+
+	b.Run("files", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, path := range legacy {
+				var entry DocumentCache
+				require.NoError(b, readLegacy(path, &entry))
+			}
+		}
+	})
+
+	b.Run("index", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			c, err := NewCache(indexDir)
+			require.NoError(b, err)
+			c.mu.Lock()
+			require.NoError(b, c.load())
+			c.mu.Unlock()
+			require.Len(b, c.index.Documents, documents)
+		}
+	})
+}
+
+func TestCheckWritable(t *testing.T) {
+	dir := t.TempDir()
+	c, err := NewCache(dir)
+	require.NoError(t, err)
+	require.NoError(t, c.CheckWritable())
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries, "the probe file is removed")
+}
@@ -0,0 +1,120 @@
+package history
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Entry records a link that was previously inserted by the tool
+type Entry struct {
+	Source   string    `json:"source"`
+	Target   string    `json:"target"`
+	Phrase   string    `json:"phrase"`
+	LinkedAt time.Time `json:"linked_at"`
+}
+
+// History remembers which links have been applied across runs
+type History struct {
+	path    string
+	Entries []Entry `json:"entries"`
+
+	// Entries counted by source and target, and by source and phrase, so
+	// HasPair and HasPhrase don't scan them all
+	pairs   map[entryKey]int
+	phrases map[entryKey]int
+}
+
+// entryKey is a source and the target or phrase of one of its entries
+type entryKey struct {
+	source, other string
+}
+
+// Load reads the history file at path, returning an empty history if it
+// doesn't exist. An empty path gives a history that is only kept in memory.
+func Load(path string) (*History, error) {
+	h := &History{path: path}
+	if path == "" {
+		return h, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return h, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history file: %w", err)
+	}
+
+	if err := json.Unmarshal(data, h); err != nil {
+		return nil, fmt.Errorf("failed to parse history file: %w", err)
+	}
+	for _, e := range h.Entries {
+		h.count(e, 1)
+	}
+
+	return h, nil
+}
+
+// Save writes the history back to its file, if it has one
+func (h *History) Save() error {
+	if h.path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(h, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal history: %w", err)
+	}
+
+	if err := os.WriteFile(h.path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write history file: %w", err)
+	}
+
+	return nil
+}
+
+// Record adds an applied link to the history
+func (h *History) Record(source, target, phrase string) {
+	e := Entry{
+		Source:   source,
+		Target:   target,
+		Phrase:   phrase,
+		LinkedAt: time.Now(),
+	}
+	h.Entries = append(h.Entries, e)
+	h.count(e, 1)
+}
+
+// HasPair reports whether source has ever been linked to target
+func (h *History) HasPair(source, target string) bool {
+	return h.pairs[entryKey{source, target}] > 0
+}
+
+// HasPhrase reports whether phrase has ever been linked in source
+func (h *History) HasPhrase(source, phrase string) bool {
+	return h.phrases[entryKey{source, phrase}] > 0
+}
+
+// Forget removes the most recent record of a link, e.g. after it was undone
+func (h *History) Forget(source, target, phrase string) {
+	for i := len(h.Entries) - 1; i >= 0; i-- {
+		e := h.Entries[i]
+		if e.Source == source && e.Target == target && e.Phrase == phrase {
+			h.Entries = append(h.Entries[:i], h.Entries[i+1:]...)
+			h.count(e, -1)
+			return
+		}
+	}
+}
+
+// count adds delta to the counts of the pair and the phrase of e
+func (h *History) count(e Entry, delta int) {
+	if h.pairs == nil {
+		h.pairs = make(map[entryKey]int)
+		h.phrases = make(map[entryKey]int)
+	}
+	h.pairs[entryKey{e.Source, e.Target}] += delta
+	h.phrases[entryKey{e.Source, e.Phrase}] += delta
+}
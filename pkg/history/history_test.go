@@ -0,0 +1,58 @@
+package history
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistoryRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.json")
+
+	h, err := Load(path)
+	require.NoError(t, err)
+	assert.Empty(t, h.Entries)
+
+	h.Record("posts/a.md", "docs/b.md", "prometheus alerting")
+	require.NoError(t, h.Save())
+
+	loaded, err := Load(path)
+	require.NoError(t, err)
+	assert.True(t, loaded.HasPair("posts/a.md", "docs/b.md"))
+	assert.False(t, loaded.HasPair("posts/a.md", "docs/c.md"))
+	assert.True(t, loaded.HasPhrase("posts/a.md", "prometheus alerting"))
+	assert.False(t, loaded.HasPhrase("docs/b.md", "prometheus alerting"))
+}
+
+func TestInMemoryHistory(t *testing.T) {
+	h, err := Load("")
+	require.NoError(t, err)
+
+	h.Record("posts/a.md", "docs/b.md", "prometheus alerting")
+	require.NoError(t, h.Save())
+	assert.True(t, h.HasPair("posts/a.md", "docs/b.md"))
+}
+
+func TestForget(t *testing.T) {
+	h, err := Load("")
+	require.NoError(t, err)
+
+	h.Record("posts/a.md", "docs/b.md", "prometheus alerting")
+	h.Record("posts/a.md", "docs/c.md", "grafana dashboards")
+	h.Forget("posts/a.md", "docs/b.md", "prometheus alerting")
+	assert.False(t, h.HasPair("posts/a.md", "docs/b.md"))
+	assert.True(t, h.HasPair("posts/a.md", "docs/c.md"))
+
+	// Forgetting an unknown link is harmless
+	h.Forget("posts/a.md", "docs/x.md", "loki logs")
+	assert.Len(t, h.Entries, 1)
+
+	// The pair stays known while another record of it is left
+	h.Record("posts/a.md", "docs/c.md", "grafana panels")
+	h.Forget("posts/a.md", "docs/c.md", "grafana dashboards")
+	assert.True(t, h.HasPair("posts/a.md", "docs/c.md"))
+	assert.False(t, h.HasPhrase("posts/a.md", "grafana dashboards"))
+	assert.True(t, h.HasPhrase("posts/a.md", "grafana panels"))
+}
@@ -0,0 +1,107 @@
+package journal
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Insertion records a link inserted into a file, so it can be undone
+type Insertion struct {
+	File     string `json:"file"`
+	Target   string `json:"target"`
+	Position int    `json:"position"` // Byte offset of Markup in the file right after the run
+	Phrase   string `json:"phrase"`   // Text the link replaced
+	Markup   string `json:"markup"`   // Link as inserted
+
+	// Definition is the reference definition line added along with the
+	// link, removed again once no link uses its label
+	Definition string `json:"definition,omitempty"`
+
+	// Source is the file as the link history names it, relative to the
+	// run's directory; journals written before it was kept lack it
+	Source string `json:"source,omitempty"`
+}
+
+// Run holds the insertions made by one invocation of the tool
+type Run struct {
+	Root       string      `json:"root"` // Directory the run worked on
+	StartedAt  time.Time   `json:"started_at"`
+	Insertions []Insertion `json:"insertions"`
+}
+
+// Journal records every link the tool inserted, run by run, oldest first
+type Journal struct {
+	path string
+	Runs []*Run `json:"runs"`
+}
+
+// Load reads the journal file at path, returning an empty journal if it
+// doesn't exist. An empty path gives a journal that is only kept in memory.
+func Load(path string) (*Journal, error) {
+	j := &Journal{path: path}
+	if path == "" {
+		return j, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return j, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read journal file: %w", err)
+	}
+
+	if err := json.Unmarshal(data, j); err != nil {
+		return nil, fmt.Errorf("failed to parse journal file: %w", err)
+	}
+
+	return j, nil
+}
+
+// Save writes the journal back to its file, if it has one
+func (j *Journal) Save() error {
+	if j.path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal journal: %w", err)
+	}
+
+	if err := os.WriteFile(j.path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write journal file: %w", err)
+	}
+
+	return nil
+}
+
+// Begin starts recording a new run over root
+func (j *Journal) Begin(root string) *Run {
+	run := &Run{Root: root, StartedAt: time.Now()}
+	j.Runs = append(j.Runs, run)
+	return run
+}
+
+// RunsFor returns the runs over root, most recent first
+func (j *Journal) RunsFor(root string) []*Run {
+	var runs []*Run
+	for i := len(j.Runs) - 1; i >= 0; i-- {
+		if j.Runs[i].Root == root {
+			runs = append(runs, j.Runs[i])
+		}
+	}
+	return runs
+}
+
+// Remove drops a run from the journal
+func (j *Journal) Remove(run *Run) {
+	for i, r := range j.Runs {
+		if r == run {
+			j.Runs = append(j.Runs[:i], j.Runs[i+1:]...)
+			return
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package journal
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJournalRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.json")
+
+	j, err := Load(path)
+	require.NoError(t, err)
+	assert.Empty(t, j.Runs)
+
+	first := j.Begin("/docs")
+	first.Insertions = append(first.Insertions, Insertion{File: "/docs/a.md", Target: "b.md", Position: 4, Phrase: "grafana", Markup: "[grafana](b.md)"})
+	j.Begin("/blog")
+	j.Begin("/docs")
+	require.NoError(t, j.Save())
+
+	loaded, err := Load(path)
+	require.NoError(t, err)
+	runs := loaded.RunsFor("/docs")
+	require.Len(t, runs, 2)
+	assert.Empty(t, runs[0].Insertions, "most recent run first")
+	assert.Equal(t, first.Insertions, runs[1].Insertions)
+	assert.False(t, runs[1].StartedAt.After(runs[0].StartedAt))
+
+	loaded.Remove(runs[0])
+	assert.Len(t, loaded.RunsFor("/docs"), 1)
+	assert.Len(t, loaded.Runs, 2)
+}
+
+func TestInMemoryJournal(t *testing.T) {
+	j, err := Load("")
+	require.NoError(t, err)
+
+	j.Begin("/docs")
+	require.NoError(t, j.Save())
+	assert.Len(t, j.RunsFor("/docs"), 1)
+}
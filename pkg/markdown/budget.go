@@ -0,0 +1,116 @@
+package markdown
+
+import (
+	"fmt"
+
+	"github.com/yuin/goldmark/ast"
+)
+
+// Budget overflow handling modes
+const (
+	BudgetTruncate = "truncate" // Stop analyzing the document once a limit is hit
+	BudgetUnigrams = "unigrams" // Re-analyze the document with single words only
+)
+
+// BudgetError reports that a document exceeded the configured token budget.
+// It is returned together with the (partial) results, so callers may treat
+// it as a warning.
+type BudgetError struct {
+	Limit    string // Name of the limit that was exceeded
+	Max      int
+	Unigrams bool // The document was re-analyzed using unigrams only
+}
+
+func (e *BudgetError) Error() string {
+	if e.Unigrams {
+		return fmt.Sprintf("document exceeds %s of %d, fell back to unigrams", e.Limit, e.Max)
+	}
+	return fmt.Sprintf("document exceeds %s of %d, analysis truncated", e.Limit, e.Max)
+}
+
+// occurrenceSink collects occurrences while enforcing the per-document budget
+type occurrenceSink struct {
+	occurrences    []WordOccurrence
+	minNGram       int
+	maxNGram       int
+	maxOccurrences int
+	maxTerms       int
+	terms          map[string]struct{}
+	exceeded       *BudgetError
+
+	// Existing wikilinks and custom markup links in the document, whose
+	// text is never reported
+	linked []ExistingLink
+
+	// Heading and blockquote text is not reported
+	skipHeadings    bool
+	skipBlockquotes bool
+
+	// Significant tokens of the text seen since the last block or inline
+	// element phrases don't run across. When linking, phrases are kept
+	// within one inline element.
+	run     []runToken
+	linking bool
+
+	// Anchor of the most recent heading and the heading itself, recorded on
+	// every occurrence
+	section string
+	heading *Heading
+	anchors anchorSet
+}
+
+// runToken is a token of the sink's current run
+type runToken struct {
+	Token
+	container ast.Node // Parent of the text node the token is part of
+	ancestry  string
+}
+
+func newOccurrenceSink(minNGram, maxNGram, maxOccurrences, maxTerms int) *occurrenceSink {
+	sink := &occurrenceSink{
+		minNGram:       minNGram,
+		maxNGram:       maxNGram,
+		maxOccurrences: maxOccurrences,
+		maxTerms:       maxTerms,
+		anchors:        make(anchorSet),
+	}
+	if maxTerms > 0 {
+		sink.terms = make(map[string]struct{})
+	}
+	return sink
+}
+
+// add records an occurrence, reporting false once a limit has been reached
+func (s *occurrenceSink) add(occ WordOccurrence) bool {
+	if s.exceeded != nil {
+		return false
+	}
+	if s.maxOccurrences > 0 && len(s.occurrences) >= s.maxOccurrences {
+		s.exceeded = &BudgetError{Limit: "max occurrences per document", Max: s.maxOccurrences}
+		return false
+	}
+	if s.terms != nil {
+		if _, seen := s.terms[occ.Word]; !seen {
+			if len(s.terms) >= s.maxTerms {
+				s.exceeded = &BudgetError{Limit: "max terms per document", Max: s.maxTerms}
+				return false
+			}
+			s.terms[occ.Word] = struct{}{}
+		}
+	}
+	occ.Section = s.section
+	occ.Heading = s.heading
+	s.occurrences = append(s.occurrences, occ)
+	return true
+}
+
+// inLink reports whether the byte range overlaps an existing wikilink or
+// link in custom markup
+func (s *occurrenceSink) inLink(start, end int) bool {
+	return overlapsLink(s.linked, start, end)
+}
+
+// full reports whether the sink stopped accepting occurrences
+func (s *occurrenceSink) full() bool {
+	return s.exceeded != nil
+}
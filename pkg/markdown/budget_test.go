@@ -0,0 +1,96 @@
+package markdown
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// hugeDocument generates a document of distinct words spread over many paragraphs
+func hugeDocument(words int) []byte {
+	var b strings.Builder
+	for i := 0; i < words; i++ {
+		fmt.Fprintf(&b, "term%s ", letterNumber(i))
+		if i%50 == 49 {
+			b.WriteString("\n\n")
+		}
+	}
+	return []byte(b.String())
+}
+
+// letterNumber spells the digits of i as letters, so numbered words don't
+// look like identifiers
+func letterNumber(i int) string {
+	return strings.Map(func(r rune) rune { return r - '0' + 'a' }, fmt.Sprint(i))
+}
+
+func TestBudgetTruncate(t *testing.T) {
+	content := hugeDocument(20000)
+
+	tests := []struct {
+		name   string
+		config ParserConfig
+		limit  string
+		check  func(t *testing.T, occurrences []WordOccurrence)
+	}{
+		{
+			name:   "max occurrences",
+			config: ParserConfig{MinNGram: 2, MaxNGram: 3, MaxOccurrencesPerDoc: 1000},
+			limit:  "max occurrences per document",
+			check: func(t *testing.T, occurrences []WordOccurrence) {
+				assert.Len(t, occurrences, 1000)
+			},
+		},
+		{
+			name:   "max terms",
+			config: ParserConfig{MinNGram: 1, MaxNGram: 1, MaxTermsPerDoc: 500},
+			limit:  "max terms per document",
+			check: func(t *testing.T, occurrences []WordOccurrence) {
+				assert.Len(t, occurrences, 500)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parser := NewParser(tt.config)
+			occurrences, err := parser.FindWordOccurrences(content, 1)
+
+			var budgetErr *BudgetError
+			assert.True(t, errors.As(err, &budgetErr))
+			assert.Equal(t, tt.limit, budgetErr.Limit)
+			assert.False(t, budgetErr.Unigrams)
+			tt.check(t, occurrences)
+
+			wordFreq, err := parser.ParseContent(content)
+			assert.True(t, errors.As(err, &budgetErr))
+			assert.NotEmpty(t, wordFreq)
+		})
+	}
+}
+
+func TestBudgetUnigramFallback(t *testing.T) {
+	content := hugeDocument(20000)
+
+	// Bigrams and trigrams would need ~40,000 occurrences, unigrams fit
+	parser := NewParser(ParserConfig{MinNGram: 2, MaxNGram: 3, MaxOccurrencesPerDoc: 25000, BudgetOverflow: BudgetUnigrams})
+	occurrences, err := parser.FindWordOccurrences(content, 1)
+
+	var budgetErr *BudgetError
+	assert.True(t, errors.As(err, &budgetErr))
+	assert.True(t, budgetErr.Unigrams)
+	assert.Len(t, occurrences, 20000)
+	for _, occ := range occurrences {
+		assert.NotContains(t, occ.Word, " ")
+	}
+}
+
+func TestBudgetNotExceeded(t *testing.T) {
+	parser := NewParser(ParserConfig{MinNGram: 2, MaxNGram: 2, MaxOccurrencesPerDoc: 100, MaxTermsPerDoc: 100})
+	occurrences, err := parser.FindWordOccurrences([]byte("A simple test document"), 1)
+	assert.NoError(t, err)
+	assert.Len(t, occurrences, 2)
+}
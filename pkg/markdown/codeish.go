@@ -0,0 +1,37 @@
+package markdown
+
+import (
+	"strings"
+	"unicode"
+)
+
+// looksLikeCode reports whether a token reads as an identifier or a path
+// rather than a word of prose: camelCase with a capital inside a word that
+// starts in lowercase (myFunctionName, but not JavaScript), snake_case,
+// words joined by "/" or "." (src/pkg/foo, config.yaml) and letters run
+// together with digits (v1beta1, k8s). Punctuation around the token is
+// ignored.
+func looksLikeCode(token string) bool {
+	word := strings.TrimFunc(token, func(r rune) bool { return !isWordRune(r) })
+	runes := []rune(word)
+	for i, r := range runes {
+		if i == 0 {
+			continue
+		}
+		prev := runes[i-1]
+		switch {
+		case unicode.IsUpper(r) && unicode.IsLower(prev) && unicode.IsLower(runes[0]):
+			return true
+		case unicode.IsLetter(r) && unicode.IsDigit(prev), unicode.IsDigit(r) && unicode.IsLetter(prev):
+			return true
+		case (r == '_' || r == '/' || r == '.') && i+1 < len(runes) && isWordRune(prev) && isWordRune(runes[i+1]):
+			return true
+		}
+	}
+	return false
+}
+
+// isWordRune reports whether r can be part of a word or identifier
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,61 @@
+package markdown
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLooksLikeCode(t *testing.T) {
+	for token, code := range map[string]bool{
+		"myFunctionName": true,
+		"`getUser()`,":   true,
+		"snake_case":     true,
+		"src/pkg/foo":    true,
+		"config.yaml.":   true,
+		"v1beta1":        true,
+		"k8s":            true,
+		"JavaScript":     false,
+		"Kubernetes":     false,
+		"end.":           false,
+		"(README)":       false,
+		"2024":           false,
+		"COVID-19":       false,
+		"well-known":     false,
+		"_emphasis_":     false,
+	} {
+		assert.Equal(t, code, looksLikeCode(token), token)
+	}
+}
+
+func TestCodeishTokensSkipped(t *testing.T) {
+	content := []byte("Call myFunctionName before the v1beta1 rollout of services in src/pkg/foo today.\n")
+
+	words := func(config ParserConfig) map[string]int {
+		freq, err := NewParser(config).ParseContent(content)
+		require.NoError(t, err)
+		return freq
+	}
+
+	freq := words(ParserConfig{MinNGram: 1, MaxNGram: 1})
+	for _, term := range []string{"myfunctionname", "v1beta1", "src/pkg/foo"} {
+		assert.NotContains(t, freq, term)
+	}
+	// Phrases don't bridge the identifiers either
+	phrases := words(ParserConfig{MinNGram: 2, MaxNGram: 2})
+	assert.NotContains(t, phrases, "call before")
+	assert.Contains(t, phrases, "rollout services")
+
+	allowed := words(ParserConfig{MinNGram: 1, MaxNGram: 1, AllowCodeishTokens: true})
+	assert.Contains(t, allowed, "myfunctionname")
+	assert.Contains(t, allowed, "v1beta1")
+
+	// Occurrences are found the same way, so anchors match the counts
+	occurrences, err := NewParser(ParserConfig{MinNGram: 1, MaxNGram: 1}).FindWordOccurrences(content, 1)
+	require.NoError(t, err)
+	for _, occ := range occurrences {
+		assert.Contains(t, freq, occ.Word)
+	}
+	assert.Empty(t, NewParser(ParserConfig{MinNGram: 1, MaxNGram: 1}).Normalize("myFunctionName"))
+}
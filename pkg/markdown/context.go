@@ -0,0 +1,118 @@
+package markdown
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Inline markdown stripped from contexts, replaced by the text it marks up
+var (
+	imagePattern     = regexp.MustCompile(`!\[([^\]]*)\]\([^)]*\)`)
+	inlineLinkOrRef  = regexp.MustCompile(`\[([^\[\]]*)\](?:\([^)]*\)|\[[^\]]*\])`)
+	wikiContext      = regexp.MustCompile(`\[\[(?:[^\[\]|]*\|)?([^\[\]]*)\]\]`)
+	autolinkPattern  = regexp.MustCompile(`<((?:https?|mailto):[^>\s]*)>`)
+	cutLinkPattern   = regexp.MustCompile(`\]\([^)\s]*\)?|\[\[?|\]\]?`)
+	emphasisPattern  = regexp.MustCompile("\\*+|`+|~~|\\b_+|_+\\b")
+	lineStartPattern = regexp.MustCompile(`\n[ \t]*(?:#{1,6}|[-*+]|\d+[.)]|>)[ \t]+`)
+)
+
+// extractContext extracts the text around an occurrence as plain prose:
+// the window of contextSize bytes on each side is widened to whole words,
+// inline markdown is stripped and whitespace collapsed. It returns the
+// context and the byte range of the occurrence within it.
+func (p *Parser) extractContext(content []byte, position, wordLen int) (string, [2]int) {
+	end := position + wordLen
+	start := wordStart(content, max(position-p.contextSize, 0), position, p.contextSize)
+	stop := wordEnd(content, min(end+p.contextSize, len(content)), end, p.contextSize)
+
+	before := strings.TrimLeft(plainText(content[start:position], start == 0 || content[start-1] == '\n'), " ")
+	phrase := plainText(content[position:end], false)
+	after := strings.TrimRight(plainText(content[end:stop], false), " ")
+	if start > 0 {
+		before = "..." + before
+	}
+	if stop < len(content) {
+		after += "..."
+	}
+	return before + phrase + after, [2]int{len(before), len(before) + len(phrase)}
+}
+
+// wordStart moves the start of a context window back to the beginning of
+// the word it cuts into. A run of more than limit bytes without a space,
+// such as a URL, is cut after its next space instead, or at the occurrence.
+func wordStart(content []byte, start, position, limit int) int {
+	if start == 0 || isSpace(content[start-1]) || isSpace(content[start]) {
+		return start
+	}
+	for i := start - 1; i >= 0 && i >= start-limit; i-- {
+		if i == 0 || isSpace(content[i-1]) {
+			return i
+		}
+	}
+	for i := start; i < position; i++ {
+		if isSpace(content[i]) {
+			return i
+		}
+	}
+	return position
+}
+
+// wordEnd moves the end of a context window on to the end of the word it
+// cuts into, like wordStart
+func wordEnd(content []byte, stop, end, limit int) int {
+	if stop == len(content) || isSpace(content[stop]) || isSpace(content[stop-1]) {
+		return stop
+	}
+	for i := stop; i < len(content) && i <= stop+limit; i++ {
+		if i == len(content)-1 || isSpace(content[i+1]) {
+			return i + 1
+		}
+	}
+	for i := stop - 1; i > end; i-- {
+		if isSpace(content[i]) {
+			return i
+		}
+	}
+	return end
+}
+
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\r'
+}
+
+// plainText strips inline markdown from a part of a context and collapses
+// its whitespace, keeping a single space where it started or ended with
+// some, so the parts can be joined again. Block markers such as heading
+// hashes and list bullets are dropped at the start of lines; lineStart
+// tells whether the part itself starts one.
+func plainText(b []byte, lineStart bool) string {
+	s := string(b)
+	if lineStart {
+		s = "\n" + s
+	}
+	s = lineStartPattern.ReplaceAllString(s, "\n")
+	s = imagePattern.ReplaceAllString(s, "$1")
+	s = wikiContext.ReplaceAllString(s, "$1")
+	s = inlineLinkOrRef.ReplaceAllString(s, "$1")
+	s = autolinkPattern.ReplaceAllString(s, "$1")
+	// Links the window cut through leave brackets and targets behind
+	s = cutLinkPattern.ReplaceAllString(s, "")
+	s = emphasisPattern.ReplaceAllString(s, "")
+
+	leading := len(s) > 0 && isSpace(s[0])
+	trailing := len(s) > 0 && isSpace(s[len(s)-1])
+	s = strings.Join(strings.Fields(s), " ")
+	if s == "" {
+		if leading || trailing {
+			return " "
+		}
+		return ""
+	}
+	if leading {
+		s = " " + s
+	}
+	if trailing {
+		s += " "
+	}
+	return s
+}
@@ -0,0 +1,137 @@
+package markdown
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtractContext(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		phrase   string
+		size     int
+		expected string
+	}{
+		{
+			name:     "whole words",
+			content:  "we changed the prometheus alerting rules for grafana dashboards",
+			phrase:   "prometheus alerting",
+			size:     8,
+			expected: "...changed the [prometheus alerting] rules for...",
+		},
+		{
+			name:     "inline markdown stripped",
+			content:  "see [the guide](guide.md) then **prometheus alerting** with `kubectl` and _more_ here",
+			phrase:   "prometheus alerting",
+			size:     40,
+			expected: "see the guide then [prometheus alerting] with kubectl and more here",
+		},
+		{
+			name:     "link cut at the window start",
+			content:  "read [the long guide](guide.md) before prometheus alerting starts",
+			phrase:   "prometheus alerting",
+			size:     22,
+			expected: "...guide before [prometheus alerting] starts",
+		},
+		{
+			name:     "block markers and newlines",
+			content:  "# Alerts\n\n- prometheus alerting\n  rules\n",
+			phrase:   "prometheus alerting",
+			size:     20,
+			expected: "Alerts [prometheus alerting] rules",
+		},
+		{
+			name:     "snake case kept",
+			content:  "the grafana_dashboards of prometheus alerting",
+			phrase:   "prometheus alerting",
+			size:     30,
+			expected: "the grafana_dashboards of [prometheus alerting]",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parser := NewParser(ParserConfig{ContextSize: tt.size})
+			position := strings.Index(tt.content, tt.phrase)
+			context, highlight := parser.extractContext([]byte(tt.content), position, len(tt.phrase))
+			marked := context[:highlight[0]] + "[" + context[highlight[0]:highlight[1]] + "]" + context[highlight[1]:]
+			assert.Equal(t, tt.expected, marked)
+		})
+	}
+}
+
+func TestOccurrenceHighlight(t *testing.T) {
+	parser := NewParser(ParserConfig{MinNGram: 2, MaxNGram: 2})
+	occurrences, err := parser.FindWordOccurrences([]byte("We tuned *Prometheus alerting* today."), 3)
+	assert.NoError(t, err)
+	for _, occ := range occurrences {
+		if occ.Word == "prometheus alerting" {
+			assert.Equal(t, "Prometheus alerting", occ.Context[occ.Highlight[0]:occ.Highlight[1]])
+			assert.Equal(t, "We tuned Prometheus alerting today.", occ.Context)
+			return
+		}
+	}
+	t.Fatal("occurrence not found")
+}
+
+func TestLazyOccurrenceContext(t *testing.T) {
+	parser := NewParser(ParserConfig{MinNGram: 2, MaxNGram: 2})
+	content := []byte("---\ntitle: x\n---\nWe tuned *Prometheus alerting* today.\n")
+
+	find := func(find func([]byte, int) ([]WordOccurrence, error)) WordOccurrence {
+		occurrences, err := find(content, 3)
+		assert.NoError(t, err)
+		for _, occ := range occurrences {
+			if occ.Word == "prometheus alerting" {
+				return occ
+			}
+		}
+		t.Fatal("occurrence not found")
+		return WordOccurrence{}
+	}
+
+	// Contexts are only extracted for the occurrences that need one
+	eager, lazy := find(parser.FindWordOccurrences), find(parser.FindOccurrences)
+	assert.Empty(t, lazy.Context)
+	assert.Equal(t, len("Prometheus alerting"), lazy.Length)
+	parser.OccurrenceContext(content, &lazy)
+	assert.Equal(t, eager, lazy)
+	assert.Equal(t, "We tuned Prometheus alerting today.", lazy.Context)
+}
+
+// benchmarkDocument generates a document of the given number of sections,
+// each a heading and a paragraph with phrases of its own
+func benchmarkDocument(sections int) []byte {
+	var b strings.Builder
+	for i := 0; i < sections; i++ {
+		fmt.Fprintf(&b, "## Section %s\n\nWe tuned the prometheus%s alerting rules for the grafana dashboards of team%s, then shipped them.\n\n",
+			letterNumber(i), letterNumber(i), letterNumber(i+1))
+	}
+	return []byte(b.String())
+}
+
+func BenchmarkFindOccurrences(b *testing.B) {
+	parser := NewParser(ParserConfig{MinNGram: 2, MaxNGram: 3})
+	content := benchmarkDocument(200)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		parser.FindOccurrences(content, 3)
+	}
+}
+
+// BenchmarkFindWordOccurrences measures the cost of extracting every context
+// up front, for comparison with BenchmarkFindOccurrences
+func BenchmarkFindWordOccurrences(b *testing.B) {
+	parser := NewParser(ParserConfig{MinNGram: 2, MaxNGram: 3})
+	content := benchmarkDocument(200)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		parser.FindWordOccurrences(content, 3)
+	}
+}
@@ -0,0 +1,161 @@
+package markdown
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Frontmatter holds the document metadata read from YAML or TOML frontmatter
+type Frontmatter struct {
+	Title string `yaml:"title" toml:"title"`
+	Slug  string `yaml:"slug" toml:"slug"`
+	URL   string `yaml:"url" toml:"url"` // Explicit permalink, overriding the slug
+
+	// Keywords holds the "keywords" and "tags" values, each of which may be
+	// a single string or a list
+	Keywords []string `yaml:"-" toml:"-"`
+
+	// Tags and Categories hold the taxonomy values of the same name, which
+	// may also be a single string or a list
+	Tags       []string `yaml:"-" toml:"-"`
+	Categories []string `yaml:"-" toml:"-"`
+
+	// Canonical is set by "canonical: true", marking the page preferred
+	// among its near duplicates; other values, such as canonical URLs, are
+	// ignored
+	Canonical bool `yaml:"-" toml:"-"`
+
+	// Aliases are the other URLs the page is published under, such as
+	// Hugo's redirects from old URLs; a single string or a list
+	Aliases []string `yaml:"-" toml:"-"`
+}
+
+// FrontmatterEnd returns the offset just past the closing delimiter line of
+// the YAML (---) or TOML (+++) frontmatter, or 0 if the document has none.
+// The opening delimiter must be the first line and the closing one a line of
+// its own outside any code fence, otherwise a document starting with a
+// thematic break would lose everything up to the next "---".
+func (p *Parser) FrontmatterEnd(content []byte) int {
+	first := lineEnd(content, 0)
+	delimiter, ok := openingDelimiter(content[:first])
+	if !ok {
+		return 0
+	}
+
+	inFence := false
+	for start := first + 1; start < len(content); {
+		end := lineEnd(content, start)
+		line := content[start:end]
+		trimmed := bytes.TrimLeft(line, " \t")
+		switch {
+		case bytes.HasPrefix(trimmed, []byte("```")) || bytes.HasPrefix(trimmed, []byte("~~~")):
+			inFence = !inFence
+		case !inFence && isDelimiterLine(line, delimiter):
+			if end < len(content) {
+				end++ // Include the line break
+			}
+			return end
+		}
+		start = end + 1
+	}
+	return 0
+}
+
+// openingDelimiter returns the delimiter a first line opens frontmatter
+// with. YAML allows a comment after the opening ---.
+func openingDelimiter(line []byte) ([]byte, bool) {
+	for _, delimiter := range [][]byte{[]byte("---"), []byte("+++")} {
+		if isDelimiterLine(line, delimiter) {
+			return delimiter, true
+		}
+	}
+	rest, ok := bytes.CutPrefix(line, []byte("--- "))
+	if ok && bytes.HasPrefix(bytes.TrimLeft(rest, " \t"), []byte("#")) {
+		return []byte("---"), true
+	}
+	return nil, false
+}
+
+// isDelimiterLine reports whether a line consists of the delimiter alone
+func isDelimiterLine(line, delimiter []byte) bool {
+	return bytes.Equal(bytes.TrimRight(line, " \t\r"), delimiter)
+}
+
+// ParseFrontmatter extracts metadata from the document's frontmatter, if any
+func (p *Parser) ParseFrontmatter(content []byte) (Frontmatter, error) {
+	var fm Frontmatter
+	if err := p.decodeFrontmatter(content, &fm); err != nil {
+		return fm, fmt.Errorf("failed to parse frontmatter: %w", err)
+	}
+
+	var params map[string]interface{}
+	if err := p.decodeFrontmatter(content, &params); err != nil {
+		return fm, fmt.Errorf("failed to parse frontmatter: %w", err)
+	}
+	for _, key := range []string{"keywords", "tags"} {
+		fm.Keywords = append(fm.Keywords, stringList(params[key])...)
+	}
+	fm.Tags = stringList(params["tags"])
+	fm.Categories = stringList(params["categories"])
+	fm.Canonical = params["canonical"] == true
+	fm.Aliases = stringList(params["aliases"])
+	return fm, nil
+}
+
+// stringList returns the strings of a frontmatter value that is either a
+// string or a list, ignoring anything else
+func stringList(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		var list []string
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				list = append(list, s)
+			}
+		}
+		return list
+	}
+	return nil
+}
+
+// OptedOut reports whether the frontmatter excludes the document from
+// internal linking, either with "key: false" or "key_ignore: true"
+func (p *Parser) OptedOut(content []byte, key string) (bool, error) {
+	var params map[string]interface{}
+	if err := p.decodeFrontmatter(content, &params); err != nil {
+		return false, fmt.Errorf("failed to parse frontmatter: %w", err)
+	}
+
+	if enabled, ok := params[key].(bool); ok && !enabled {
+		return true, nil
+	}
+	ignore, ok := params[key+"_ignore"].(bool)
+	return ok && ignore, nil
+}
+
+// decodeFrontmatter unmarshals the YAML or TOML frontmatter into v, leaving
+// it untouched if the document has none
+func (p *Parser) decodeFrontmatter(content []byte, v interface{}) error {
+	_, skipped := p.skipFrontmatter(content)
+	if skipped == 0 {
+		return nil
+	}
+
+	// Strip the opening and closing delimiter lines
+	block := content[:skipped]
+	delimiter := block[:3]
+	block = block[bytes.IndexByte(block, '\n')+1:]
+	if idx := bytes.LastIndex(block, delimiter); idx != -1 {
+		block = block[:idx]
+	}
+
+	if bytes.Equal(delimiter, []byte("+++")) {
+		return toml.Unmarshal(block, v)
+	}
+	return yaml.Unmarshal(block, v)
+}
@@ -0,0 +1,48 @@
+package markdown
+
+import (
+	"sort"
+	"unicode/utf8"
+)
+
+// LineIndex maps byte offsets in a document to lines and columns, e.g. for
+// tools that locate findings by line rather than by offset
+type LineIndex struct {
+	content []byte
+	starts  []int // Offset of the first byte of each line
+}
+
+// NewLineIndex indexes the lines of content
+func NewLineIndex(content []byte) *LineIndex {
+	starts := []int{0}
+	for i, b := range content {
+		if b == '\n' {
+			starts = append(starts, i+1)
+		}
+	}
+	return &LineIndex{content: content, starts: starts}
+}
+
+// Position returns the 1-based line and column of offset. Columns count
+// characters, so a multi-byte UTF-8 sequence takes up one column.
+func (ix *LineIndex) Position(offset int) (line, column int) {
+	offset = max(0, min(offset, len(ix.content)))
+	i := sort.Search(len(ix.starts), func(i int) bool { return ix.starts[i] > offset }) - 1
+	text := ix.content[ix.starts[i]:offset]
+	return i + 1, utf8.RuneCount(text) + 1
+}
+
+// Line returns the byte span of the line containing offset, without its
+// line break
+func (ix *LineIndex) Line(offset int) (start, end int) {
+	offset = max(0, min(offset, len(ix.content)))
+	i := sort.Search(len(ix.starts), func(i int) bool { return ix.starts[i] > offset }) - 1
+	start, end = ix.starts[i], len(ix.content)
+	if i+1 < len(ix.starts) {
+		end = ix.starts[i+1] - 1
+	}
+	if end > start && ix.content[end-1] == '\r' {
+		end--
+	}
+	return start, end
+}
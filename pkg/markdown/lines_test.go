@@ -0,0 +1,52 @@
+package markdown
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLineIndex(t *testing.T) {
+	content := []byte("# Title\n\nCafé prometheus alerting\nlast")
+	ix := NewLineIndex(content)
+
+	tests := []struct {
+		offset       int
+		line, column int
+	}{
+		{offset: 0, line: 1, column: 1},
+		{offset: 7, line: 1, column: 8},
+		{offset: 8, line: 2, column: 1},
+		{offset: 9, line: 3, column: 1},
+		{offset: 15, line: 3, column: 6}, // After the two-byte é
+		{offset: len(content), line: 4, column: 5},
+		{offset: len(content) + 10, line: 4, column: 5},
+	}
+	for _, tt := range tests {
+		line, column := ix.Position(tt.offset)
+		assert.Equal(t, tt.line, line, "line of offset %d", tt.offset)
+		assert.Equal(t, tt.column, column, "column of offset %d", tt.offset)
+	}
+}
+
+func TestLineIndexLine(t *testing.T) {
+	content := []byte("# Title\r\n\nsecond line\nlast")
+	ix := NewLineIndex(content)
+
+	tests := []struct {
+		offset     int
+		start, end int
+	}{
+		{offset: 0, start: 0, end: 7}, // Without the \r\n
+		{offset: 8, start: 0, end: 7},
+		{offset: 9, start: 9, end: 9},
+		{offset: 15, start: 10, end: 21},
+		{offset: 21, start: 10, end: 21},
+		{offset: len(content) + 10, start: 22, end: 26},
+	}
+	for _, tt := range tests {
+		start, end := ix.Line(tt.offset)
+		assert.Equal(t, tt.start, start, "start of offset %d", tt.offset)
+		assert.Equal(t, tt.end, end, "end of offset %d", tt.offset)
+	}
+}
@@ -0,0 +1,178 @@
+package markdown
+
+import (
+	"bytes"
+	"regexp"
+	"sort"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+// ExistingLink is an inline markdown link already present in a document
+type ExistingLink struct {
+	Text        string // Anchor text as written
+	Destination string // Link destination as written
+	Start       int    // Byte offset of the opening '['
+	End         int    // Byte offset just past the closing ')'
+	Wikilink    bool   // Written as [[Destination|Text]] rather than [Text](Destination)
+	Reference   bool   // Written as [Text][label], [Text][] or [Text], with Destination defined elsewhere
+	Custom      bool   // Matched by ParserConfig.LinkedPattern rather than written in markdown syntax
+	Paragraph   int    // Paragraph the link is in, numbered as in WordOccurrence
+}
+
+// FindLinks returns the inline links, wikilinks and custom markup links of
+// the document with their spans, in order of appearance. Reference-style links are reported
+// with the destination of their definition; autolinks are not reported.
+func (p *Parser) FindLinks(content []byte) ([]ExistingLink, error) {
+	body, frontmatterOffset := p.skipFrontmatter(content)
+	doc := p.md.Parser().Parse(text.NewReader(body))
+
+	spans := paragraphSpans(doc)
+	var links []ExistingLink
+	err := ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		link, ok := n.(*ast.Link)
+		if !ok {
+			return ast.WalkContinue, nil
+		}
+
+		if existing, ok := linkSpan(link, body); ok {
+			existing.Paragraph = paragraphAt(spans, existing.Start)
+			existing.Start += frontmatterOffset
+			existing.End += frontmatterOffset
+			links = append(links, existing)
+		}
+		return ast.WalkSkipChildren, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, link := range findWikilinks(body) {
+		link.Paragraph = paragraphAt(spans, link.Start)
+		link.Start += frontmatterOffset
+		link.End += frontmatterOffset
+		links = append(links, link)
+	}
+	// Custom markup is only reported where it isn't a link already
+	found := len(links)
+	for _, link := range findPatternLinks(p.linked, body) {
+		link.Paragraph = paragraphAt(spans, link.Start)
+		link.Start += frontmatterOffset
+		link.End += frontmatterOffset
+		if !overlapsLink(links[:found], link.Start, link.End) {
+			links = append(links, link)
+		}
+	}
+	sort.Slice(links, func(i, j int) bool {
+		return links[i].Start < links[j].Start
+	})
+
+	return links, nil
+}
+
+// linkSpan recovers the byte span of an inline link from its text segments,
+// since goldmark doesn't record positions for inline nodes
+func linkSpan(link *ast.Link, content []byte) (ExistingLink, bool) {
+	start, stop := -1, -1
+	_ = ast.Walk(link, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if t, ok := n.(*ast.Text); ok && entering {
+			if start == -1 || t.Segment.Start < start {
+				start = t.Segment.Start
+			}
+			if t.Segment.Stop > stop {
+				stop = t.Segment.Stop
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+	if start == -1 {
+		return ExistingLink{}, false
+	}
+
+	// Walk outwards over any emphasis markers to the brackets
+	open := bytes.LastIndexByte(content[:start], '[')
+	if open == -1 {
+		return ExistingLink{}, false
+	}
+	closeIdx := bytes.IndexByte(content[stop:], ']')
+	if closeIdx == -1 {
+		return ExistingLink{}, false
+	}
+	existing := ExistingLink{
+		Text:        string(content[open+1 : stop+closeIdx]),
+		Destination: string(link.Destination),
+		Start:       open,
+	}
+	after := stop + closeIdx + 1
+
+	switch {
+	case bytes.HasPrefix(content[after:], []byte("(")):
+		destLen := bytes.IndexByte(content[after:], ')')
+		if destLen == -1 {
+			return ExistingLink{}, false
+		}
+		existing.End = after + destLen + 1
+	case bytes.HasPrefix(content[after:], []byte("[")):
+		labelLen := bytes.IndexByte(content[after:], ']')
+		if labelLen == -1 {
+			return ExistingLink{}, false
+		}
+		existing.End = after + labelLen + 1
+		existing.Reference = true
+	default:
+		// Shortcut reference, [Text] alone
+		existing.End = after
+		existing.Reference = true
+	}
+	return existing, true
+}
+
+// findPatternLinks returns the spans of content matched by pattern, the
+// links of custom markup, with the text and destination of the phrase and
+// target groups when the pattern has them. Without a phrase group the whole
+// match is the text.
+func findPatternLinks(pattern *regexp.Regexp, content []byte) []ExistingLink {
+	if pattern == nil {
+		return nil
+	}
+	phrase, target := pattern.SubexpIndex("phrase"), pattern.SubexpIndex("target")
+	group := func(m []int, i int) string {
+		if i < 0 || m[2*i] < 0 {
+			return ""
+		}
+		return string(content[m[2*i]:m[2*i+1]])
+	}
+
+	var links []ExistingLink
+	for _, m := range pattern.FindAllSubmatchIndex(content, -1) {
+		if m[0] == m[1] {
+			continue
+		}
+		text := string(content[m[0]:m[1]])
+		if phrase >= 0 {
+			text = group(m, phrase)
+		}
+		links = append(links, ExistingLink{
+			Text:        text,
+			Destination: group(m, target),
+			Start:       m[0],
+			End:         m[1],
+			Custom:      true,
+		})
+	}
+	return links
+}
+
+// overlapsLink reports whether the byte range overlaps any of the links
+func overlapsLink(links []ExistingLink, start, end int) bool {
+	for _, link := range links {
+		if start < link.End && end > link.Start {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,118 @@
+package markdown
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindLinks(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected []ExistingLink
+	}{
+		{
+			name:    "inline link",
+			content: "See [the guide](guide.md) for details.",
+			expected: []ExistingLink{
+				{Text: "the guide", Destination: "guide.md", Start: 4, End: 25, Paragraph: 1},
+			},
+		},
+		{
+			name:    "emphasis inside anchor",
+			content: "Read [**bold** words](other.md#part).",
+			expected: []ExistingLink{
+				{Text: "**bold** words", Destination: "other.md#part", Start: 5, End: 36, Paragraph: 1},
+			},
+		},
+		{
+			name:    "offset by frontmatter",
+			content: "---\ntitle: X\n---\n[a](b.md)",
+			expected: []ExistingLink{
+				{Text: "a", Destination: "b.md", Start: 17, End: 26, Paragraph: 1},
+			},
+		},
+		{
+			name:    "wikilinks",
+			content: "See [[Guide]] and [[notes/Setup#Install|the setup]].",
+			expected: []ExistingLink{
+				{Text: "Guide", Destination: "Guide", Start: 4, End: 13, Wikilink: true, Paragraph: 1},
+				{Text: "the setup", Destination: "notes/Setup", Start: 18, End: 51, Wikilink: true, Paragraph: 1},
+			},
+		},
+		{
+			name:    "mixed link syntax in order",
+			content: "[[Guide|guide]] then [a](b.md)",
+			expected: []ExistingLink{
+				{Text: "guide", Destination: "Guide", Start: 0, End: 15, Wikilink: true, Paragraph: 1},
+				{Text: "a", Destination: "b.md", Start: 21, End: 30, Paragraph: 1},
+			},
+		},
+		{
+			name:    "paragraphs",
+			content: "# [Title](t.md)\n\nFirst [a](a.md).\n\n- [[B]]\n- [c](c.md)\n",
+			expected: []ExistingLink{
+				{Text: "Title", Destination: "t.md", Start: 2, End: 15, Paragraph: 0},
+				{Text: "a", Destination: "a.md", Start: 23, End: 32, Paragraph: 1},
+				{Text: "B", Destination: "B", Start: 37, End: 42, Wikilink: true, Paragraph: 2},
+				{Text: "c", Destination: "c.md", Start: 45, End: 54, Paragraph: 3},
+			},
+		},
+		{
+			name:    "reference links",
+			content: "[a][ref], [b][] and [c] then [d](d.md)\n\n[ref]: r.md\n[b]: b.md\n[c]: c.md\n",
+			expected: []ExistingLink{
+				{Text: "a", Destination: "r.md", Start: 0, End: 8, Reference: true, Paragraph: 1},
+				{Text: "b", Destination: "b.md", Start: 10, End: 15, Reference: true, Paragraph: 1},
+				{Text: "c", Destination: "c.md", Start: 20, End: 23, Reference: true, Paragraph: 1},
+				{Text: "d", Destination: "d.md", Start: 29, End: 38, Paragraph: 1},
+			},
+		},
+		{
+			name:     "no links",
+			content:  "Plain text only.",
+			expected: nil,
+		},
+	}
+
+	parser := NewParser(ParserConfig{MinNGram: 1, MaxNGram: 1})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			links, err := parser.FindLinks([]byte(tt.content))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, links)
+			for _, link := range links {
+				closing := byte(')')
+				if link.Wikilink || link.Reference {
+					closing = ']'
+				}
+				assert.Equal(t, byte('['), tt.content[link.Start])
+				assert.Equal(t, closing, tt.content[link.End-1])
+			}
+		})
+	}
+}
+
+func TestFindLinksLinkedPattern(t *testing.T) {
+	content := []byte(`See {{< relref "setup.md" >}} and [the guide](guide.md) for grafana dashboards.` + "\n")
+
+	p := NewParser(ParserConfig{LinkedPattern: `\{\{< relref "(?P<target>[^"]+)" >\}\}|\[the guide\]`})
+	links, err := p.FindLinks(content)
+	require.NoError(t, err)
+	// The match inside a markdown link is that link, reported once
+	require.Len(t, links, 2)
+	assert.True(t, links[0].Custom)
+	assert.Equal(t, "setup.md", links[0].Destination)
+	assert.Equal(t, `{{< relref "setup.md" >}}`, links[0].Text)
+	assert.False(t, links[1].Custom)
+	assert.Equal(t, "guide.md", links[1].Destination)
+
+	freq, err := p.ParseContent(content)
+	require.NoError(t, err)
+	assert.NotContains(t, freq, "relref")
+
+	assert.NotEqual(t, NewParser(ParserConfig{}).CacheKey(), p.CacheKey())
+	assert.Equal(t, NewParser(ParserConfig{}).CacheKey(), NewParser(ParserConfig{LinkedPattern: "(["}).CacheKey())
+}
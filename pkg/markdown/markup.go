@@ -0,0 +1,111 @@
+package markdown
+
+import (
+	"bytes"
+	"errors"
+	"unicode"
+	"unicode/utf8"
+)
+
+// CheckLinkSpan reports why a link can't be inserted around
+// content[start:end] without breaking the inline markup around it, if it
+// can't. Positions are raw byte offsets, so a phrase may run into emphasis,
+// code or strikethrough delimiters the parser's text segments leave out.
+//
+// A phrase making up all of an emphasis span is linked inside it, giving
+// **[phrase](target)**, and so is one at either end of it. That only holds
+// while the delimiters keep their meaning once the brackets sit next to
+// them, which takes whitespace or punctuation on their other side:
+// "**phrase**s" would lose its emphasis.
+func CheckLinkSpan(content []byte, start, end int) error {
+	phrase := content[start:end]
+	switch {
+	case bytes.IndexByte(phrase, '`') >= 0:
+		return errors.New("phrase crosses a code span")
+	case bytes.IndexByte(phrase, '~') >= 0:
+		return errors.New("phrase crosses struck-out text")
+	case bytes.IndexByte(phrase, '*') >= 0 || hasEmphasisUnderscore(phrase):
+		return errors.New("phrase crosses emphasis markup")
+	}
+
+	for _, run := range []delimiterRun{runBefore(content, start), runAfter(content, end)} {
+		switch run.delimiter {
+		case 0:
+		case '`':
+			return errors.New("phrase is inside a code span")
+		case '~':
+			return errors.New("phrase is inside struck-out text")
+		default:
+			if !run.bounded {
+				return errors.New("phrase touches emphasis markup that the link would break")
+			}
+		}
+	}
+	return nil
+}
+
+// delimiterRun is a run of the same inline markup delimiter next to a
+// phrase, bounded when whitespace, punctuation or the end of the content
+// lies on its far side
+type delimiterRun struct {
+	delimiter byte
+	bounded   bool
+}
+
+// isDelimiter reports whether c opens or closes inline markup
+func isDelimiter(c byte) bool {
+	return c == '*' || c == '_' || c == '`' || c == '~'
+}
+
+// runBefore returns the delimiter run ending at offset, if any
+func runBefore(content []byte, offset int) delimiterRun {
+	if offset == 0 || !isDelimiter(content[offset-1]) {
+		return delimiterRun{}
+	}
+	c := content[offset-1]
+	i := offset - 1
+	for i > 0 && content[i-1] == c {
+		i--
+	}
+	if i > 0 && content[i-1] == '\\' {
+		return delimiterRun{} // Escaped, so literal
+	}
+	r, _ := utf8.DecodeLastRune(content[:i])
+	return delimiterRun{delimiter: c, bounded: i == 0 || isBoundary(r)}
+}
+
+// runAfter returns the delimiter run starting at offset, if any
+func runAfter(content []byte, offset int) delimiterRun {
+	if offset >= len(content) || !isDelimiter(content[offset]) {
+		return delimiterRun{}
+	}
+	c := content[offset]
+	i := offset
+	for i < len(content) && content[i] == c {
+		i++
+	}
+	r, _ := utf8.DecodeRune(content[i:])
+	return delimiterRun{delimiter: c, bounded: i == len(content) || isBoundary(r)}
+}
+
+// isBoundary reports whether r keeps a delimiter run next to it flanking
+// the same way with or without link brackets on its other side
+func isBoundary(r rune) bool {
+	return unicode.IsSpace(r) || unicode.IsPunct(r) || unicode.IsSymbol(r)
+}
+
+// hasEmphasisUnderscore reports whether text holds an underscore that can
+// open or close emphasis, one not inside a word such as snake_case
+func hasEmphasisUnderscore(text []byte) bool {
+	for i, c := range text {
+		if c != '_' {
+			continue
+		}
+		prev, _ := utf8.DecodeLastRune(text[:i])
+		next, _ := utf8.DecodeRune(text[i+1:])
+		if (i == 0 || !isWordRune(prev) && prev != '_') || (i == len(text)-1 || !isWordRune(next) && next != '_') {
+			return true
+		}
+	}
+	return false
+}
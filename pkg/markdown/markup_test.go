@@ -0,0 +1,44 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckLinkSpan(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		phrase  string
+		err     string
+	}{
+		{name: "plain", content: "we use prometheus alerting daily", phrase: "prometheus alerting"},
+		{name: "whole bold span", content: "we use **prometheus alerting** daily", phrase: "prometheus alerting"},
+		{name: "start of bold span", content: "we use **prometheus alerting** daily", phrase: "prometheus"},
+		{name: "whole italic span", content: "we use _prometheus alerting_.", phrase: "prometheus alerting"},
+		{name: "bold at line start", content: "**prometheus alerting**", phrase: "prometheus alerting"},
+		{name: "escaped asterisks", content: `we use \*prometheus alerting\* daily`, phrase: "prometheus alerting"},
+		{name: "snake case", content: "set alert_manager here", phrase: "alert_manager"},
+		{name: "bold followed by a letter", content: "some **prometheus alert**s", phrase: "prometheus alert", err: "touches emphasis"},
+		{name: "italic after a letter", content: "x*prometheus alerting* y", phrase: "prometheus alerting", err: "touches emphasis"},
+		{name: "across bold", content: "we use **prometheus** alerting", phrase: "prometheus** alerting", err: "crosses emphasis"},
+		{name: "across italic", content: "we use _prometheus_ alerting", phrase: "prometheus_ alerting", err: "crosses emphasis"},
+		{name: "code span", content: "run `prometheus alerting` now", phrase: "prometheus alerting", err: "inside a code span"},
+		{name: "across code span", content: "run `prometheus` alerting", phrase: "prometheus` alerting", err: "crosses a code span"},
+		{name: "strikethrough", content: "no ~~prometheus alerting~~ here", phrase: "prometheus alerting", err: "inside struck-out text"},
+		{name: "across strikethrough", content: "no ~~prometheus~~ alerting", phrase: "prometheus~~ alerting", err: "crosses struck-out text"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := strings.Index(tt.content, tt.phrase)
+			err := CheckLinkSpan([]byte(tt.content), start, start+len(tt.phrase))
+			if tt.err == "" {
+				assert.NoError(t, err)
+			} else if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tt.err)
+			}
+		})
+	}
+}
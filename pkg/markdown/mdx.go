@@ -0,0 +1,129 @@
+package markdown
+
+import (
+	"bytes"
+	"unicode"
+)
+
+// MaskMDX blanks out the parts of an MDX document that aren't prose: the
+// ESM import and export statements at the top of the document and JSX tags
+// starting a line. They are replaced by spaces, so byte offsets into the
+// masked content still match the original.
+func (p *Parser) MaskMDX(content []byte) []byte {
+	masked := append([]byte(nil), content...)
+	_, pos := p.skipFrontmatter(masked)
+
+	// ESM statements may only appear before the first paragraph
+	for pos < len(masked) {
+		end := lineEnd(masked, pos)
+		line := bytes.TrimSpace(masked[pos:end])
+		if len(line) == 0 {
+			pos = end + 1
+			continue
+		}
+		if !isESM(line) {
+			break
+		}
+
+		// A statement continues over several lines until its brackets close
+		depth := bracketDepth(masked[pos:end])
+		for depth > 0 && end < len(masked) {
+			next := lineEnd(masked, end+1)
+			depth += bracketDepth(masked[end+1 : next])
+			end = next
+		}
+		blank(masked[pos:end])
+		pos = end + 1
+	}
+
+	for start := pos; start < len(masked); start = lineEnd(masked, start) + 1 {
+		i := start
+		for i < len(masked) && (masked[i] == ' ' || masked[i] == '\t') {
+			i++
+		}
+		if isJSXTag(masked[i:]) {
+			blank(masked[i:tagEnd(masked, i)])
+		}
+	}
+
+	return masked
+}
+
+// isESM reports whether a line starts an import or export statement
+func isESM(line []byte) bool {
+	for _, keyword := range []string{"import", "export"} {
+		if rest, ok := bytes.CutPrefix(line, []byte(keyword)); ok {
+			return len(rest) > 0 && (rest[0] == ' ' || rest[0] == '{' || rest[0] == '*')
+		}
+	}
+	return false
+}
+
+// isJSXTag reports whether b starts with an opening or closing tag of a
+// component; components are capitalized, unlike HTML elements
+func isJSXTag(b []byte) bool {
+	if len(b) < 2 || b[0] != '<' {
+		return false
+	}
+	b = b[1:]
+	if b[0] == '/' {
+		b = b[1:]
+	}
+	return len(b) > 0 && b[0] < unicode.MaxASCII && unicode.IsUpper(rune(b[0]))
+}
+
+// tagEnd returns the offset just past the '>' closing the tag starting at
+// start, ignoring any inside attribute strings or expressions
+func tagEnd(b []byte, start int) int {
+	var quote byte
+	depth := 0
+	for i := start; i < len(b); i++ {
+		switch c := b[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '{':
+			depth++
+		case c == '}':
+			depth--
+		case c == '>' && depth <= 0:
+			return i + 1
+		}
+	}
+	return len(b)
+}
+
+// bracketDepth returns how many more brackets the line opens than it closes
+func bracketDepth(line []byte) int {
+	depth := 0
+	for _, c := range line {
+		switch c {
+		case '{', '(', '[':
+			depth++
+		case '}', ')', ']':
+			depth--
+		}
+	}
+	return depth
+}
+
+// lineEnd returns the offset of the newline ending the line at start, or
+// the length of b for the last line
+func lineEnd(b []byte, start int) int {
+	if i := bytes.IndexByte(b[start:], '\n'); i != -1 {
+		return start + i
+	}
+	return len(b)
+}
+
+// blank replaces everything but line breaks with spaces
+func blank(b []byte) {
+	for i, c := range b {
+		if c != '\n' {
+			b[i] = ' '
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package markdown
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaskMDX(t *testing.T) {
+	content := "---\ntitle: Setup\n---\n" +
+		"import Tabs from '@theme/Tabs';\n" +
+		"import {\n  TabItem,\n  Admonition,\n} from '@site/components';\n" +
+		"\n" +
+		"Prometheus alerting needs a rule file.\n\n" +
+		"<Admonition type=\"tip\" title={`Grafana ${version}`}>\n\n" +
+		"Grafana dashboards help.\n\n" +
+		"</Admonition>\n\n" +
+		"import this is prose now.\n"
+
+	parser := NewParser(ParserConfig{MinNGram: 1, MaxNGram: 1})
+	masked := parser.MaskMDX([]byte(content))
+	require.Len(t, masked, len(content), "offsets must be preserved")
+
+	wordFreq, err := parser.ParseContent(masked)
+	require.NoError(t, err)
+	for _, term := range []string{"tabs", "tabitem", "admonition", "theme", "tip"} {
+		assert.NotContains(t, wordFreq, term)
+	}
+	for _, term := range []string{"prometheus", "grafana", "dashboards", "prose", "import"} {
+		assert.Contains(t, wordFreq, term)
+	}
+	assert.Equal(t, 1, wordFreq["grafana"], "the title expression belongs to the tag")
+
+	fm, err := parser.ParseFrontmatter(masked)
+	require.NoError(t, err)
+	assert.Equal(t, "Setup", fm.Title)
+}
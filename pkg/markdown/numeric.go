@@ -0,0 +1,37 @@
+package markdown
+
+import (
+	"strings"
+	"unicode"
+)
+
+// IsNumeric reports whether a token is predominantly a number: a date,
+// version, size or count such as 2024, 2024-01-15, v2.3.1, 500ms, 10gb or
+// 50%. It starts with a digit, or a "v" followed by one, and has no more
+// letters than digits, so words like x86, 64-bit or 3rd are not numeric.
+// Punctuation around the token is ignored.
+func IsNumeric(token string) bool {
+	word := strings.TrimFunc(token, func(r rune) bool { return !isWordRune(r) })
+	runes := []rune(word)
+	if len(runes) == 0 {
+		return false
+	}
+	start := runes
+	if unicode.ToLower(start[0]) == 'v' {
+		start = start[1:]
+	}
+	if len(start) == 0 || !unicode.IsDigit(start[0]) {
+		return false
+	}
+
+	letters, digits := 0, 0
+	for _, r := range runes {
+		switch {
+		case unicode.IsDigit(r):
+			digits++
+		case unicode.IsLetter(r):
+			letters++
+		}
+	}
+	return letters <= digits
+}
@@ -0,0 +1,60 @@
+package markdown
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsNumeric(t *testing.T) {
+	for token, numeric := range map[string]bool{
+		"2024":       true,
+		"2024-01-15": true,
+		"v2.3.1":     true,
+		"V2":         true,
+		"500ms":      true,
+		"10gb":       true,
+		"1,000":      true,
+		"50%":        true,
+		"12:30":      true,
+		"(2024),":    true,
+		"x86":        false,
+		"s3":         false,
+		"64-bit":     false,
+		"3rd":        false,
+		"2fa":        false,
+		"ipv6":       false,
+		"version":    false,
+		"v":          false,
+		"":           false,
+	} {
+		assert.Equal(t, numeric, IsNumeric(token), token)
+	}
+}
+
+func TestNumericTokensSkipped(t *testing.T) {
+	content := []byte("Grafana released 2024-01-15 dashboards in 2024, weighing 1,000 panels at 50% load.\n")
+
+	words := func(config ParserConfig) map[string]int {
+		freq, err := NewParser(config).ParseContent(content)
+		require.NoError(t, err)
+		return freq
+	}
+
+	freq := words(ParserConfig{MinNGram: 1, MaxNGram: 1})
+	for _, term := range []string{"2024-01-15", "2024", "1,000", "50%"} {
+		assert.NotContains(t, freq, term)
+	}
+	assert.Contains(t, freq, "dashboards")
+	phrases := words(ParserConfig{MinNGram: 2, MaxNGram: 2})
+	assert.NotContains(t, phrases, "released 2024-01-15")
+	assert.NotContains(t, phrases, "2024 weighing")
+
+	kept := words(ParserConfig{MinNGram: 1, MaxNGram: 1, KeepNumericTokens: true})
+	assert.Contains(t, kept, "2024")
+	assert.Contains(t, kept, "2024-01-15")
+
+	assert.Equal(t, "grafana dashboards", NewParser(ParserConfig{}).Normalize("Grafana 2024 dashboards"))
+	assert.NotEqual(t, NewParser(ParserConfig{}).CacheKey(), NewParser(ParserConfig{KeepNumericTokens: true}).CacheKey())
+}
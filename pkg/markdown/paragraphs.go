@@ -0,0 +1,40 @@
+package markdown
+
+import (
+	"sort"
+
+	"github.com/yuin/goldmark/ast"
+)
+
+// paragraphSpan is the byte range of a paragraph, from the start of its
+// first line to the end of its last
+type paragraphSpan struct {
+	start, end int
+}
+
+// paragraphSpans returns the paragraphs of a parsed document in order.
+// goldmark keeps the text of tight list items in text blocks rather than
+// paragraphs, so each of those counts as a paragraph of its own.
+func paragraphSpans(doc ast.Node) []paragraphSpan {
+	var spans []paragraphSpan
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering || n.Kind() != ast.KindParagraph && n.Kind() != ast.KindTextBlock {
+			return ast.WalkContinue, nil
+		}
+		if lines := n.Lines(); lines.Len() > 0 {
+			spans = append(spans, paragraphSpan{lines.At(0).Start, lines.At(lines.Len() - 1).Stop})
+		}
+		return ast.WalkSkipChildren, nil
+	})
+	return spans
+}
+
+// paragraphAt returns the number, from 1, of the paragraph containing
+// position, or 0 when it lies outside every paragraph, e.g. in a heading
+func paragraphAt(spans []paragraphSpan, position int) int {
+	i := sort.Search(len(spans), func(i int) bool { return spans[i].end > position })
+	if i < len(spans) && spans[i].start <= position {
+		return i + 1
+	}
+	return 0
+}
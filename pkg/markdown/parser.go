@@ -3,15 +3,30 @@ package markdown
 import (
 	"bytes"
 	"fmt"
+	"regexp"
 	"sort"
 	"strings"
 
 	"github.com/yuin/goldmark"
 	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	extast "github.com/yuin/goldmark/extension/ast"
 	"github.com/yuin/goldmark/text"
 )
 
-// Common English function/grammatical words to skip
+// Markdown flavors understood by the parser
+const (
+	FlavorCommonMark = "commonmark" // Plain CommonMark without extensions
+	FlavorGFM        = "gfm"        // GitHub Flavored Markdown plus footnotes
+)
+
+// Defaults for the ParserConfig settings left at zero
+const (
+	DefaultMinWordLength = 3  // Words of one or two characters are dropped
+	DefaultContextSize   = 50 // Characters of context on each side of an occurrence
+)
+
+// Common English function/grammatical words to skip, the default stop words
 var functionWords = map[string]bool{
 	// Articles
 	"a": true, "an": true, "the": true,
@@ -50,20 +65,133 @@ var functionWords = map[string]bool{
 type WordOccurrence struct {
 	Word     string
 	Position int
-	Context  string
+	Length   int    // Bytes of the content the occurrence spans from Position
+	Context  string // The surrounding text, as plain prose
+	Ancestry string // AST path to the occurrence, only set with ParserConfig.DebugPositions
+	Section  string // Anchor of the heading the occurrence falls under
+
+	// Heading is the heading the occurrence falls under, shared by all of
+	// its occurrences, or nil before the first heading
+	Heading *Heading
+
+	// Paragraph numbers the paragraph the occurrence is in, from 1 in
+	// document order, or is 0 outside paragraphs, e.g. in a heading
+	Paragraph int
+
+	// Surface is the text of the occurrence as written, when it differs
+	// from Word through case, stemming or skipped stop words
+	Surface string
+
+	// Highlight is the byte range of the occurrence within Context. Both
+	// are left empty by FindOccurrences; see OccurrenceContext.
+	Highlight [2]int
+
+	// Line and Column locate Position in the content as given, frontmatter
+	// included; both count from 1, and columns count characters
+	Line   int
+	Column int
 }
 
 // Parser handles markdown document parsing and manipulation
 type Parser struct {
-	md       goldmark.Markdown
-	minNGram int
-	maxNGram int
+	md        goldmark.Markdown
+	flavor    string
+	tokenizer Tokenizer
+	minNGram  int
+	maxNGram  int
+	debug     bool
+
+	linkFormat      string
+	linked          *regexp.Regexp
+	linkInHeadings  bool
+	skipBlockquotes bool
+
+	stopWords    map[string]bool
+	stopWordsKey string
+	stemming     bool
+
+	minWordLength int
+	contextSize   int
+	allowCodeish  bool
+	keepNumeric   bool
+
+	maxOccurrences int
+	maxTerms       int
+	overflow       string
 }
 
 // ParserConfig holds configuration for the parser
 type ParserConfig struct {
-	MinNGram int // Minimum number of words in n-grams
-	MaxNGram int // Maximum number of words in n-grams
+	MinNGram int    // Minimum number of words in n-grams
+	MaxNGram int    // Maximum number of words in n-grams
+	Flavor   string // Markdown flavor, FlavorCommonMark (default) or FlavorGFM
+
+	// Tokenizer overrides how text is split into tokens. When nil, the
+	// tokenizer registered under TokenizerName is used, falling back to
+	// the default whitespace tokenizer.
+	Tokenizer     Tokenizer
+	TokenizerName string
+
+	// DebugPositions records the AST ancestry of every occurrence
+	DebugPositions bool
+
+	// Per-document guards against n-gram explosion on huge files (0 = unlimited)
+	MaxOccurrencesPerDoc int
+	MaxTermsPerDoc       int
+	BudgetOverflow       string // BudgetTruncate (default) or BudgetUnigrams
+
+	// LinkFormat selects the syntax of inserted links, LinkFormatMarkdown
+	// (default), LinkFormatWikilink or LinkFormatReference
+	LinkFormat string
+
+	// LinkedPattern is a regular expression matching links written in
+	// custom markup, such as Hugo relref shortcodes or HTML anchors. Text it
+	// matches is treated like an existing link: never linked again and not
+	// counted. Named groups phrase and target, if present, give the link's
+	// text and destination. An invalid pattern is ignored; callers validate
+	// it with regexp.Compile first.
+	LinkedPattern string
+
+	// LinkInHeadings allows occurrences inside headings to be linked. By
+	// default headings are skipped, though their words still count towards
+	// the document's frequencies.
+	LinkInHeadings bool
+
+	// SkipBlockquotes keeps occurrences inside blockquotes from being
+	// linked, so quoted text never carries links. Their words still count
+	// towards the document's frequencies.
+	SkipBlockquotes bool
+
+	// Stop words are never indexed. Language selects a bundled list
+	// (LanguageEnglish by default); StopWordsFile, one word per line,
+	// replaces it, or extends it when ExtendStopWords is set.
+	Language        string
+	StopWordsFile   string
+	ExtendStopWords bool
+
+	// Stemming reduces words to their English stem before they are counted
+	// or joined into n-grams, so "deployment" matches "deployments"
+	Stemming bool
+
+	// MinWordLength drops shorter words before they are counted or joined
+	// into n-grams (DefaultMinWordLength if 0). Lower it to index terms
+	// like "go" or "ai".
+	MinWordLength int
+
+	// ContextSize is how many characters around an occurrence its context
+	// shows on each side (DefaultContextSize if 0)
+	ContextSize int
+
+	// AllowCodeishTokens keeps tokens that look like identifiers or paths
+	// outside code spans, such as myFunctionName, snake_case, src/pkg/foo or
+	// v1beta1. By default they are neither counted nor linked, and phrases
+	// don't run across them.
+	AllowCodeishTokens bool
+
+	// KeepNumericTokens counts and links numbers, dates, versions and sizes
+	// such as 2024, 2024-01-15, v2.3.1 or 500ms, which are dropped by
+	// default (see IsNumeric)
+	KeepNumericTokens bool
 }
 
 // NewParser creates a new markdown parser
@@ -71,11 +199,93 @@ func NewParser(config ParserConfig) *Parser {
 	if config.MinNGram < 1 {
 		config.MinNGram = 1 // Default to unigrams if not specified
 	}
+
+	tokenizer := config.Tokenizer
+	if tokenizer == nil {
+		var ok bool
+		if tokenizer, ok = LookupTokenizer(config.TokenizerName); !ok {
+			tokenizer = WhitespaceTokenizer{}
+		}
+	}
+
+	if config.Flavor == "" {
+		config.Flavor = FlavorCommonMark
+	}
+
+	if config.MinWordLength < 1 {
+		config.MinWordLength = DefaultMinWordLength
+	}
+	if config.ContextSize < 1 {
+		config.ContextSize = DefaultContextSize
+	}
+
+	// Like an unknown tokenizer, unusable stop-word settings fall back to the
+	// default; callers validate them with LoadStopWords first
+	stopWords, err := LoadStopWords(config)
+	if err != nil {
+		config.Language, config.StopWordsFile = LanguageEnglish, ""
+		stopWords = functionWords
+	}
+
+	linked, err := regexp.Compile(config.LinkedPattern)
+	if err != nil || config.LinkedPattern == "" {
+		linked = nil
+	}
+
+	var extensions []goldmark.Extender
+	if config.Flavor == FlavorGFM {
+		extensions = append(extensions, extension.GFM, extension.Footnote)
+	}
+
 	return &Parser{
-		md:       goldmark.New(),
-		minNGram: config.MinNGram,
-		maxNGram: config.MaxNGram,
+		md:        goldmark.New(goldmark.WithExtensions(extensions...)),
+		flavor:    config.Flavor,
+		tokenizer: tokenizer,
+		minNGram:  config.MinNGram,
+		maxNGram:  config.MaxNGram,
+		debug:     config.DebugPositions,
+
+		maxOccurrences:  config.MaxOccurrencesPerDoc,
+		maxTerms:        config.MaxTermsPerDoc,
+		overflow:        config.BudgetOverflow,
+		linkFormat:      config.LinkFormat,
+		linked:          linked,
+		linkInHeadings:  config.LinkInHeadings,
+		skipBlockquotes: config.SkipBlockquotes,
+		stopWords:       stopWords,
+		stopWordsKey:    stopWordsKey(config, stopWords),
+		stemming:        config.Stemming,
+		minWordLength:   config.MinWordLength,
+		contextSize:     config.ContextSize,
+		allowCodeish:    config.AllowCodeishTokens,
+		keepNumeric:     config.KeepNumericTokens,
+	}
+}
+
+// TokenizerName returns the name of the tokenizer in use
+func (p *Parser) TokenizerName() string {
+	return p.tokenizer.Name()
+}
+
+// MinWordLength returns the length below which words are dropped
+func (p *Parser) MinWordLength() int {
+	return p.minWordLength
+}
+
+// CacheKey describes every setting that affects ParseContent's results, so
+// cached frequencies are only reused by a parser that would produce the same.
+// New parser options that change the output must be added here.
+func (p *Parser) CacheKey() string {
+	return fmt.Sprintf("tokenizer=%s ngram=%d-%d flavor=%s max-occurrences=%d max-terms=%d overflow=%s stop-words=%s stemming=%t min-word-length=%d codeish=%t numeric=%t linked=%q",
+		p.tokenizer.Name(), p.minNGram, p.maxNGram, p.flavor, p.maxOccurrences, p.maxTerms, p.overflow, p.stopWordsKey, p.stemming, p.minWordLength, p.allowCodeish, p.keepNumeric, p.linkedPattern())
+}
+
+// linkedPattern returns the pattern of custom markup links, or "" for none
+func (p *Parser) linkedPattern() string {
+	if p.linked == nil {
+		return ""
 	}
+	return p.linked.String()
 }
 
 // generateNGrams generates n-grams of exactly the specified length
@@ -92,50 +302,30 @@ func generateNGrams(words []string, n int) []string {
 	return ngrams
 }
 
-// isSignificantWord checks if a word carries lexical meaning
-func isSignificantWord(word string) bool {
-	// Skip function words
-	if functionWords[word] {
+// isSignificant checks if a normalized token carries lexical meaning
+func (p *Parser) isSignificant(word string) bool {
+	// Skip stop words
+	if p.stopWords[word] {
 		return false
 	}
 
-	// Skip words that are just numbers
-	if strings.IndexFunc(word, func(r rune) bool { return !strings.ContainsRune("0123456789", r) }) == -1 {
+	// Skip numbers, dates, versions and sizes
+	if !p.keepNumeric && IsNumeric(word) {
 		return false
 	}
 
 	return true
 }
 
+// isCodeish reports whether a token is left out for looking like code
+func (p *Parser) isCodeish(token string) bool {
+	return !p.allowCodeish && looksLikeCode(token)
+}
+
 // skipFrontmatter returns the content without frontmatter and the number of bytes skipped
 func (p *Parser) skipFrontmatter(content []byte) ([]byte, int) {
-	// Check for YAML/TOML frontmatter
-	if len(content) > 3 && (bytes.HasPrefix(content, []byte("---")) || bytes.HasPrefix(content, []byte("+++"))) {
-		rest := content[3:]
-
-		// Find first newline after opening delimiter
-		if nl := bytes.IndexByte(rest, '\n'); nl != -1 {
-			rest = rest[nl+1:]
-
-			// Find the closing delimiter
-			var delimiter []byte
-			if bytes.HasPrefix(content, []byte("---")) {
-				delimiter = []byte("---")
-			} else {
-				delimiter = []byte("+++")
-			}
-
-			if idx := bytes.Index(rest, delimiter); idx != -1 {
-				// Find the newline after the closing delimiter
-				closeRest := rest[idx+3:]
-				if nl := bytes.IndexByte(closeRest, '\n'); nl != -1 {
-					skipped := len(content) - len(closeRest[nl+1:])
-					return closeRest[nl+1:], skipped
-				}
-			}
-		}
-	}
-	return content, 0
+	end := p.FrontmatterEnd(content)
+	return content[end:], end
 }
 
 // processTextNode extracts text from a text node and writes it to the buffer
@@ -152,7 +342,11 @@ func (p *Parser) walkTextNodes(n ast.Node, content []byte, buf *bytes.Buffer) as
 		if text, ok := n.(*ast.Text); ok {
 			p.processTextNode(text, content, buf)
 		}
-	case ast.KindCodeBlock, ast.KindFencedCodeBlock, ast.KindCodeSpan:
+	case ast.KindCodeBlock, ast.KindFencedCodeBlock, ast.KindCodeSpan, ast.KindHTMLBlock, ast.KindRawHTML:
+		return ast.WalkSkipChildren
+	case ast.KindLink, ast.KindAutoLink, ast.KindImage:
+		return ast.WalkSkipChildren
+	case extast.KindStrikethrough, extast.KindFootnoteLink, extast.KindFootnoteBacklink, extast.KindTaskCheckBox:
 		return ast.WalkSkipChildren
 	default:
 		// For all other nodes (including headings), process their children
@@ -165,189 +359,371 @@ func (p *Parser) walkTextNodes(n ast.Node, content []byte, buf *bytes.Buffer) as
 	return ast.WalkContinue
 }
 
-// ParseContent parses markdown content and returns a map of word/n-gram frequencies
+// ParseContent parses markdown content and returns a map of word/n-gram frequencies.
+// Like FindOccurrences, it returns partial results with a *BudgetError
+// when the document exceeds the configured budget.
 func (p *Parser) ParseContent(content []byte) (map[string]int, error) {
-	// Use FindWordOccurrences to get all word/n-gram occurrences
-	occurrences, err := p.FindWordOccurrences(content, 1) // minWordLen=1 since we'll filter later
-	if err != nil {
-		return nil, err
+	wordFreq, _, err := p.ParseSections(content)
+	return wordFreq, err
+}
+
+// processTextNodeWithPosition adds the significant words of a text node to
+// the sink's current run, so phrases can continue across the text nodes of
+// a paragraph, e.g. into emphasis or over a line break. Token offsets are
+// made relative to content.
+func (p *Parser) processTextNodeWithPosition(text *ast.Text, content []byte, frontmatterOffset int, minWordLen int, ancestry string, sink *occurrenceSink) bool {
+	segmentStart := text.Segment.Start
+	tokens := p.tokenizer.Tokenize(string(text.Segment.Value(content)))
+
+	for _, token := range tokens {
+		token.Start += segmentStart
+		token.End += segmentStart
+
+		// Phrases don't span across wikilinks or custom markup links, whose
+		// text is never reported, or URLs and identifiers written out as
+		// plain text
+		if sink.inLink(token.Start, token.End) || p.breaksPhrase(token) {
+			if !p.flushRun(content, frontmatterOffset, minWordLen, sink) {
+				return false
+			}
+			continue
+		}
+
+		if token, ok := p.indexedToken(token); ok {
+			sink.run = append(sink.run, runToken{Token: token, container: text.Parent(), ancestry: ancestry})
+		}
 	}
+	return true
+}
+
+// breaksPhrase reports whether a token ends the phrase before it instead of
+// being skipped: a URL or an identifier written out as plain text
+func (p *Parser) breaksPhrase(token Token) bool {
+	return isURL(token.Surface) || p.isCodeish(token.Surface)
+}
 
-	// Convert occurrences to frequency map
-	wordFreq := make(map[string]int)
-	for _, occ := range occurrences {
-		wordFreq[occ.Word]++
+// indexedToken returns a token as it is indexed, narrowed to its word and
+// stemmed if stemming is enabled, and false for numbers, stop words and very
+// short words, which phrases skip
+func (p *Parser) indexedToken(token Token) (Token, bool) {
+	if !p.isSignificant(token.Normalized) || len(token.Normalized) < p.minWordLength {
+		return token, false
+	}
+	normalized := token.Normalized
+	token = trimToWord(token)
+	if p.stemming {
+		token.Normalized = Stem(normalized)
 	}
+	return token, true
+}
 
-	return wordFreq, nil
+// eachNGram calls fn with the start and length of every n-gram of a run of
+// count words, stopping when fn returns false. A minimum length of one
+// counts the single words only.
+func eachNGram(count, minNGram, maxNGram int, fn func(i, n int) bool) bool {
+	if minNGram == 1 {
+		maxNGram = 1
+	}
+	for n := minNGram; n <= maxNGram && n <= count; n++ {
+		for i := 0; i <= count-n; i++ {
+			if !fn(i, n) {
+				return false
+			}
+		}
+	}
+	return true
 }
 
-// processTextNodeWithPosition processes a text node and adds word occurrences to the slice
-func (p *Parser) processTextNodeWithPosition(text *ast.Text, content []byte, currentPosition int, frontmatterOffset int, minWordLen int, occurrences *[]WordOccurrence) {
-	textContent := text.Segment.Value(content)
-	textStr := string(textContent)
+// isURL reports whether a token is a bare URL, i.e. starts with a scheme
+// followed by "://", such as https://example.com/screenshot-final.png
+func isURL(token string) bool {
+	token = strings.TrimLeft(token, "([{<\"'")
+	scheme, _, found := strings.Cut(token, "://")
+	if !found || scheme == "" {
+		return false
+	}
+	for i, r := range scheme {
+		letter := r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z'
+		if !letter && (i == 0 || !strings.ContainsRune("0123456789+-.", r)) {
+			return false
+		}
+	}
+	return true
+}
 
-	// Get all words and their positions
-	words := strings.Fields(textStr)
-	if len(words) == 0 {
-		return
+// flushRun emits the occurrences of the sink's current run and starts a new
+// one, reporting false once the sink is full
+func (p *Parser) flushRun(content []byte, frontmatterOffset int, minWordLen int, sink *occurrenceSink) bool {
+	run := sink.run
+	sink.run = sink.run[:0]
+	if len(run) == 0 {
+		return !sink.full()
 	}
+	return p.emitOccurrences(run, content, frontmatterOffset, minWordLen, sink)
+}
 
-	// Create normalized words and track their positions
-	var significantWords []string
-	var significantWordPositions []int
-	pos := 0
+// trimToWord narrows a token's span to the text its normalized form was
+// taken from, leaving out surrounding punctuation
+func trimToWord(token Token) Token {
+	lower := strings.ToLower(token.Surface)
+	if token.Normalized == "" || len(lower) != len(token.Surface) {
+		return token
+	}
+	if i := strings.Index(lower, token.Normalized); i >= 0 {
+		token.Surface = token.Surface[i : i+len(token.Normalized)]
+		token.Start += i
+		token.End = token.Start + len(token.Normalized)
+	}
+	return token
+}
 
-	for i, word := range words {
-		normalized := strings.ToLower(strings.Trim(word, ".,!?()[]{}\"'"))
+// surface returns the written text of an occurrence if it differs from its word
+func surface(text []byte, word string) string {
+	if string(text) == word {
+		return ""
+	}
+	return string(text)
+}
 
-		// Skip numbers and function words
-		if strings.IndexFunc(normalized, func(r rune) bool { return !strings.ContainsRune("0123456789", r) }) == -1 {
+// Normalize turns a phrase into the form its occurrences are indexed under:
+// significant words only, lowercased and stemmed if stemming is enabled
+func (p *Parser) Normalize(phrase string) string {
+	var words []string
+	for _, token := range p.tokenizer.Tokenize(phrase) {
+		if p.isCodeish(token.Surface) {
 			continue
 		}
-		if functionWords[normalized] {
-			continue
+		if token, ok := p.indexedToken(token); ok {
+			words = append(words, token.Normalized)
 		}
-		if len(normalized) <= 2 {
+	}
+	return strings.Join(words, " ")
+}
+
+// Terms counts the words or n-grams of plain text the way those of a
+// document's text are counted, so a query split by Terms has exactly the
+// terms documents are indexed under
+func (p *Parser) Terms(text string) map[string]int {
+	terms := make(map[string]int)
+	var run []string
+	flush := func() {
+		eachNGram(len(run), p.minNGram, p.maxNGram, func(i, n int) bool {
+			terms[strings.Join(run[i:i+n], " ")]++
+			return true
+		})
+		run = run[:0]
+	}
+	for _, token := range p.tokenizer.Tokenize(text) {
+		if p.breaksPhrase(token) {
+			flush()
 			continue
 		}
+		if token, ok := p.indexedToken(token); ok {
+			run = append(run, token.Normalized)
+		}
+	}
+	flush()
+	return terms
+}
 
-		// Find the exact position of the word in the original text
-		wordPos := -1
-		if i == 0 {
-			wordPos = bytes.Index(textContent, []byte(word))
-		} else {
-			wordPos = bytes.Index(textContent[pos:], []byte(word))
-			if wordPos != -1 {
-				wordPos += pos
-			}
+// emitOccurrences adds the words or n-grams of a run of significant tokens
+// to the sink, reporting false once the sink is full. When looking for text
+// to link, phrases whose words lie in different inline elements, such as
+// one inside emphasis and one outside of it, are left out: a link around
+// them would cut through the markup.
+func (p *Parser) emitOccurrences(significant []runToken, content []byte, frontmatterOffset int, minWordLen int, sink *occurrenceSink) bool {
+	return eachNGram(len(significant), sink.minNGram, sink.maxNGram, func(i, n int) bool {
+		run := significant[i : i+n]
+		if n == 1 && len(run[0].Normalized) < minWordLen || sink.linking && !sameContainer(run) {
+			return true
 		}
-		if wordPos != -1 {
-			pos = wordPos + len(word)
-			significantWords = append(significantWords, normalized)
-			significantWordPositions = append(significantWordPositions, wordPos)
+		words := make([]string, n)
+		for j, token := range run {
+			words[j] = token.Normalized
+		}
+		word := strings.Join(words, " ")
+
+		start, end := run[0].Start, run[n-1].End
+		return sink.add(WordOccurrence{
+			Word:     word,
+			Position: frontmatterOffset + start,
+			Length:   end - start,
+			Ancestry: run[0].ancestry,
+			Surface:  surface(content[start:end], word),
+		})
+	})
+}
+
+// sameContainer reports whether the tokens all come from text directly
+// inside the same node
+func sameContainer(tokens []runToken) bool {
+	for _, token := range tokens[1:] {
+		if token.container != tokens[0].container {
+			return false
 		}
 	}
+	return true
+}
 
-	// If no significant words found, return early
-	if len(significantWords) == 0 {
-		return
+// walkNodesWithPosition walks through nodes recursively and processes text nodes with position tracking.
+// When debugging positions, ancestry holds the kinds of the nodes above n.
+func (p *Parser) walkNodesWithPosition(n ast.Node, content []byte, frontmatterOffset int, minWordLen int, ancestry []string, sink *occurrenceSink) ast.WalkStatus {
+	// Phrases run across the inline elements of a block but end with it,
+	// before a heading changes the section they are recorded in
+	if n.Type() == ast.TypeBlock && !p.flushRun(content, frontmatterOffset, minWordLen, sink) {
+		return ast.WalkStop
 	}
 
-	// For single words (unigrams)
-	if p.minNGram == 1 {
-		for i, word := range significantWords {
-			if len(word) >= minWordLen {
-				absPos := frontmatterOffset + currentPosition + significantWordPositions[i]
-				context := p.extractContext(content, currentPosition+significantWordPositions[i], len(words[i]))
-				*occurrences = append(*occurrences, WordOccurrence{
-					Word:     word,
-					Position: absPos,
-					Context:  context,
-				})
-			}
+	// Code and raw HTML, text that is already linked, struck-out text and
+	// footnote or task list markers never carry new links, and phrases
+	// don't run across them
+	switch n.Kind() {
+	case ast.KindCodeBlock, ast.KindFencedCodeBlock, ast.KindHTMLBlock:
+		return ast.WalkSkipChildren
+	case ast.KindCodeSpan, ast.KindRawHTML, ast.KindLink, ast.KindAutoLink, ast.KindImage,
+		extast.KindStrikethrough, extast.KindFootnoteLink, extast.KindFootnoteBacklink, extast.KindTaskCheckBox:
+		if !p.flushRun(content, frontmatterOffset, minWordLen, sink) {
+			return ast.WalkStop
+		}
+		return ast.WalkSkipChildren
+	case ast.KindHeading:
+		text := headingText(n, content)
+		sink.section = sink.anchors.add(text)
+		sink.heading = &Heading{Text: strings.TrimSpace(text), Level: n.(*ast.Heading).Level}
+
+		// Linked headings look odd and break some themes' anchor generation
+		if sink.skipHeadings {
+			return ast.WalkSkipChildren
+		}
+	case ast.KindBlockquote:
+		if sink.skipBlockquotes {
+			return ast.WalkSkipChildren
 		}
-		return
 	}
 
-	// For n-grams
-	if len(significantWords) >= p.minNGram {
-		// Generate n-grams for each length between minNGram and maxNGram
-		for n := p.minNGram; n <= p.maxNGram && n <= len(significantWords); n++ {
-			for i := 0; i <= len(significantWords)-n; i++ {
-				ngramWords := significantWords[i : i+n]
-				ngram := strings.Join(ngramWords, " ")
-
-				startPos := significantWordPositions[i]
-				endWordIdx := i + n - 1
-				endPos := significantWordPositions[endWordIdx] + len(words[endWordIdx])
-				absPos := frontmatterOffset + currentPosition + startPos
-
-				context := p.extractContext(content, currentPosition+startPos, endPos-startPos)
-				*occurrences = append(*occurrences, WordOccurrence{
-					Word:     ngram,
-					Position: absPos,
-					Context:  context,
-				})
-			}
-		}
+	if p.debug {
+		ancestry = append(ancestry, n.Kind().String())
 	}
-}
 
-// walkNodesWithPosition walks through nodes recursively and processes text nodes with position tracking
-func (p *Parser) walkNodesWithPosition(n ast.Node, content []byte, currentPosition *int, frontmatterOffset int, minWordLen int, occurrences *[]WordOccurrence) ast.WalkStatus {
 	// Process text nodes
 	if text, ok := n.(*ast.Text); ok {
-		// Use the original segment position to maintain correct offsets
-		segmentStart := text.Segment.Start
-		p.processTextNodeWithPosition(text, content, segmentStart, frontmatterOffset, minWordLen, occurrences)
-		*currentPosition = text.Segment.Stop
+		var path string
+		if p.debug {
+			path = fmt.Sprintf("%s[%d:%d]", strings.Join(ancestry, " > "),
+				frontmatterOffset+text.Segment.Start, frontmatterOffset+text.Segment.Stop)
+		}
+
+		if !p.processTextNodeWithPosition(text, content, frontmatterOffset, minWordLen, path, sink) {
+			return ast.WalkStop
+		}
 	}
 
 	// Recurse through all children
 	for child := n.FirstChild(); child != nil; child = child.NextSibling() {
-		p.walkNodesWithPosition(child, content, currentPosition, frontmatterOffset, minWordLen, occurrences)
+		if p.walkNodesWithPosition(child, content, frontmatterOffset, minWordLen, ancestry, sink) == ast.WalkStop {
+			return ast.WalkStop
+		}
 	}
 
+	// The run of the block's last text ends with it
+	if n.Type() == ast.TypeBlock && !p.flushRun(content, frontmatterOffset, minWordLen, sink) {
+		return ast.WalkStop
+	}
 	return ast.WalkContinue
 }
 
-// FindWordOccurrences finds all occurrences of words and n-grams in the document
+// FindOccurrences finds all occurrences of words and n-grams in the document,
+// without their contexts: only the few that end up suggested need one, so
+// OccurrenceContext extracts it on demand. If the document exceeds the
+// configured budget, the occurrences found within the budget are returned
+// together with a *BudgetError.
+func (p *Parser) FindOccurrences(content []byte, minWordLen int) ([]WordOccurrence, error) {
+	return p.findOccurrences(content, minWordLen, true)
+}
+
+// FindWordOccurrences is like FindOccurrences, but extracts the context of
+// every occurrence
 func (p *Parser) FindWordOccurrences(content []byte, minWordLen int) ([]WordOccurrence, error) {
+	occurrences, err := p.findOccurrences(content, minWordLen, true)
+	body, frontmatterOffset := p.skipFrontmatter(content)
+	for i := range occurrences {
+		p.fillContext(body, frontmatterOffset, &occurrences[i])
+	}
+	return occurrences, err
+}
+
+// OccurrenceContext fills in the context of an occurrence FindOccurrences
+// found in content, unless it has one
+func (p *Parser) OccurrenceContext(content []byte, occ *WordOccurrence) {
+	if occ.Context != "" {
+		return
+	}
+	body, frontmatterOffset := p.skipFrontmatter(content)
+	p.fillContext(body, frontmatterOffset, occ)
+}
+
+// fillContext sets the context of an occurrence in the body of a document
+// that starts frontmatterOffset bytes into it
+func (p *Parser) fillContext(body []byte, frontmatterOffset int, occ *WordOccurrence) {
+	occ.Context, occ.Highlight = p.extractContext(body, occ.Position-frontmatterOffset, occ.Length)
+}
+
+// findOccurrences implements FindOccurrences. Occurrences in headings,
+// and optionally blockquotes, are left out when linking is set, i.e. when
+// looking for text to link rather than counting a document's terms.
+func (p *Parser) findOccurrences(content []byte, minWordLen int, linking bool) ([]WordOccurrence, error) {
+	lines := NewLineIndex(content)
 	content, frontmatterOffset := p.skipFrontmatter(content)
 	reader := text.NewReader(content)
 	doc := p.md.Parser().Parse(reader)
 
-	var occurrences []WordOccurrence
-	currentPosition := 0
+	sink := p.walkDocument(doc, content, frontmatterOffset, minWordLen, p.minNGram, p.maxNGram, linking)
 
-	// Process the entire document tree
-	p.walkNodesWithPosition(doc, content, &currentPosition, frontmatterOffset, minWordLen, &occurrences)
+	var budgetErr *BudgetError
+	if sink.full() {
+		budgetErr = sink.exceeded
+
+		// Phrases are what blow up the budget, so retry with single words
+		if p.overflow == BudgetUnigrams && p.minNGram > 1 {
+			sink = p.walkDocument(doc, content, frontmatterOffset, minWordLen, 1, 1, linking)
+			budgetErr = &BudgetError{Limit: budgetErr.Limit, Max: budgetErr.Max, Unigrams: true}
+		}
+	}
+	occurrences := sink.occurrences
+
+	spans := paragraphSpans(doc)
+	for i := range occurrences {
+		occurrences[i].Paragraph = paragraphAt(spans, occurrences[i].Position-frontmatterOffset)
+		occurrences[i].Line, occurrences[i].Column = lines.Position(occurrences[i].Position)
+	}
 
 	// Sort occurrences by position to ensure consistent order
 	sort.Slice(occurrences, func(i, j int) bool {
 		return occurrences[i].Position < occurrences[j].Position
 	})
 
+	if budgetErr != nil {
+		return occurrences, budgetErr
+	}
 	return occurrences, nil
 }
 
-// extractContext extracts surrounding context for a word
-func (p *Parser) extractContext(content []byte, position, wordLen int) string {
-	// Define context window size (characters before and after the word)
-	const contextSize = 50
-
-	start := position - contextSize
-	if start < 0 {
-		start = 0
-	}
-
-	end := position + wordLen + contextSize
-	if end > len(content) {
-		end = len(content)
-	}
-
-	// Extract context and clean it up
-	context := content[start:end]
+// walkDocument collects the occurrences of the parsed document using the given n-gram range
+func (p *Parser) walkDocument(doc ast.Node, content []byte, frontmatterOffset, minWordLen, minNGram, maxNGram int, linking bool) *occurrenceSink {
+	sink := newOccurrenceSink(minNGram, maxNGram, p.maxOccurrences, p.maxTerms)
+	sink.linked = append(findWikilinks(content), findPatternLinks(p.linked, content)...)
+	sink.skipHeadings = linking && !p.linkInHeadings
+	sink.skipBlockquotes = linking && p.skipBlockquotes
+	sink.linking = linking
 
-	// Replace newlines and multiple spaces with single spaces
-	context = bytes.ReplaceAll(context, []byte{'\n'}, []byte{' '})
-	context = bytes.Join(bytes.Fields(context), []byte{' '})
-
-	// Add ellipsis if context is truncated
-	var result bytes.Buffer
-	if start > 0 {
-		result.WriteString("...")
-	}
-	result.Write(context)
-	if end < len(content) {
-		result.WriteString("...")
-	}
+	// Process the entire document tree
+	p.walkNodesWithPosition(doc, content, frontmatterOffset, minWordLen, nil, sink)
 
-	return result.String()
+	return sink
 }
 
-// InsertLink inserts a markdown link at the specified position
+// InsertLink inserts a link at the specified position. In the reference
+// link format the definition it needs is added at the end of the document.
 func (p *Parser) InsertLink(content []byte, word string, target string, position int) ([]byte, error) {
 	if position < 0 || position >= len(content) {
 		return nil, fmt.Errorf("position %d is out of range for content length %d", position, len(content))
@@ -365,13 +741,26 @@ func (p *Parser) InsertLink(content []byte, word string, target string, position
 	}
 
 	// Create the link
-	link := []byte(fmt.Sprintf("[%s](%s)", word, target))
+	link := []byte(p.FormatLink(word, target))
+	var definitions []DefinitionInsertion
+	if p.linkFormat == LinkFormatReference {
+		refs := p.NewReferences(content)
+		markup, _ := refs.Link(word, target)
+		link = []byte(markup)
+		definitions = refs.Insertions(content)
+	}
 
-	// Construct the result
+	// Construct the result; definitions all lie after the link
 	result := make([]byte, 0, len(content)+len(link)-len(word))
 	result = append(result, content[:position]...)
 	result = append(result, link...)
-	result = append(result, content[position+len(word):]...)
+	prev := position + len(word)
+	for i := len(definitions) - 1; i >= 0; i-- {
+		result = append(result, content[prev:definitions[i].Offset]...)
+		result = append(result, definitions[i].Text...)
+		prev = definitions[i].Offset
+	}
+	result = append(result, content[prev:]...)
 
 	return result, nil
 }
@@ -1,10 +1,12 @@
 package markdown
 
 import (
+	"fmt"
 	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestParseContent(t *testing.T) {
@@ -92,6 +94,7 @@ More content about testing`,
 			maxNGram: 2,
 			expected: map[string]int{
 				"hello world":     1,
+				"world test":      1,
 				"testing testing": 1,
 				"test testing":    1,
 			},
@@ -187,6 +190,28 @@ This is a test document`,
 				{Word: "after code", Position: 31, Context: "...After code"},
 			},
 		},
+		{
+			name:       "with existing links and images",
+			content:    "Before link [test document](a.md) and ![diagram image](d.png) <https://example.com> after link",
+			minNGram:   2,
+			maxNGram:   2,
+			minWordLen: 3,
+			expected: []WordOccurrence{
+				{Word: "before link", Position: 0, Context: "Before link..."},
+				{Word: "after link", Position: 84, Context: "...after link"},
+			},
+		},
+		{
+			name:       "with existing wikilinks",
+			content:    "Before link [[Test Document|test document]] after link",
+			minNGram:   2,
+			maxNGram:   2,
+			minWordLen: 3,
+			expected: []WordOccurrence{
+				{Word: "before link", Position: 0, Context: "Before link..."},
+				{Word: "after link", Position: 44, Context: "...after link"},
+			},
+		},
 		{
 			name:       "multiple n-gram lengths",
 			content:    "This is a test document about testing",
@@ -226,6 +251,68 @@ This is a test document`,
 	}
 }
 
+func TestMinWordLength(t *testing.T) {
+	content := []byte("We deploy Go services, AI tooling.")
+
+	words := func(config ParserConfig) []string {
+		parser := NewParser(config)
+		occurrences, err := parser.FindWordOccurrences(content, parser.MinWordLength())
+		assert.NoError(t, err)
+		var words []string
+		for _, occ := range occurrences {
+			words = append(words, occ.Word)
+		}
+		return words
+	}
+
+	assert.Equal(t, []string{"deploy", "services", "tooling"}, words(ParserConfig{MinNGram: 1, MaxNGram: 1}))
+	assert.Equal(t, []string{"deploy", "go", "services", "ai", "tooling"}, words(ParserConfig{MinNGram: 1, MaxNGram: 1, MinWordLength: 2}))
+	assert.Equal(t, []string{"deploy go", "go services", "services ai", "ai tooling"}, words(ParserConfig{MinNGram: 2, MaxNGram: 2, MinWordLength: 2}))
+	assert.Equal(t, []string{"services", "tooling"}, words(ParserConfig{MinNGram: 1, MaxNGram: 1, MinWordLength: 7}))
+
+	assert.Equal(t, "go services", NewParser(ParserConfig{MinWordLength: 2}).Normalize("Go services"))
+	assert.Equal(t, "services", NewParser(ParserConfig{}).Normalize("Go services"))
+}
+
+func TestOccurrenceParagraphs(t *testing.T) {
+	content := []byte("---\ntitle: Paragraphs\n---\n# Heading words\n\nFirst paragraph\nwrapped line.\n\n> Quoted text.\n\n- Listed entry\n- Another item\n")
+	parser := NewParser(ParserConfig{MinNGram: 1, MaxNGram: 1, LinkInHeadings: true})
+	occurrences, err := parser.FindWordOccurrences(content, 3)
+	assert.NoError(t, err)
+
+	paragraphs := make(map[string]int)
+	for _, occ := range occurrences {
+		paragraphs[occ.Word] = occ.Paragraph
+	}
+	assert.Equal(t, map[string]int{
+		"heading": 0, "words": 0,
+		"first": 1, "paragraph": 1, "wrapped": 1, "line": 1,
+		"quoted": 2, "text": 2,
+		"listed": 3, "entry": 3, "another": 4, "item": 4,
+	}, paragraphs)
+}
+
+func TestContextSize(t *testing.T) {
+	content := []byte(strings.Repeat("filler ", 30) + "kubernetes" + strings.Repeat(" padding", 30))
+
+	contextOf := func(size int) string {
+		parser := NewParser(ParserConfig{MinNGram: 1, MaxNGram: 1, ContextSize: size})
+		occurrences, err := parser.FindWordOccurrences(content, 3)
+		assert.NoError(t, err)
+		for _, occ := range occurrences {
+			if occ.Word == "kubernetes" {
+				return occ.Context
+			}
+		}
+		t.Fatal("kubernetes not found")
+		return ""
+	}
+
+	assert.Equal(t, "...filler kubernetes padding...", contextOf(8))
+	assert.Len(t, contextOf(0), len(contextOf(DefaultContextSize)))
+	assert.Greater(t, len(contextOf(120)), len(contextOf(DefaultContextSize)))
+}
+
 func TestInsertLink(t *testing.T) {
 	parser := NewParser(ParserConfig{MinNGram: 1, MaxNGram: 1})
 
@@ -301,6 +388,31 @@ func TestInsertLink(t *testing.T) {
 	}
 }
 
+func TestInsertWikilink(t *testing.T) {
+	parser := NewParser(ParserConfig{MinNGram: 1, MaxNGram: 1, LinkFormat: LinkFormatWikilink})
+
+	result, err := parser.InsertLink([]byte("This is a test document"), "test", "notes/Target Note.md", 10)
+	assert.NoError(t, err)
+	assert.Equal(t, "This is a [[notes/Target Note|test]] document", string(result))
+}
+
+func TestCacheKeyReflectsSettings(t *testing.T) {
+	base := NewParser(ParserConfig{MinNGram: 1, MaxNGram: 1})
+	assert.Equal(t, base.CacheKey(), NewParser(ParserConfig{MinNGram: 1, MaxNGram: 1, Flavor: FlavorCommonMark}).CacheKey())
+
+	for _, config := range []ParserConfig{
+		{MinNGram: 2, MaxNGram: 3},
+		{MinNGram: 1, MaxNGram: 1, Flavor: FlavorGFM},
+		{MinNGram: 1, MaxNGram: 1, MaxTermsPerDoc: 10},
+		{MinNGram: 1, MaxNGram: 1, BudgetOverflow: BudgetUnigrams},
+		{MinNGram: 1, MaxNGram: 1, Stemming: true},
+		{MinNGram: 1, MaxNGram: 1, MinWordLength: 2},
+		{MinNGram: 1, MaxNGram: 1, AllowCodeishTokens: true},
+	} {
+		assert.NotEqual(t, base.CacheKey(), NewParser(config).CacheKey(), "%+v", config)
+	}
+}
+
 func TestGenerateNGrams(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -353,3 +465,473 @@ func TestGenerateNGrams(t *testing.T) {
 		})
 	}
 }
+
+func TestParseFrontmatter(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected Frontmatter
+		wantErr  bool
+	}{
+		{
+			name:     "yaml frontmatter",
+			content:  "---\ntitle: Monitoring\nslug: monitoring-guide\n---\nBody text",
+			expected: Frontmatter{Title: "Monitoring", Slug: "monitoring-guide"},
+		},
+		{
+			name:     "toml frontmatter",
+			content:  "+++\ntitle = \"Monitoring\"\nslug = \"monitoring-guide\"\n+++\nBody text",
+			expected: Frontmatter{Title: "Monitoring", Slug: "monitoring-guide"},
+		},
+		{
+			name:     "explicit url",
+			content:  "---\ntitle: About\nurl: /about-us/\n---\nBody text",
+			expected: Frontmatter{Title: "About", URL: "/about-us/"},
+		},
+		{
+			name:     "yaml keywords and tags",
+			content:  "---\ntitle: Rollouts\nkeywords: [canary, blue green]\ntags: kubernetes\n---\nBody text",
+			expected: Frontmatter{Title: "Rollouts", Keywords: []string{"canary", "blue green", "kubernetes"}, Tags: []string{"kubernetes"}},
+		},
+		{
+			name:     "toml tags",
+			content:  "+++\ntitle = \"Rollouts\"\ntags = [\"kubernetes\", 3]\n+++\nBody text",
+			expected: Frontmatter{Title: "Rollouts", Keywords: []string{"kubernetes"}, Tags: []string{"kubernetes"}},
+		},
+		{
+			name:     "categories",
+			content:  "---\ntitle: Rollouts\ntags: [Kubernetes, CI]\ncategories: Operations\n---\nBody text",
+			expected: Frontmatter{Title: "Rollouts", Keywords: []string{"Kubernetes", "CI"}, Tags: []string{"Kubernetes", "CI"}, Categories: []string{"Operations"}},
+		},
+		{
+			name:     "canonical",
+			content:  "---\ntitle: Alerts\ncanonical: true\n---\nBody text",
+			expected: Frontmatter{Title: "Alerts", Canonical: true},
+		},
+		{
+			name:     "canonical url",
+			content:  "---\ntitle: Alerts\ncanonical: https://example.com/alerts/\n---\nBody text",
+			expected: Frontmatter{Title: "Alerts"},
+		},
+		{
+			name:     "aliases",
+			content:  "---\ntitle: Alerts\naliases: [/old-alerts/, /monitoring/alerting/]\n---\nBody text",
+			expected: Frontmatter{Title: "Alerts", Aliases: []string{"/old-alerts/", "/monitoring/alerting/"}},
+		},
+		{
+			name:     "toml alias",
+			content:  "+++\ntitle = \"Alerts\"\naliases = \"/old-alerts/\"\n+++\nBody text",
+			expected: Frontmatter{Title: "Alerts", Aliases: []string{"/old-alerts/"}},
+		},
+		{
+			name:     "no frontmatter",
+			content:  "Just a body",
+			expected: Frontmatter{},
+		},
+		{
+			name:     "delimiter inside a value",
+			content:  "---\ntitle: \"Before --- after\"\n---\nBody text",
+			expected: Frontmatter{Title: "Before --- after"},
+		},
+		{
+			name:    "malformed yaml",
+			content: "---\ntitle: [unclosed\n---\nBody text",
+			wantErr: true,
+		},
+	}
+
+	parser := NewParser(ParserConfig{MinNGram: 1, MaxNGram: 1})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fm, err := parser.ParseFrontmatter([]byte(tt.content))
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, fm)
+		})
+	}
+}
+
+func TestParseContentFlavors(t *testing.T) {
+	content := "| Header one | Header two |\n| --- | --- |\n| alpha beta | gamma delta |\n\n" +
+		"Some prose note[^1] and ~~removed words~~.\n\n[^1]: Footnote detail.\n"
+
+	tests := []struct {
+		name        string
+		flavor      string
+		contains    []string
+		notContains []string
+	}{
+		{
+			name:        "commonmark treats gfm syntax as text",
+			flavor:      FlavorCommonMark,
+			contains:    []string{"alpha", "footnote", "---", "~~removed", "words~~"},
+			notContains: []string{"removed"},
+		},
+		{
+			name:        "gfm understands tables, strikethrough and footnotes",
+			flavor:      FlavorGFM,
+			contains:    []string{"alpha", "delta", "prose", "note", "footnote", "detail"},
+			notContains: []string{"---", "~~removed", "removed", "words"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parser := NewParser(ParserConfig{MinNGram: 1, MaxNGram: 1, Flavor: tt.flavor})
+			wordFreq, err := parser.ParseContent([]byte(content))
+			assert.NoError(t, err)
+			for _, word := range tt.contains {
+				assert.Contains(t, wordFreq, word)
+			}
+			for _, word := range tt.notContains {
+				assert.NotContains(t, wordFreq, word)
+			}
+		})
+	}
+}
+
+func TestTableCellsDoNotFormCrossCellNGrams(t *testing.T) {
+	content := "| alpha beta | gamma delta |\n| --- | --- |\n| one | two |\n"
+
+	commonmark := NewParser(ParserConfig{MinNGram: 2, MaxNGram: 2, Flavor: FlavorCommonMark})
+	wordFreq, err := commonmark.ParseContent([]byte(content))
+	assert.NoError(t, err)
+	assert.Contains(t, wordFreq, "beta gamma")
+
+	gfm := NewParser(ParserConfig{MinNGram: 2, MaxNGram: 2, Flavor: FlavorGFM})
+	wordFreq, err = gfm.ParseContent([]byte(content))
+	assert.NoError(t, err)
+	assert.NotContains(t, wordFreq, "beta gamma")
+}
+
+func TestNGramsAcrossTextNodes(t *testing.T) {
+	content := "We use *continuous deployment* daily.\n\nShip with continuous\ndeployment too.\n"
+	parser := NewParser(ParserConfig{MinNGram: 2, MaxNGram: 2})
+
+	occurrences, err := parser.FindWordOccurrences([]byte(content), 3)
+	assert.NoError(t, err)
+	var positions []int
+	for _, occ := range occurrences {
+		assert.NotEqual(t, "deployment daily", occ.Word, "phrases crossing emphasis markers can't be linked")
+		if occ.Word == "continuous deployment" {
+			positions = append(positions, occ.Position)
+		}
+	}
+	first := strings.Index(content, "continuous")
+	second := strings.LastIndex(content, "continuous")
+	assert.Equal(t, []int{first, second}, positions, "the phrase is found inside emphasis and across the line break")
+
+	// A link inside the emphasis leaves its markers intact
+	result, err := parser.InsertLink([]byte(content), "continuous deployment", "cd.md", first)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(result), "We use *[continuous deployment](cd.md)* daily."))
+
+	// ...and so does one across the line break
+	var occ WordOccurrence
+	for _, o := range occurrences {
+		if o.Position == second && o.Word == "continuous deployment" {
+			occ = o
+		}
+	}
+	assert.Equal(t, "continuous\ndeployment", occ.Surface)
+	result, err = parser.InsertLink([]byte(content), occ.Surface, "cd.md", occ.Position)
+	assert.NoError(t, err)
+	assert.Contains(t, string(result), "Ship with [continuous\ndeployment](cd.md) too.")
+
+	// Term frequencies do count phrases across emphasis
+	wordFreq, err := parser.ParseContent([]byte(content))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, wordFreq["continuous deployment"])
+	assert.Equal(t, 1, wordFreq["deployment daily"])
+	assert.NotContains(t, wordFreq, "daily ship", "phrases end with their paragraph")
+}
+
+func TestDebugPositionsAncestry(t *testing.T) {
+	content := "- first item\n  - nested prometheus alerting\n"
+
+	parser := NewParser(ParserConfig{MinNGram: 2, MaxNGram: 2, DebugPositions: true})
+	occurrences, err := parser.FindWordOccurrences([]byte(content), 3)
+	assert.NoError(t, err)
+	assert.Len(t, occurrences, 3)
+
+	last := occurrences[len(occurrences)-1]
+	assert.Equal(t, "prometheus alerting", last.Word)
+	assert.Equal(t, "Document > List > ListItem > List > ListItem > TextBlock > Text[17:43]", last.Ancestry)
+
+	parser = NewParser(ParserConfig{MinNGram: 2, MaxNGram: 2})
+	occurrences, err = parser.FindWordOccurrences([]byte(content), 3)
+	assert.NoError(t, err)
+	for _, occ := range occurrences {
+		assert.Empty(t, occ.Ancestry, "ancestry should only be captured when debugging")
+	}
+}
+
+func TestHeadingsNotLinked(t *testing.T) {
+	content := "## Getting Started\n\nGetting started takes a minute.\n"
+
+	parser := NewParser(ParserConfig{MinNGram: 2, MaxNGram: 2})
+	occurrences, err := parser.FindWordOccurrences([]byte(content), 3)
+	assert.NoError(t, err)
+	assert.Equal(t, 20, occurrences[0].Position, "the heading should not be reported")
+
+	// Heading words still count towards the document's frequencies
+	wordFreq, err := parser.ParseContent([]byte(content))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, wordFreq["getting started"])
+
+	parser = NewParser(ParserConfig{MinNGram: 2, MaxNGram: 2, LinkInHeadings: true})
+	occurrences, err = parser.FindWordOccurrences([]byte(content), 3)
+	assert.NoError(t, err)
+	assert.Equal(t, "getting started", occurrences[0].Word)
+	assert.Equal(t, 3, occurrences[0].Position)
+}
+
+func TestCodeAndHTMLNotLinked(t *testing.T) {
+	content := "Deploy kubernetes clusters.\n\n" +
+		"```go\nkubernetes clusters := deploy()\n```\n\n" +
+		"    kubernetes clusters indented\n\n" +
+		"Run `kubernetes clusters` again <!-- kubernetes clusters --> soon.\n\n" +
+		"<div>\nkubernetes clusters in html\n</div>\n"
+	parser := NewParser(ParserConfig{MinNGram: 2, MaxNGram: 2})
+
+	occurrences, err := parser.FindWordOccurrences([]byte(content), 3)
+	assert.NoError(t, err)
+	fence := strings.Index(content, "```go")
+	fenceEnd := strings.LastIndex(content, "```\n")
+	var positions []int
+	for _, occ := range occurrences {
+		assert.False(t, occ.Position > fence && occ.Position < fenceEnd, "occurrence %q inside the fenced block", occ.Word)
+		if occ.Word == "kubernetes clusters" {
+			positions = append(positions, occ.Position)
+		}
+	}
+	assert.Equal(t, []int{strings.Index(content, "kubernetes")}, positions, "only the prose occurrence is reported")
+
+	// Frequencies leave code out the same way
+	_, sections, err := parser.ParseSections([]byte(content))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, sections[0].WordFreq["kubernetes clusters"])
+}
+
+func TestSkipBlockquotes(t *testing.T) {
+	content := "> Kubernetes clusters are hard, they said.\n\nKubernetes clusters are fine.\n"
+
+	parser := NewParser(ParserConfig{MinNGram: 2, MaxNGram: 2})
+	occurrences, err := parser.FindWordOccurrences([]byte(content), 3)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, occurrences[0].Position, "quotes are linked by default")
+
+	parser = NewParser(ParserConfig{MinNGram: 2, MaxNGram: 2, SkipBlockquotes: true})
+	occurrences, err = parser.FindWordOccurrences([]byte(content), 3)
+	assert.NoError(t, err)
+	assert.Equal(t, strings.Index(content, "\nKubernetes")+1, occurrences[0].Position)
+
+	// Quoted words still count towards the document's frequencies
+	wordFreq, _, err := parser.ParseSections([]byte(content))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, wordFreq["kubernetes clusters"])
+}
+
+func TestOptedOut(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected bool
+	}{
+		{name: "disabled", content: "---\ninternal_link: false\n---\nBody", expected: true},
+		{name: "ignored", content: "---\ninternal_link_ignore: true\n---\nBody", expected: true},
+		{name: "toml", content: "+++\ninternal_link = false\n+++\nBody", expected: true},
+		{name: "enabled", content: "---\ninternal_link: true\n---\nBody"},
+		{name: "not a boolean", content: "---\ninternal_link: \"no\"\n---\nBody"},
+		{name: "other keys", content: "---\ntitle: Legal\n---\nBody"},
+		{name: "no frontmatter", content: "Body"},
+	}
+
+	parser := NewParser(ParserConfig{MinNGram: 1, MaxNGram: 1})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			optedOut, err := parser.OptedOut([]byte(tt.content), "internal_link")
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, optedOut)
+		})
+	}
+
+	optedOut, err := parser.OptedOut([]byte("---\nautolink: false\n---\nBody"), "autolink")
+	require.NoError(t, err)
+	assert.True(t, optedOut)
+}
+
+func TestFrontmatterPositions(t *testing.T) {
+	body := "We configure prometheus alerting here.\n"
+	tests := []struct {
+		name        string
+		frontmatter string
+	}{
+		{name: "none"},
+		{name: "short yaml", frontmatter: "---\ntitle: A\n---\n"},
+		{name: "long yaml", frontmatter: "---\ntitle: Monitoring\ndescription: " + strings.Repeat("prometheus alerting ", 20) + "\n---\n"},
+		{name: "toml", frontmatter: "+++\ntitle = \"prometheus alerting\"\n+++\n"},
+		{name: "delimiter inside a string", frontmatter: "---\ntitle: \"prometheus alerting --- setup\"\n---\n"},
+		{name: "delimiter inside a block scalar", frontmatter: "---\nsummary: |\n  prometheus alerting\n  ---\n---\n"},
+		{name: "crlf line endings", frontmatter: "---\r\ntitle: prometheus alerting\r\n---\r\n"},
+	}
+
+	parser := NewParser(ParserConfig{MinNGram: 2, MaxNGram: 2})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := []byte(tt.frontmatter + body)
+			assert.Equal(t, len(tt.frontmatter), parser.FrontmatterEnd(content))
+
+			occurrences, err := parser.FindWordOccurrences(content, 3)
+			require.NoError(t, err)
+			require.NotEmpty(t, occurrences)
+			for _, occ := range occurrences {
+				assert.GreaterOrEqual(t, occ.Position, len(tt.frontmatter), "%s lands in the frontmatter", occ.Word)
+			}
+
+			expected := len(tt.frontmatter) + strings.Index(body, "prometheus alerting")
+			var found bool
+			for _, occ := range occurrences {
+				if occ.Word == "prometheus alerting" {
+					found = true
+					assert.Equal(t, expected, occ.Position)
+				}
+			}
+			assert.True(t, found)
+		})
+	}
+}
+
+func TestOccurrenceLines(t *testing.T) {
+	content := []byte("---\ntitle: Alerts\n---\nPrometheus alerting first.\n\nÜber straße, prometheus alerting again.\n")
+	parser := NewParser(ParserConfig{MinNGram: 2, MaxNGram: 2})
+
+	occurrences, err := parser.FindWordOccurrences(content, 3)
+	require.NoError(t, err)
+
+	var locations [][2]int
+	for _, occ := range occurrences {
+		if occ.Word == "prometheus alerting" {
+			locations = append(locations, [2]int{occ.Line, occ.Column})
+		}
+	}
+	// The first starts right after the newline closing the frontmatter; the
+	// second follows multi-byte characters on its line
+	assert.Equal(t, [][2]int{{4, 1}, {6, 14}}, locations)
+}
+
+func TestFrontmatterEnd(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected int
+	}{
+		{name: "yaml", content: "---\ntitle: A\n---\nBody", expected: 17},
+		{name: "toml", content: "+++\ntitle = \"A\"\n+++\nBody", expected: 20},
+		{name: "comment after opener", content: "--- # meta\ntitle: A\n---\nBody", expected: 24},
+		{name: "trailing spaces on closer", content: "---\ntitle: A\n---  \nBody", expected: 19},
+		{name: "closer at end of file", content: "---\ntitle: A\n---", expected: 16},
+		{name: "empty frontmatter", content: "---\n---\nBody", expected: 8},
+		{name: "thematic break without closer", content: "---\n\nSome prose after a rule.\n", expected: 0},
+		{name: "closer only inside a code block", content: "---\n\nProse.\n\n```yaml\n---\n```\n", expected: 0},
+		{name: "delimiter inside a line", content: "---\ntitle: a --- b\n", expected: 0},
+		{name: "mismatched closer", content: "---\ntitle: A\n+++\nBody", expected: 0},
+		{name: "heading underline", content: "Title\n---\nBody\n---\n", expected: 0},
+		{name: "longer rule", content: "----\ntitle: A\n----\nBody", expected: 0},
+		{name: "text after opener", content: "---title\n---\nBody", expected: 0},
+		{name: "no frontmatter", content: "Body", expected: 0},
+		{name: "empty", content: "", expected: 0},
+	}
+
+	parser := NewParser(ParserConfig{MinNGram: 1, MaxNGram: 1})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, parser.FrontmatterEnd([]byte(tt.content)))
+		})
+	}
+
+	// A document opening with a thematic break keeps all its prose
+	occurrences, err := parser.FindWordOccurrences([]byte("---\n\nprometheus alerting\n"), 3)
+	require.NoError(t, err)
+	require.NotEmpty(t, occurrences)
+	assert.Equal(t, "prometheus", occurrences[0].Word)
+	assert.Equal(t, 5, occurrences[0].Position)
+}
+
+func TestImagesAndURLsNotCounted(t *testing.T) {
+	content := "Compare ![final dashboard screenshot](img/screenshot-2023-final.png) first.\n\n" +
+		"Read <https://example.com/grafana-dashboards> or visit https://example.org/prometheus-alerting today.\n\n" +
+		"Grafana dashboards help.\n"
+
+	for _, flavor := range []string{FlavorCommonMark, FlavorGFM} {
+		for _, n := range []int{1, 2} {
+			parser := NewParser(ParserConfig{MinNGram: n, MaxNGram: n, Flavor: flavor})
+			wordFreq, err := parser.ParseContent([]byte(content))
+			assert.NoError(t, err)
+			for term := range wordFreq {
+				for _, part := range []string{"screenshot", "final", "example", "prometheus", "http"} {
+					assert.NotContains(t, term, part, "%s: term %q comes from an image or URL", flavor, term)
+				}
+			}
+
+			occurrences, err := parser.FindWordOccurrences([]byte(content), 3)
+			assert.NoError(t, err)
+			for _, occ := range occurrences {
+				assert.NotContains(t, occ.Word, "example", flavor)
+			}
+
+			if n == 2 {
+				assert.Equal(t, 1, wordFreq["grafana dashboards"], flavor)
+				assert.NotContains(t, wordFreq, "visit today", "%s: phrases don't run across a URL", flavor)
+			}
+		}
+	}
+}
+
+func TestIsURL(t *testing.T) {
+	assert.True(t, isURL("https://example.com/a.png"))
+	assert.True(t, isURL("(git+ssh://host/repo)"))
+	assert.False(t, isURL("://nothing"))
+	assert.False(t, isURL("1ab://c"))
+	assert.False(t, isURL("example.com"))
+}
+
+// BenchmarkParseContent parses a large generated document with the n-gram
+// ranges in common use
+func BenchmarkParseContent(b *testing.B) {
+	content := benchmarkDocument(2000)
+	for _, ngrams := range [][2]int{{1, 1}, {2, 2}, {2, 3}, {3, 4}} {
+		b.Run(fmt.Sprintf("ngram-%d-%d", ngrams[0], ngrams[1]), func(b *testing.B) {
+			parser := NewParser(ParserConfig{MinNGram: ngrams[0], MaxNGram: ngrams[1]})
+			b.SetBytes(int64(len(content)))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := parser.ParseContent(content); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestTermsMatchParseContent(t *testing.T) {
+	text := "The test of document scoring ran against src/pkg/foo and https://example.com before the Kubernetes deployments of 2024"
+
+	for _, config := range []ParserConfig{
+		{MinNGram: 1, MaxNGram: 1},
+		{MinNGram: 2, MaxNGram: 3},
+		{MinNGram: 2, MaxNGram: 3, Stemming: true},
+	} {
+		p := NewParser(config)
+		freq, err := p.ParseContent([]byte(text + "\n"))
+		require.NoError(t, err)
+		assert.Equal(t, freq, p.Terms(text), "%+v", config)
+	}
+
+	bigrams := NewParser(ParserConfig{MinNGram: 2, MaxNGram: 2}).Terms("test of document")
+	assert.Equal(t, map[string]int{"test document": 1}, bigrams)
+}
@@ -0,0 +1,218 @@
+package markdown
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// referenceLabelPrefix starts the labels of reference definitions added by
+// the tool, keeping them apart from hand-written ones
+const referenceLabelPrefix = "auto-"
+
+// definitionPattern matches a link reference definition line, [label]: destination
+var definitionPattern = regexp.MustCompile(`(?m)^ {0,3}\[([^\[\]\n]+)\]:[ \t]*(\S+)[^\n]*(?:\n|$)`)
+
+// ReferenceDefinition is a "[label]: destination" line of a document
+type ReferenceDefinition struct {
+	Label       string
+	Destination string
+	Start       int // Byte offset of the line
+	End         int // Byte offset just past the line and its newline
+}
+
+// FindReferenceDefinitions returns the link reference definitions of the
+// document in order. Lines that only look like definitions, e.g. in code
+// blocks, are told apart by the references goldmark registers.
+func (p *Parser) FindReferenceDefinitions(content []byte) []ReferenceDefinition {
+	body, frontmatterOffset := p.skipFrontmatter(content)
+	pc := parser.NewContext()
+	p.md.Parser().Parse(text.NewReader(body), parser.WithContext(pc))
+	known := make(map[string]bool)
+	for _, ref := range pc.References() {
+		known[util.ToLinkReference(ref.Label())] = true
+	}
+
+	var defs []ReferenceDefinition
+	for _, m := range definitionPattern.FindAllSubmatchIndex(content, -1) {
+		label := string(content[m[2]:m[3]])
+		if m[0] < frontmatterOffset || !known[util.ToLinkReference([]byte(label))] {
+			continue
+		}
+		defs = append(defs, ReferenceDefinition{
+			Label:       label,
+			Destination: string(content[m[4]:m[5]]),
+			Start:       m[0],
+			End:         m[1],
+		})
+	}
+	return defs
+}
+
+// References hands out the labels of reference-style links inserted into
+// one document, reusing the label of a destination that is already defined
+type References struct {
+	defs   []ReferenceDefinition
+	labels map[string]string // Label by destination
+	taken  map[string]bool   // Labels in use, normalized as they match case-insensitively
+	added  []ReferenceDefinition
+}
+
+// NewReferences collects the reference definitions content already has
+func (p *Parser) NewReferences(content []byte) *References {
+	r := &References{
+		defs:   p.FindReferenceDefinitions(content),
+		labels: make(map[string]string),
+		taken:  make(map[string]bool),
+	}
+	for _, def := range r.defs {
+		if _, ok := r.labels[def.Destination]; !ok {
+			r.labels[def.Destination] = def.Label
+		}
+		r.taken[util.ToLinkReference([]byte(def.Label))] = true
+	}
+	return r
+}
+
+// Link returns the markup linking phrase to destination, [phrase][label].
+// A destination without a definition gets a new auto- label, derived from
+// its file name and anchor, and definition is the line it needs once
+// Insertions are applied; otherwise definition is empty.
+func (r *References) Link(phrase, destination string) (markup, definition string) {
+	label, ok := r.labels[destination]
+	if !ok {
+		label = r.add(destination)
+		definition = formatDefinition(label, destination)
+	}
+	return formatReference(phrase, label), definition
+}
+
+// add gives destination a label no other definition of the document uses
+func (r *References) add(destination string) string {
+
+	base := referenceLabelPrefix + referenceSlug(destination)
+	label := base
+	for n := 1; r.taken[util.ToLinkReference([]byte(label))]; n++ {
+		label = fmt.Sprintf("%s-%d", base, n)
+	}
+	r.labels[destination] = label
+	r.taken[util.ToLinkReference([]byte(label))] = true
+	r.added = append(r.added, ReferenceDefinition{Label: label, Destination: destination})
+	return label
+}
+
+// referenceSlug names a destination by its file name without extension,
+// followed by its anchor if it has one
+func referenceSlug(destination string) string {
+	target, anchor, _ := strings.Cut(destination, "#")
+	name := path.Base(strings.TrimSuffix(target, "/"))
+	name = strings.TrimSuffix(name, path.Ext(name))
+	if name == "." || name == "/" || name == ".." {
+		name = ""
+	}
+
+	slug := strings.Trim(Slugify(name+" "+anchor), "-")
+	if slug == "" {
+		return "link"
+	}
+	return slug
+}
+
+// formatReference writes a reference-style link
+func formatReference(phrase, label string) string {
+	return fmt.Sprintf("[%s][%s]", phrase, label)
+}
+
+// formatDefinition writes a reference definition line, without its newline
+func formatDefinition(label, destination string) string {
+	return fmt.Sprintf("[%s]: %s", label, destination)
+}
+
+// DefinitionInsertion is text holding new reference definitions, to be
+// inserted at Offset
+type DefinitionInsertion struct {
+	Offset int
+	Text   string
+}
+
+// Insertions places the definitions added through Label. When the document
+// ends with a block of definitions they are merged into it, each before
+// the first existing label sorting after it; otherwise they are appended in
+// a block of their own. Insertions are ordered by descending offset.
+func (r *References) Insertions(content []byte) []DefinitionInsertion {
+	if len(r.added) == 0 {
+		return nil
+	}
+	added := append([]ReferenceDefinition(nil), r.added...)
+	sort.Slice(added, func(i, j int) bool {
+		return strings.ToLower(added[i].Label) < strings.ToLower(added[j].Label)
+	})
+
+	block := r.trailingBlock(content)
+	if len(block) == 0 {
+		var b strings.Builder
+		if len(content) > 0 && !strings.HasSuffix(string(content), "\n") {
+			b.WriteString("\n")
+		}
+		if len(content) > 0 {
+			b.WriteString("\n")
+		}
+		insertion := DefinitionInsertion{Offset: len(content)}
+		for _, def := range added {
+			b.WriteString(formatDefinition(def.Label, def.Destination) + "\n")
+		}
+		insertion.Text = b.String()
+		return []DefinitionInsertion{insertion}
+	}
+
+	// Group the new definitions by the existing line they go before
+	last := block[len(block)-1]
+	byOffset := make(map[int]*DefinitionInsertion)
+	var offsets []int
+	for _, def := range added {
+		offset := last.End
+		for _, existing := range block {
+			if strings.ToLower(existing.Label) > strings.ToLower(def.Label) {
+				offset = existing.Start
+				break
+			}
+		}
+
+		insertion, ok := byOffset[offset]
+		if !ok {
+			insertion = &DefinitionInsertion{Offset: offset}
+			if offset == len(content) && !strings.HasSuffix(string(content), "\n") {
+				insertion.Text = "\n"
+			}
+			byOffset[offset] = insertion
+			offsets = append(offsets, offset)
+		}
+		insertion.Text += formatDefinition(def.Label, def.Destination) + "\n"
+	}
+
+	sort.Sort(sort.Reverse(sort.IntSlice(offsets)))
+	insertions := make([]DefinitionInsertion, 0, len(offsets))
+	for _, offset := range offsets {
+		insertions = append(insertions, *byOffset[offset])
+	}
+	return insertions
+}
+
+// trailingBlock returns the definitions the document ends with, on
+// consecutive lines with nothing but blank space after them
+func (r *References) trailingBlock(content []byte) []ReferenceDefinition {
+	if len(r.defs) == 0 || strings.TrimSpace(string(content[r.defs[len(r.defs)-1].End:])) != "" {
+		return nil
+	}
+	i := len(r.defs) - 1
+	for i > 0 && r.defs[i-1].End == r.defs[i].Start {
+		i--
+	}
+	return r.defs[i:]
+}
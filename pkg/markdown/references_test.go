@@ -0,0 +1,129 @@
+package markdown
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindReferenceDefinitions(t *testing.T) {
+	content := "---\nx: y\n---\nSee [docs].\n\n```\n[code]: not-a-definition.md\n```\n\n[docs]: https://example.com/docs \"Docs\"\n  [Setup Guide]: setup.md\n"
+	parser := NewParser(ParserConfig{})
+
+	defs := parser.FindReferenceDefinitions([]byte(content))
+	assert.Equal(t, []ReferenceDefinition{
+		{Label: "docs", Destination: "https://example.com/docs", Start: strings.Index(content, "[docs]:"), End: strings.Index(content, "  [Setup")},
+		{Label: "Setup Guide", Destination: "setup.md", Start: strings.Index(content, "  [Setup"), End: len(content)},
+	}, defs)
+	for _, def := range defs {
+		assert.Contains(t, content[def.Start:def.End], "["+def.Label+"]:")
+	}
+}
+
+func TestReferencesLink(t *testing.T) {
+	parser := NewParser(ParserConfig{})
+	refs := parser.NewReferences([]byte("Text.\n\n[guide]: ../guides/setup.md\n[auto-alerts]: other/alerts.md\n"))
+
+	// An existing definition is reused, whatever its label
+	markup, definition := refs.Link("the setup", "../guides/setup.md")
+	assert.Equal(t, "[the setup][guide]", markup)
+	assert.Empty(t, definition)
+
+	markup, definition = refs.Link("Install it", "../guides/setup.md#install")
+	assert.Equal(t, "[Install it][auto-setup-install]", markup)
+	assert.Equal(t, "[auto-setup-install]: ../guides/setup.md#install", definition)
+
+	// Labels taken by other destinations are suffixed
+	markup, definition = refs.Link("alerting", "alerts.md")
+	assert.Equal(t, "[alerting][auto-alerts-1]", markup)
+	assert.Equal(t, "[auto-alerts-1]: alerts.md", definition)
+
+	// Linking the same destination again reuses the new label
+	markup, definition = refs.Link("alerts", "alerts.md")
+	assert.Equal(t, "[alerts][auto-alerts-1]", markup)
+	assert.Empty(t, definition)
+
+	markup, _ = refs.Link("the blog", "/blog/")
+	assert.Equal(t, "[the blog][auto-blog]", markup)
+}
+
+func TestReferenceInsertions(t *testing.T) {
+	parser := NewParser(ParserConfig{})
+	apply := func(content string, destinations ...string) string {
+		refs := parser.NewReferences([]byte(content))
+		for _, destination := range destinations {
+			refs.Link("phrase", destination)
+		}
+		for _, insertion := range refs.Insertions([]byte(content)) {
+			content = content[:insertion.Offset] + insertion.Text + content[insertion.Offset:]
+		}
+		return content
+	}
+
+	tests := []struct {
+		name         string
+		content      string
+		destinations []string
+		expected     string
+	}{
+		{
+			name:         "new block",
+			content:      "Text.\n",
+			destinations: []string{"b.md", "a.md"},
+			expected:     "Text.\n\n[auto-a]: a.md\n[auto-b]: b.md\n",
+		},
+		{
+			name:         "no trailing newline",
+			content:      "Text.",
+			destinations: []string{"a.md"},
+			expected:     "Text.\n\n[auto-a]: a.md\n",
+		},
+		{
+			name:         "merged sorted into the final block",
+			content:      "Text [x][bravo].\n\n[bravo]: x.md\n[delta]: y.md\n",
+			destinations: []string{"charlie.md", "echo.md", "alpha.md"},
+			expected:     "Text [x][bravo].\n\n[auto-alpha]: alpha.md\n[auto-charlie]: charlie.md\n[auto-echo]: echo.md\n[bravo]: x.md\n[delta]: y.md\n",
+		},
+		{
+			name:         "after the last label",
+			content:      "Text [x][a].\n\n[a]: x.md",
+			destinations: []string{"zulu.md"},
+			expected:     "Text [x][a].\n\n[a]: x.md\n[auto-zulu]: zulu.md\n",
+		},
+		{
+			name:         "definitions not at the end",
+			content:      "[a]: x.md\n\nText [x][a].\n",
+			destinations: []string{"b.md"},
+			expected:     "[a]: x.md\n\nText [x][a].\n\n[auto-b]: b.md\n",
+		},
+		{
+			name:         "only reused labels",
+			content:      "Text [x][a].\n\n[a]: x.md\n",
+			destinations: []string{"x.md"},
+			expected:     "Text [x][a].\n\n[a]: x.md\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, apply(tt.content, tt.destinations...))
+		})
+	}
+}
+
+func TestInsertReferenceLink(t *testing.T) {
+	parser := NewParser(ParserConfig{LinkFormat: LinkFormatReference})
+
+	content := []byte("We use prometheus alerting daily.\n\n[other]: other.md\n")
+	result, err := parser.InsertLink(content, "prometheus alerting", "../ops/alerts.md", 7)
+	assert.NoError(t, err)
+	assert.Equal(t, "We use [prometheus alerting][auto-alerts] daily.\n\n[auto-alerts]: ../ops/alerts.md\n[other]: other.md\n", string(result))
+
+	result, err = parser.InsertLink(result, "daily", "../ops/alerts.md", bytes.Index(result, []byte("daily")))
+	assert.NoError(t, err)
+	assert.Equal(t, "We use [prometheus alerting][auto-alerts] [daily][auto-alerts].\n\n[auto-alerts]: ../ops/alerts.md\n[other]: other.md\n", string(result))
+
+	assert.Equal(t, "[phrase][auto-alerts]", parser.FormatLink("phrase", "alerts.md"))
+}
@@ -0,0 +1,108 @@
+package markdown
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/yuin/goldmark/ast"
+)
+
+// Section holds the term frequencies of the text under one heading
+type Section struct {
+	Anchor   string         `json:"anchor"` // Empty for the text before the first heading
+	WordFreq map[string]int `json:"word_freq"`
+}
+
+// Heading is a heading of a document, with its level from 1 to 6
+type Heading struct {
+	Text  string
+	Level int
+}
+
+// String returns the heading as written in ATX style, e.g. "## Setup"
+func (h Heading) String() string {
+	return strings.Repeat("#", h.Level) + " " + h.Text
+}
+
+// Slugify turns heading text into an anchor the way GitHub does: lowercase,
+// punctuation stripped and spaces replaced by hyphens
+func Slugify(heading string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(strings.TrimSpace(heading)) {
+		switch {
+		case r == ' ':
+			b.WriteRune('-')
+		case r == '-' || r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// anchorSet hands out unique anchors within a document, suffixing repeated
+// headings with -1, -2 and so on
+type anchorSet map[string]int
+
+func (s anchorSet) add(heading string) string {
+	anchor := Slugify(heading)
+	count, seen := s[anchor]
+	s[anchor] = count + 1
+	if !seen {
+		return anchor
+	}
+	return fmt.Sprintf("%s-%d", anchor, count)
+}
+
+// headingText returns the plain text of a heading node
+func headingText(n ast.Node, content []byte) string {
+	var b strings.Builder
+	ast.Walk(n, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch t := n.(type) {
+		case *ast.Text:
+			b.Write(t.Segment.Value(content))
+			if t.SoftLineBreak() || t.HardLineBreak() {
+				b.WriteByte(' ')
+			}
+		case *ast.String:
+			b.Write(t.Value)
+		}
+		return ast.WalkContinue, nil
+	})
+	return b.String()
+}
+
+// ParseSections is like ParseContent, but also returns the frequencies of
+// each section of the document in document order
+func (p *Parser) ParseSections(content []byte) (map[string]int, []Section, error) {
+	// Collect all word/n-gram occurrences, headings included
+	occurrences, err := p.findOccurrences(content, 1, false) // minWordLen=1 since we'll filter later
+	var budgetErr *BudgetError
+	if err != nil && !errors.As(err, &budgetErr) {
+		return nil, nil, err
+	}
+
+	wordFreq := make(map[string]int)
+	var sections []Section
+	index := make(map[string]int)
+	for _, occ := range occurrences {
+		wordFreq[occ.Word]++
+
+		i, ok := index[occ.Section]
+		if !ok {
+			i = len(sections)
+			index[occ.Section] = i
+			sections = append(sections, Section{Anchor: occ.Section, WordFreq: make(map[string]int)})
+		}
+		sections[i].WordFreq[occ.Word]++
+	}
+
+	if budgetErr != nil {
+		return wordFreq, sections, budgetErr
+	}
+	return wordFreq, sections, nil
+}
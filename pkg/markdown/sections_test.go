@@ -0,0 +1,84 @@
+package markdown
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSlugify(t *testing.T) {
+	tests := []struct {
+		heading  string
+		expected string
+	}{
+		{heading: "Configuration Options", expected: "configuration-options"},
+		{heading: "What's new?", expected: "whats-new"},
+		{heading: "Step 1: Install `helm`", expected: "step-1-install-helm"},
+		{heading: "snake_case and kebab-case", expected: "snake_case-and-kebab-case"},
+		{heading: "Überblick", expected: "überblick"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.heading, func(t *testing.T) {
+			assert.Equal(t, tt.expected, Slugify(tt.heading))
+		})
+	}
+}
+
+func TestParseSections(t *testing.T) {
+	content := "Intro about grafana.\n\n" +
+		"## Configuration Options\n\nPrometheus alerting rules.\n\n" +
+		"## Examples\n\nGrafana dashboards.\n\n" +
+		"## Examples\n\nMore prometheus alerting.\n"
+
+	parser := NewParser(ParserConfig{MinNGram: 2, MaxNGram: 2})
+	wordFreq, sections, err := parser.ParseSections([]byte(content))
+	require.NoError(t, err)
+	assert.Equal(t, 2, wordFreq["prometheus alerting"])
+
+	var anchors []string
+	for _, section := range sections {
+		anchors = append(anchors, section.Anchor)
+	}
+	assert.Equal(t, []string{"", "configuration-options", "examples", "examples-1"}, anchors)
+	assert.Equal(t, 1, sections[1].WordFreq["prometheus alerting"])
+	assert.Equal(t, 1, sections[1].WordFreq["configuration options"], "heading words belong to their own section")
+	assert.Equal(t, 1, sections[2].WordFreq["grafana dashboards"])
+	assert.Equal(t, 1, sections[3].WordFreq["prometheus alerting"])
+
+	occurrences, err := parser.FindWordOccurrences([]byte(content), 3)
+	require.NoError(t, err)
+	assert.Equal(t, "", occurrences[0].Section)
+	assert.Equal(t, "examples-1", occurrences[len(occurrences)-1].Section)
+}
+
+func TestOccurrenceHeading(t *testing.T) {
+	content := "Grafana dashboards intro.\n\n" +
+		"## Trouble Shooting\n\nPrometheus alerting rules.\n\n" +
+		"### Deeper *Level*\n\nMore grafana dashboards.\n\n" +
+		"Setext Heading\n==============\n\nLast prometheus alerting.\n"
+
+	parser := NewParser(ParserConfig{MinNGram: 2, MaxNGram: 2})
+	occurrences, err := parser.FindOccurrences([]byte(content), 3)
+	require.NoError(t, err)
+
+	var headings []string
+	for _, occ := range occurrences {
+		heading := ""
+		if occ.Heading != nil {
+			heading = occ.Heading.String()
+		}
+		headings = append(headings, occ.Word+" under "+heading)
+	}
+	assert.Equal(t, []string{
+		"grafana dashboards under ",
+		"dashboards intro under ",
+		"prometheus alerting under ## Trouble Shooting",
+		"alerting rules under ## Trouble Shooting",
+		"grafana dashboards under ### Deeper Level",
+		"last prometheus under # Setext Heading",
+		"prometheus alerting under # Setext Heading",
+	}, headings)
+	assert.Same(t, occurrences[2].Heading, occurrences[3].Heading, "occurrences share their heading")
+}
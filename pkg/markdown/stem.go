@@ -0,0 +1,240 @@
+package markdown
+
+// Stem reduces an English word to its stem with the Porter algorithm, so
+// that "deploy", "deploys" and "deployment" all become "deploy". Words that
+// aren't plain lowercase ASCII are returned unchanged.
+func Stem(word string) string {
+	if len(word) <= 2 {
+		return word
+	}
+	for i := 0; i < len(word); i++ {
+		if word[i] < 'a' || word[i] > 'z' {
+			return word
+		}
+	}
+
+	s := &stemmer{b: []byte(word), k: len(word) - 1}
+	s.step1ab()
+	if s.k > 0 {
+		s.step1c()
+		s.step2()
+		s.step3()
+		s.step4()
+		s.step5()
+	}
+	return string(s.b[:s.k+1])
+}
+
+// stemmer holds the word being stemmed; b[0..k] is the current stem and j
+// marks the end of the stem before a suffix found by ends
+type stemmer struct {
+	b    []byte
+	k, j int
+}
+
+// cons reports whether b[i] is a consonant
+func (s *stemmer) cons(i int) bool {
+	switch s.b[i] {
+	case 'a', 'e', 'i', 'o', 'u':
+		return false
+	case 'y':
+		return i == 0 || !s.cons(i-1)
+	}
+	return true
+}
+
+// m measures the number of vowel-consonant sequences in b[0..j]
+func (s *stemmer) m() int {
+	n, i := 0, 0
+	for ; ; i++ {
+		if i > s.j {
+			return n
+		}
+		if !s.cons(i) {
+			break
+		}
+	}
+	for i++; ; i++ {
+		for ; ; i++ {
+			if i > s.j {
+				return n
+			}
+			if s.cons(i) {
+				break
+			}
+		}
+		n++
+		for i++; ; i++ {
+			if i > s.j {
+				return n
+			}
+			if !s.cons(i) {
+				break
+			}
+		}
+	}
+}
+
+// vowelInStem reports whether b[0..j] contains a vowel
+func (s *stemmer) vowelInStem() bool {
+	for i := 0; i <= s.j; i++ {
+		if !s.cons(i) {
+			return true
+		}
+	}
+	return false
+}
+
+// doublec reports whether b[i-1..i] is a double consonant
+func (s *stemmer) doublec(i int) bool {
+	return i >= 1 && s.b[i] == s.b[i-1] && s.cons(i)
+}
+
+// cvc reports whether b[i-2..i] is consonant-vowel-consonant with the last
+// consonant not w, x or y, as in "hop" but not "snow"
+func (s *stemmer) cvc(i int) bool {
+	if i < 2 || !s.cons(i) || s.cons(i-1) || !s.cons(i-2) {
+		return false
+	}
+	switch s.b[i] {
+	case 'w', 'x', 'y':
+		return false
+	}
+	return true
+}
+
+// ends reports whether b[0..k] ends with suffix, setting j before it
+func (s *stemmer) ends(suffix string) bool {
+	n := len(suffix)
+	if n > s.k+1 || string(s.b[s.k-n+1:s.k+1]) != suffix {
+		return false
+	}
+	s.j = s.k - n
+	return true
+}
+
+// setTo replaces b[j+1..k] with replacement
+func (s *stemmer) setTo(replacement string) {
+	s.b = append(s.b[:s.j+1], replacement...)
+	s.k = s.j + len(replacement)
+}
+
+// replace replaces the suffix found by ends if the remaining stem has a measure above zero
+func (s *stemmer) replace(replacement string) {
+	if s.m() > 0 {
+		s.setTo(replacement)
+	}
+}
+
+// step1ab removes plurals and -ed or -ing
+func (s *stemmer) step1ab() {
+	if s.b[s.k] == 's' {
+		switch {
+		case s.ends("sses"):
+			s.k -= 2
+		case s.ends("ies"):
+			s.setTo("i")
+		case s.b[s.k-1] != 's':
+			s.k--
+		}
+	}
+
+	if s.ends("eed") {
+		if s.m() > 0 {
+			s.k--
+		}
+	} else if (s.ends("ed") || s.ends("ing")) && s.vowelInStem() {
+		s.k = s.j
+		switch {
+		case s.ends("at"):
+			s.setTo("ate")
+		case s.ends("bl"):
+			s.setTo("ble")
+		case s.ends("iz"):
+			s.setTo("ize")
+		case s.doublec(s.k):
+			s.k--
+			switch s.b[s.k] {
+			case 'l', 's', 'z':
+				s.k++
+			}
+		default:
+			s.j = s.k
+			if s.m() == 1 && s.cvc(s.k) {
+				s.setTo("e")
+			}
+		}
+	}
+}
+
+// step1c turns a terminal y after a consonant into i. Following Snowball
+// rather than the original algorithm, a y after a vowel is kept, so
+// "deploy" and "deployment" share a stem.
+func (s *stemmer) step1c() {
+	if s.ends("y") && s.j > 0 && s.cons(s.j) {
+		s.b[s.k] = 'i'
+	}
+}
+
+// replaceFirst applies the first of the suffix rules that matches, checking
+// longer suffixes before the shorter ones they end with
+func (s *stemmer) replaceFirst(rules [][2]string) {
+	for _, rule := range rules {
+		if s.ends(rule[0]) {
+			s.replace(rule[1])
+			return
+		}
+	}
+}
+
+// step2 maps double suffixes to single ones, e.g. -ization to -ize
+func (s *stemmer) step2() {
+	s.replaceFirst([][2]string{
+		{"ational", "ate"}, {"tional", "tion"}, {"enci", "ence"}, {"anci", "ance"},
+		{"izer", "ize"}, {"bli", "ble"}, {"alli", "al"}, {"entli", "ent"},
+		{"eli", "e"}, {"ousli", "ous"}, {"ization", "ize"}, {"ation", "ate"},
+		{"ator", "ate"}, {"alism", "al"}, {"iveness", "ive"}, {"fulness", "ful"},
+		{"ousness", "ous"}, {"aliti", "al"}, {"iviti", "ive"}, {"biliti", "ble"},
+		{"logi", "log"},
+	})
+}
+
+// step3 handles -ic-, -full, -ness and the like
+func (s *stemmer) step3() {
+	s.replaceFirst([][2]string{
+		{"icate", "ic"}, {"ative", ""}, {"alize", "al"}, {"iciti", "ic"},
+		{"ical", "ic"}, {"ful", ""}, {"ness", ""},
+	})
+}
+
+// step4 removes -ant, -ence and other suffixes from long enough stems
+func (s *stemmer) step4() {
+	for _, suffix := range []string{
+		"al", "ance", "ence", "er", "ic", "able", "ible", "ant", "ement", "ment",
+		"ent", "ion", "ou", "ism", "ate", "iti", "ous", "ive", "ize",
+	} {
+		if !s.ends(suffix) {
+			continue
+		}
+		if suffix == "ion" && (s.j < 0 || s.b[s.j] != 's' && s.b[s.j] != 't') {
+			return
+		}
+		if s.m() > 1 {
+			s.k = s.j
+		}
+		return
+	}
+}
+
+// step5 removes a final -e and reduces -ll to -l on long enough stems
+func (s *stemmer) step5() {
+	s.j = s.k
+	if s.b[s.k] == 'e' {
+		if a := s.m(); a > 1 || a == 1 && !s.cvc(s.k-1) {
+			s.k--
+		}
+	}
+	if s.b[s.k] == 'l' && s.doublec(s.k) && s.m() > 1 {
+		s.k--
+	}
+}
@@ -0,0 +1,77 @@
+package markdown
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStem(t *testing.T) {
+	// Examples from Porter's paper plus the words this feature is for
+	tests := map[string]string{
+		"caresses":       "caress",
+		"ponies":         "poni",
+		"ties":           "ti",
+		"cats":           "cat",
+		"feed":           "feed",
+		"agreed":         "agre",
+		"plastered":      "plaster",
+		"motoring":       "motor",
+		"sing":           "sing",
+		"conflated":      "conflat",
+		"hopping":        "hop",
+		"falling":        "fall",
+		"hissing":        "hiss",
+		"filing":         "file",
+		"happy":          "happi",
+		"relational":     "relat",
+		"generalization": "gener",
+		"adjustment":     "adjust",
+		"controlling":    "control",
+		"deploy":         "deploy",
+		"deploys":        "deploy",
+		"deployment":     "deploy",
+		"deployments":    "deploy",
+		"kubernetes":     "kubernet",
+		"über":           "über",
+		"go":             "go",
+	}
+
+	for word, expected := range tests {
+		t.Run(word, func(t *testing.T) {
+			assert.Equal(t, expected, Stem(word))
+		})
+	}
+}
+
+func TestStemming(t *testing.T) {
+	content := []byte("Rolling out Kubernetes Deployments safely.\n")
+
+	plain := NewParser(ParserConfig{MinNGram: 2, MaxNGram: 2})
+	wordFreq, err := plain.ParseContent(content)
+	require.NoError(t, err)
+	assert.Contains(t, wordFreq, "kubernetes deployments")
+
+	stemmed := NewParser(ParserConfig{MinNGram: 2, MaxNGram: 2, Stemming: true})
+	wordFreq, err = stemmed.ParseContent(content)
+	require.NoError(t, err)
+	assert.Contains(t, wordFreq, "kubernet deploy")
+	assert.NotContains(t, wordFreq, "kubernetes deployments")
+
+	// Occurrences keep the text as written so links can be inserted
+	occurrences, err := stemmed.FindWordOccurrences(content, 1)
+	require.NoError(t, err)
+	var found *WordOccurrence
+	for i := range occurrences {
+		if occurrences[i].Word == "kubernet deploy" {
+			found = &occurrences[i]
+		}
+	}
+	require.NotNil(t, found)
+	assert.Equal(t, "Kubernetes Deployments", found.Surface)
+	assert.Equal(t, found.Surface, string(content[found.Position:found.Position+len(found.Surface)]))
+
+	assert.Equal(t, "kubernet deploy", stemmed.Normalize("the Kubernetes deployment"))
+	assert.Equal(t, "kubernetes deployment", plain.Normalize("the Kubernetes deployment"))
+}
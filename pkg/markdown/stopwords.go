@@ -0,0 +1,131 @@
+package markdown
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Languages with a bundled stop-word list
+const (
+	LanguageEnglish = "en" // Default
+	LanguageGerman  = "de"
+	LanguageFrench  = "fr"
+	LanguageSpanish = "es"
+)
+
+// Bundled stop words besides English, which uses functionWords
+var stopWordLists = map[string]string{
+	LanguageGerman: `
+		der die das den dem des ein eine einer eines einem einen
+		und oder aber denn sondern doch weil dass wenn als ob wie wo
+		ich du er sie es wir ihr mich dich sich uns euch ihm ihn ihnen
+		mein dein sein unser euer ihre dieser diese dieses jener welche
+		ist sind war waren bin bist sein gewesen hat haben hatte hatten
+		wird werden wurde wurden kann können muss müssen soll sollen
+		nicht kein keine auch noch nur schon sehr hier dort dann jetzt
+		mit von zu zum zur auf aus bei nach für über unter vor durch um an im in am
+	`,
+	LanguageFrench: `
+		le la les un une des du de au aux ce cet cette ces
+		et ou mais donc car ni que qui quoi dont où si comme quand
+		je tu il elle on nous vous ils elles me te se lui leur eux moi toi
+		mon ton son ma ta sa mes tes ses notre votre nos vos leurs
+		est sont être était étaient suis es été avoir ai as avons avez ont avait
+		ne pas plus très aussi encore déjà ici là alors
+		dans sur sous avec sans pour par chez entre vers en
+	`,
+	LanguageSpanish: `
+		el la los las un una unos unas lo al del de
+		y o pero sino porque que quien cual donde si como cuando
+		yo tú él ella nosotros vosotros ellos ellas me te se le les nos os
+		mi tu su mis tus sus nuestro vuestro este esta estos estas ese esa
+		es son ser era eran fue soy está están estar ha han haber había
+		no más muy también ya aquí allí entonces
+		en con sin para por sobre entre hacia desde hasta
+	`,
+}
+
+// LoadStopWords builds the stop-word set described by the config: the
+// bundled list of its Language, replaced by the words in StopWordsFile or
+// extended with them when ExtendStopWords is set
+func LoadStopWords(config ParserConfig) (map[string]bool, error) {
+	language := config.Language
+	if language == "" {
+		language = LanguageEnglish
+	}
+
+	stopWords := make(map[string]bool)
+	if config.StopWordsFile == "" || config.ExtendStopWords {
+		if language == LanguageEnglish {
+			for word := range functionWords {
+				stopWords[word] = true
+			}
+		} else {
+			list, ok := stopWordLists[language]
+			if !ok {
+				return nil, fmt.Errorf("unknown stop-word language %q (expected en, de, fr or es)", config.Language)
+			}
+			for _, word := range strings.Fields(list) {
+				stopWords[word] = true
+			}
+		}
+	}
+
+	if config.StopWordsFile != "" {
+		words, err := readStopWords(config.StopWordsFile)
+		if err != nil {
+			return nil, err
+		}
+		for _, word := range words {
+			stopWords[word] = true
+		}
+	}
+
+	return stopWords, nil
+}
+
+// readStopWords reads one word per line, ignoring blank lines and # comments
+func readStopWords(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open stop-words file: %w", err)
+	}
+	defer f.Close()
+
+	var words []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		word := strings.ToLower(strings.TrimSpace(scanner.Text()))
+		if word == "" || strings.HasPrefix(word, "#") {
+			continue
+		}
+		words = append(words, word)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read stop-words file: %w", err)
+	}
+	return words, nil
+}
+
+// stopWordsKey identifies a stop-word set in the cache key: by language for
+// a bundled list, by a hash of its words otherwise
+func stopWordsKey(config ParserConfig, stopWords map[string]bool) string {
+	if config.StopWordsFile == "" {
+		if config.Language == "" {
+			return LanguageEnglish
+		}
+		return config.Language
+	}
+
+	words := make([]string, 0, len(stopWords))
+	for word := range stopWords {
+		words = append(words, word)
+	}
+	sort.Strings(words)
+	sum := sha256.Sum256([]byte(strings.Join(words, "\n")))
+	return fmt.Sprintf("custom-%x", sum[:8])
+}
@@ -0,0 +1,58 @@
+package markdown
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStopWordLanguages(t *testing.T) {
+	content := []byte("Die Konfiguration der Überwachung und die Warnungen.\n")
+
+	english := NewParser(ParserConfig{MinNGram: 1, MaxNGram: 1})
+	wordFreq, err := english.ParseContent(content)
+	require.NoError(t, err)
+	assert.Contains(t, wordFreq, "die")
+	assert.Contains(t, wordFreq, "und")
+
+	german := NewParser(ParserConfig{MinNGram: 1, MaxNGram: 1, Language: LanguageGerman})
+	wordFreq, err = german.ParseContent(content)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"konfiguration": 1, "überwachung": 1, "warnungen": 1}, wordFreq)
+	assert.NotEqual(t, english.CacheKey(), german.CacheKey())
+}
+
+func TestStopWordsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stopwords.txt")
+	require.NoError(t, os.WriteFile(path, []byte("# project noise\nPlease\n\nnote\n"), 0644))
+	content := []byte("Please note the alerting rules.\n")
+
+	tests := []struct {
+		name     string
+		extend   bool
+		expected map[string]int
+	}{
+		{name: "replaces the bundled list", expected: map[string]int{"the": 1, "alerting": 1, "rules": 1}},
+		{name: "extends the bundled list", extend: true, expected: map[string]int{"alerting": 1, "rules": 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parser := NewParser(ParserConfig{MinNGram: 1, MaxNGram: 1, StopWordsFile: path, ExtendStopWords: tt.extend})
+			wordFreq, err := parser.ParseContent(content)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, wordFreq)
+		})
+	}
+}
+
+func TestLoadStopWordsErrors(t *testing.T) {
+	_, err := LoadStopWords(ParserConfig{Language: "xx"})
+	assert.Error(t, err)
+
+	_, err = LoadStopWords(ParserConfig{StopWordsFile: filepath.Join(t.TempDir(), "missing.txt")})
+	assert.Error(t, err)
+}
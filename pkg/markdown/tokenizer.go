@@ -0,0 +1,92 @@
+package markdown
+
+import (
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// DefaultTokenizerName is the name of the built-in whitespace tokenizer
+const DefaultTokenizerName = "default"
+
+// Token is a single token found in a run of text
+type Token struct {
+	Surface    string // Text exactly as it appears in the document
+	Normalized string // Form used for matching and frequency counting
+	Start      int    // Byte offset of Surface within the tokenized text
+	End        int    // Byte offset just past Surface
+}
+
+// Tokenizer splits text into tokens. Implementations must report spans that
+// index into the original text so links can be inserted at the right place.
+type Tokenizer interface {
+	// Name identifies the tokenizer; it is part of the cache key
+	Name() string
+
+	// Tokenize returns the tokens of text in order of appearance
+	Tokenize(text string) []Token
+}
+
+var (
+	tokenizersMu sync.RWMutex
+	tokenizers   = map[string]Tokenizer{
+		DefaultTokenizerName: WhitespaceTokenizer{},
+	}
+)
+
+// RegisterTokenizer makes a tokenizer selectable by name via ParserConfig.TokenizerName
+func RegisterTokenizer(t Tokenizer) {
+	tokenizersMu.Lock()
+	defer tokenizersMu.Unlock()
+	tokenizers[t.Name()] = t
+}
+
+// LookupTokenizer returns the registered tokenizer with the given name
+func LookupTokenizer(name string) (Tokenizer, bool) {
+	tokenizersMu.RLock()
+	defer tokenizersMu.RUnlock()
+	t, ok := tokenizers[name]
+	return t, ok
+}
+
+// WhitespaceTokenizer splits on whitespace, lowercases and trims surrounding punctuation
+type WhitespaceTokenizer struct{}
+
+// Name implements the Tokenizer interface
+func (WhitespaceTokenizer) Name() string {
+	return DefaultTokenizerName
+}
+
+// Tokenize implements the Tokenizer interface
+func (WhitespaceTokenizer) Tokenize(text string) []Token {
+	var tokens []Token
+
+	start := -1
+	for i, r := range text {
+		if unicode.IsSpace(r) {
+			if start != -1 {
+				tokens = append(tokens, newWhitespaceToken(text, start, i))
+				start = -1
+			}
+			continue
+		}
+		if start == -1 {
+			start = i
+		}
+	}
+	if start != -1 {
+		tokens = append(tokens, newWhitespaceToken(text, start, len(text)))
+	}
+
+	return tokens
+}
+
+func newWhitespaceToken(text string, start, end int) Token {
+	surface := text[start:end]
+	return Token{
+		Surface:    surface,
+		Normalized: strings.ToLower(strings.Trim(surface, ".,!?()[]{}\"'")),
+		Start:      start,
+		End:        end,
+	}
+}
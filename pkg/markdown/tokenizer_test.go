@@ -0,0 +1,73 @@
+package markdown
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// ticketTokenizer keeps ticket IDs like PROJ-1234 intact and splits everything else on non-letters
+type ticketTokenizer struct{}
+
+var ticketPattern = regexp.MustCompile(`[A-Z]+-[0-9]+|[\pL\pN]+`)
+
+func (ticketTokenizer) Name() string { return "tickets" }
+
+func (ticketTokenizer) Tokenize(text string) []Token {
+	var tokens []Token
+	for _, loc := range ticketPattern.FindAllStringIndex(text, -1) {
+		surface := text[loc[0]:loc[1]]
+		normalized := surface
+		if !strings.Contains(surface, "-") {
+			normalized = strings.ToLower(surface)
+		}
+		tokens = append(tokens, Token{Surface: surface, Normalized: normalized, Start: loc[0], End: loc[1]})
+	}
+	return tokens
+}
+
+func TestWhitespaceTokenizer(t *testing.T) {
+	tokens := WhitespaceTokenizer{}.Tokenize("Hello,  world!\nNext")
+	assert.Equal(t, []Token{
+		{Surface: "Hello,", Normalized: "hello", Start: 0, End: 6},
+		{Surface: "world!", Normalized: "world", Start: 8, End: 14},
+		{Surface: "Next", Normalized: "next", Start: 15, End: 19},
+	}, tokens)
+}
+
+func TestCustomTokenizer(t *testing.T) {
+	content := "Fixed regression in PROJ-1234/PROJ-99 today."
+
+	// The whitespace tokenizer keeps the IDs together, which then reads as a path
+	parser := NewParser(ParserConfig{MinNGram: 1, MaxNGram: 1, AllowCodeishTokens: true})
+	wordFreq, err := parser.ParseContent([]byte(content))
+	assert.NoError(t, err)
+	assert.Contains(t, wordFreq, "proj-1234/proj-99")
+
+	parser = NewParser(ParserConfig{MinNGram: 1, MaxNGram: 1, Tokenizer: ticketTokenizer{}})
+	occurrences, err := parser.FindWordOccurrences([]byte(content), 1)
+	assert.NoError(t, err)
+
+	var words []string
+	for _, occ := range occurrences {
+		words = append(words, occ.Word)
+		assert.True(t, strings.EqualFold(content[occ.Position:occ.Position+len(occ.Word)], occ.Word))
+	}
+	assert.Equal(t, []string{"fixed", "regression", "PROJ-1234", "PROJ-99"}, words)
+}
+
+func TestRegisteredTokenizer(t *testing.T) {
+	RegisterTokenizer(ticketTokenizer{})
+
+	tokenizer, ok := LookupTokenizer("tickets")
+	assert.True(t, ok)
+	assert.Equal(t, "tickets", tokenizer.Name())
+
+	parser := NewParser(ParserConfig{MinNGram: 1, MaxNGram: 1, TokenizerName: "tickets"})
+	assert.Equal(t, "tickets", parser.TokenizerName())
+
+	parser = NewParser(ParserConfig{MinNGram: 1, MaxNGram: 1})
+	assert.Equal(t, DefaultTokenizerName, parser.TokenizerName())
+}
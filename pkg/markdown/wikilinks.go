@@ -0,0 +1,62 @@
+package markdown
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Link formats written by InsertLink
+const (
+	LinkFormatMarkdown  = "markdown"  // [phrase](target)
+	LinkFormatWikilink  = "wikilink"  // [[target|phrase]], as used by Obsidian
+	LinkFormatReference = "reference" // [phrase][label], defined by "[label]: target" at the end
+)
+
+// wikilinkPattern matches [[Note]], [[Note|alias]] and [[Note#Heading|alias]]
+var wikilinkPattern = regexp.MustCompile(`\[\[([^\[\]|\n]+)(?:\|([^\[\]\n]+))?\]\]`)
+
+// FormatLink returns the markup linking phrase to target in the configured
+// link format. Wikilinks refer to notes by name, so a .md extension is
+// dropped. Reference links use the label target gets in a document without
+// definitions; References picks labels for a given document.
+func (p *Parser) FormatLink(phrase, target string) string {
+	return FormatLinkAs(p.linkFormat, phrase, target)
+}
+
+// FormatLinkAs is FormatLink in the given link format rather than the
+// configured one
+func FormatLinkAs(format, phrase, target string) string {
+	switch format {
+	case LinkFormatWikilink:
+		return fmt.Sprintf("[[%s|%s]]", strings.TrimSuffix(target, ".md"), phrase)
+	case LinkFormatReference:
+		return formatReference(phrase, referenceLabelPrefix+referenceSlug(target))
+	}
+	return fmt.Sprintf("[%s](%s)", phrase, target)
+}
+
+// findWikilinks returns the wikilinks in content. goldmark doesn't know the
+// syntax, so they are matched on the raw bytes.
+func findWikilinks(content []byte) []ExistingLink {
+	var links []ExistingLink
+	for _, m := range wikilinkPattern.FindAllSubmatchIndex(content, -1) {
+		destination := string(content[m[2]:m[3]])
+		text := destination
+		if m[4] != -1 {
+			text = string(content[m[4]:m[5]])
+		}
+		if idx := strings.IndexByte(destination, '#'); idx != -1 {
+			destination = destination[:idx]
+		}
+
+		links = append(links, ExistingLink{
+			Text:        strings.TrimSpace(text),
+			Destination: strings.TrimSpace(destination),
+			Start:       m[0],
+			End:         m[1],
+			Wikilink:    true,
+		})
+	}
+	return links
+}
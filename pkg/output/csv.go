@@ -0,0 +1,55 @@
+package output
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+
+	"internal-link/pkg/scorer"
+)
+
+// csvColumns are the columns of CSV output, named after the JSON fields of
+// scorer.LinkSuggestion, so the file can be applied again like JSON output
+var csvColumns = []string{
+	"source_path", "target_path", "score", "word_to_link", "position", "line", "column",
+	"anchor", "alternative", "context", "context_before", "phrase", "context_after",
+}
+
+// CSVWriter writes suggestions as CSV, one per row after a header
+type CSVWriter struct {
+	w io.Writer
+}
+
+// NewCSVWriter returns a writer of CSV
+func NewCSVWriter(w io.Writer) *CSVWriter {
+	return &CSVWriter{w: w}
+}
+
+// Write implements the Writer interface
+func (c *CSVWriter) Write(suggestions []scorer.LinkSuggestion) error {
+	cw := csv.NewWriter(c.w)
+	cw.Write(csvColumns)
+	for _, s := range suggestions {
+		cw.Write([]string{
+			s.SourcePath,
+			s.TargetPath,
+			strconv.FormatFloat(s.Score, 'f', -1, 64),
+			s.WordToLink,
+			strconv.Itoa(s.Position),
+			strconv.Itoa(s.Line),
+			strconv.Itoa(s.Column),
+			s.Anchor,
+			strconv.Itoa(s.Alternative),
+			s.Context,
+			s.ContextBefore,
+			s.Phrase,
+			s.ContextAfter,
+		})
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("failed to write suggestions: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"internal-link/pkg/scorer"
+)
+
+// JSONWriter writes suggestions as a JSON array
+type JSONWriter struct {
+	w io.Writer
+}
+
+// NewJSONWriter returns a writer of JSON arrays
+func NewJSONWriter(w io.Writer) *JSONWriter {
+	return &JSONWriter{w: w}
+}
+
+// Write implements the Writer interface, emitting [] when there are no suggestions
+func (j *JSONWriter) Write(suggestions []scorer.LinkSuggestion) error {
+	if suggestions == nil {
+		suggestions = []scorer.LinkSuggestion{}
+	}
+	enc := json.NewEncoder(j.w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(suggestions); err != nil {
+		return fmt.Errorf("failed to write suggestions: %w", err)
+	}
+	return nil
+}
+
+// WriteRetargets implements the RetargetWriter interface, emitting [] when
+// there are no retargets
+func (j *JSONWriter) WriteRetargets(retargets []scorer.RetargetSuggestion) error {
+	if retargets == nil {
+		retargets = []scorer.RetargetSuggestion{}
+	}
+	enc := json.NewEncoder(j.w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(retargets); err != nil {
+		return fmt.Errorf("failed to write retargets: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+// Package output writes link suggestions in the formats of the command line
+package output
+
+import (
+	"fmt"
+	"io"
+
+	"internal-link/pkg/report"
+	"internal-link/pkg/scorer"
+)
+
+// Output formats
+const (
+	FormatText  = "text"
+	FormatJSON  = "json"
+	FormatCSV   = "csv"
+	FormatHTML  = "html"
+	FormatSARIF = "sarif"
+)
+
+// Writer writes a batch of suggestions in one format
+type Writer interface {
+	Write(suggestions []scorer.LinkSuggestion) error
+}
+
+// RetargetWriter is implemented by the writers that can also list the
+// existing links an audit proposes to point at a different document
+type RetargetWriter interface {
+	WriteRetargets(retargets []scorer.RetargetSuggestion) error
+}
+
+// Options control how suggestions are written; each format uses the
+// settings that apply to it
+type Options struct {
+	// Base is the directory text output shows paths relative to; empty to
+	// show them as they are
+	Base string
+
+	// Details adds the context and phrase of each suggestion to text output
+	Details bool
+
+	// Compact writes text output as one line per suggestion:
+	// source -> target score phrase
+	Compact bool
+
+	// HighlightMarkers wrap the phrase in the context of text output, the
+	// same on both sides or "open,close"; empty for none
+	HighlightMarkers string
+
+	// Threshold is the minimum score the suggestions met, shown in the
+	// summary closing text output
+	Threshold float64
+
+	// Report controls the paths of HTML reports
+	Report report.Options
+
+	// SARIF controls how SARIF output locates suggestions
+	SARIF report.SARIFOptions
+}
+
+// CheckFormat returns an error when format is not an output format
+func CheckFormat(format string) error {
+	switch format {
+	case FormatText, FormatJSON, FormatCSV, FormatHTML, FormatSARIF:
+		return nil
+	}
+	return fmt.Errorf("invalid output format %q (expected text, json, csv, html or sarif)", format)
+}
+
+// New returns the writer of format writing to w
+func New(w io.Writer, format string, opts Options) (Writer, error) {
+	switch format {
+	case FormatText:
+		return NewTextWriter(w, opts), nil
+	case FormatJSON:
+		return NewJSONWriter(w), nil
+	case FormatCSV:
+		return NewCSVWriter(w), nil
+	case FormatHTML:
+		return &reportWriter{write: func(s []scorer.LinkSuggestion) error { return report.WriteHTML(w, s, opts.Report) }}, nil
+	case FormatSARIF:
+		return &reportWriter{write: func(s []scorer.LinkSuggestion) error { return report.WriteSARIF(w, s, opts.SARIF) }}, nil
+	}
+	return nil, CheckFormat(format)
+}
+
+// reportWriter writes the documents of the report package
+type reportWriter struct {
+	write func([]scorer.LinkSuggestion) error
+}
+
+// Write implements the Writer interface
+func (r *reportWriter) Write(suggestions []scorer.LinkSuggestion) error {
+	if err := r.write(suggestions); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package output
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"internal-link/pkg/scorer"
+)
+
+var testSuggestions = []scorer.LinkSuggestion{
+	{
+		SourcePath:    "/docs/posts/setup.md",
+		TargetPath:    "/docs/alerts.md",
+		Score:         1.25,
+		WordToLink:    "prometheus alerting",
+		Position:      18,
+		Line:          3,
+		Column:        10,
+		Context:       "We tuned prometheus alerting.",
+		ContextBefore: "We tuned ",
+		Phrase:        "prometheus alerting",
+		ContextAfter:  ".",
+		SourceSection: "## Tuning",
+		AnchorScore:   2.5,
+	},
+	{
+		SourcePath: "/docs/guide.md",
+		TargetPath: "/docs/dashboards.md",
+		Score:      0.5,
+		WordToLink: "grafana dashboards, panels",
+		Position:   4,
+	},
+}
+
+func TestTextWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := New(&buf, FormatText, Options{Base: "/docs", Details: true, HighlightMarkers: "[,]", Threshold: 0.3})
+	require.NoError(t, err)
+	require.NoError(t, w.Write(testSuggestions[:1]))
+
+	assert.Equal(t, "File: posts/setup.md (1 suggestion(s))\n"+
+		"  posts/setup.md:3:10\n"+
+		"    Under: ## Tuning\n"+
+		"    Suggested link to: alerts.md\n"+
+		"    Score: 1.2500\n"+
+		"    Context: We tuned [prometheus alerting].\n"+
+		"    Phrase to link: prometheus alerting (anchor score 2.5000)\n\n"+
+		"1 suggestion(s) across 1 file(s) (threshold 0.30)\n", buf.String())
+}
+
+func TestTextWriterPreviewLines(t *testing.T) {
+	suggestion := testSuggestions[1]
+	suggestion.LineBefore = "see grafana dashboards,\npanels and more"
+	suggestion.LineAfter = "see [grafana dashboards,\npanels](dashboards.md) and more"
+	var buf bytes.Buffer
+	w, err := New(&buf, FormatText, Options{Base: "/docs", Details: true})
+	require.NoError(t, err)
+	require.NoError(t, w.Write([]scorer.LinkSuggestion{suggestion}))
+
+	assert.Contains(t, buf.String(), "    Before: see grafana dashboards,\n            panels and more\n"+
+		"    After:  see [grafana dashboards,\n            panels](dashboards.md) and more\n")
+}
+
+func TestTextWriterGroupsByFile(t *testing.T) {
+	suggestions := []scorer.LinkSuggestion{
+		{SourcePath: "/docs/a.md", TargetPath: "/docs/x.md", Score: 0.5, Position: 40, Line: 4, Column: 1},
+		{SourcePath: "/docs/b.md", TargetPath: "/docs/x.md", Score: 0.5, Position: 10, Line: 1, Column: 11},
+		{SourcePath: "/docs/a.md", TargetPath: "/docs/y.md", Score: 0.5, Position: 5, Line: 1, Column: 6},
+	}
+	var buf bytes.Buffer
+	w, err := New(&buf, FormatText, Options{Base: "/docs"})
+	require.NoError(t, err)
+	require.NoError(t, w.Write(suggestions))
+
+	assert.Equal(t, "File: a.md (2 suggestion(s))\n"+
+		"  a.md:1:6\n    Suggested link to: y.md\n    Score: 0.5000\n\n"+
+		"  a.md:4:1\n    Suggested link to: x.md\n    Score: 0.5000\n\n"+
+		"File: b.md (1 suggestion(s))\n"+
+		"  b.md:1:11\n    Suggested link to: x.md\n    Score: 0.5000\n\n"+
+		"3 suggestion(s) across 2 file(s)\n", buf.String())
+}
+
+func TestCompactTextWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := New(&buf, FormatText, Options{Details: true, Compact: true})
+	require.NoError(t, err)
+	require.NoError(t, w.Write(testSuggestions))
+
+	assert.Equal(t, "/docs/posts/setup.md:3:10 -> /docs/alerts.md 1.2500 prometheus alerting\n"+
+		"/docs/guide.md -> /docs/dashboards.md 0.5000 grafana dashboards, panels\n", buf.String())
+}
+
+func TestJSONWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := New(&buf, FormatJSON, Options{})
+	require.NoError(t, err)
+	require.NoError(t, w.Write(nil))
+	assert.Equal(t, "[]\n", buf.String())
+
+	buf.Reset()
+	require.NoError(t, w.Write(testSuggestions))
+	var decoded []scorer.LinkSuggestion
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+	assert.Equal(t, testSuggestions, decoded)
+}
+
+func TestWriteRetargets(t *testing.T) {
+	retargets := []scorer.RetargetSuggestion{{
+		SourcePath:     "/docs/post.md",
+		AnchorText:     "kubernetes deployment strategies",
+		Destination:    "old-notes.md",
+		CurrentTarget:  "/docs/old-notes.md",
+		ProposedTarget: "/docs/deployments.md",
+		CurrentScore:   0.1,
+		ProposedScore:  1.5,
+		Start:          11,
+		End:            59,
+	}}
+
+	var buf bytes.Buffer
+	require.NoError(t, NewTextWriter(&buf, Options{Base: "/docs"}).WriteRetargets(retargets))
+	assert.Equal(t, "File: post.md\n"+
+		"  Existing link: [kubernetes deployment strategies](old-notes.md)\n"+
+		"  Current target: old-notes.md (score 0.1000)\n"+
+		"  Proposed target: deployments.md (score 1.5000)\n\n", buf.String())
+
+	buf.Reset()
+	w := NewJSONWriter(&buf)
+	require.NoError(t, w.WriteRetargets(nil))
+	assert.Equal(t, "[]\n", buf.String())
+
+	buf.Reset()
+	require.NoError(t, w.WriteRetargets(retargets))
+	assert.Contains(t, buf.String(), `"proposed_target": "/docs/deployments.md"`)
+	var decoded []scorer.RetargetSuggestion
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+	assert.Equal(t, retargets, decoded)
+}
+
+func TestCSVWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := New(&buf, FormatCSV, Options{})
+	require.NoError(t, err)
+	require.NoError(t, w.Write(testSuggestions))
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+	assert.Equal(t, csvColumns, records[0])
+	assert.Equal(t, []string{"/docs/guide.md", "/docs/dashboards.md", "0.5", "grafana dashboards, panels", "4", "0", "0", "", "0", "", "", "", ""}, records[2])
+}
+
+func TestUnknownFormat(t *testing.T) {
+	_, err := New(&bytes.Buffer{}, "yaml", Options{})
+	assert.EqualError(t, err, `invalid output format "yaml" (expected text, json, csv, html or sarif)`)
+	assert.NoError(t, CheckFormat(FormatSARIF))
+}
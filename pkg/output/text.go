@@ -0,0 +1,165 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"internal-link/pkg/scorer"
+)
+
+// TextWriter writes suggestions in the human-readable format
+type TextWriter struct {
+	w    io.Writer
+	opts Options
+}
+
+// NewTextWriter returns a writer of the human-readable format
+func NewTextWriter(w io.Writer, opts Options) *TextWriter {
+	return &TextWriter{w: w, opts: opts}
+}
+
+// Write implements the Writer interface. Suggestions are grouped by source
+// file under a header, in the order of their position in it, and followed by
+// a summary line; compact output is one line per suggestion as given.
+func (t *TextWriter) Write(suggestions []scorer.LinkSuggestion) error {
+	if t.opts.Compact {
+		for _, s := range suggestions {
+			fmt.Fprintf(t.w, "%s -> %s %.4f %s", t.location(s), t.display(s.TargetPath), s.Score, s.WordToLink)
+			if s.Suppressed != "" {
+				fmt.Fprintf(t.w, " (suppressed: %s)", s.Suppressed)
+			}
+			fmt.Fprintln(t.w)
+		}
+		return nil
+	}
+
+	files := groupBySource(suggestions)
+	for _, file := range files {
+		fmt.Fprintf(t.w, "File: %s (%d suggestion(s))\n", t.display(file[0].SourcePath), len(file))
+		for _, s := range file {
+			t.writeSuggestion(s)
+		}
+	}
+
+	fmt.Fprintf(t.w, "%d suggestion(s) across %d file(s)", len(suggestions), len(files))
+	if t.opts.Threshold > 0 {
+		fmt.Fprintf(t.w, " (threshold %.2f)", t.opts.Threshold)
+	}
+	fmt.Fprintln(t.w)
+	return nil
+}
+
+// WriteRetargets implements the RetargetWriter interface, writing each
+// retarget under the header of its file
+func (t *TextWriter) WriteRetargets(retargets []scorer.RetargetSuggestion) error {
+	for _, r := range retargets {
+		fmt.Fprintf(t.w, "File: %s\n", t.display(r.SourcePath))
+		fmt.Fprintf(t.w, "  Existing link: [%s](%s)\n", r.AnchorText, r.Destination)
+		fmt.Fprintf(t.w, "  Current target: %s (score %.4f)\n", t.display(r.CurrentTarget), r.CurrentScore)
+		fmt.Fprintf(t.w, "  Proposed target: %s (score %.4f)\n", t.display(r.ProposedTarget), r.ProposedScore)
+		fmt.Fprintln(t.w)
+	}
+	return nil
+}
+
+// writeSuggestion writes one suggestion below the header of its file
+func (t *TextWriter) writeSuggestion(s scorer.LinkSuggestion) {
+	fmt.Fprintf(t.w, "  %s\n", t.location(s))
+	if s.SourceSection != "" {
+		fmt.Fprintf(t.w, "    Under: %s\n", s.SourceSection)
+	}
+	fmt.Fprintf(t.w, "    Suggested link to: %s\n", t.display(s.TargetPath))
+	fmt.Fprintf(t.w, "    Score: %.4f\n", s.Score)
+	if s.Suppressed != "" {
+		fmt.Fprintf(t.w, "    Suppressed: %s\n", s.Suppressed)
+	}
+	if t.opts.Details {
+		fmt.Fprintf(t.w, "    Context: %s\n", t.highlight(s))
+		fmt.Fprintf(t.w, "    Phrase to link: %s (anchor score %.4f)\n", s.WordToLink, s.AnchorScore)
+		if s.Alternative > 0 {
+			fmt.Fprintf(t.w, "    Alternative phrase: %d\n", s.Alternative)
+		}
+	}
+	if s.LineBefore != "" {
+		fmt.Fprintf(t.w, "    Before: %s\n", indentLines(s.LineBefore))
+		fmt.Fprintf(t.w, "    After:  %s\n", indentLines(s.LineAfter))
+	}
+	if s.DebugInfo != "" {
+		fmt.Fprintf(t.w, "    Debug: %s\n", s.DebugInfo)
+	}
+	if s.Explanation != nil {
+		writeExplanation(t.w, s.Explanation)
+	}
+	fmt.Fprintln(t.w)
+}
+
+// indentLines indents the lines after the first of a preview to line up
+// below it
+func indentLines(text string) string {
+	return strings.ReplaceAll(text, "\n", "\n            ")
+}
+
+// groupBySource returns the suggestions of each source file, sorted by
+// position, with the files in the order they first appear
+func groupBySource(suggestions []scorer.LinkSuggestion) [][]scorer.LinkSuggestion {
+	index := make(map[string]int)
+	var files [][]scorer.LinkSuggestion
+	for _, s := range suggestions {
+		i, ok := index[s.SourcePath]
+		if !ok {
+			i = len(files)
+			index[s.SourcePath] = i
+			files = append(files, nil)
+		}
+		files[i] = append(files[i], s)
+	}
+	for _, file := range files {
+		sort.SliceStable(file, func(i, j int) bool { return file[i].Position < file[j].Position })
+	}
+	return files
+}
+
+// display returns path relative to the base directory, if set
+func (t *TextWriter) display(path string) string {
+	if t.opts.Base == "" {
+		return path
+	}
+	if rel, err := filepath.Rel(t.opts.Base, path); err == nil {
+		return rel
+	}
+	return path
+}
+
+// location returns the source of a suggestion as path:line:column, or as
+// the path alone when its line isn't known
+func (t *TextWriter) location(s scorer.LinkSuggestion) string {
+	if s.Line == 0 {
+		return t.display(s.SourcePath)
+	}
+	return fmt.Sprintf("%s:%d:%d", t.display(s.SourcePath), s.Line, s.Column)
+}
+
+// highlight returns the context of a suggestion with its phrase wrapped in
+// the highlight markers
+func (t *TextWriter) highlight(s scorer.LinkSuggestion) string {
+	if s.Phrase == "" || t.opts.HighlightMarkers == "" {
+		return s.Context
+	}
+	opening, closing, found := strings.Cut(t.opts.HighlightMarkers, ",")
+	if !found {
+		closing = opening
+	}
+	return s.ContextBefore + opening + s.Phrase + closing + s.ContextAfter
+}
+
+// writeExplanation writes the score breakdown of a suggestion, one matched term per line
+func writeExplanation(w io.Writer, e *scorer.Explanation) {
+	fmt.Fprintf(w, "    Explanation: BM25 %.4f x boost %.2f (target length %d, average %.1f)\n", e.BM25, e.Boost, e.DocLength, e.AvgDocLength)
+	for _, t := range e.Terms {
+		fmt.Fprintf(w, "      %s: %.4f (idf %.4f, %d in target, %d in source, length boost %.1f, title boost %.1f)\n",
+			t.Term, t.Score, t.IDF, t.TargetFreq, t.QueryCount, t.LengthBoost, t.TitleBoost)
+	}
+}
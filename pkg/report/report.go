@@ -0,0 +1,126 @@
+// Package report renders link suggestions for review outside the terminal
+package report
+
+import (
+	_ "embed"
+	"fmt"
+	"html/template"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"internal-link/pkg/scorer"
+)
+
+//go:embed report.html.tmpl
+var htmlTemplate string
+
+var page = template.Must(template.New("report").Parse(htmlTemplate))
+
+// Options control how paths appear in a report
+type Options struct {
+	Title string // Page title (default "Internal link suggestions")
+	Root  string // Directory the paths shown are made relative to
+
+	// OutputDir is the directory the report is written to, so links to
+	// targets can be made relative to it
+	OutputDir string
+}
+
+// File groups the suggestions of one source document
+type File struct {
+	Path        string
+	Suggestions []Suggestion
+}
+
+// Suggestion is a link suggestion laid out for display, with its context
+// split around the phrase to link
+type Suggestion struct {
+	Before, Phrase, After string
+	Target                string // Target path as shown
+	Href                  string // Link to the target file
+	Score                 float64
+	Position              int
+}
+
+// data is what the template renders
+type data struct {
+	Title string
+	Root  string
+	Total int
+	Files []File
+}
+
+// WriteHTML writes a standalone HTML page listing the suggestions grouped by
+// source file, in the order given
+func WriteHTML(w io.Writer, suggestions []scorer.LinkSuggestion, opts Options) error {
+	d := data{Title: opts.Title, Root: opts.Root, Total: len(suggestions)}
+	if d.Title == "" {
+		d.Title = "Internal link suggestions"
+	}
+
+	index := make(map[string]int)
+	for _, s := range suggestions {
+		source := displayPath(opts.Root, s.SourcePath)
+		i, ok := index[source]
+		if !ok {
+			i = len(d.Files)
+			index[source] = i
+			d.Files = append(d.Files, File{Path: source})
+		}
+		d.Files[i].Suggestions = append(d.Files[i].Suggestions, newSuggestion(s, opts))
+	}
+
+	if err := page.Execute(w, d); err != nil {
+		return fmt.Errorf("failed to render report: %w", err)
+	}
+	return nil
+}
+
+// newSuggestion lays out a suggestion, highlighting its phrase in the
+// context, or the first occurrence of it in suggestions read back without
+// the context split
+func newSuggestion(s scorer.LinkSuggestion, opts Options) Suggestion {
+	r := Suggestion{
+		Before:   s.Context,
+		Target:   displayPath(opts.Root, s.TargetPath),
+		Href:     href(opts.OutputDir, s.TargetPath),
+		Score:    s.Score,
+		Position: s.Position,
+	}
+	if s.Phrase != "" {
+		r.Before, r.Phrase, r.After = s.ContextBefore, s.Phrase, s.ContextAfter
+	} else if i := strings.Index(s.Context, s.WordToLink); i >= 0 && s.WordToLink != "" {
+		r.Before, r.Phrase, r.After = s.Context[:i], s.WordToLink, s.Context[i+len(s.WordToLink):]
+	}
+	if s.Anchor != "" {
+		r.Target += "#" + s.Anchor
+		r.Href += "#" + s.Anchor
+	}
+	return r
+}
+
+// displayPath returns path relative to root, when it lies beneath it
+func displayPath(root, path string) string {
+	if root != "" {
+		if rel, err := filepath.Rel(root, path); err == nil && !strings.HasPrefix(rel, "..") {
+			return filepath.ToSlash(rel)
+		}
+	}
+	return filepath.ToSlash(path)
+}
+
+// href returns a link to path from a page in dir, or path itself when no
+// directory is given
+func href(dir, path string) string {
+	if dir != "" {
+		absDir, dirErr := filepath.Abs(dir)
+		absPath, pathErr := filepath.Abs(path)
+		if dirErr == nil && pathErr == nil {
+			if rel, err := filepath.Rel(absDir, absPath); err == nil {
+				return filepath.ToSlash(rel)
+			}
+		}
+	}
+	return filepath.ToSlash(path)
+}
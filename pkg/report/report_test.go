@@ -0,0 +1,67 @@
+package report
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"internal-link/pkg/scorer"
+)
+
+func TestWriteHTML(t *testing.T) {
+	suggestions := []scorer.LinkSuggestion{
+		{
+			SourcePath: "docs/posts/setup.md",
+			TargetPath: "docs/alerts.md",
+			Score:      1.25,
+			Context:    "we tuned <script>alert(1)</script> prometheus alerting & more",
+			WordToLink: "prometheus alerting",
+			Position:   40,
+		},
+		{
+			SourcePath: "docs/posts/setup.md",
+			TargetPath: "docs/dashboards.md",
+			Score:      0.5,
+			Context:    "grafana dashboards",
+			WordToLink: "grafana dashboards",
+			Anchor:     "panels",
+		},
+		{
+			SourcePath: "docs/guide.md",
+			TargetPath: "docs/alerts.md",
+			Score:      0.75,
+			Context:    "phrase moved out of the context",
+			WordToLink: "prometheus alerting",
+		},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteHTML(&buf, suggestions, Options{Root: "docs", OutputDir: "."}))
+	html := buf.String()
+
+	assert.Contains(t, html, "3 suggested link(s) in 2 file(s)")
+	assert.Contains(t, html, `<a href="#file-0">posts/setup.md</a></td><td class="count">2</td>`)
+	assert.Contains(t, html, `<a href="#file-1">guide.md</a></td><td class="count">1</td>`)
+
+	// Content is escaped and the phrase highlighted
+	assert.NotContains(t, html, "<script>")
+	assert.Contains(t, html, "we tuned &lt;script&gt;alert(1)&lt;/script&gt; <mark>prometheus alerting</mark> &amp; more")
+	assert.Contains(t, html, "<td>phrase moved out of the context</td>")
+
+	// Targets link to their files relative to the report, sections included
+	assert.Contains(t, html, `<a href="docs/alerts.md">alerts.md</a>`)
+	assert.Contains(t, html, `<a href="docs/dashboards.md#panels">dashboards.md#panels</a>`)
+	assert.Contains(t, html, `<td class="score">1.2500</td>`)
+	assert.Equal(t, 3, strings.Count(html, `type="checkbox"`))
+}
+
+func TestWriteHTMLEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, WriteHTML(&buf, nil, Options{Title: "Review"}))
+	assert.Contains(t, buf.String(), "<title>Review</title>")
+	assert.Contains(t, buf.String(), "0 suggested link(s) in 0 file(s)")
+	assert.NotContains(t, buf.String(), "<table>")
+}
@@ -0,0 +1,153 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"internal-link/pkg/markdown"
+	"internal-link/pkg/scorer"
+)
+
+// SARIFRuleID identifies suggestions in SARIF output
+const SARIFRuleID = "missing-internal-link"
+
+// SARIFOptions control how suggestions are written as SARIF
+type SARIFOptions struct {
+	// ReadFile reads the source documents, to turn the byte offsets of
+	// suggestions into lines and columns (default os.ReadFile)
+	ReadFile func(path string) ([]byte, error)
+}
+
+// The subset of the SARIF 2.1.0 object model written by WriteSARIF
+type (
+	sarifLog struct {
+		Schema  string     `json:"$schema"`
+		Version string     `json:"version"`
+		Runs    []sarifRun `json:"runs"`
+	}
+	sarifRun struct {
+		Tool       sarifTool     `json:"tool"`
+		ColumnKind string        `json:"columnKind"`
+		Results    []sarifResult `json:"results"`
+	}
+	sarifTool struct {
+		Driver sarifDriver `json:"driver"`
+	}
+	sarifDriver struct {
+		Name  string      `json:"name"`
+		Rules []sarifRule `json:"rules"`
+	}
+	sarifRule struct {
+		ID               string       `json:"id"`
+		ShortDescription sarifMessage `json:"shortDescription"`
+	}
+	sarifMessage struct {
+		Text string `json:"text"`
+	}
+	sarifResult struct {
+		RuleID     string          `json:"ruleId"`
+		Level      string          `json:"level"`
+		Message    sarifMessage    `json:"message"`
+		Locations  []sarifLocation `json:"locations"`
+		Properties sarifProperties `json:"properties"`
+	}
+	sarifLocation struct {
+		PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+	}
+	sarifPhysicalLocation struct {
+		ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+		Region           sarifRegion           `json:"region"`
+	}
+	sarifArtifactLocation struct {
+		URI string `json:"uri"`
+	}
+	sarifRegion struct {
+		StartLine   int `json:"startLine"`
+		StartColumn int `json:"startColumn"`
+		EndLine     int `json:"endLine"`
+		EndColumn   int `json:"endColumn"`
+	}
+	sarifProperties struct {
+		Target string  `json:"target"`
+		Phrase string  `json:"phrase"`
+		Score  float64 `json:"score"`
+	}
+)
+
+// WriteSARIF writes the suggestions as a SARIF 2.1.0 log, one result per
+// suggestion located at its phrase, for code scanning tools to annotate
+// the source documents with
+func WriteSARIF(w io.Writer, suggestions []scorer.LinkSuggestion, opts SARIFOptions) error {
+	if opts.ReadFile == nil {
+		opts.ReadFile = os.ReadFile
+	}
+
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name: "internal-link",
+			Rules: []sarifRule{{
+				ID:               SARIFRuleID,
+				ShortDescription: sarifMessage{Text: "A phrase matches another document it does not link to yet"},
+			}},
+		}},
+		ColumnKind: "unicodeCodePoints",
+		Results:    []sarifResult{},
+	}
+
+	lines := make(map[string]*markdown.LineIndex)
+	for _, s := range suggestions {
+		index, ok := lines[s.SourcePath]
+		if !ok {
+			content, err := opts.ReadFile(s.SourcePath)
+			if err != nil {
+				return fmt.Errorf("failed to read %s: %w", s.SourcePath, err)
+			}
+			index = markdown.NewLineIndex(content)
+			lines[s.SourcePath] = index
+		}
+
+		var region sarifRegion
+		region.StartLine, region.StartColumn = index.Position(s.Position)
+		region.EndLine, region.EndColumn = index.Position(s.Position + len(s.WordToLink))
+
+		target := filepath.ToSlash(s.TargetPath)
+		if s.Anchor != "" {
+			target += "#" + s.Anchor
+		}
+		run.Results = append(run.Results, sarifResult{
+			RuleID:  SARIFRuleID,
+			Level:   "note",
+			Message: sarifMessage{Text: fmt.Sprintf("Link %q to %s (score %.4f)", s.WordToLink, target, s.Score)},
+			Locations: []sarifLocation{{PhysicalLocation: sarifPhysicalLocation{
+				ArtifactLocation: sarifArtifactLocation{URI: artifactURI(s.SourcePath)},
+				Region:           region,
+			}}},
+			Properties: sarifProperties{Target: target, Phrase: s.WordToLink, Score: s.Score},
+		})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	err := enc.Encode(sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write SARIF: %w", err)
+	}
+	return nil
+}
+
+// artifactURI spells path as a SARIF artifact location: relative paths as
+// they are, resolved against the directory the tool runs in, and absolute
+// ones as file URIs
+func artifactURI(path string) string {
+	if filepath.IsAbs(path) {
+		return "file://" + filepath.ToSlash(path)
+	}
+	return filepath.ToSlash(path)
+}
@@ -0,0 +1,75 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"internal-link/pkg/scorer"
+)
+
+func TestWriteSARIF(t *testing.T) {
+	files := map[string]string{
+		"docs/setup.md": "# Setup\n\nWe tuned prometheus alerting.\n",
+		"docs/guide.md": "Über grafana dashboards\n",
+	}
+	reads := 0
+	readFile := func(path string) ([]byte, error) {
+		reads++
+		content, ok := files[path]
+		if !ok {
+			return nil, os.ErrNotExist
+		}
+		return []byte(content), nil
+	}
+	suggestions := []scorer.LinkSuggestion{
+		{SourcePath: "docs/setup.md", TargetPath: "docs/alerts.md", WordToLink: "prometheus alerting", Position: 18, Score: 1.25},
+		{SourcePath: "docs/setup.md", TargetPath: "docs/tuning.md", WordToLink: "tuned", Position: 12, Score: 0.5},
+		{SourcePath: "docs/guide.md", TargetPath: "docs/dashboards.md", Anchor: "panels", WordToLink: "grafana dashboards", Position: 6, Score: 0.75},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteSARIF(&buf, suggestions, SARIFOptions{ReadFile: readFile}))
+	assert.Equal(t, 2, reads, "each file should be read once")
+
+	var log sarifLog
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &log))
+	assert.Equal(t, "2.1.0", log.Version)
+	require.Len(t, log.Runs, 1)
+	run := log.Runs[0]
+	assert.Equal(t, "internal-link", run.Tool.Driver.Name)
+	require.Len(t, run.Results, 3)
+
+	first := run.Results[0]
+	assert.Equal(t, SARIFRuleID, first.RuleID)
+	assert.Equal(t, `Link "prometheus alerting" to docs/alerts.md (score 1.2500)`, first.Message.Text)
+	require.Len(t, first.Locations, 1)
+	location := first.Locations[0].PhysicalLocation
+	assert.Equal(t, "docs/setup.md", location.ArtifactLocation.URI)
+	assert.Equal(t, sarifRegion{StartLine: 3, StartColumn: 10, EndLine: 3, EndColumn: 29}, location.Region)
+
+	// Columns count code points, not bytes
+	third := run.Results[2]
+	assert.Equal(t, "docs/dashboards.md#panels", third.Properties.Target)
+	assert.Equal(t, sarifRegion{StartLine: 1, StartColumn: 6, EndLine: 1, EndColumn: 24}, third.Locations[0].PhysicalLocation.Region)
+}
+
+func TestWriteSARIFEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, WriteSARIF(&buf, nil, SARIFOptions{}))
+	assert.Contains(t, buf.String(), `"results": []`)
+}
+
+func TestWriteSARIFUnreadableSource(t *testing.T) {
+	suggestions := []scorer.LinkSuggestion{{SourcePath: "missing.md", TargetPath: "b.md", WordToLink: "phrase"}}
+	readFile := func(string) ([]byte, error) { return nil, os.ErrNotExist }
+
+	err := WriteSARIF(&bytes.Buffer{}, suggestions, SARIFOptions{ReadFile: readFile})
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+}
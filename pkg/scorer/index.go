@@ -0,0 +1,181 @@
+package scorer
+
+import (
+	"encoding/gob"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// IndexVersion is bumped whenever the layout or meaning of a saved Index
+// changes, so indexes saved by older versions are not loaded
+const IndexVersion = 2
+
+// Index is the corpus-wide state BM25 scores against: the documents it was
+// built from and the number of documents each term occurs in. It can be
+// saved and loaded again, so a run over a mostly unchanged corpus neither
+// derives the length and title terms of every document nor counts every
+// term's documents again.
+type Index struct {
+	Version   int
+	Documents map[string]IndexedDocument // By path
+	DocFreq   map[string]int             // Documents each term occurs in
+	Total     int                        // Sum of the document lengths
+}
+
+// IndexedDocument is a document as its index counted it
+type IndexedDocument struct {
+	Hash       string // Document.Hash when it was counted
+	Length     int
+	TitleTerms []string // Sorted
+}
+
+// Indexer is implemented by scorers whose corpus state can be saved and
+// restored between runs
+type Indexer interface {
+	// LoadIndex seeds the scorer with an index saved by an earlier run.
+	// Documents processed afterwards with the hash they were indexed with
+	// are taken as counted; the others are applied as changes.
+	LoadIndex(idx *Index)
+
+	// Index returns the state of the current corpus. While the corpus still
+	// matches the loaded index, that index itself is returned, so callers
+	// can tell whether there is anything new to save.
+	Index() *Index
+}
+
+// Save writes the index in gob encoding
+func (idx *Index) Save(w io.Writer) error {
+	if err := gob.NewEncoder(w).Encode(idx); err != nil {
+		return fmt.Errorf("failed to encode index: %w", err)
+	}
+	return nil
+}
+
+// LoadIndex reads an index written by Save. An index saved by another
+// version is an error.
+func LoadIndex(r io.Reader) (*Index, error) {
+	var idx Index
+	if err := gob.NewDecoder(r).Decode(&idx); err != nil {
+		return nil, fmt.Errorf("failed to decode index: %w", err)
+	}
+	if idx.Version != IndexVersion {
+		return nil, fmt.Errorf("index version %d is not supported (expected %d)", idx.Version, IndexVersion)
+	}
+	return &idx, nil
+}
+
+// LoadIndex implements the Indexer interface
+func (s *BM25Scorer) LoadIndex(idx *Index) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.loaded = idx
+	s.stale = true
+}
+
+// Index implements the Indexer interface
+func (s *BM25Scorer) Index() *Index {
+	s.refreshIDF()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.matchesLoaded() {
+		return s.loaded
+	}
+	idx := &Index{
+		Version:   IndexVersion,
+		Documents: make(map[string]IndexedDocument, len(s.docs)),
+		DocFreq:   s.docFreq,
+		Total:     s.total,
+	}
+	for _, doc := range s.docs {
+		var titleTerms []string
+		for term := range doc.TitleTerms {
+			titleTerms = append(titleTerms, term)
+		}
+		sort.Strings(titleTerms)
+		idx.Documents[doc.Path] = IndexedDocument{Hash: doc.Hash, Length: doc.Length, TitleTerms: titleTerms}
+	}
+	return idx
+}
+
+// restore sets the length and title terms of doc to those the loaded index
+// counted, reporting whether it did: only when doc still has the content it
+// was indexed with. s.mu must be held.
+func (s *BM25Scorer) restore(doc *Document) bool {
+	if s.loaded == nil || doc.Hash == "" {
+		return false
+	}
+	entry, ok := s.loaded.Documents[doc.Path]
+	if !ok || entry.Hash != doc.Hash {
+		return false
+	}
+	doc.Length = entry.Length
+	if doc.TitleTerms == nil {
+		doc.TitleTerms = make(map[string]bool, len(entry.TitleTerms))
+		for _, term := range entry.TitleTerms {
+			doc.TitleTerms[term] = true
+		}
+	}
+	return true
+}
+
+// matchesLoaded reports whether the corpus consists of exactly the
+// documents of the loaded index, with the same content. s.mu must be held.
+func (s *BM25Scorer) matchesLoaded() bool {
+	if s.loaded == nil || len(s.loaded.Documents) != len(s.docs) {
+		return false
+	}
+	for _, doc := range s.docs {
+		if !s.indexed(doc) {
+			return false
+		}
+	}
+	return true
+}
+
+// indexed reports whether the loaded index counted doc as it is now.
+// Documents without a hash can't be matched. s.mu must be held.
+func (s *BM25Scorer) indexed(doc *Document) bool {
+	entry, ok := s.loaded.Documents[doc.Path]
+	return ok && doc.Hash != "" && entry.Hash == doc.Hash && entry.Length == doc.Length
+}
+
+// countDocFreq returns the number of documents each term occurs in. The
+// loaded index is reused when the corpus only gained documents since;
+// otherwise the terms are counted from the postings. s.mu must be held.
+func (s *BM25Scorer) countDocFreq() map[string]int {
+	if s.loaded != nil && s.loaded.DocFreq != nil {
+		var added []*Document
+		kept := 0
+		for _, doc := range s.docs {
+			if s.indexed(doc) {
+				kept++
+			} else {
+				added = append(added, doc)
+			}
+		}
+		// Documents removed or changed since took their counts with them
+		if kept == len(s.loaded.Documents) {
+			if len(added) == 0 {
+				return s.loaded.DocFreq
+			}
+			docFreq := make(map[string]int, len(s.loaded.DocFreq))
+			for term, count := range s.loaded.DocFreq {
+				docFreq[term] = count
+			}
+			for _, doc := range added {
+				for term := range doc.WordFreq {
+					docFreq[term]++
+				}
+			}
+			return docFreq
+		}
+	}
+
+	docFreq := make(map[string]int, len(s.postings))
+	for term, docs := range s.postings {
+		docFreq[term] = len(docs)
+	}
+	return docFreq
+}
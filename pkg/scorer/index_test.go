@@ -0,0 +1,132 @@
+package scorer
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// indexCorpus returns fresh copies of a small corpus, so each scorer gets
+// documents of its own
+func indexCorpus() []*Document {
+	return []*Document{
+		{Path: "a.md", Hash: "1", WordFreq: map[string]int{"prometheus alerting": 2, "grafana": 1}},
+		{Path: "b.md", Hash: "2", WordFreq: map[string]int{"grafana": 3, "loki": 1}},
+		{Path: "c.md", Hash: "3", WordFreq: map[string]int{"loki": 1, "prometheus alerting": 1}},
+	}
+}
+
+func newIndexedScorer(t *testing.T, idx *Index, docs []*Document) *BM25Scorer {
+	t.Helper()
+	s := NewBM25Scorer(3, DefaultScorerConfig())
+	if idx != nil {
+		s.LoadIndex(idx)
+	}
+	for _, doc := range docs {
+		require.NoError(t, s.ProcessDocument(doc))
+	}
+	return s
+}
+
+func scores(s *BM25Scorer, docs []*Document) []float64 {
+	query := NewQuery(map[string]int{"prometheus alerting": 1, "grafana": 1, "loki": 1})
+	var scores []float64
+	for _, doc := range docs {
+		scores = append(scores, s.ScoreQuery(query, doc))
+	}
+	return scores
+}
+
+func TestIndexSaveLoad(t *testing.T) {
+	idx := newIndexedScorer(t, nil, indexCorpus()).Index()
+	assert.Equal(t, map[string]int{"prometheus alerting": 2, "grafana": 2, "loki": 2}, idx.DocFreq)
+	assert.Equal(t, 9, idx.Total)
+	assert.Equal(t, IndexedDocument{Hash: "2", Length: 4}, idx.Documents["b.md"])
+
+	var buf bytes.Buffer
+	require.NoError(t, idx.Save(&buf))
+	loaded, err := LoadIndex(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, idx, loaded)
+
+	idx.Version = IndexVersion + 1
+	buf.Reset()
+	require.NoError(t, idx.Save(&buf))
+	_, err = LoadIndex(&buf)
+	assert.Error(t, err)
+}
+
+func TestLoadedIndexIsReused(t *testing.T) {
+	idx := newIndexedScorer(t, nil, indexCorpus()).Index()
+	// A count only the loaded index has shows whether it was used
+	idx.DocFreq["loki"] = 3
+
+	docs := indexCorpus()
+	s := newIndexedScorer(t, idx, docs)
+	assert.Same(t, idx, s.Index(), "an unchanged corpus keeps the loaded index")
+	assert.Equal(t, 3, s.docFreq["loki"])
+
+	// Added documents are counted on top of the loaded index
+	added := append(indexCorpus(), &Document{Path: "d.md", Hash: "4", WordFreq: map[string]int{"loki": 1}})
+	s = newIndexedScorer(t, idx, added)
+	assert.NotSame(t, idx, s.Index())
+	assert.Equal(t, 4, s.docFreq["loki"])
+}
+
+func TestLoadedIndexDeltas(t *testing.T) {
+	idx := newIndexedScorer(t, nil, indexCorpus()).Index()
+
+	tests := []struct {
+		name string
+		edit func(docs []*Document) []*Document
+	}{
+		{name: "added", edit: func(docs []*Document) []*Document {
+			return append(docs, &Document{Path: "d.md", Hash: "4", WordFreq: map[string]int{"grafana": 1}})
+		}},
+		{name: "changed", edit: func(docs []*Document) []*Document {
+			docs[1] = &Document{Path: "b.md", Hash: "5", WordFreq: map[string]int{"prometheus alerting": 1}}
+			return docs
+		}},
+		{name: "removed", edit: func(docs []*Document) []*Document {
+			return docs[:2]
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fresh := tt.edit(indexCorpus())
+			expected := scores(newIndexedScorer(t, nil, fresh), fresh)
+
+			docs := tt.edit(indexCorpus())
+			s := newIndexedScorer(t, idx, docs)
+			assert.Equal(t, expected, scores(s, docs))
+			assert.NotSame(t, idx, s.Index())
+		})
+	}
+}
+
+func TestLoadedIndexRestoresDocuments(t *testing.T) {
+	titled := func() []*Document {
+		docs := indexCorpus()
+		for _, doc := range docs {
+			doc.Title = "Grafana"
+		}
+		return docs
+	}
+	idx := newIndexedScorer(t, nil, titled()).Index()
+	assert.Equal(t, []string{"grafana"}, idx.Documents["a.md"].TitleTerms)
+	// Values only the loaded index has show whether it was used
+	a := idx.Documents["a.md"]
+	a.TitleTerms, a.Length = []string{"loki"}, 7
+	idx.Documents["a.md"] = a
+
+	docs := titled()
+	docs[1].Hash = "changed"
+	newIndexedScorer(t, idx, docs)
+	assert.Equal(t, map[string]bool{"loki": true}, docs[0].TitleTerms, "unchanged documents take the indexed title terms")
+	assert.Equal(t, 7, docs[0].Length)
+	assert.Equal(t, map[string]bool{"grafana": true}, docs[1].TitleTerms, "changed documents are derived again")
+	assert.Equal(t, 4, docs[1].Length)
+}
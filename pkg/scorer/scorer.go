@@ -1,25 +1,126 @@
 package scorer
 
 import (
+	"fmt"
 	"math"
+	"sort"
 	"strings"
+	"sync"
+
+	"internal-link/pkg/markdown"
 )
 
 // Document represents a markdown document with its content and metadata
 type Document struct {
-	Path     string
-	Content  string
+	Path       string
+	Title      string
+	Slug       string
+	URL        string
+	Aliases    []string // Other URLs the document is published under, from the frontmatter
+	Content    string
+	WordFreq   map[string]int
+	Length     int       // Total number of term occurrences, set by ProcessDocument
+	Sections   []Section // Term frequencies under each heading, in document order
+	Ignored    bool      // Opted out of internal linking, neither as source nor as target
+	Keywords   []string  // Keywords and tags from the frontmatter
+	Tags       []string  // Taxonomies from the frontmatter
+	Categories []string
+	Canonical  bool     // Marked canonical in the frontmatter, preferred among near duplicates
+	Links      []string // Corpus documents this one already links to, sorted
+	Hash       string   // Identifies the content the document was built from, matched against a loaded Index
+
+	// TitleTerms are the terms of the title and keywords, whose matches get
+	// the title boost. ProcessDocument derives them from Title and Keywords
+	// unless they are already set.
+	TitleTerms map[string]bool
+}
+
+// Section is the part of a document under one heading
+type Section struct {
+	Anchor   string // Empty for the text before the first heading
 	WordFreq map[string]int
 }
 
 // LinkSuggestion represents a suggested internal link
 type LinkSuggestion struct {
-	SourcePath string
-	TargetPath string
-	Score      float64
-	Context    string
-	WordToLink string
-	Position   int
+	SourcePath string   `json:"source_path"`
+	TargetPath string   `json:"target_path"`
+	Score      float64  `json:"score"`
+	RawScore   float64  `json:"raw_score,omitempty"` // Score before normalization, set when scores are normalized
+	Context    string   `json:"context"`             // Plain text around the phrase
+	WordToLink string   `json:"word_to_link"`
+	Position   int      `json:"position"`
+	Line       int      `json:"line,omitempty"`        // 1-based line of Position in the source
+	Column     int      `json:"column,omitempty"`      // 1-based column of Position, in characters
+	Location   string   `json:"location,omitempty"`    // Source path, line and column as path:line:column
+	Anchor     string   `json:"anchor,omitempty"`      // Section of the target to link to
+	Backlink   bool     `json:"backlink,omitempty"`    // The target already links to the source, so this completes the pair
+	SharedTags []string `json:"shared_tags,omitempty"` // Tags of the source the target has too, compared case-insensitively
+	DebugInfo  string   `json:"debug_info,omitempty"`  // AST ancestry and raw bytes of the span, set when debugging positions
+
+	// Context split around the phrase, so it can be highlighted; Phrase is
+	// the phrase as it reads in the context, without inline markdown
+	ContextBefore string `json:"context_before,omitempty"`
+	Phrase        string `json:"phrase,omitempty"`
+	ContextAfter  string `json:"context_after,omitempty"`
+
+	// SourceSection is the heading of the source the phrase falls under, in
+	// ATX style such as "## Troubleshooting"; empty before the first heading
+	SourceSection string `json:"source_section,omitempty"`
+
+	// LineBefore is the source line holding the phrase and LineAfter the
+	// same line with the link inserted, set when previewing the edits
+	LineBefore string `json:"line_before,omitempty"`
+	LineAfter  string `json:"line_after,omitempty"`
+
+	// AnchorScore rates the phrase as an anchor for the target, from its
+	// frequency there, its IDF and its length; the best scoring is chosen
+	AnchorScore float64 `json:"anchor_score,omitempty"`
+
+	// Alternative numbers the further phrases offered for the same pair when
+	// several candidates per target are asked for; 0 is the preferred one
+	Alternative int `json:"alternative,omitempty"`
+
+	// Suppressed tells why a suggestion was left out, for suggestions kept
+	// to audit the filters only; they are never applied
+	Suppressed string `json:"suppressed,omitempty"`
+
+	// Explanation breaks the score down by matched term, set when explaining scores
+	Explanation *Explanation `json:"explanation,omitempty"`
+}
+
+// Explanation breaks the score of a document pair down into the
+// contributions of the terms the source and target have in common
+type Explanation struct {
+	BM25         float64            `json:"bm25"`           // Sum of the term contributions
+	Boost        float64            `json:"boost"`          // Multiplier applied on top by the analyzer, 1 for none
+	DocLength    int                `json:"doc_length"`     // Length of the target in term occurrences
+	AvgDocLength float64            `json:"avg_doc_length"` // Average length of the corpus documents
+	Terms        []TermContribution `json:"terms"`          // Largest contribution first
+}
+
+// TermContribution is what one matched term adds to a BM25 score
+type TermContribution struct {
+	Term        string  `json:"term"`
+	QueryCount  int     `json:"query_count"` // Occurrences in the source
+	TargetFreq  int     `json:"target_freq"` // Occurrences in the target
+	IDF         float64 `json:"idf"`
+	LengthBoost float64 `json:"length_boost"` // Weight of longer n-grams
+	TitleBoost  float64 `json:"title_boost"`  // Weight of terms in the target's title or keywords, 1 for none
+	Score       float64 `json:"score"`
+}
+
+// RetargetSuggestion proposes pointing an existing link at a better matching document
+type RetargetSuggestion struct {
+	SourcePath     string  `json:"source_path"`
+	AnchorText     string  `json:"anchor_text"`
+	Destination    string  `json:"destination"` // Destination as currently written in the link
+	CurrentTarget  string  `json:"current_target"`
+	ProposedTarget string  `json:"proposed_target"`
+	CurrentScore   float64 `json:"current_score"`
+	ProposedScore  float64 `json:"proposed_score"`
+	Start          int     `json:"start"` // Byte span of the existing link markup
+	End            int     `json:"end"`
 }
 
 // Scorer defines the interface for document scoring algorithms
@@ -27,88 +128,381 @@ type Scorer interface {
 	// Score calculates the relevance score between a query and a document
 	Score(query string, doc *Document) float64
 
+	// ScoreQuery scores a query that is already split into terms. Callers
+	// scoring the same query against many documents should build it once.
+	ScoreQuery(query Query, doc *Document) float64
+
 	// ProcessDocument prepares a document for scoring
 	ProcessDocument(doc *Document) error
+
+	// UpdateDocument replaces the document with the same path, or adds it
+	// if there is none, e.g. after its content changed
+	UpdateDocument(doc *Document) error
+
+	// RemoveDocument drops the document with the given path from the
+	// corpus, reporting whether there was one
+	RemoveDocument(path string) bool
+
+	// Candidates returns the documents sharing at least one term with the
+	// query, ordered by path. Every other document scores 0.
+	Candidates(query Query) []*Document
+}
+
+// Explainer is implemented by scorers that can break a score down, for
+// example to help tune a minimum score
+type Explainer interface {
+	// Explain returns the contributions of the matched terms to the score
+	// ScoreQuery gives the query against doc
+	Explain(query Query, doc *Document) Explanation
+}
+
+// TermWeigher is implemented by scorers that weigh terms by how rare they
+// are across the corpus, for example to prefer distinctive anchor phrases
+type TermWeigher interface {
+	// IDF returns the inverse document frequency of term
+	IDF(term string) float64
+}
+
+// CandidateRanker is implemented by scorers that can bound the score of the
+// candidates of a query cheaply, so only the most promising ones need to be
+// scored in full
+type CandidateRanker interface {
+	// TopCandidates returns the n candidates with the highest upper bound on
+	// their ScoreQuery score times their weight, ordered by path. weight, if
+	// set, returns the factor the caller scales a candidate's score by, and
+	// candidates weighing 0 or less are left out. It also returns the highest
+	// weighted bound among the candidates left out: none of them scores more
+	// than that, so a caller can tell whether one could have mattered. With
+	// n <= 0 every candidate of positive weight is returned, and the bound
+	// is 0.
+	TopCandidates(query Query, n int, weight func(*Document) float64) ([]*Document, float64)
+}
+
+// ScorerConfig holds the BM25 tuning parameters
+type ScorerConfig struct {
+	K1         float64 // Term frequency saturation (>= 0)
+	B          float64 // Strength of document length normalization (0 to 1)
+	TitleBoost float64 // Multiplier for terms found in a document's title or keywords (0 or 1 for none)
+}
+
+// DefaultScorerConfig returns the commonly used BM25 parameters
+func DefaultScorerConfig() ScorerConfig {
+	return ScorerConfig{K1: 1.2, B: 0.75, TitleBoost: 2}
+}
+
+// Validate checks that the parameters are within their valid ranges
+func (c ScorerConfig) Validate() error {
+	if c.K1 < 0 {
+		return fmt.Errorf("invalid BM25 k1 %g (expected k1 >= 0)", c.K1)
+	}
+	if c.B < 0 || c.B > 1 {
+		return fmt.Errorf("invalid BM25 b %g (expected 0 <= b <= 1)", c.B)
+	}
+	if c.TitleBoost < 0 {
+		return fmt.Errorf("invalid title boost %g (expected a boost >= 0)", c.TitleBoost)
+	}
+	return nil
 }
 
-// BM25Scorer implements the BM25 algorithm for document scoring
+// QueryTerm is a query term and how often it occurs in the query
+type QueryTerm struct {
+	Term  string
+	Count int
+}
+
+// Query is a pre-tokenized query
+type Query []QueryTerm
+
+// NewQuery builds a query from term frequencies, such as a document's
+// WordFreq. Terms are sorted so scores are summed in a stable order.
+func NewQuery(terms map[string]int) Query {
+	query := make(Query, 0, len(terms))
+	for term, count := range terms {
+		query = append(query, QueryTerm{Term: term, Count: count})
+	}
+	sort.Slice(query, func(i, j int) bool {
+		return query[i].Term < query[j].Term
+	})
+	return query
+}
+
+// BM25Scorer implements the BM25 algorithm for document scoring. It is safe
+// for concurrent use.
 type BM25Scorer struct {
-	k1       float64
-	b        float64
-	docs     []*Document
-	avgdl    float64
-	idf      map[string]float64
-	maxNGram int
+	mu         sync.RWMutex
+	k1         float64
+	b          float64
+	titleBoost float64
+	docs       []*Document
+	index      map[string]int // Position of each document in docs, by path
+	total      int            // Sum of the lengths of all documents
+	avgdl      float64
+	idf        map[string]float64
+	postings   map[string][]*Document // Documents containing each term
+	docFreq    map[string]int         // Documents containing each term, as of the last idf computation
+	stale      bool                   // Documents were added or removed since idf was computed
+	loaded     *Index                 // Index of an earlier run, seeded with LoadIndex
+	maxNGram   int
+	parser     *markdown.Parser // Splits queries into terms, set with UseParser
 }
 
-// NewBM25Scorer creates a new BM25 scorer with default parameters
-func NewBM25Scorer(maxNGram int) *BM25Scorer {
+// NewBM25Scorer creates a new BM25 scorer with the given parameters
+func NewBM25Scorer(maxNGram int, config ScorerConfig) *BM25Scorer {
 	return &BM25Scorer{
-		k1:       1.2,
-		b:        0.75,
-		idf:      make(map[string]float64),
-		maxNGram: maxNGram,
+		k1:         config.K1,
+		b:          config.B,
+		titleBoost: config.TitleBoost,
+		index:      make(map[string]int),
+		idf:        make(map[string]float64),
+		postings:   make(map[string][]*Document),
+		maxNGram:   maxNGram,
 	}
 }
 
-// ProcessDocument implements the Scorer interface
+// ProcessDocument implements the Scorer interface. A document with the
+// path of one processed before replaces it, so it is never counted twice.
 func (s *BM25Scorer) ProcessDocument(doc *Document) error {
+	return s.UpdateDocument(doc)
+}
+
+// UpdateDocument implements the Scorer interface
+func (s *BM25Scorer) UpdateDocument(doc *Document) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.remove(doc.Path)
+	s.add(doc)
+	return nil
+}
+
+// RemoveDocument implements the Scorer interface
+func (s *BM25Scorer) RemoveDocument(path string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.remove(path)
+}
+
+// add registers a document whose path isn't in the corpus. s.mu must be held.
+func (s *BM25Scorer) add(doc *Document) {
+	if !s.restore(doc) {
+		doc.Length = documentLength(doc)
+		if doc.TitleTerms == nil {
+			doc.TitleTerms = s.titleTerms(doc)
+		}
+	}
+	s.index[doc.Path] = len(s.docs)
 	s.docs = append(s.docs, doc)
+	for term := range doc.WordFreq {
+		postings, ok := s.postings[term]
+		if !ok && s.loaded != nil {
+			// Room for the documents the loaded index counted
+			postings = make([]*Document, 0, s.loaded.DocFreq[term])
+		}
+		s.postings[term] = append(postings, doc)
+	}
+
+	s.total += doc.Length
+	s.updateStats()
+}
 
-	// Recalculate average document length
-	var totalLength int
-	for _, d := range s.docs {
-		totalLength += len(d.WordFreq)
+// remove drops the document with the given path, reporting whether there
+// was one. s.mu must be held.
+func (s *BM25Scorer) remove(path string) bool {
+	i, ok := s.index[path]
+	if !ok {
+		return false
 	}
-	s.avgdl = float64(totalLength) / float64(len(s.docs))
+	doc := s.docs[i]
 
-	// Update IDF scores
-	s.calculateIDF()
+	// Move the last document into the gap; the order of docs doesn't matter
+	last := len(s.docs) - 1
+	s.docs[i] = s.docs[last]
+	s.index[s.docs[i].Path] = i
+	s.docs[last] = nil
+	s.docs = s.docs[:last]
+	delete(s.index, path)
 
-	return nil
+	for term := range doc.WordFreq {
+		postings := s.postings[term]
+		for j, posted := range postings {
+			if posted == doc {
+				postings = append(postings[:j], postings[j+1:]...)
+				break
+			}
+		}
+		if len(postings) == 0 {
+			delete(s.postings, term)
+		} else {
+			s.postings[term] = postings
+		}
+	}
+
+	s.total -= doc.Length
+	s.updateStats()
+	return true
+}
+
+// updateStats recomputes the average document length after the corpus
+// changed and marks the IDF table for recomputation on the next Score,
+// since it depends on the whole corpus. s.mu must be held.
+func (s *BM25Scorer) updateStats() {
+	s.avgdl = 0
+	if len(s.docs) > 0 {
+		s.avgdl = float64(s.total) / float64(len(s.docs))
+	}
+	s.stale = true
+}
+
+// Candidates implements the Scorer interface
+func (s *BM25Scorer) Candidates(query Query) []*Document {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	seen := make(map[*Document]bool)
+	var candidates []*Document
+	for _, qt := range query {
+		for _, doc := range s.postings[qt.Term] {
+			if !seen[doc] {
+				seen[doc] = true
+				candidates = append(candidates, doc)
+			}
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].Path < candidates[j].Path
+	})
+	return candidates
+}
+
+// TopCandidates implements the CandidateRanker interface. A term's weight
+// never exceeds k1+1 times its boosts, whatever its frequency in the
+// document and the document's length, so the bound of a candidate is the sum
+// of that over the query terms it contains. It only takes the postings of
+// the query terms to compute.
+func (s *BM25Scorer) TopCandidates(query Query, n int, weight func(*Document) float64) ([]*Document, float64) {
+	s.refreshIDF()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	bounds := make(map[*Document]float64)
+	weights := make(map[*Document]float64)
+	var candidates []*Document
+	for _, qt := range query {
+		bound := float64(qt.Count) * s.idf[qt.Term] * (s.k1 + 1) * ngramBoost(qt.Term)
+		for _, doc := range s.postings[qt.Term] {
+			w, seen := weights[doc]
+			if !seen {
+				w = 1
+				if weight != nil {
+					w = weight(doc)
+				}
+				weights[doc] = w
+				if w > 0 {
+					candidates = append(candidates, doc)
+				}
+			}
+			if w <= 0 {
+				continue
+			}
+			if s.titleBoost > 1 && doc.TitleTerms[qt.Term] {
+				bounds[doc] += bound * s.titleBoost * w
+			} else {
+				bounds[doc] += bound * w
+			}
+		}
+	}
+
+	var dropped float64
+	if n > 0 && len(candidates) > n {
+		sort.Slice(candidates, func(i, j int) bool {
+			bi, bj := bounds[candidates[i]], bounds[candidates[j]]
+			if bi != bj {
+				return bi > bj
+			}
+			return candidates[i].Path < candidates[j].Path
+		})
+		dropped = bounds[candidates[n]]
+		candidates = candidates[:n]
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].Path < candidates[j].Path
+	})
+	return candidates, dropped
 }
 
 // Score implements the Scorer interface
 func (s *BM25Scorer) Score(query string, doc *Document) float64 {
-	var score float64
-	docLen := float64(len(doc.WordFreq))
+	terms := make(map[string]int)
+	s.addNGrams(terms, query)
+	return s.ScoreQuery(NewQuery(terms), doc)
+}
 
-	// Split query into terms and normalize
-	queryTerms := strings.Fields(strings.ToLower(query))
+// UseParser makes Score and the title terms derived for documents without
+// TitleTerms split text with the parser the documents' terms were counted
+// by, so queries have the same terms as the documents, without stop words
+// or numbers and with n-grams skipping over them alike. Without a parser,
+// text is split into the n-grams of its lowercased words, up to maxNGram.
+// It must be called before the first document is processed.
+func (s *BM25Scorer) UseParser(p *markdown.Parser) {
+	s.parser = p
+}
 
-	// Generate n-grams from query terms
-	var allQueryTerms []string
-	ngramLimit := min(len(queryTerms), s.maxNGram)
+// addNGrams counts the terms of text into terms
+func (s *BM25Scorer) addNGrams(terms map[string]int, text string) {
+	if s.parser != nil {
+		for term, count := range s.parser.Terms(text) {
+			terms[term] += count
+		}
+		return
+	}
+	words := strings.Fields(strings.ToLower(text))
+	ngramLimit := min(len(words), s.maxNGram)
 	for n := 1; n <= ngramLimit; n++ {
-		for i := 0; i <= len(queryTerms)-n; i++ {
-			ngram := strings.Join(queryTerms[i:i+n], " ")
-			allQueryTerms = append(allQueryTerms, ngram)
+		for i := 0; i <= len(words)-n; i++ {
+			terms[strings.Join(words[i:i+n], " ")]++
 		}
 	}
+}
+
+// titleTerms derives the terms of a document's title and keywords the same
+// way Score splits a query. Each keyword is split on its own, so n-grams
+// don't span two keywords.
+func (s *BM25Scorer) titleTerms(doc *Document) map[string]bool {
+	terms := make(map[string]int)
+	for _, text := range append([]string{doc.Title}, doc.Keywords...) {
+		s.addNGrams(terms, text)
+	}
+	titleTerms := make(map[string]bool, len(terms))
+	for term := range terms {
+		titleTerms[term] = true
+	}
+	return titleTerms
+}
+
+// ScoreQuery implements the Scorer interface
+func (s *BM25Scorer) ScoreQuery(query Query, doc *Document) float64 {
+	s.refreshIDF()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var score float64
+	docLen := s.docLength(doc)
 
 	// Check if any query terms exist in the document
 	hasMatch := false
-	for _, term := range allQueryTerms {
-		termFreq, exists := doc.WordFreq[term]
-		if !exists {
-			continue
-		}
-
-		idf, exists := s.idf[term]
-		if !exists {
+	for _, qt := range query {
+		termFreq, idf, ok := s.match(qt.Term, doc)
+		if !ok {
 			continue
 		}
 
 		hasMatch = true
-		numerator := float64(termFreq) * (s.k1 + 1)
-		denominator := float64(termFreq) + s.k1*(1-s.b+s.b*docLen/s.avgdl)
+		weight, _, _ := s.termWeight(qt.Term, termFreq, docLen, doc)
 
-		// Add length-based weight factor: (1 + 0.5 * (length - 1))
-		// This gives more weight to longer n-grams while still keeping single terms relevant
-		termLength := float64(len(strings.Fields(term)))
-		lengthBoost := 1.0 + 0.5*(termLength-1)
-
-		score += idf * numerator / denominator * lengthBoost
+		// Every occurrence of the term in the query contributes
+		score += float64(qt.Count) * idf * weight
 	}
 
 	// Return 0 if no query terms were found in the document
@@ -119,26 +513,134 @@ func (s *BM25Scorer) Score(query string, doc *Document) float64 {
 	return score
 }
 
-func (s *BM25Scorer) calculateIDF() {
-	N := float64(len(s.docs))
-
-	for _, doc := range s.docs {
-		for term := range doc.WordFreq {
-			if _, exists := s.idf[term]; exists {
-				continue
-			}
+// Explain implements the Explainer interface. Its terms sum to the score
+// of ScoreQuery; unlike ScoreQuery it allocates, so it is meant for the few
+// pairs worth explaining.
+func (s *BM25Scorer) Explain(query Query, doc *Document) Explanation {
+	s.refreshIDF()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 
-			// Count documents containing the term
-			var docCount float64
-			for _, d := range s.docs {
-				if _, has := d.WordFreq[term]; has {
-					docCount++
-				}
-			}
+	docLen := s.docLength(doc)
+	explanation := Explanation{Boost: 1, DocLength: int(docLen), AvgDocLength: s.avgdl}
+	for _, qt := range query {
+		termFreq, idf, ok := s.match(qt.Term, doc)
+		if !ok {
+			continue
+		}
 
-			// Calculate IDF
-			s.idf[term] = math.Log(1 + (N-docCount+0.5)/(docCount+0.5))
+		weight, lengthBoost, titleBoost := s.termWeight(qt.Term, termFreq, docLen, doc)
+		contribution := TermContribution{
+			Term:        qt.Term,
+			QueryCount:  qt.Count,
+			TargetFreq:  termFreq,
+			IDF:         idf,
+			LengthBoost: lengthBoost,
+			TitleBoost:  titleBoost,
+			Score:       float64(qt.Count) * idf * weight,
 		}
+		explanation.BM25 += contribution.Score
+		explanation.Terms = append(explanation.Terms, contribution)
+	}
+
+	sort.SliceStable(explanation.Terms, func(i, j int) bool {
+		return explanation.Terms[i].Score > explanation.Terms[j].Score
+	})
+	return explanation
+}
+
+// IDF implements the TermWeigher interface; a term no document contains has
+// an IDF of 0
+func (s *BM25Scorer) IDF(term string) float64 {
+	s.refreshIDF()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.idf[term]
+}
+
+// docLength returns the length BM25 normalizes doc by
+func (s *BM25Scorer) docLength(doc *Document) float64 {
+	if doc.Length == 0 {
+		return float64(documentLength(doc))
+	}
+	return float64(doc.Length)
+}
+
+// match returns the frequency of term in doc and its IDF, reporting whether
+// the term contributes to the score at all. The caller holds s.mu.
+func (s *BM25Scorer) match(term string, doc *Document) (int, float64, bool) {
+	termFreq, exists := doc.WordFreq[term]
+	if !exists {
+		return 0, 0, false
+	}
+	idf, exists := s.idf[term]
+	if !exists {
+		return 0, 0, false
+	}
+	return termFreq, idf, true
+}
+
+// termWeight is the BM25 weight of a term occurring termFreq times in doc,
+// before IDF, including its boosts
+func (s *BM25Scorer) termWeight(term string, termFreq int, docLen float64, doc *Document) (weight, lengthBoost, titleBoost float64) {
+	numerator := float64(termFreq) * (s.k1 + 1)
+	denominator := float64(termFreq) + s.k1*(1-s.b+s.b*docLen/s.avgdl)
+
+	lengthBoost = ngramBoost(term)
+
+	// Terms the document is titled or tagged with say more about its topic
+	titleBoost = 1.0
+	if s.titleBoost > 0 && doc.TitleTerms[term] {
+		titleBoost = s.titleBoost
+	}
+
+	return numerator / denominator * lengthBoost * titleBoost, lengthBoost, titleBoost
+}
+
+// ngramBoost is the weight factor of a term of n words: 1 + 0.5 * (n - 1).
+// This gives more weight to longer n-grams while still keeping single terms
+// relevant.
+func ngramBoost(term string) float64 {
+	termLength := float64(strings.Count(term, " ") + 1)
+	return 1.0 + 0.5*(termLength-1)
+}
+
+// documentLength returns the number of term occurrences in the document,
+// which BM25 uses as its length
+func documentLength(doc *Document) int {
+	var length int
+	for _, freq := range doc.WordFreq {
+		length += freq
+	}
+	return length
+}
+
+// refreshIDF recomputes the IDF table if documents were added since it was last built
+func (s *BM25Scorer) refreshIDF() {
+	s.mu.RLock()
+	stale := s.stale
+	s.mu.RUnlock()
+	if !stale {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stale {
+		s.calculateIDF()
+		s.stale = false
+	}
+}
+
+// calculateIDF rebuilds the IDF of every term against the current corpus size
+func (s *BM25Scorer) calculateIDF() {
+	N := float64(len(s.docs))
+
+	s.docFreq = s.countDocFreq()
+	s.idf = make(map[string]float64, len(s.docFreq))
+	for term, count := range s.docFreq {
+		df := float64(count)
+		s.idf[term] = math.Log(1 + (N-df+0.5)/(df+0.5))
 	}
 }
 
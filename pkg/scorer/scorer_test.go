@@ -1,13 +1,17 @@
 package scorer
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"internal-link/pkg/markdown"
 )
 
 func TestBM25Scorer(t *testing.T) {
-	scorer := NewBM25Scorer(3)
+	scorer := NewBM25Scorer(3, DefaultScorerConfig())
 
 	// Create test documents
 	doc1 := &Document{
@@ -65,7 +69,7 @@ func TestBM25Scorer(t *testing.T) {
 }
 
 func TestBM25ScorerEmpty(t *testing.T) {
-	scorer := NewBM25Scorer(3)
+	scorer := NewBM25Scorer(3, DefaultScorerConfig())
 
 	// Test with empty document
 	emptyDoc := &Document{
@@ -80,3 +84,491 @@ func TestBM25ScorerEmpty(t *testing.T) {
 	score := scorer.Score("test", emptyDoc)
 	assert.Equal(t, float64(0), score)
 }
+
+func TestBM25LengthNormalization(t *testing.T) {
+	// The long document repeats the phrase but covers many other topics too
+	long := &Document{Path: "long.md", WordFreq: map[string]int{"grafana dashboards": 3}}
+	for i := 0; i < 19; i++ {
+		long.WordFreq[fmt.Sprintf("topic %d", i)] = 1
+	}
+	short := &Document{Path: "short.md", WordFreq: map[string]int{"grafana dashboards": 1, "setup": 1}}
+
+	rank := func(b float64) (float64, float64) {
+		scorer := NewBM25Scorer(3, ScorerConfig{K1: 1.2, B: b})
+		assert.NoError(t, scorer.ProcessDocument(long))
+		assert.NoError(t, scorer.ProcessDocument(short))
+		return scorer.Score("grafana dashboards", long), scorer.Score("grafana dashboards", short)
+	}
+
+	longScore, shortScore := rank(0)
+	assert.Greater(t, longScore, shortScore, "without length normalization term frequency wins")
+
+	longScore, shortScore = rank(1)
+	assert.Greater(t, shortScore, longScore, "full length normalization favours the short document")
+}
+
+func TestScorerConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  ScorerConfig
+		wantErr bool
+	}{
+		{name: "defaults", config: DefaultScorerConfig()},
+		{name: "no saturation or normalization", config: ScorerConfig{K1: 0, B: 0}},
+		{name: "full normalization", config: ScorerConfig{K1: 2, B: 1}},
+		{name: "negative k1", config: ScorerConfig{K1: -0.1, B: 0.75}, wantErr: true},
+		{name: "negative b", config: ScorerConfig{K1: 1.2, B: -0.1}, wantErr: true},
+		{name: "b above one", config: ScorerConfig{K1: 1.2, B: 1.5}, wantErr: true},
+		{name: "negative title boost", config: ScorerConfig{K1: 1.2, B: 0.75, TitleBoost: -2}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestBM25DocumentLengthCountsOccurrences(t *testing.T) {
+	// Both documents have three distinct terms, but one repeats them heavily
+	repetitive := &Document{Path: "repetitive.md", WordFreq: map[string]int{"grafana dashboards": 1, "alpha": 10, "beta": 10}}
+	diverse := &Document{Path: "diverse.md", WordFreq: map[string]int{"grafana dashboards": 1, "alpha": 1, "beta": 1}}
+
+	scorer := NewBM25Scorer(3, DefaultScorerConfig())
+	assert.NoError(t, scorer.ProcessDocument(repetitive))
+	assert.NoError(t, scorer.ProcessDocument(diverse))
+
+	assert.Equal(t, 21, repetitive.Length)
+	assert.Equal(t, 3, diverse.Length)
+	assert.InDelta(t, 12.0, scorer.avgdl, 1e-9)
+
+	// The same single mention weighs more in the shorter document
+	assert.Greater(t, scorer.Score("grafana dashboards", diverse), scorer.Score("grafana dashboards", repetitive))
+}
+
+func TestBM25ScoresIndependentOfLoadOrder(t *testing.T) {
+	newDocs := func() []*Document {
+		docs := []*Document{
+			{Path: "k8s.md", WordFreq: map[string]int{"kubernetes": 2, "deployment": 1}},
+			{Path: "helm.md", WordFreq: map[string]int{"kubernetes": 1, "helm": 3}},
+		}
+		for i := 0; i < 20; i++ {
+			docs = append(docs, &Document{Path: fmt.Sprintf("other-%d.md", i), WordFreq: map[string]int{fmt.Sprintf("topic%d", i): 1}})
+		}
+		return docs
+	}
+
+	score := func(docs []*Document) (float64, float64) {
+		scorer := NewBM25Scorer(3, DefaultScorerConfig())
+		for _, doc := range docs {
+			assert.NoError(t, scorer.ProcessDocument(doc))
+		}
+		byPath := map[string]*Document{}
+		for _, doc := range docs {
+			byPath[doc.Path] = doc
+		}
+		return scorer.Score("kubernetes helm", byPath["k8s.md"]), scorer.Score("kubernetes helm", byPath["helm.md"])
+	}
+
+	forward := newDocs()
+	reversed := newDocs()
+	for i, j := 0, len(reversed)-1; i < j; i, j = i+1, j-1 {
+		reversed[i], reversed[j] = reversed[j], reversed[i]
+	}
+
+	k8sForward, helmForward := score(forward)
+	k8sReversed, helmReversed := score(reversed)
+	assert.InDelta(t, k8sForward, k8sReversed, 1e-12)
+	assert.InDelta(t, helmForward, helmReversed, 1e-12)
+}
+
+func TestScoreQueryMatchesScore(t *testing.T) {
+	scorer := NewBM25Scorer(3, DefaultScorerConfig())
+	doc := &Document{Path: "doc.md", WordFreq: map[string]int{"grafana": 2, "dashboards": 1, "grafana dashboards": 1}}
+	other := &Document{Path: "other.md", WordFreq: map[string]int{"loki": 1}}
+	assert.NoError(t, scorer.ProcessDocument(doc))
+	assert.NoError(t, scorer.ProcessDocument(other))
+
+	query := NewQuery(map[string]int{"grafana": 2, "dashboards": 1, "grafana dashboards": 1, "grafana grafana": 1})
+	assert.Equal(t, "dashboards", query[0].Term, "terms should be sorted")
+	assert.InDelta(t, scorer.Score("grafana dashboards grafana", doc), scorer.ScoreQuery(query, doc), 1e-12)
+	assert.Equal(t, float64(0), scorer.ScoreQuery(query, other))
+}
+
+func TestScoreUsesParser(t *testing.T) {
+	parser := markdown.NewParser(markdown.ParserConfig{MinNGram: 2, MaxNGram: 3})
+	freq, err := parser.ParseContent([]byte("A test of document scoring released in 2024.\n"))
+	require.NoError(t, err)
+	doc := &Document{Path: "doc.md", WordFreq: freq}
+	other := &Document{Path: "other.md", WordFreq: map[string]int{"loki queries": 1}}
+
+	scorer := NewBM25Scorer(3, DefaultScorerConfig())
+	scorer.UseParser(parser)
+	require.NoError(t, scorer.ProcessDocument(doc))
+	require.NoError(t, scorer.ProcessDocument(other))
+
+	// Both sides skip the stop word, so the phrase has the index's bigram
+	assert.Equal(t, map[string]int{"test document": 1}, parser.Terms("test of document"))
+	assert.Contains(t, freq, "test document")
+	assert.InDelta(t, scorer.Score("test document", doc), scorer.Score("test of document", doc), 1e-12)
+	assert.Greater(t, scorer.Score("test of document", doc), 0.0)
+
+	// Numbers are dropped alike
+	assert.InDelta(t, scorer.Score("scoring released", doc), scorer.Score("scoring released 2024", doc), 1e-12)
+
+	// Every term of a query is one the documents could be indexed under
+	for term := range parser.Terms("A test of document scoring released in 2024.") {
+		assert.Contains(t, freq, term)
+	}
+}
+
+func TestCandidates(t *testing.T) {
+	scorer := NewBM25Scorer(3, DefaultScorerConfig())
+	docs := []*Document{
+		{Path: "k8s.md", WordFreq: map[string]int{"kubernetes": 2, "deployment": 1}},
+		{Path: "helm.md", WordFreq: map[string]int{"kubernetes": 1, "helm": 3}},
+		{Path: "loki.md", WordFreq: map[string]int{"loki": 1}},
+	}
+	for _, doc := range docs {
+		assert.NoError(t, scorer.ProcessDocument(doc))
+	}
+
+	query := NewQuery(map[string]int{"kubernetes": 1, "helm": 1})
+	candidates := scorer.Candidates(query)
+	var paths []string
+	for _, doc := range candidates {
+		paths = append(paths, doc.Path)
+	}
+	assert.Equal(t, []string{"helm.md", "k8s.md"}, paths, "each match once, ordered by path")
+
+	// Documents left out of the candidates contribute nothing
+	assert.Equal(t, float64(0), scorer.ScoreQuery(query, docs[2]))
+	assert.Empty(t, scorer.Candidates(NewQuery(map[string]int{"nonexistent": 1})))
+}
+
+func TestTopCandidates(t *testing.T) {
+	// Long pages sharing many common terms with the query have a high bound
+	// but score little, while a short page with one rare term scores best
+	scorer := NewBM25Scorer(3, DefaultScorerConfig())
+	docs := []*Document{
+		{Path: "rare.md", WordFreq: map[string]int{"thanos": 1, "store": 1}},
+		{Path: "long-a.md", WordFreq: map[string]int{"kubernetes": 1, "helm": 1, "grafana": 1, "filler": 400}},
+		{Path: "long-b.md", WordFreq: map[string]int{"kubernetes": 1, "helm": 1, "grafana": 1, "filler": 300}},
+		{Path: "long-c.md", WordFreq: map[string]int{"kubernetes": 1, "helm": 1, "filler": 200}},
+		{Path: "other-a.md", WordFreq: map[string]int{"loki": 1}},
+		{Path: "other-b.md", WordFreq: map[string]int{"tempo": 1}},
+	}
+	for _, doc := range docs {
+		assert.NoError(t, scorer.ProcessDocument(doc))
+	}
+	query := NewQuery(map[string]int{"thanos": 1, "kubernetes": 1, "helm": 1, "grafana": 1})
+	paths := func(docs []*Document) []string {
+		var paths []string
+		for _, doc := range docs {
+			paths = append(paths, doc.Path)
+		}
+		return paths
+	}
+
+	all, dropped := scorer.TopCandidates(query, 0, nil)
+	assert.Equal(t, scorer.Candidates(query), all, "without a limit every candidate is kept")
+	assert.Equal(t, float64(0), dropped)
+
+	kept, _ := scorer.TopCandidates(query, 0, func(doc *Document) float64 {
+		if doc.Path == "long-b.md" {
+			return 0
+		}
+		return 1
+	})
+	assert.Equal(t, []string{"long-a.md", "long-c.md", "rare.md"}, paths(kept))
+
+	// Weights scale the bounds, so a boosted candidate can take the pool
+	boosted, dropped := scorer.TopCandidates(query, 1, func(doc *Document) float64 {
+		if doc.Path == "rare.md" {
+			return 10
+		}
+		return 1
+	})
+	assert.Equal(t, []string{"rare.md"}, paths(boosted))
+	assert.GreaterOrEqual(t, dropped, scorer.ScoreQuery(query, docs[1]))
+
+	top, dropped := scorer.TopCandidates(query, 1, nil)
+	assert.Equal(t, []string{"long-a.md"}, paths(top), "ranked by bound")
+	assert.Greater(t, scorer.ScoreQuery(query, docs[0]), scorer.ScoreQuery(query, docs[1]), "the bound is an approximation")
+
+	// Whatever the pool size, no candidate left out scores above the bound
+	for n := 1; n <= len(all); n++ {
+		top, dropped := scorer.TopCandidates(query, n, nil)
+		require.Len(t, top, n)
+		inPool := make(map[*Document]bool)
+		for _, doc := range top {
+			inPool[doc] = true
+		}
+		for _, doc := range all {
+			if !inPool[doc] {
+				assert.LessOrEqual(t, scorer.ScoreQuery(query, doc), dropped, "%s with a pool of %d", doc.Path, n)
+			}
+		}
+	}
+}
+
+// benchmarkSizes are the corpus sizes the scorer is benchmarked at
+var benchmarkSizes = []int{100, 1000, 10000}
+
+// benchmarkDocuments generates documents that each share terms with only a
+// few others, like a real corpus of mostly unrelated pages
+func benchmarkDocuments(size int) []*Document {
+	docs := make([]*Document, size)
+	for i := range docs {
+		docs[i] = &Document{
+			Path: fmt.Sprintf("doc-%05d.md", i),
+			WordFreq: map[string]int{
+				fmt.Sprintf("topic%d", i/4):   2,
+				fmt.Sprintf("subject%d", i/8): 1,
+				fmt.Sprintf("unique%d", i):    1,
+			},
+		}
+	}
+	return docs
+}
+
+// benchmarkCorpus builds a scorer over benchmarkDocuments
+func benchmarkCorpus(b *testing.B, size int) (*BM25Scorer, []*Document) {
+	scorer := NewBM25Scorer(3, DefaultScorerConfig())
+	docs := benchmarkDocuments(size)
+	for _, doc := range docs {
+		if err := scorer.ProcessDocument(doc); err != nil {
+			b.Fatal(err)
+		}
+	}
+	return scorer, docs
+}
+
+// BenchmarkProcessDocument builds a scorer over whole corpora, including
+// the IDF computed before the first score
+func BenchmarkProcessDocument(b *testing.B) {
+	for _, size := range benchmarkSizes {
+		b.Run(fmt.Sprintf("docs-%d", size), func(b *testing.B) {
+			docs := benchmarkDocuments(size)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				scorer := NewBM25Scorer(3, DefaultScorerConfig())
+				for _, doc := range docs {
+					if err := scorer.ProcessDocument(doc); err != nil {
+						b.Fatal(err)
+					}
+				}
+				scorer.IDF("topic0")
+			}
+		})
+	}
+}
+
+// BenchmarkScore scores a query string against every document of a corpus
+func BenchmarkScore(b *testing.B) {
+	for _, size := range benchmarkSizes {
+		b.Run(fmt.Sprintf("docs-%d", size), func(b *testing.B) {
+			scorer, docs := benchmarkCorpus(b, size)
+			query := fmt.Sprintf("topic%d subject%d", size/8, size/16)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				for _, doc := range docs {
+					scorer.Score(query, doc)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkScoreAllDocuments(b *testing.B) {
+	scorer, docs := benchmarkCorpus(b, 5000)
+	query := NewQuery(docs[len(docs)/2].WordFreq)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, doc := range docs {
+			scorer.ScoreQuery(query, doc)
+		}
+	}
+}
+
+func BenchmarkScoreCandidates(b *testing.B) {
+	scorer, docs := benchmarkCorpus(b, 5000)
+	query := NewQuery(docs[len(docs)/2].WordFreq)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, doc := range scorer.Candidates(query) {
+			scorer.ScoreQuery(query, doc)
+		}
+	}
+}
+
+func TestTitleBoost(t *testing.T) {
+	newDoc := func(path, title string, keywords ...string) *Document {
+		return &Document{
+			Path:     path,
+			Title:    title,
+			Keywords: keywords,
+			WordFreq: map[string]int{"kubernetes": 1, "deployment": 1, "kubernetes deployment": 1, "rollout": 1},
+		}
+	}
+
+	tests := []struct {
+		name   string
+		boost  float64
+		target *Document
+		ratio  float64
+	}{
+		{name: "title", boost: 2, target: newDoc("titled.md", "Kubernetes Deployment"), ratio: 2},
+		{name: "keywords", boost: 2, target: newDoc("tagged.md", "Notes", "kubernetes deployment"), ratio: 2},
+		{name: "no boost", boost: 1, target: newDoc("titled.md", "Kubernetes Deployment"), ratio: 1},
+		{name: "zero boost", boost: 0, target: newDoc("titled.md", "Kubernetes Deployment"), ratio: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scorer := NewBM25Scorer(2, ScorerConfig{K1: 1.2, B: 0.75, TitleBoost: tt.boost})
+			plain := newDoc("plain.md", "Operations")
+			assert.NoError(t, scorer.ProcessDocument(plain))
+			assert.NoError(t, scorer.ProcessDocument(tt.target))
+			assert.NoError(t, scorer.ProcessDocument(&Document{Path: "other.md", WordFreq: map[string]int{"other": 1}}))
+
+			plainScore := scorer.Score("kubernetes deployment", plain)
+			assert.Greater(t, plainScore, 0.0)
+			assert.InDelta(t, tt.ratio, scorer.Score("kubernetes deployment", tt.target)/plainScore, 1e-9)
+
+			// Terms outside the title aren't boosted
+			assert.InDelta(t, scorer.Score("rollout", plain), scorer.Score("rollout", tt.target), 1e-9)
+		})
+	}
+}
+
+func TestRemoveDocument(t *testing.T) {
+	newDocs := func() []*Document {
+		return []*Document{
+			{Path: "alerts.md", WordFreq: map[string]int{"prometheus alerting": 2, "rules": 1}},
+			{Path: "dashboards.md", WordFreq: map[string]int{"grafana dashboards": 1, "rules": 2}},
+			{Path: "post.md", WordFreq: map[string]int{"prometheus alerting": 1, "today": 1}},
+		}
+	}
+
+	full := NewBM25Scorer(2, DefaultScorerConfig())
+	docs := newDocs()
+	for _, doc := range docs {
+		assert.NoError(t, full.ProcessDocument(doc))
+	}
+
+	assert.True(t, full.RemoveDocument("post.md"))
+	assert.False(t, full.RemoveDocument("post.md"))
+	assert.False(t, full.RemoveDocument("missing.md"))
+
+	// The scorer behaves as if the document had never been added
+	fresh := NewBM25Scorer(2, DefaultScorerConfig())
+	for _, doc := range newDocs()[:2] {
+		assert.NoError(t, fresh.ProcessDocument(doc))
+	}
+	query := NewQuery(map[string]int{"prometheus alerting": 1, "rules": 1})
+	assert.InDelta(t, fresh.ScoreQuery(query, fresh.docs[0]), full.ScoreQuery(query, docs[0]), 1e-9)
+	assert.Equal(t, []*Document{docs[0]}, full.Candidates(NewQuery(map[string]int{"prometheus alerting": 1})))
+	assert.Empty(t, full.Candidates(NewQuery(map[string]int{"today": 1})))
+}
+
+func TestUpdateDocumentMatchesFreshScorer(t *testing.T) {
+	doc := func(path string, freq map[string]int) *Document {
+		return &Document{Path: path, WordFreq: freq}
+	}
+
+	incremental := NewBM25Scorer(2, DefaultScorerConfig())
+	for _, d := range []*Document{
+		doc("alerts.md", map[string]int{"prometheus alerting": 2, "rules": 1}),
+		doc("dashboards.md", map[string]int{"grafana dashboards": 1, "rules": 2}),
+		doc("post.md", map[string]int{"prometheus alerting": 1, "today": 1}),
+	} {
+		assert.NoError(t, incremental.ProcessDocument(d))
+	}
+	assert.NoError(t, incremental.UpdateDocument(doc("dashboards.md", map[string]int{"grafana dashboards": 3, "panels": 1})))
+	assert.True(t, incremental.RemoveDocument("alerts.md"))
+	assert.NoError(t, incremental.UpdateDocument(doc("runbook.md", map[string]int{"prometheus alerting": 1, "rules": 1, "pager": 4})))
+	// Processing a path again replaces the document instead of counting it twice
+	assert.NoError(t, incremental.ProcessDocument(doc("post.md", map[string]int{"prometheus alerting": 1, "today": 1})))
+
+	final := []*Document{
+		doc("dashboards.md", map[string]int{"grafana dashboards": 3, "panels": 1}),
+		doc("post.md", map[string]int{"prometheus alerting": 1, "today": 1}),
+		doc("runbook.md", map[string]int{"prometheus alerting": 1, "rules": 1, "pager": 4}),
+	}
+	fresh := NewBM25Scorer(2, DefaultScorerConfig())
+	for _, d := range final {
+		assert.NoError(t, fresh.ProcessDocument(d))
+	}
+
+	paths := func(docs []*Document) []string {
+		var paths []string
+		for _, d := range docs {
+			paths = append(paths, d.Path)
+		}
+		return paths
+	}
+
+	for _, query := range []string{"prometheus alerting", "rules", "grafana dashboards", "panels today", "pager"} {
+		q := NewQuery(map[string]int{query: 1})
+		assert.Equal(t, paths(fresh.Candidates(q)), paths(incremental.Candidates(q)), query)
+		for _, d := range final {
+			assert.InDelta(t, fresh.Score(query, d), incremental.Score(query, d), 1e-9, "%s in %s", query, d.Path)
+		}
+	}
+	assert.Equal(t, fresh.avgdl, incremental.avgdl)
+	assert.Len(t, incremental.docs, 3)
+}
+
+func TestExplain(t *testing.T) {
+	scorer := NewBM25Scorer(2, DefaultScorerConfig())
+	target := &Document{
+		Path:     "target.md",
+		Title:    "Kubernetes Deployment",
+		WordFreq: map[string]int{"kubernetes": 2, "deployment": 1, "kubernetes deployment": 1, "rollout": 3},
+	}
+	assert.NoError(t, scorer.ProcessDocument(target))
+	assert.NoError(t, scorer.ProcessDocument(&Document{Path: "other.md", WordFreq: map[string]int{"rollout": 1, "other": 1}}))
+
+	query := NewQuery(map[string]int{"kubernetes deployment": 2, "rollout": 1, "unrelated": 4})
+	explanation := scorer.Explain(query, target)
+
+	assert.InDelta(t, scorer.ScoreQuery(query, target), explanation.BM25, 1e-9)
+	assert.Equal(t, 1.0, explanation.Boost)
+	assert.Equal(t, 7, explanation.DocLength)
+	assert.InDelta(t, 4.5, explanation.AvgDocLength, 1e-9)
+
+	// Only matched terms contribute, largest first
+	var sum float64
+	var terms []string
+	for i, term := range explanation.Terms {
+		sum += term.Score
+		terms = append(terms, term.Term)
+		if i > 0 {
+			assert.GreaterOrEqual(t, explanation.Terms[i-1].Score, term.Score)
+		}
+	}
+	assert.ElementsMatch(t, []string{"kubernetes deployment", "rollout"}, terms)
+	assert.InDelta(t, explanation.BM25, sum, 1e-9)
+
+	top := explanation.Terms[0]
+	assert.Equal(t, TermContribution{
+		Term:        "kubernetes deployment",
+		QueryCount:  2,
+		TargetFreq:  1,
+		IDF:         top.IDF,
+		LengthBoost: 1.5,
+		TitleBoost:  2,
+		Score:       top.Score,
+	}, top)
+	assert.Greater(t, top.IDF, 0.0)
+
+	assert.Empty(t, scorer.Explain(NewQuery(map[string]int{"unrelated": 1}), target).Terms)
+}